@@ -0,0 +1,160 @@
+package hooks
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// ─── Campaign pause / resume / cancel ────────────────────────────────────────
+//
+// The send loop (processCampaignRun) re-reads the campaign status between
+// recipients, so these handlers only flip the status; the loop stops itself.
+//
+//   pause  : en_cours              → en_pause  (the run keeps its progress)
+//   resume : en_pause              → en_cours  (continues the paused run)
+//   cancel : programmee | en_cours | en_pause → annulee
+
+// canManageCampaign mirrors the campaigns UpdateRule (admin or creator).
+func canManageCampaign(e *core.RequestEvent, campaign *core.Record) bool {
+	if e.HasSuperuserAuth() {
+		return true
+	}
+	return e.Auth.GetString("role") == "admin" || campaign.GetString("created_by") == e.Auth.Id
+}
+
+// runContactIDs returns the recipients snapshot of a run (the campaign's
+// current list for runs started before snapshots existed).
+func runContactIDs(runRec, campaign *core.Record) []string {
+	var contactIDs []string
+	if err := runRec.UnmarshalJSONField("contact_ids", &contactIDs); err != nil || len(contactIDs) == 0 {
+		return campaignContactIDs(campaign)
+	}
+	return uniqueIDs(contactIDs)
+}
+
+// findPausedRun returns the latest paused run of a campaign.
+func findPausedRun(app core.App, campaignId string) (*core.Record, error) {
+	return app.FindFirstRecordByFilter("campaign_runs",
+		"campaign = {:id} && status = 'en_pause'",
+		dbx.Params{"id": campaignId},
+	)
+}
+
+func buildPauseCampaign(app core.App) func(*core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		campaign, err := app.FindRecordById("campaigns", e.Request.PathValue("id"))
+		if err != nil {
+			return e.NotFoundError("Campaign not found", err)
+		}
+		if !canManageCampaign(e, campaign) {
+			return e.ForbiddenError("Not allowed to manage this campaign", nil)
+		}
+		if campaign.GetString("status") != "en_cours" {
+			return e.BadRequestError("Only a campaign being sent can be paused", nil)
+		}
+
+		campaign.Set("status", "en_pause")
+		if err := app.Save(campaign); err != nil {
+			return e.InternalServerError("Failed to pause campaign", err)
+		}
+
+		return e.JSON(http.StatusOK, map[string]interface{}{
+			"campaign_id": campaign.Id,
+			"status":      "en_pause",
+		})
+	}
+}
+
+func buildResumeCampaign(app core.App) func(*core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		campaign, err := app.FindRecordById("campaigns", e.Request.PathValue("id"))
+		if err != nil {
+			return e.NotFoundError("Campaign not found", err)
+		}
+		if !canManageCampaign(e, campaign) {
+			return e.ForbiddenError("Not allowed to manage this campaign", nil)
+		}
+		if campaign.GetString("status") != "en_pause" {
+			return e.BadRequestError("Only a paused campaign can be resumed", nil)
+		}
+
+		if _, running := runningCampaigns.LoadOrStore(campaign.Id, struct{}{}); running {
+			return e.Error(http.StatusConflict, "Campaign is currently being sent", nil)
+		}
+		defer runningCampaigns.Delete(campaign.Id)
+
+		runRec, err := findPausedRun(app, campaign.Id)
+		if err != nil {
+			return e.BadRequestError("No paused run found for this campaign", err)
+		}
+
+		campaign.Set("status", "en_cours")
+		if err := app.Save(campaign); err != nil {
+			return e.InternalServerError("Failed to resume campaign", err)
+		}
+		runRec.Set("status", "en_cours")
+		if err := app.Save(runRec); err != nil {
+			return e.InternalServerError("Failed to resume campaign run", err)
+		}
+
+		result := processCampaignRun(app, campaign, runRec, runContactIDs(runRec, campaign), runRec.GetString("sent_by"))
+
+		return e.JSON(http.StatusOK, map[string]interface{}{
+			"campaign_id": campaign.Id,
+			"run_id":      result.RunID,
+			"run_number":  result.RunNumber,
+			"status":      result.Status,
+			"sent":        result.Sent,
+			"failed":      result.Failed,
+			"skipped":     result.Skipped,
+			"cancelled":   result.Cancelled,
+		})
+	}
+}
+
+func buildCancelCampaign(app core.App) func(*core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		campaign, err := app.FindRecordById("campaigns", e.Request.PathValue("id"))
+		if err != nil {
+			return e.NotFoundError("Campaign not found", err)
+		}
+		if !canManageCampaign(e, campaign) {
+			return e.ForbiddenError("Not allowed to manage this campaign", nil)
+		}
+
+		previous := campaign.GetString("status")
+		switch previous {
+		case "programmee", "en_cours", "en_pause":
+		default:
+			return e.BadRequestError("Only a scheduled, running or paused campaign can be cancelled", nil)
+		}
+
+		campaign.Set("status", "annulee")
+		if err := app.Save(campaign); err != nil {
+			return e.InternalServerError("Failed to cancel campaign", err)
+		}
+
+		// A running send finalizes its own run; a paused run has no loop left to do it
+		cancelled := 0
+		if previous == "en_pause" {
+			if runRec, err := findPausedRun(app, campaign.Id); err == nil {
+				cancelled = runRec.GetInt("total") - runRec.GetInt("processed")
+				runRec.Set("status", "annulee")
+				runRec.Set("cancelled", cancelled)
+				runRec.Set("finished_at", time.Now().UTC().Format("2006-01-02 15:04:05.000Z"))
+				if err := app.Save(runRec); err != nil {
+					return e.InternalServerError("Failed to cancel campaign run", err)
+				}
+			}
+		}
+
+		return e.JSON(http.StatusOK, map[string]interface{}{
+			"campaign_id": campaign.Id,
+			"status":      "annulee",
+			"cancelled":   cancelled,
+		})
+	}
+}
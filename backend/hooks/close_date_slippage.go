@@ -0,0 +1,174 @@
+package hooks
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// ─── Expected close slippage ─────────────────────────────────────────────────
+//
+// Every change of leads.expected_close is written to lead_close_date_history
+// by the leads update hook. A change to a later date is a slip and increments
+// leads.close_date_slips.
+
+// closeDateSlipped reports whether an expected close moved to a later day.
+// Setting a first date or clearing it is not a slip.
+func closeDateSlipped(oldDay, newDay string) bool {
+	return oldDay != "" && newDay != "" && newDay > oldDay
+}
+
+// recordCloseDateChange appends an entry to lead_close_date_history (best-effort).
+func recordCloseDateChange(app core.App, lead *core.Record, oldDate, newDate, changedBy string) {
+	col, err := app.FindCollectionByNameOrId("lead_close_date_history")
+	if err != nil {
+		log.Printf("[leads] lead_close_date_history collection not found: %v", err)
+		return
+	}
+	rec := core.NewRecord(col)
+	rec.Set("lead", lead.Id)
+	rec.Set("old_date", oldDate)
+	rec.Set("new_date", newDate)
+	oldT, errOld := time.Parse("2006-01-02", dayOf(oldDate))
+	newT, errNew := time.Parse("2006-01-02", dayOf(newDate))
+	if errOld == nil && errNew == nil {
+		rec.Set("days_moved", int(newT.Sub(oldT).Hours()/24))
+	}
+	rec.Set("changed_by", changedBy)
+	rec.Set("changed_at", time.Now().UTC().Format("2006-01-02 15:04:05.000Z"))
+	rec.Set("value", lead.GetFloat("value"))
+	if err := app.Save(rec); err != nil {
+		log.Printf("[leads] failed to record close date history for lead %s: %v", lead.Id, err)
+	}
+}
+
+// leadsSlippedOutOf returns the leads whose expected close was moved from a
+// day in [startDay, endDay) to endDay or later.
+func leadsSlippedOutOf(app core.App, startDay, endDay string) map[string]bool {
+	ids := make([]string, 0)
+	app.DB().NewQuery(`
+		SELECT DISTINCT lead FROM lead_close_date_history
+		WHERE substr(old_date, 1, 10) >= {:start} AND substr(old_date, 1, 10) < {:end}
+		  AND substr(new_date, 1, 10) >= {:end}
+	`).Bind(dbx.Params{"start": startDay, "end": endDay}).Column(&ids) //nolint:errcheck
+	slipped := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		slipped[id] = true
+	}
+	return slipped
+}
+
+// buildCloseDateSlippage serves
+// GET /api/crm/stats/close-date-slippage?period=month|quarter&date=&owner=&pipeline=&currency=
+// It lists the deals expected to close in the month / quarter containing date
+// (default today) whose expected close was pushed past its end and that have
+// not closed within it since, with totals by owner.
+func buildCloseDateSlippage(app core.App) func(*core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		q := e.Request.URL.Query()
+		rc, err := statsCurrency(app, e)
+		if err != nil {
+			return e.BadRequestError(err.Error(), nil)
+		}
+		at := time.Now().UTC()
+		if d := q.Get("date"); d != "" {
+			if at, err = time.Parse("2006-01-02", d); err != nil {
+				return e.BadRequestError("date must be YYYY-MM-DD", nil)
+			}
+		}
+		period := quotaPeriodAt(quotaPeriodType(q.Get("period")), at)
+
+		type slippedRow struct {
+			ID               string  `db:"id" json:"id"`
+			Title            string  `db:"title" json:"title"`
+			Owner            string  `db:"owner" json:"owner"`
+			OwnerName        string  `db:"owner_name" json:"owner_name"`
+			StageName        string  `db:"stage_name" json:"stage_name"`
+			Value            float64 `db:"value" json:"value"`
+			ExpectedCloseWas string  `db:"expected_close_was" json:"expected_close_was"`
+			ExpectedCloseNow string  `db:"expected_close_now" json:"expected_close_now"`
+			DaysPushed       int     `db:"days_pushed" json:"days_pushed"`
+			Slips            int     `db:"slips" json:"slips"`
+			LastSlippedAt    string  `db:"last_slipped_at" json:"last_slipped_at"`
+		}
+		rows := make([]slippedRow, 0)
+		err = app.DB().NewQuery(`
+			WITH slips AS (
+				SELECT h.lead, MIN(substr(h.old_date, 1, 10)) AS expected_close_was, MAX(h.changed_at) AS last_slipped_at
+				FROM lead_close_date_history h
+				WHERE substr(h.old_date, 1, 10) >= {:start} AND substr(h.old_date, 1, 10) < {:end}
+				  AND substr(h.new_date, 1, 10) >= {:end}
+				GROUP BY h.lead
+			)
+			SELECT l.id, l.title, COALESCE(l.owner, '') AS owner, COALESCE(u.name, '') AS owner_name,
+			       ps.name AS stage_name, ` + leadValueSQL("l") + ` AS value,
+			       s.expected_close_was, substr(l.expected_close, 1, 10) AS expected_close_now,
+			       CAST(julianday(substr(l.expected_close, 1, 10)) - julianday(s.expected_close_was) AS INTEGER) AS days_pushed,
+			       COALESCE(l.close_date_slips, 0) AS slips, s.last_slipped_at
+			FROM slips s
+			JOIN leads l ON l.id = s.lead
+			JOIN pipeline_stages ps ON ps.id = l.stage
+			LEFT JOIN users u ON u.id = l.owner
+			WHERE substr(l.expected_close, 1, 10) >= {:end}
+			  AND NOT ((ps.is_won = TRUE OR ps.is_lost = TRUE) AND substr(l.closed_at, 1, 10) < {:end})
+			  AND ({:owner} = '' OR l.owner = {:owner})
+			  AND ({:pipeline} = '' OR l.pipeline = {:pipeline})
+			ORDER BY value DESC
+		`).Bind(dbx.Params{
+			"start":    period.Start.Format("2006-01-02"),
+			"end":      period.End.Format("2006-01-02"),
+			"owner":    q.Get("owner"),
+			"pipeline": q.Get("pipeline"),
+			"currency": rc,
+		}).All(&rows)
+		if err != nil {
+			return e.InternalServerError("Failed to load close date history", err)
+		}
+
+		type ownerRow struct {
+			Owner    string  `json:"owner"`
+			Name     string  `json:"name"`
+			Count    int     `json:"count"`
+			Value    float64 `json:"value"`
+			AvgSlips float64 `json:"avg_slips"`
+		}
+		owners := map[string]*ownerRow{}
+		var totalValue float64
+		for i := range rows {
+			r := &rows[i]
+			r.Value = roundCents(r.Value)
+			totalValue += r.Value
+			o := owners[r.Owner]
+			if o == nil {
+				o = &ownerRow{Owner: r.Owner, Name: r.OwnerName}
+				owners[r.Owner] = o
+			}
+			o.Count++
+			o.Value = roundCents(o.Value + r.Value)
+			o.AvgSlips += float64(r.Slips)
+		}
+		byOwner := make([]ownerRow, 0, len(owners))
+		for _, o := range owners {
+			o.AvgSlips = round1(o.AvgSlips / float64(o.Count))
+			byOwner = append(byOwner, *o)
+		}
+		sort.Slice(byOwner, func(i, j int) bool { return byOwner[i].Value > byOwner[j].Value })
+
+		return e.JSON(http.StatusOK, map[string]interface{}{
+			"period_type":  period.Type,
+			"period_start": period.Start.Format("2006-01-02"),
+			"period_end":   period.End.AddDate(0, 0, -1).Format("2006-01-02"),
+			"total": map[string]interface{}{
+				"count": len(rows),
+				"value": roundCents(totalValue),
+			},
+			"by_owner": byOwner,
+			"leads":    rows,
+			"currency": rc,
+		})
+	}
+}
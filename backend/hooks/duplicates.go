@@ -0,0 +1,505 @@
+package hooks
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"pocket-crm/services"
+)
+
+// ─── Duplicates ──────────────────────────────────────────────────────────────
+//
+//	POST /api/crm/duplicates/check   — likely duplicates of a record being entered
+//	GET  /api/crm/duplicates         — duplicates report for a collection
+//	POST /api/crm/duplicates/merge   — merge records into a survivor (admin)
+//
+// Contacts match on normalised email, phone or name (same company counts),
+// companies on name, website / business email domain or phone, open leads on
+// a similar title for the same contact or company (see services/duplicates.go).
+
+// duplicateCollections are the collections that can be checked and merged.
+var duplicateCollections = []string{"contacts", "companies", "leads"}
+
+// mergeJSONIDFields are JSON fields holding record ids outside relation
+// fields: the recipients of a campaign and the snapshot its runs mail.
+var mergeJSONIDFields = map[string][][2]string{
+	"contacts": {{"campaigns", "contact_ids"}, {"campaign_runs", "contact_ids"}},
+}
+
+// RegisterDuplicateRoutes registers the duplicate check, report and merge routes.
+func RegisterDuplicateRoutes(app core.App) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.POST("/api/crm/duplicates/check", buildCheckDuplicates(app)).Bind(apis.RequireAuth())
+		se.Router.GET("/api/crm/duplicates", buildDuplicatesReport(app)).Bind(apis.RequireAuth())
+		se.Router.POST("/api/crm/duplicates/merge", buildMergeDuplicates(app)).Bind(apis.RequireAuth())
+		return se.Next()
+	})
+	log.Println("[hooks] Duplicate routes registered (check, report, merge)")
+}
+
+// ─── Candidates ──────────────────────────────────────────────────────────────
+
+func contactCandidate(id, firstName, lastName, email, phone, company string) services.DuplicateCandidate {
+	label := strings.TrimSpace(firstName + " " + lastName)
+	if email != "" {
+		label += " <" + email + ">"
+	}
+	return services.DuplicateCandidate{
+		ID:      id,
+		Label:   label,
+		Name:    services.NormalizeName(firstName + " " + lastName),
+		Email:   services.NormalizeEmail(email),
+		Phone:   services.NormalizePhone(phone),
+		Company: company,
+	}
+}
+
+func companyCandidate(id, name, website, email, phone string) services.DuplicateCandidate {
+	domain := services.WebsiteDomain(website)
+	if domain == "" {
+		domain = services.BusinessEmailDomain(email)
+	}
+	return services.DuplicateCandidate{
+		ID:     id,
+		Label:  name,
+		Name:   services.NormalizeName(name),
+		Phone:  services.NormalizePhone(phone),
+		Domain: domain,
+	}
+}
+
+func leadCandidate(id, title, company, contact string) services.DuplicateCandidate {
+	return services.DuplicateCandidate{
+		ID:      id,
+		Label:   title,
+		Name:    services.NormalizeName(title),
+		Company: company,
+		Contact: contact,
+	}
+}
+
+// loadDuplicateCandidates reads the comparable fields of a whole collection
+// (open leads only: closed deals are history, not duplicates).
+func loadDuplicateCandidates(app core.App, collection string) ([]services.DuplicateCandidate, error) {
+	var rows []struct {
+		ID string `db:"id"`
+		A  string `db:"a"`
+		B  string `db:"b"`
+		C  string `db:"c"`
+		D  string `db:"d"`
+		E  string `db:"e"`
+	}
+	var query string
+	switch collection {
+	case "contacts":
+		query = `SELECT id, first_name AS a, last_name AS b, COALESCE(email, '') AS c,
+			COALESCE(phone, '') AS d, COALESCE(company, '') AS e FROM contacts`
+	case "companies":
+		query = `SELECT id, name AS a, COALESCE(website, '') AS b, COALESCE(email, '') AS c,
+			COALESCE(phone, '') AS d, '' AS e FROM companies`
+	case "leads":
+		query = `SELECT l.id, l.title AS a, COALESCE(l.company, '') AS b, COALESCE(l.contact, '') AS c,
+			'' AS d, '' AS e
+			FROM leads l LEFT JOIN pipeline_stages ps ON ps.id = l.stage
+			WHERE COALESCE(ps.is_won, FALSE) = FALSE AND COALESCE(ps.is_lost, FALSE) = FALSE`
+	}
+	if err := app.DB().NewQuery(query).All(&rows); err != nil {
+		return nil, err
+	}
+
+	candidates := make([]services.DuplicateCandidate, 0, len(rows))
+	for _, r := range rows {
+		switch collection {
+		case "contacts":
+			candidates = append(candidates, contactCandidate(r.ID, r.A, r.B, r.C, r.D, r.E))
+		case "companies":
+			candidates = append(candidates, companyCandidate(r.ID, r.A, r.B, r.C, r.D))
+		case "leads":
+			candidates = append(candidates, leadCandidate(r.ID, r.A, r.B, r.C))
+		}
+	}
+	return candidates, nil
+}
+
+// ─── Check (warning before create) ───────────────────────────────────────────
+
+// buildCheckDuplicates compares the fields being entered with existing
+// records. Body: {"collection": "contacts", "id": "" (when editing), "data": {…}}.
+func buildCheckDuplicates(app core.App) func(*core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		var body struct {
+			Collection string            `json:"collection"`
+			ID         string            `json:"id"`
+			Data       map[string]string `json:"data"`
+		}
+		if err := e.BindBody(&body); err != nil {
+			return e.BadRequestError("Invalid request body", err)
+		}
+		if !slices.Contains(duplicateCollections, body.Collection) {
+			return e.BadRequestError("collection must be contacts, companies or leads", nil)
+		}
+
+		d := body.Data
+		var candidate services.DuplicateCandidate
+		switch body.Collection {
+		case "contacts":
+			candidate = contactCandidate(body.ID, d["first_name"], d["last_name"], d["email"], d["phone"], d["company"])
+		case "companies":
+			candidate = companyCandidate(body.ID, d["name"], d["website"], d["email"], d["phone"])
+		case "leads":
+			candidate = leadCandidate(body.ID, d["title"], d["company"], d["contact"])
+		}
+
+		existing, err := loadDuplicateCandidates(app, body.Collection)
+		if err != nil {
+			return e.InternalServerError("Failed to load records", err)
+		}
+		type duplicate struct {
+			ID      string   `json:"id"`
+			Label   string   `json:"label"`
+			Score   int      `json:"score"`
+			Reasons []string `json:"reasons"`
+		}
+		duplicates := make([]duplicate, 0)
+		for _, m := range services.MatchDuplicates(body.Collection, candidate, existing, services.DuplicateMinScore) {
+			duplicates = append(duplicates, duplicate{ID: m.B.ID, Label: m.B.Label, Score: m.Score, Reasons: m.Reasons})
+		}
+
+		return e.JSON(http.StatusOK, map[string]any{
+			"collection": body.Collection,
+			"duplicates": duplicates,
+		})
+	}
+}
+
+// ─── Report ──────────────────────────────────────────────────────────────────
+
+// maxDuplicatePairs caps the report size.
+const maxDuplicatePairs = 500
+
+// buildDuplicatesReport lists likely duplicate pairs of a collection, best
+// first. Query: ?collection=contacts|companies|leads&min_score=60.
+func buildDuplicatesReport(app core.App) func(*core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		collection := e.Request.URL.Query().Get("collection")
+		if !slices.Contains(duplicateCollections, collection) {
+			return e.BadRequestError("collection must be contacts, companies or leads", nil)
+		}
+		minScore := services.DuplicateMinScore
+		if v := e.Request.URL.Query().Get("min_score"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 100 {
+				return e.BadRequestError("min_score must be between 1 and 100", nil)
+			}
+			minScore = n
+		}
+
+		candidates, err := loadDuplicateCandidates(app, collection)
+		if err != nil {
+			return e.InternalServerError("Failed to load records", err)
+		}
+		pairs := services.FindDuplicates(collection, candidates, minScore)
+		total := len(pairs)
+		if len(pairs) > maxDuplicatePairs {
+			pairs = pairs[:maxDuplicatePairs]
+		}
+
+		return e.JSON(http.StatusOK, map[string]any{
+			"collection": collection,
+			"min_score":  minScore,
+			"total":      total,
+			"pairs":      pairs,
+		})
+	}
+}
+
+// ─── Merge ───────────────────────────────────────────────────────────────────
+
+// buildMergeDuplicates merges records into a survivor, in one transaction:
+//
+//  1. empty fields of the survivor are filled from the duplicates (multi-value
+//     fields are combined, notes are appended)
+//  2. every relation to a duplicate (leads, tasks, invoices, activities,
+//     email_logs, …) is re-pointed to the survivor
+//  3. the duplicates are deleted and a "fusion" activity is logged
+//
+// Body: {"collection": "contacts", "survivor": "id", "duplicates": ["id", …]}.
+func buildMergeDuplicates(app core.App) func(*core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		if !e.HasSuperuserAuth() && e.Auth.GetString("role") != "admin" {
+			return e.ForbiddenError("Only admins can merge records", nil)
+		}
+		var body struct {
+			Collection string   `json:"collection"`
+			Survivor   string   `json:"survivor"`
+			Duplicates []string `json:"duplicates"`
+		}
+		if err := e.BindBody(&body); err != nil {
+			return e.BadRequestError("Invalid request body", err)
+		}
+		if !slices.Contains(duplicateCollections, body.Collection) {
+			return e.BadRequestError("collection must be contacts, companies or leads", nil)
+		}
+		duplicateIDs := slices.DeleteFunc(uniqueIDs(body.Duplicates), func(id string) bool { return id == body.Survivor })
+		if body.Survivor == "" || len(duplicateIDs) == 0 {
+			return e.BadRequestError("survivor and duplicates are required", nil)
+		}
+
+		actorID := ""
+		if e.Auth != nil && e.Auth.Collection().Name == "users" {
+			actorID = e.Auth.Id
+		}
+
+		var survivor *core.Record
+		err := app.RunInTransaction(func(txApp core.App) error {
+			var err error
+			survivor, err = txApp.FindRecordById(body.Collection, body.Survivor)
+			if err != nil {
+				return fmt.Errorf("survivor %s not found", body.Survivor)
+			}
+			duplicates := make([]*core.Record, 0, len(duplicateIDs))
+			for _, id := range duplicateIDs {
+				dup, err := txApp.FindRecordById(body.Collection, id)
+				if err != nil {
+					return fmt.Errorf("record %s not found", id)
+				}
+				duplicates = append(duplicates, dup)
+			}
+			return mergeRecords(txApp, survivor, duplicates, actorID)
+		})
+		if err != nil {
+			return e.BadRequestError("Failed to merge records: "+err.Error(), nil)
+		}
+
+		return e.JSON(http.StatusOK, map[string]any{
+			"collection": body.Collection,
+			"survivor":   survivor,
+			"merged":     duplicateIDs,
+		})
+	}
+}
+
+// mergeRecords folds duplicates into survivor (see buildMergeDuplicates).
+func mergeRecords(txApp core.App, survivor *core.Record, duplicates []*core.Record, actorID string) error {
+	collection := survivor.Collection()
+	merged := make([]map[string]any, 0, len(duplicates))
+
+	for _, dup := range duplicates {
+		mergeRecordFields(survivor, dup)
+		if err := mergeBuyingRoles(txApp, collection.Name, dup.Id, survivor.Id); err != nil {
+			return err
+		}
+		if err := repointRelations(txApp, collection, dup.Id, survivor.Id); err != nil {
+			return err
+		}
+		merged = append(merged, map[string]any{"id": dup.Id, "label": recordLabel(dup)})
+	}
+
+	if collection.Name == "leads" {
+		// The duplicates' lines now belong to the survivor
+		applyLeadItemsValue(txApp, survivor)
+	}
+	if err := txApp.Save(survivor); err != nil {
+		return fmt.Errorf("save survivor: %w", err)
+	}
+	for _, dup := range duplicates {
+		// Reload: the relations it had were just moved away
+		fresh, err := txApp.FindRecordById(collection.Name, dup.Id)
+		if err != nil {
+			return err
+		}
+		if err := txApp.Delete(fresh); err != nil {
+			return fmt.Errorf("delete %s: %w", dup.Id, err)
+		}
+	}
+
+	labels := make([]string, 0, len(merged))
+	for _, m := range merged {
+		labels = append(labels, fmt.Sprintf("« %s »", m["label"]))
+	}
+	return logMergeActivity(txApp, survivor, actorID,
+		fmt.Sprintf("%s fusionné(s) dans « %s » : %s", mergeKindLabel[collection.Name], recordLabel(survivor), strings.Join(labels, ", ")),
+		map[string]any{"merged": merged})
+}
+
+var mergeKindLabel = map[string]string{
+	"contacts":  "Contact(s)",
+	"companies": "Entreprise(s)",
+	"leads":     "Opportunité(s)",
+}
+
+// mergeRecordFields fills the survivor's empty fields from dup.
+func mergeRecordFields(survivor, dup *core.Record) {
+	for _, field := range survivor.Collection().Fields {
+		name := field.GetName()
+		if field.GetSystem() || name == "id" {
+			continue
+		}
+		switch f := field.(type) {
+		case *core.AutodateField, *core.FileField, *core.PasswordField, *core.JSONField:
+			continue
+		case *core.EditorField:
+			if dupNotes := dup.GetString(name); dupNotes != "" && dupNotes != survivor.GetString(name) {
+				survivor.Set(name, strings.TrimSpace(survivor.GetString(name)+"\n"+dupNotes))
+			}
+		case *core.SelectField:
+			if f.IsMultiple() {
+				survivor.Set(name, uniqueIDs(append(survivor.GetStringSlice(name), dup.GetStringSlice(name)...)))
+			} else if survivor.GetString(name) == "" {
+				survivor.Set(name, dup.GetString(name))
+			}
+		case *core.RelationField:
+			if f.IsMultiple() {
+				survivor.Set(name, uniqueIDs(append(survivor.GetStringSlice(name), dup.GetStringSlice(name)...)))
+			} else if survivor.GetString(name) == "" {
+				survivor.Set(name, dup.GetString(name))
+			}
+		case *core.NumberField, *core.BoolField:
+			// Computed or deliberate values (score, value, flags): the survivor's stay
+			continue
+		default:
+			if survivor.GetString(name) == "" {
+				survivor.Set(name, dup.Get(name))
+			}
+		}
+	}
+}
+
+// repointRelations moves every relation (single or multiple) and known JSON
+// id list from one record to another, with raw SQL so that no hook fires on
+// the related records.
+func repointRelations(txApp core.App, target *core.Collection, fromID, toID string) error {
+	collections, err := txApp.FindAllCollections(core.CollectionTypeBase, core.CollectionTypeAuth)
+	if err != nil {
+		return err
+	}
+	params := dbx.Params{"from": fromID, "to": toID}
+	for _, col := range collections {
+		for _, field := range col.Fields {
+			rel, ok := field.(*core.RelationField)
+			if !ok || rel.CollectionId != target.Id {
+				continue
+			}
+			table, column := col.Name, rel.Name
+			if rel.IsMultiple() {
+				err = repointJSONIDs(txApp, table, column, params)
+			} else {
+				err = repointColumn(txApp, table, column, params)
+			}
+			if err != nil {
+				return fmt.Errorf("%s.%s: %w", table, column, err)
+			}
+		}
+	}
+	for _, ref := range mergeJSONIDFields[target.Name] {
+		if err := repointJSONIDs(txApp, ref[0], ref[1], params); err != nil {
+			return fmt.Errorf("%s.%s: %w", ref[0], ref[1], err)
+		}
+	}
+	return nil
+}
+
+// repointColumn moves a single relation from one record to another. Rows that
+// would break a unique index (e.g. the same contact twice on a lead) are
+// dropped: the survivor already has the equivalent row.
+func repointColumn(txApp core.App, table, column string, params dbx.Params) error {
+	if _, err := txApp.DB().NewQuery(fmt.Sprintf("UPDATE OR IGNORE {{%s}} SET [[%s]] = {:to} WHERE [[%s]] = {:from}",
+		table, column, column)).Bind(params).Execute(); err != nil {
+		return err
+	}
+	_, err := txApp.DB().NewQuery(fmt.Sprintf("DELETE FROM {{%s}} WHERE [[%s]] = {:from}",
+		table, column)).Bind(params).Execute()
+	return err
+}
+
+// mergeBuyingRoles copies the buying role of the duplicate's lead_contacts
+// rows to the survivor's rows for the same lead / contact when they have none,
+// before repointColumn drops the duplicate rows.
+func mergeBuyingRoles(txApp core.App, collection, fromID, toID string) error {
+	column, other := "contact", "lead"
+	switch collection {
+	case "contacts":
+	case "leads":
+		column, other = "lead", "contact"
+	default:
+		return nil
+	}
+	_, err := txApp.DB().NewQuery(fmt.Sprintf(`
+		UPDATE lead_contacts SET role = (
+			SELECT d.role FROM lead_contacts d
+			WHERE d.[[%[1]s]] = {:from} AND d.[[%[2]s]] = lead_contacts.[[%[2]s]] AND COALESCE(d.role, '') != ''
+		)
+		WHERE [[%[1]s]] = {:to} AND COALESCE(role, '') = ''
+		  AND EXISTS (
+			SELECT 1 FROM lead_contacts d
+			WHERE d.[[%[1]s]] = {:from} AND d.[[%[2]s]] = lead_contacts.[[%[2]s]] AND COALESCE(d.role, '') != ''
+		  )
+	`, column, other)).Bind(dbx.Params{"from": fromID, "to": toID}).Execute()
+	return err
+}
+
+func repointJSONIDs(txApp core.App, table, column string, params dbx.Params) error {
+	_, err := txApp.DB().NewQuery(fmt.Sprintf(`
+		UPDATE {{%[1]s}} SET [[%[2]s]] = (
+			SELECT json_group_array(DISTINCT CASE WHEN value = {:from} THEN {:to} ELSE value END)
+			FROM json_each({{%[1]s}}.[[%[2]s]])
+		)
+		WHERE json_valid([[%[2]s]]) AND EXISTS (SELECT 1 FROM json_each({{%[1]s}}.[[%[2]s]]) WHERE value = {:from})
+	`, table, column)).Bind(params).Execute()
+	return err
+}
+
+// recordLabel names a contact, company or lead in activity descriptions.
+func recordLabel(r *core.Record) string {
+	switch r.Collection().Name {
+	case "contacts":
+		return strings.TrimSpace(r.GetString("first_name") + " " + r.GetString("last_name"))
+	case "companies":
+		return r.GetString("name")
+	default:
+		return r.GetString("title")
+	}
+}
+
+// logMergeActivity records a "fusion" activity on the survivor. The author is
+// the user who merged, else the survivor's owner.
+func logMergeActivity(txApp core.App, survivor *core.Record, actorID, description string, metadata map[string]any) error {
+	if actorID == "" {
+		actorID = survivor.GetString("owner")
+	}
+	if actorID == "" {
+		log.Printf("[duplicates] merge into %s not logged: no author", survivor.Id)
+		return nil
+	}
+	col, err := txApp.FindCollectionByNameOrId("activities")
+	if err != nil {
+		return err
+	}
+	activity := core.NewRecord(col)
+	activity.Set("type", "fusion")
+	if runes := []rune(description); len(runes) > 1000 {
+		description = string(runes[:999]) + "…"
+	}
+	activity.Set("description", description)
+	activity.Set("user", actorID)
+	activity.Set("metadata", metadata)
+	switch survivor.Collection().Name {
+	case "contacts":
+		activity.Set("contact", survivor.Id)
+		activity.Set("company", survivor.GetString("company"))
+	case "companies":
+		activity.Set("company", survivor.Id)
+	case "leads":
+		activity.Set("lead", survivor.Id)
+		activity.Set("contact", survivor.GetString("contact"))
+		activity.Set("company", survivor.GetString("company"))
+	}
+	return txApp.Save(activity)
+}
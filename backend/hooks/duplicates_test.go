@@ -0,0 +1,77 @@
+package hooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestMergeRecordsRepointsContactSnapshots(t *testing.T) {
+	app := newTestApp(t)
+	keep := testRecord(t, app, "contacts", map[string]any{"first_name": "Jean", "last_name": "Dupont", "email": "jean@acme.fr"})
+	dup := testRecord(t, app, "contacts", map[string]any{"first_name": "Jean", "last_name": "Dupond", "phone": "0612345678"})
+	other := testRecord(t, app, "contacts", map[string]any{"first_name": "Marie", "last_name": "Curie"})
+
+	// The recipient lists of campaigns and of their runs are JSON id lists
+	tests := []struct {
+		name   string
+		table  string
+		before []string
+		want   []string
+	}{
+		{"campaign list", "campaigns", []string{dup.Id, other.Id}, []string{keep.Id, other.Id}},
+		{"campaign list with both", "campaigns", []string{keep.Id, dup.Id}, []string{keep.Id}},
+		{"campaign list without the duplicate", "campaigns", []string{other.Id}, []string{other.Id}},
+		{"run snapshot", "campaign_runs", []string{dup.Id}, []string{keep.Id}},
+		{"run snapshot with both", "campaign_runs", []string{other.Id, keep.Id, dup.Id}, []string{keep.Id, other.Id}},
+	}
+	for i, tt := range tests {
+		ids, _ := json.Marshal(tt.before)
+		if _, err := app.DB().Insert(tt.table, dbx.Params{"id": fmt.Sprint("row", i), "contact_ids": string(ids)}).Execute(); err != nil {
+			t.Fatalf("insert %s: %v", tt.table, err)
+		}
+	}
+
+	err := app.RunInTransaction(func(txApp core.App) error {
+		return mergeRecords(txApp, keep, []*core.Record{dup}, "")
+	})
+	if err != nil {
+		t.Fatalf("mergeRecords() error = %v", err)
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var raw string
+			if err := app.DB().Select("contact_ids").From(tt.table).
+				Where(dbx.HashExp{"id": fmt.Sprint("row", i)}).Row(&raw); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			if err := json.Unmarshal([]byte(raw), &got); err != nil {
+				t.Fatalf("%s.contact_ids = %s: %v", tt.table, raw, err)
+			}
+			slices.Sort(got)
+			want := slices.Clone(tt.want)
+			slices.Sort(want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s.contact_ids = %v, want %v", tt.table, got, want)
+			}
+		})
+	}
+
+	if _, err := app.FindRecordById("contacts", dup.Id); err == nil {
+		t.Errorf("duplicate %s still exists after the merge", dup.Id)
+	}
+	survivor, err := app.FindRecordById("contacts", keep.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := survivor.GetString("phone"); got != "0612345678" {
+		t.Errorf("survivor phone = %q, want the duplicate's", got)
+	}
+}
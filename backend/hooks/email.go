@@ -2,8 +2,6 @@ package hooks
 
 import (
 	"bytes"
-	"crypto/rand"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,6 +11,8 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/pocketbase/dbx"
@@ -24,6 +24,13 @@ import (
 // errCampaignNoContacts is returned by executeCampaignSend when a campaign has no contacts.
 var errCampaignNoContacts = errors.New("campaign has no contacts")
 
+// errCampaignAlreadyRunning is returned when a run of the same campaign is in progress.
+var errCampaignAlreadyRunning = errors.New("campaign is already being sent")
+
+// runningCampaigns holds the IDs of campaigns with a send loop in progress,
+// so the HTTP handlers and the scheduler never run the same campaign twice.
+var runningCampaigns sync.Map
+
 // transparentGIF is a 1×1 transparent GIF pixel, generated once at startup.
 var transparentGIF []byte
 
@@ -44,13 +51,6 @@ func init() {
 	transparentGIF = buf.Bytes()
 }
 
-// generateCampaignID returns a random 16-char hex string to group bulk sends.
-func generateCampaignID() string {
-	b := make([]byte, 8)
-	rand.Read(b) //nolint:errcheck
-	return hex.EncodeToString(b)
-}
-
 // RegisterEmailRoutes registers all custom email API routes on the PocketBase server.
 func RegisterEmailRoutes(app core.App) {
 	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
@@ -59,9 +59,12 @@ func RegisterEmailRoutes(app core.App) {
 		se.Router.GET("/api/crm/email/track-click/{logId}", buildTrackClick(app))
 
 		// ── Protected endpoints (require authenticated user) ──────────────────
-		se.Router.POST("/api/crm/send-email", buildSendEmail(app)).Bind(apis.RequireAuth())
-		se.Router.POST("/api/crm/send-campaign", buildSendCampaign(app)).Bind(apis.RequireAuth())
-		se.Router.POST("/api/crm/campaigns/{id}/send", buildSendCampaignById(app)).Bind(apis.RequireAuth())
+		se.Router.POST("/api/crm/send-email", buildSendEmail(app)).Bind(apis.RequireAuth(), requireIdempotency(app))
+		se.Router.POST("/api/crm/send-campaign", buildSendCampaign(app)).Bind(apis.RequireAuth(), requireIdempotency(app))
+		se.Router.POST("/api/crm/campaigns/{id}/send", buildSendCampaignById(app)).Bind(apis.RequireAuth(), requireIdempotency(app))
+		se.Router.POST("/api/crm/campaigns/{id}/pause", buildPauseCampaign(app)).Bind(apis.RequireAuth())
+		se.Router.POST("/api/crm/campaigns/{id}/resume", buildResumeCampaign(app)).Bind(apis.RequireAuth())
+		se.Router.POST("/api/crm/campaigns/{id}/cancel", buildCancelCampaign(app)).Bind(apis.RequireAuth())
 		se.Router.GET("/api/crm/campaigns/{id}/runs", buildCampaignRuns(app)).Bind(apis.RequireAuth())
 		se.Router.GET("/api/crm/email/global-stats", buildGlobalStats(app)).Bind(apis.RequireAuth())
 		se.Router.GET("/api/crm/email/campaign-stats-list", buildCampaignStatsList(app)).Bind(apis.RequireAuth())
@@ -71,7 +74,7 @@ func RegisterEmailRoutes(app core.App) {
 		return se.Next()
 	})
 
-	log.Println("[hooks] Email routes registered (send-email, send-campaign, track-open, track-click, campaign-stats)")
+	log.Println("[hooks] Email routes registered (send-email, send-campaign, campaign controls, track-open, track-click, campaign-stats)")
 }
 
 // ─── Send single email ────────────────────────────────────────────────────────
@@ -83,6 +86,9 @@ func buildSendEmail(app core.App) func(*core.RequestEvent) error {
 			ContactID      string            `json:"contact_id"`      // optional
 			RecipientEmail string            `json:"recipient_email"` // used if no contact_id
 			RecipientName  string            `json:"recipient_name"`  // optional
+			Locale         string            `json:"locale"`          // optional — overrides the contact's locale
+			LeadID         string            `json:"lead_id"`         // optional — ties the email to a lead
+			Roles          []string          `json:"roles"`           // lead_id without recipient: buying roles to email (empty = all)
 			Variables      map[string]string `json:"variables"`
 		}
 		if err := e.BindBody(&body); err != nil {
@@ -92,43 +98,104 @@ func buildSendEmail(app core.App) func(*core.RequestEvent) error {
 			return e.BadRequestError("template_id is required", nil)
 		}
 
-		params := services.EmailSendParams{
-			TemplateID: body.TemplateID,
-			SentByID:   e.Auth.Id,
-			Variables:  body.Variables,
-			BaseURL:    app.Settings().Meta.AppURL,
+		var lead *core.Record
+		if body.LeadID != "" {
+			var err error
+			if lead, err = app.FindRecordById("leads", body.LeadID); err != nil {
+				return e.BadRequestError("Lead not found", err)
+			}
 		}
 
-		// If a contact_id is provided, load the contact to fill recipient info
-		if body.ContactID != "" {
+		// Recipients: the contact, else the given address, else the lead contacts
+		var contacts []*core.Record
+		switch {
+		case body.ContactID != "":
 			contact, err := app.FindRecordById("contacts", body.ContactID)
 			if err != nil {
 				return e.BadRequestError("Contact not found", err)
 			}
+			contacts = []*core.Record{contact}
+		case body.RecipientEmail != "":
+		case lead != nil:
+			involved, err := leadContactsWithRoles(app, lead, body.Roles)
+			if err != nil {
+				return e.BadRequestError(err.Error(), nil)
+			}
+			for _, contact := range involved {
+				if contact.GetString("email") != "" {
+					contacts = append(contacts, contact)
+				}
+			}
+			if len(contacts) == 0 {
+				return e.BadRequestError("The lead has no contact with an email address for these roles", nil)
+			}
+		default:
+			return e.BadRequestError("recipient_email is required when neither contact_id nor lead_id is provided", nil)
+		}
+
+		newParams := func() services.EmailSendParams {
+			vars := make(map[string]string, len(body.Variables)+4)
+			for k, v := range body.Variables {
+				vars[k] = v
+			}
+			if _, ok := vars["lead_title"]; !ok && lead != nil {
+				vars["lead_title"] = lead.GetString("title")
+			}
+			return services.EmailSendParams{
+				TemplateID: body.TemplateID,
+				SentByID:   e.Auth.Id,
+				Variables:  vars,
+				BaseURL:    app.Settings().Meta.AppURL,
+				Locale:     body.Locale,
+				LeadID:     body.LeadID,
+			}
+		}
+
+		sentTo := make([]string, 0, len(contacts))
+		failed := 0
+		if len(contacts) == 0 {
+			params := newParams()
+			params.RecipientEmail = body.RecipientEmail
+			params.RecipientName = body.RecipientName
+			if err := services.SendTemplatedEmail(app, params); err != nil {
+				return e.BadRequestError("Failed to send email", err)
+			}
+			sentTo = append(sentTo, body.RecipientEmail)
+		}
+		for _, contact := range contacts {
+			params := newParams()
 			params.RecipientEmail = contact.GetString("email")
 			params.RecipientName = contact.GetString("first_name") + " " + contact.GetString("last_name")
-			params.RecipientContactID = body.ContactID
+			params.RecipientContactID = contact.Id
 
 			// Auto-populate common variables from the contact
-			if params.Variables == nil {
-				params.Variables = map[string]string{}
-			}
 			params.Variables["first_name"] = contact.GetString("first_name")
 			params.Variables["last_name"] = contact.GetString("last_name")
 			params.Variables["email"] = contact.GetString("email")
-		} else {
-			if body.RecipientEmail == "" {
-				return e.BadRequestError("recipient_email is required when contact_id is not provided", nil)
+
+			if err := services.SendTemplatedEmail(app, params); err != nil {
+				if len(contacts) == 1 {
+					return e.BadRequestError("Failed to send email", err)
+				}
+				log.Printf("[email] send to contact %s failed: %v", contact.Id, err)
+				failed++
+				continue
 			}
-			params.RecipientEmail = body.RecipientEmail
-			params.RecipientName = body.RecipientName
+			sentTo = append(sentTo, params.RecipientEmail)
+		}
+		if len(sentTo) == 0 {
+			return e.BadRequestError("Failed to send email", nil)
 		}
 
-		if err := services.SendTemplatedEmail(app, params); err != nil {
-			return e.BadRequestError("Failed to send email", err)
+		if lead != nil {
+			name := body.TemplateID
+			if template, err := app.FindRecordById("email_templates", body.TemplateID); err == nil {
+				name = template.GetString("name")
+			}
+			createLeadActivity(app, lead, "email", fmt.Sprintf("Email \"%s\" envoyé à %s", name, strings.Join(sentTo, ", ")))
 		}
 
-		return e.JSON(http.StatusOK, map[string]string{"status": "sent"})
+		return e.JSON(http.StatusOK, map[string]any{"status": "sent", "sent": len(sentTo), "failed": failed})
 	}
 }
 
@@ -139,7 +206,7 @@ func buildSendCampaign(app core.App) func(*core.RequestEvent) error {
 		var body struct {
 			TemplateID string   `json:"template_id"`
 			ContactIDs []string `json:"contact_ids"`
-			CampaignID string   `json:"campaign_id"` // optional override
+			CampaignID string   `json:"campaign_id"` // optional — adds a run to an existing email campaign
 		}
 		if err := e.BindBody(&body); err != nil {
 			return e.BadRequestError("Invalid request body", err)
@@ -151,64 +218,78 @@ func buildSendCampaign(app core.App) func(*core.RequestEvent) error {
 			return e.BadRequestError("contact_ids must not be empty", nil)
 		}
 
-		campaignID := body.CampaignID
-		if campaignID == "" {
-			campaignID = generateCampaignID()
+		template, err := app.FindRecordById("email_templates", body.TemplateID)
+		if err != nil {
+			return e.BadRequestError("Template not found", err)
 		}
 
-		baseURL := app.Settings().Meta.AppURL
-		sentByID := e.Auth.Id
-
-		var sent, failed int
-		var errors []string
-
-		for _, contactID := range body.ContactIDs {
-			contact, err := app.FindRecordById("contacts", contactID)
+		// An extra run of an existing campaign keeps its template and contact
+		// list: the run stores what it mails
+		contactIDs := uniqueIDs(body.ContactIDs)
+		var campaign *core.Record
+		if body.CampaignID != "" {
+			campaign, err = app.FindRecordById("campaigns", body.CampaignID)
 			if err != nil {
-				failed++
-				errors = append(errors, fmt.Sprintf("contact %s: %v", contactID, err))
-				continue
+				return e.BadRequestError("Campaign not found", err)
 			}
-
-			recipientEmail := contact.GetString("email")
-			if recipientEmail == "" {
-				failed++
-				errors = append(errors, fmt.Sprintf("contact %s has no email", contactID))
-				continue
+			if campaign.GetString("type") != "email" {
+				return e.BadRequestError("Campaign is not an email campaign", nil)
 			}
-
-			params := services.EmailSendParams{
-				TemplateID:         body.TemplateID,
-				RecipientEmail:     recipientEmail,
-				RecipientName:      contact.GetString("first_name") + " " + contact.GetString("last_name"),
-				RecipientContactID: contactID,
-				SentByID:           sentByID,
-				CampaignID:         campaignID,
-				BaseURL:            baseURL,
-				Variables: map[string]string{
-					"first_name": contact.GetString("first_name"),
-					"last_name":  contact.GetString("last_name"),
-					"email":      recipientEmail,
-				},
+			if !canManageCampaign(e, campaign) {
+				return e.ForbiddenError("Not allowed to manage this campaign", nil)
+			}
+		} else {
+			campaign, err = newAdHocCampaign(app, template, e.Auth.Id)
+			if err != nil {
+				return e.InternalServerError("Failed to create campaign", err)
 			}
+			campaign.Set("template", body.TemplateID)
+			campaign.Set("contact_ids", contactIDs)
+			if err := app.Save(campaign); err != nil {
+				return e.InternalServerError("Failed to save campaign", err)
+			}
+		}
 
-			if err := services.SendTemplatedEmail(app, params); err != nil {
-				failed++
-				errors = append(errors, fmt.Sprintf("contact %s: %v", contactID, err))
-			} else {
-				sent++
+		result, err := executeCampaignSend(app, campaign, body.TemplateID, contactIDs, e.Auth.Id)
+		if err != nil {
+			if errors.Is(err, errCampaignNoContacts) {
+				return e.BadRequestError("contact_ids must not be empty", nil)
 			}
+			if errors.Is(err, errCampaignAlreadyRunning) {
+				return e.Error(http.StatusConflict, "Campaign is currently being sent", nil)
+			}
+			return e.InternalServerError("Failed to send campaign", err)
 		}
 
 		return e.JSON(http.StatusOK, map[string]interface{}{
-			"campaign_id": campaignID,
-			"sent":        sent,
-			"failed":      failed,
-			"errors":      errors,
+			"campaign_id": campaign.Id,
+			"run_id":      result.RunID,
+			"run_number":  result.RunNumber,
+			"sent":        result.Sent,
+			"failed":      result.Failed,
+			"skipped":     result.Skipped,
+			"errors":      result.Errors,
 		})
 	}
 }
 
+// newAdHocCampaign builds (unsaved) the campaigns record backing a one-off
+// bulk send, so it shows up in campaign stats and ROI reports like any other.
+func newAdHocCampaign(app core.App, template *core.Record, createdBy string) (*core.Record, error) {
+	col, err := app.FindCollectionByNameOrId("campaigns")
+	if err != nil {
+		return nil, err
+	}
+	campaign := core.NewRecord(col)
+	campaign.Set("name", fmt.Sprintf("Envoi ponctuel — %s — %s",
+		template.GetString("name"), time.Now().UTC().Format("02/01/2006 15:04")))
+	campaign.Set("type", "email")
+	campaign.Set("status", "brouillon")
+	campaign.Set("ad_hoc", true)
+	campaign.Set("created_by", createdBy)
+	return campaign, nil
+}
+
 // ─── Track email open (pixel) ─────────────────────────────────────────────────
 
 func buildTrackOpen(app core.App) func(*core.RequestEvent) error {
@@ -229,6 +310,10 @@ func buildTrackOpen(app core.App) func(*core.RequestEvent) error {
 			}
 			if err := app.Save(logRec); err != nil {
 				log.Printf("[email] track-open save error for %s: %v", logId, err)
+				return
+			}
+			if err := services.RefreshContactEngagement(app, logRec.GetString("recipient_contact")); err != nil {
+				log.Printf("[email] engagement refresh error for %s: %v", logId, err)
 			}
 		}()
 
@@ -263,6 +348,10 @@ func buildTrackClick(app core.App) func(*core.RequestEvent) error {
 			logRec.Set("status", "clique")
 			if err := app.Save(logRec); err != nil {
 				log.Printf("[email] track-click save error for %s: %v", logId, err)
+				return
+			}
+			if err := services.RefreshContactEngagement(app, logRec.GetString("recipient_contact")); err != nil {
+				log.Printf("[email] engagement refresh error for %s: %v", logId, err)
 			}
 		}()
 
@@ -296,27 +385,35 @@ func buildSMTPStatus(app core.App) func(*core.RequestEvent) error {
 type campaignSendResult struct {
 	RunID     string
 	RunNumber int
+	Status    string // run status: terminee, en_pause or annulee
 	Sent      int
 	Failed    int
+	Skipped   int
+	Cancelled int
+	Errors    []string // per-recipient failures of this call
 }
 
-// executeCampaignSend creates a campaign_run, sends emails to all contacts,
-// and updates the campaign status to "envoye". It is called both by the HTTP
-// handler and by the background scheduler.
-func executeCampaignSend(app core.App, campaign *core.Record, senderID string) (*campaignSendResult, error) {
+// executeCampaignSend creates a campaign_run mailing templateID to
+// contactIDs, sends the emails, and updates the campaign status to "envoye".
+// It is called both by the HTTP handlers and by the background scheduler.
+func executeCampaignSend(app core.App, campaign *core.Record, templateID string, contactIDs []string, senderID string) (*campaignSendResult, error) {
 	campaignId := campaign.Id
-	templateId := campaign.GetString("template")
 
-	// Parse contact_ids from JSON field
-	var contactIDs []string
-	raw, _ := json.Marshal(campaign.Get("contact_ids"))
-	json.Unmarshal(raw, &contactIDs) //nolint:errcheck
+	if _, running := runningCampaigns.LoadOrStore(campaignId, struct{}{}); running {
+		return nil, errCampaignAlreadyRunning
+	}
+	defer runningCampaigns.Delete(campaignId)
 
+	// Re-check under the lock: the record passed in may predate another send
+	if status := currentCampaignStatus(app, campaignId); status == "en_cours" || status == "en_pause" {
+		return nil, errCampaignAlreadyRunning
+	}
+
+	contactIDs = uniqueIDs(contactIDs)
 	if len(contactIDs) == 0 {
 		return nil, errCampaignNoContacts
 	}
 
-	baseURL := app.Settings().Meta.AppURL
 	now := time.Now().UTC().Format("2006-01-02 15:04:05.000Z")
 
 	// Count existing runs to assign the next run_number
@@ -333,12 +430,15 @@ func executeCampaignSend(app core.App, campaign *core.Record, senderID string) (
 	runRec.Set("campaign", campaignId)
 	runRec.Set("run_number", runCount+1)
 	runRec.Set("total", len(contactIDs))
+	runRec.Set("status", "en_cours")
+	runRec.Set("processed", 0)
+	runRec.Set("template", templateID)
+	runRec.Set("contact_ids", contactIDs) // snapshot: resumes ignore later edits of the campaign
 	runRec.Set("sent_by", senderID)
 	runRec.Set("sent_at", now)
 	if err := app.Save(runRec); err != nil {
 		return nil, fmt.Errorf("failed to create campaign run: %w", err)
 	}
-	runID := runRec.Id
 
 	campaign.Set("status", "en_cours")
 	campaign.Set("total", len(contactIDs))
@@ -346,65 +446,237 @@ func executeCampaignSend(app core.App, campaign *core.Record, senderID string) (
 		return nil, fmt.Errorf("failed to update campaign: %w", err)
 	}
 
-	var sent, failed int
+	return processCampaignRun(app, campaign, runRec, contactIDs, senderID), nil
+}
 
-	for _, contactID := range contactIDs {
-		contact, err := app.FindRecordById("contacts", contactID)
-		if err != nil {
-			failed++
-			continue
+// processCampaignRun sends the run's remaining recipients, starting after the
+// run's "processed" counter so a paused run resumes where it stopped.
+// Recipients whose normalized email was already handled in this run are
+// logged as "doublon" instead of being mailed again.
+//
+// Progress is written after every recipient, so an interrupted run resumes
+// after the last recipient handled. The campaign status is re-read between
+// recipients: "en_pause" stops the loop and keeps the run resumable,
+// "annulee" stops it for good and records the unsent recipients in the run's
+// "cancelled" counter. The run only ends as "terminee" (campaign "envoye")
+// if the campaign is still en_cours once the last recipient is handled.
+func processCampaignRun(app core.App, campaign *core.Record, runRec *core.Record, contactIDs []string, senderID string) *campaignSendResult {
+	campaignId := campaign.Id
+	templateId := runRec.GetString("template")
+	if templateId == "" {
+		templateId = campaign.GetString("template")
+	}
+	runID := runRec.Id
+	baseURL := app.Settings().Meta.AppURL
+
+	// Frequency caps only apply to marketing templates
+	isMarketing := false
+	if template, err := app.FindRecordById("email_templates", templateId); err == nil {
+		isMarketing = template.GetString("type") == "marketing"
+	}
+	minEngagement := campaign.GetFloat("min_engagement")
+
+	// Addresses already handled by this run (non-empty when resuming)
+	seenEmails := map[string]bool{}
+	var handled []string
+	app.DB().NewQuery("SELECT recipient_email FROM email_logs WHERE run_id = {:run}"). //nolint:errcheck
+												Bind(dbx.Params{"run": runID}).Column(&handled)
+	for _, email := range handled {
+		seenEmails[services.NormalizeEmail(email)] = true
+	}
+
+	errs := make([]string, 0)
+	processed := runRec.GetInt("processed")
+	interruptedBy := ""
+
+	for processed < len(contactIDs) {
+		if status := currentCampaignStatus(app, campaignId); status == "en_pause" || status == "annulee" {
+			interruptedBy = status
+			break
 		}
-		recipientEmail := contact.GetString("email")
-		if recipientEmail == "" {
-			failed++
-			continue
+
+		contactID := contactIDs[processed]
+		processed++
+		outcome, err := sendCampaignRecipient(app, campaignId, templateId, runID, contactID, senderID, baseURL, isMarketing, minEngagement, seenEmails)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("contact %s: %v", contactID, err))
 		}
-		params := services.EmailSendParams{
-			TemplateID:         templateId,
-			RecipientEmail:     recipientEmail,
-			RecipientName:      contact.GetString("first_name") + " " + contact.GetString("last_name"),
-			RecipientContactID: contactID,
-			SentByID:           senderID,
-			CampaignID:         campaignId,
-			RunID:              runID,
-			BaseURL:            baseURL,
-			Variables: map[string]string{
-				"first_name": contact.GetString("first_name"),
-				"last_name":  contact.GetString("last_name"),
-				"email":      recipientEmail,
-			},
-		}
-		if err := services.SendTemplatedEmail(app, params); err != nil {
-			failed++
-		} else {
-			sent++
+		if err := saveRunProgress(app, runID, campaignId, processed, outcome); err != nil {
+			log.Printf("[campaign] failed to save progress of run %s: %v", runID, err)
 		}
 	}
 
-	// Update run record with final counts
-	runRec.Set("sent", sent)
-	runRec.Set("failed", failed)
-	app.Save(runRec) //nolint:errcheck
+	// The last recipient may race a pause or a cancel: only a campaign still
+	// en_cours is marked as sent
+	if interruptedBy == "" {
+		res, err := app.DB().NewQuery("UPDATE campaigns SET status = 'envoye' WHERE id = {:id} AND status = 'en_cours'").
+			Bind(dbx.Params{"id": campaignId}).Execute()
+		if err != nil {
+			log.Printf("[campaign] failed to finish campaign %s: %v", campaignId, err)
+		} else if n, _ := res.RowsAffected(); n == 0 {
+			if status := currentCampaignStatus(app, campaignId); status == "en_pause" || status == "annulee" {
+				interruptedBy = status
+			}
+		}
+	}
 
-	campaign.Set("status", "envoye")
-	campaign.Set("sent", campaign.GetInt("sent")+sent)
-	campaign.Set("failed", campaign.GetInt("failed")+failed)
-	app.Save(campaign) //nolint:errcheck
+	// Reload the run: its counters were written recipient by recipient
+	if fresh, err := app.FindRecordById("campaign_runs", runID); err == nil {
+		runRec = fresh
+	}
+	switch interruptedBy {
+	case "en_pause":
+		runRec.Set("status", "en_pause")
+	case "annulee":
+		runRec.Set("status", "annulee")
+		runRec.Set("cancelled", len(contactIDs)-processed)
+		runRec.Set("finished_at", time.Now().UTC().Format("2006-01-02 15:04:05.000Z"))
+	default:
+		runRec.Set("status", "terminee")
+		runRec.Set("finished_at", time.Now().UTC().Format("2006-01-02 15:04:05.000Z"))
+	}
+	if err := app.Save(runRec); err != nil {
+		log.Printf("[campaign] failed to finish run %s: %v", runID, err)
+	}
 
 	return &campaignSendResult{
 		RunID:     runID,
-		RunNumber: runCount + 1,
-		Sent:      sent,
-		Failed:    failed,
-	}, nil
+		RunNumber: runRec.GetInt("run_number"),
+		Status:    runRec.GetString("status"),
+		Sent:      runRec.GetInt("sent"),
+		Failed:    runRec.GetInt("failed"),
+		Skipped:   runRec.GetInt("skipped"),
+		Cancelled: runRec.GetInt("cancelled"),
+		Errors:    errs,
+	}
+}
+
+// Outcomes of one campaign recipient, counted by saveRunProgress.
+const (
+	recipientSent = iota
+	recipientFailed
+	recipientSkipped
+)
+
+// sendCampaignRecipient mails (or logs as skipped) one contact of a run.
+func sendCampaignRecipient(app core.App, campaignID, templateID, runID, contactID, senderID, baseURL string,
+	isMarketing bool, minEngagement float64, seenEmails map[string]bool) (int, error) {
+	contact, err := app.FindRecordById("contacts", contactID)
+	if err != nil {
+		return recipientFailed, err
+	}
+	recipientEmail := contact.GetString("email")
+	if recipientEmail == "" {
+		return recipientFailed, errors.New("no email")
+	}
+	params := services.EmailSendParams{
+		TemplateID:         templateID,
+		RecipientEmail:     recipientEmail,
+		RecipientName:      contact.GetString("first_name") + " " + contact.GetString("last_name"),
+		RecipientContactID: contactID,
+		SentByID:           senderID,
+		CampaignID:         campaignID,
+		RunID:              runID,
+		BaseURL:            baseURL,
+		Variables: map[string]string{
+			"first_name": contact.GetString("first_name"),
+			"last_name":  contact.GetString("last_name"),
+			"email":      recipientEmail,
+		},
+	}
+	normalized := services.NormalizeEmail(recipientEmail)
+	reason := services.SkipReasonDuplicate
+	if !seenEmails[normalized] {
+		seenEmails[normalized] = true
+		reason = campaignSkipReason(app, contact, recipientEmail, isMarketing, minEngagement)
+	}
+	if reason != "" {
+		if err := services.LogSkippedEmail(app, params, reason); err != nil {
+			log.Printf("[campaign] failed to log skipped recipient %s: %v", contactID, err)
+		}
+		return recipientSkipped, nil
+	}
+	if err := services.SendTemplatedEmail(app, params); err != nil {
+		return recipientFailed, err
+	}
+	return recipientSent, nil
+}
+
+// saveRunProgress records one handled recipient on the run and its campaign.
+func saveRunProgress(app core.App, runID, campaignID string, processed, outcome int) error {
+	column := map[int]string{recipientSent: "sent", recipientFailed: "failed", recipientSkipped: "skipped"}[outcome]
+	return app.RunInTransaction(func(txApp core.App) error {
+		if _, err := txApp.DB().NewQuery(`
+			UPDATE campaign_runs SET processed = {:processed}, ` + column + ` = COALESCE(` + column + `, 0) + 1 WHERE id = {:id}
+		`).Bind(dbx.Params{"processed": processed, "id": runID}).Execute(); err != nil {
+			return err
+		}
+		_, err := txApp.DB().NewQuery(`
+			UPDATE campaigns SET ` + column + ` = COALESCE(` + column + `, 0) + 1 WHERE id = {:id}
+		`).Bind(dbx.Params{"id": campaignID}).Execute()
+		return err
+	})
+}
+
+// campaignContactIDs parses the contact_ids JSON field of a campaign,
+// dropping repeated IDs while keeping the original order.
+func campaignContactIDs(campaign *core.Record) []string {
+	var contactIDs []string
+	raw, _ := json.Marshal(campaign.Get("contact_ids"))
+	json.Unmarshal(raw, &contactIDs) //nolint:errcheck
+	return uniqueIDs(contactIDs)
+}
+
+// uniqueIDs returns ids without duplicates or empty values, in first-seen order.
+func uniqueIDs(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		result = append(result, id)
+	}
+	return result
+}
+
+// currentCampaignStatus reads the campaign status straight from the database,
+// bypassing any in-memory record that may be stale.
+func currentCampaignStatus(app core.App, campaignId string) string {
+	var status string
+	app.DB().NewQuery("SELECT status FROM campaigns WHERE id = {:id}"). //nolint:errcheck
+										Bind(dbx.Params{"id": campaignId}).Row(&status)
+	return status
+}
+
+// campaignSkipReason returns why a contact must not receive this campaign
+// (below the campaign's engagement segment, or frequency-capped for marketing
+// sends), or "" when it can be mailed.
+func campaignSkipReason(app core.App, contact *core.Record, email string, isMarketing bool, minEngagement float64) string {
+	if minEngagement > 0 && contact.GetFloat("engagement_score") < minEngagement {
+		return services.SkipReasonEngagement
+	}
+	if isMarketing {
+		capped, err := services.IsFrequencyCapped(app, contact.Id, email, time.Now().UTC())
+		if err != nil {
+			log.Printf("[campaign] frequency cap check failed for %s: %v", contact.Id, err)
+		} else if capped {
+			return services.SkipReasonFrequency
+		}
+	}
+	return ""
 }
 
 // ─── Background scheduler for programmed campaigns ───────────────────────────
 
 // RegisterCampaignScheduler starts a goroutine (60 s tick) that auto-sends
-// campaigns whose status is "programmee" and scheduled_at <= now.
+// campaigns whose status is "programmee" and scheduled_at <= now. Runs left
+// en_cours by a previous process (crash, restart) are paused at startup so
+// they can be resumed after their last handled recipient.
 func RegisterCampaignScheduler(app core.App) {
 	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		pauseInterruptedRuns(app)
 		go func() {
 			ticker := time.NewTicker(60 * time.Second)
 			defer ticker.Stop()
@@ -417,6 +689,24 @@ func RegisterCampaignScheduler(app core.App) {
 	log.Println("[hooks] Campaign scheduler registered (60s interval)")
 }
 
+// pauseInterruptedRuns marks the runs (and campaigns) still en_cours at
+// startup as en_pause: no send loop is left to finish them.
+func pauseInterruptedRuns(app core.App) {
+	err := app.RunInTransaction(func(txApp core.App) error {
+		if _, err := txApp.DB().NewQuery(`
+			UPDATE campaigns SET status = 'en_pause'
+			WHERE status = 'en_cours' AND id IN (SELECT campaign FROM campaign_runs WHERE status = 'en_cours')
+		`).Execute(); err != nil {
+			return err
+		}
+		_, err := txApp.DB().NewQuery("UPDATE campaign_runs SET status = 'en_pause' WHERE status = 'en_cours'").Execute()
+		return err
+	})
+	if err != nil {
+		log.Printf("[scheduler] Failed to pause interrupted campaign runs: %v", err)
+	}
+}
+
 func runScheduledCampaigns(app core.App) {
 	now := time.Now().UTC().Format("2006-01-02 15:04:05.000Z")
 	campaigns, err := app.FindAllRecords("campaigns",
@@ -432,7 +722,7 @@ func runScheduledCampaigns(app core.App) {
 	for _, campaign := range campaigns {
 		createdBy := campaign.GetString("created_by")
 		log.Printf("[scheduler] Triggering campaign %s (%s)", campaign.Id, campaign.GetString("name"))
-		if _, err := executeCampaignSend(app, campaign, createdBy); err != nil {
+		if _, err := executeCampaignSend(app, campaign, campaign.GetString("template"), campaignContactIDs(campaign), createdBy); err != nil {
 			log.Printf("[scheduler] Campaign %s failed: %v", campaign.Id, err)
 		}
 	}
@@ -448,15 +738,21 @@ func buildSendCampaignById(app core.App) func(*core.RequestEvent) error {
 		if err != nil {
 			return e.NotFoundError("Campaign not found", err)
 		}
-		if campaign.GetString("status") == "en_cours" {
+		switch campaign.GetString("status") {
+		case "en_cours":
 			return e.BadRequestError("Campaign is currently being sent", nil)
+		case "en_pause":
+			return e.BadRequestError("Campaign is paused, resume it instead", nil)
 		}
 
-		result, err := executeCampaignSend(app, campaign, e.Auth.Id)
+		result, err := executeCampaignSend(app, campaign, campaign.GetString("template"), campaignContactIDs(campaign), e.Auth.Id)
 		if err != nil {
 			if errors.Is(err, errCampaignNoContacts) {
 				return e.BadRequestError("Campaign has no contacts", nil)
 			}
+			if errors.Is(err, errCampaignAlreadyRunning) {
+				return e.Error(http.StatusConflict, "Campaign is currently being sent", nil)
+			}
 			return e.InternalServerError("Failed to send campaign", err)
 		}
 
@@ -464,8 +760,11 @@ func buildSendCampaignById(app core.App) func(*core.RequestEvent) error {
 			"campaign_id": campaignId,
 			"run_id":      result.RunID,
 			"run_number":  result.RunNumber,
+			"status":      result.Status,
 			"sent":        result.Sent,
 			"failed":      result.Failed,
+			"skipped":     result.Skipped,
+			"cancelled":   result.Cancelled,
 		})
 	}
 }
@@ -478,6 +777,9 @@ type campaignRunRow struct {
 	Total     int    `db:"total"`
 	Sent      int    `db:"sent"`
 	Failed    int    `db:"failed"`
+	Skipped   int    `db:"skipped"`
+	Cancelled int    `db:"cancelled"`
+	Status    string `db:"status"`
 	SentAt    string `db:"sent_at"`
 }
 
@@ -487,7 +789,7 @@ func buildCampaignRuns(app core.App) func(*core.RequestEvent) error {
 
 		var rows []campaignRunRow
 		err := app.DB().NewQuery(`
-			SELECT id, run_number, total, sent, failed, sent_at
+			SELECT id, run_number, total, sent, failed, skipped, cancelled, status, sent_at
 			FROM campaign_runs
 			WHERE campaign = {:campaignId}
 			ORDER BY run_number ASC
@@ -502,6 +804,9 @@ func buildCampaignRuns(app core.App) func(*core.RequestEvent) error {
 			Total     int    `json:"total"`
 			Sent      int    `json:"sent"`
 			Failed    int    `json:"failed"`
+			Skipped   int    `json:"skipped"`
+			Cancelled int    `json:"cancelled"`
+			Status    string `json:"status"`
 			SentAt    string `json:"sent_at"`
 		}
 
@@ -513,6 +818,9 @@ func buildCampaignRuns(app core.App) func(*core.RequestEvent) error {
 				Total:     r.Total,
 				Sent:      r.Sent,
 				Failed:    r.Failed,
+				Skipped:   r.Skipped,
+				Cancelled: r.Cancelled,
+				Status:    r.Status,
 				SentAt:    r.SentAt,
 			})
 		}
@@ -529,6 +837,7 @@ func buildGlobalStats(app core.App) func(*core.RequestEvent) error {
 			Total   int `db:"total"`
 			Sent    int `db:"sent"`
 			Failed  int `db:"failed"`
+			Skipped int `db:"skipped"`
 			Opened  int `db:"opened"`
 			Clicked int `db:"clicked"`
 		}
@@ -537,6 +846,7 @@ func buildGlobalStats(app core.App) func(*core.RequestEvent) error {
 				COUNT(*) AS total,
 				COALESCE(SUM(CASE WHEN status IN ('envoye','ouvert','clique') THEN 1 ELSE 0 END), 0) AS sent,
 				COALESCE(SUM(CASE WHEN status = 'echoue' THEN 1 ELSE 0 END), 0) AS failed,
+				COALESCE(SUM(CASE WHEN status = 'ignore' THEN 1 ELSE 0 END), 0) AS skipped,
 				COALESCE(SUM(CASE WHEN open_count > 0 THEN 1 ELSE 0 END), 0) AS opened,
 				COALESCE(SUM(CASE WHEN click_count > 0 THEN 1 ELSE 0 END), 0) AS clicked
 			FROM email_logs
@@ -556,6 +866,7 @@ func buildGlobalStats(app core.App) func(*core.RequestEvent) error {
 			"total":      row.Total,
 			"sent":       row.Sent,
 			"failed":     row.Failed,
+			"skipped":    row.Skipped,
 			"opened":     row.Opened,
 			"clicked":    row.Clicked,
 			"open_rate":  fmt.Sprintf("%.1f", openRate),
@@ -573,6 +884,7 @@ type campaignStatsListRow struct {
 	Total        int    `db:"total"`
 	Sent         int    `db:"sent"`
 	Failed       int    `db:"failed"`
+	Skipped      int    `db:"skipped"`
 	Opened       int    `db:"opened"`
 	Clicked      int    `db:"clicked"`
 }
@@ -588,6 +900,7 @@ func buildCampaignStatsList(app core.App) func(*core.RequestEvent) error {
 				COUNT(el.id)  AS total,
 				SUM(CASE WHEN el.status IN ('envoye','ouvert','clique') THEN 1 ELSE 0 END) AS sent,
 				SUM(CASE WHEN el.status = 'echoue' THEN 1 ELSE 0 END) AS failed,
+				SUM(CASE WHEN el.status = 'ignore' THEN 1 ELSE 0 END) AS skipped,
 				SUM(CASE WHEN el.open_count > 0 THEN 1 ELSE 0 END) AS opened,
 				SUM(CASE WHEN el.click_count > 0 THEN 1 ELSE 0 END) AS clicked
 			FROM campaigns c
@@ -606,6 +919,7 @@ func buildCampaignStatsList(app core.App) func(*core.RequestEvent) error {
 			Total        int    `json:"total"`
 			Sent         int    `json:"sent"`
 			Failed       int    `json:"failed"`
+			Skipped      int    `json:"skipped"`
 			Opened       int    `json:"opened"`
 			Clicked      int    `json:"clicked"`
 			OpenRate     string `json:"open_rate"`
@@ -627,6 +941,7 @@ func buildCampaignStatsList(app core.App) func(*core.RequestEvent) error {
 				Total:        r.Total,
 				Sent:         r.Sent,
 				Failed:       r.Failed,
+				Skipped:      r.Skipped,
 				Opened:       r.Opened,
 				Clicked:      r.Clicked,
 				OpenRate:     fmt.Sprintf("%.1f", openRate),
@@ -644,6 +959,7 @@ type campaignStatsRow struct {
 	Total   int `db:"total"`
 	Sent    int `db:"sent"`
 	Failed  int `db:"failed"`
+	Skipped int `db:"skipped"`
 	Opened  int `db:"opened"`
 	Clicked int `db:"clicked"`
 }
@@ -661,6 +977,7 @@ func buildCampaignStats(app core.App) func(*core.RequestEvent) error {
 				COUNT(*) AS total,
 				COALESCE(SUM(CASE WHEN status IN ('envoye','ouvert','clique') THEN 1 ELSE 0 END), 0) AS sent,
 				COALESCE(SUM(CASE WHEN status = 'echoue' THEN 1 ELSE 0 END), 0) AS failed,
+				COALESCE(SUM(CASE WHEN status = 'ignore' THEN 1 ELSE 0 END), 0) AS skipped,
 				COALESCE(SUM(CASE WHEN open_count > 0 THEN 1 ELSE 0 END), 0) AS opened,
 				COALESCE(SUM(CASE WHEN click_count > 0 THEN 1 ELSE 0 END), 0) AS clicked
 			FROM email_logs
@@ -682,6 +999,7 @@ func buildCampaignStats(app core.App) func(*core.RequestEvent) error {
 			"total":       stats.Total,
 			"sent":        stats.Sent,
 			"failed":      stats.Failed,
+			"skipped":     stats.Skipped,
 			"opened":      stats.Opened,
 			"clicked":     stats.Clicked,
 			"open_rate":   fmt.Sprintf("%.1f", openRate),
@@ -0,0 +1,47 @@
+package hooks
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/pocketbase/pocketbase/core"
+	"pocket-crm/services"
+)
+
+// RegisterEmailTemplateHooks validates the translations JSON of email templates:
+//
+//   - every key must be a supported locale (fr, en)
+//   - every translation must provide both a subject and a body
+//   - a translation may not duplicate the template's base locale
+func RegisterEmailTemplateHooks(app core.App) {
+	validate := func(e *core.RecordEvent) error {
+		if e.Record.GetString("locale") == "" {
+			e.Record.Set("locale", services.DefaultLocale)
+		}
+		baseLocale := e.Record.GetString("locale")
+
+		translations := map[string]services.TemplateTranslation{}
+		if err := e.Record.UnmarshalJSONField("translations", &translations); err != nil {
+			return fmt.Errorf("traductions invalides : %w", err)
+		}
+		for key, tr := range translations {
+			locale := services.NormalizeLocale(key)
+			if locale == "" || locale != key {
+				return fmt.Errorf("langue de traduction non supportée : %q", key)
+			}
+			if locale == baseLocale {
+				return fmt.Errorf("la traduction %q duplique la langue de base du modèle", key)
+			}
+			if tr.Subject == "" || tr.Body == "" {
+				return fmt.Errorf("la traduction %q doit avoir un objet et un contenu", key)
+			}
+		}
+
+		return e.Next()
+	}
+
+	app.OnRecordCreate("email_templates").BindFunc(validate)
+	app.OnRecordUpdate("email_templates").BindFunc(validate)
+
+	log.Println("[hooks] Email template hooks registered (locale, translations validation)")
+}
@@ -0,0 +1,44 @@
+package hooks
+
+import (
+	"log"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"pocket-crm/services"
+)
+
+// RegisterEngagementScheduler starts a goroutine that recomputes every contact's
+// email engagement score once at startup and then every 24 h, so the recency
+// decay keeps applying to contacts who stopped opening emails.
+// Opens and clicks also refresh the score immediately from the tracking routes.
+func RegisterEngagementScheduler(app core.App) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		go func() {
+			refreshAllEngagementScores(app)
+			ticker := time.NewTicker(24 * time.Hour)
+			defer ticker.Stop()
+			for range ticker.C {
+				refreshAllEngagementScores(app)
+			}
+		}()
+		return se.Next()
+	})
+	log.Println("[hooks] Engagement scheduler registered (24h interval)")
+}
+
+func refreshAllEngagementScores(app core.App) {
+	var contactIDs []string
+	if err := app.DB().NewQuery("SELECT id FROM contacts").Column(&contactIDs); err != nil {
+		log.Printf("[engagement] Failed to list contacts: %v", err)
+		return
+	}
+	var failed int
+	for _, id := range contactIDs {
+		if err := services.RefreshContactEngagement(app, id); err != nil {
+			failed++
+			log.Printf("[engagement] Contact %s: %v", id, err)
+		}
+	}
+	log.Printf("[engagement] Refreshed %d contacts (%d failed)", len(contactIDs)-failed, failed)
+}
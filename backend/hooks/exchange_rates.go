@@ -0,0 +1,235 @@
+package hooks
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"pocket-crm/services"
+)
+
+// ─── Multi-currency ──────────────────────────────────────────────────────────
+//
+// leads.value, invoices.amount/total and marketing_expenses.amount are stored
+// in their record's currency. exchange_rates holds the units of each currency
+// for 1 EUR per date (manual entry or import); stats convert every amount into
+// the reporting currency (REPORTING_CURRENCY, or ?currency= on the request)
+// at the rate of the amount's date: the latest rate on or before that date,
+// else the earliest known rate.
+
+// maxRatesImportSize caps the uploaded rates file (eurofxref-hist.xml is ~6 MB).
+const maxRatesImportSize = 20 << 20
+
+// currencyCollections are the collections whose amounts carry a currency code.
+var currencyCollections = []string{"leads", "invoices", "marketing_expenses", "products", "sales_quotas", "subscriptions"}
+
+// RegisterExchangeRateHooks defaults and validates the currency of leads,
+// invoices, expenses, products, quotas and subscriptions, normalises manual exchange_rates entries, and serves
+// POST /api/crm/exchange-rates/import (admin only).
+func RegisterExchangeRateHooks(app core.App) {
+	for _, name := range currencyCollections {
+		app.OnRecordCreate(name).BindFunc(validateRecordCurrency(app))
+		app.OnRecordUpdate(name).BindFunc(validateRecordCurrency(app))
+	}
+
+	normaliseRate := func(e *core.RecordEvent) error {
+		code := strings.ToUpper(strings.TrimSpace(e.Record.GetString("currency")))
+		e.Record.Set("currency", code)
+		if code == services.FxBaseCurrency {
+			return validation.Errors{
+				"currency": validation.NewError("validation_base_currency",
+					fmt.Sprintf("les taux sont exprimés pour 1 %s, inutile de saisir cette devise", services.FxBaseCurrency)),
+			}
+		}
+		if e.Record.GetFloat("rate") <= 0 {
+			return validation.Errors{
+				"rate": validation.NewError("validation_invalid_rate", "le taux doit être strictement positif"),
+			}
+		}
+		if e.Record.GetString("source") == "" {
+			e.Record.Set("source", "manuel")
+		}
+		return e.Next()
+	}
+	app.OnRecordCreate("exchange_rates").BindFunc(normaliseRate)
+	app.OnRecordUpdate("exchange_rates").BindFunc(normaliseRate)
+
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.POST("/api/crm/exchange-rates/import", buildImportExchangeRates(app)).Bind(apis.RequireAuth())
+		return se.Next()
+	})
+
+	log.Printf("[hooks] Exchange rate hooks registered (reporting currency %s, /api/crm/exchange-rates/import)", services.ReportingCurrency())
+}
+
+// validateRecordCurrency defaults an empty currency to the reporting currency
+// and rejects codes that have no exchange rate (so stats can convert them).
+func validateRecordCurrency(app core.App) func(*core.RecordEvent) error {
+	return func(e *core.RecordEvent) error {
+		code := strings.ToUpper(strings.TrimSpace(e.Record.GetString("currency")))
+		if code == "" {
+			code = services.ReportingCurrency()
+		}
+		e.Record.Set("currency", code)
+
+		if !e.Record.IsNew() && code == e.Record.Original().GetString("currency") {
+			return e.Next()
+		}
+		if !services.IsCurrencyCode(code) {
+			return validation.Errors{
+				"currency": validation.NewError("validation_invalid_currency", "code devise invalide (ex. EUR, CHF, GBP)"),
+			}
+		}
+		if !services.HasExchangeRate(app, code) {
+			return validation.Errors{
+				"currency": validation.NewError("validation_unknown_currency",
+					fmt.Sprintf("aucun taux de change connu pour %s", code)),
+			}
+		}
+		return e.Next()
+	}
+}
+
+// buildImportExchangeRates serves POST /api/crm/exchange-rates/import.
+// The file (ECB XML or CSV) is sent as the multipart "file" field or as the raw
+// request body. Rates already stored for the same currency and date are replaced.
+func buildImportExchangeRates(app core.App) func(*core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		if !e.HasSuperuserAuth() && e.Auth.GetString("role") != "admin" {
+			return e.ForbiddenError("Only admins can import exchange rates", nil)
+		}
+
+		var reader io.Reader = e.Request.Body
+		if file, _, err := e.Request.FormFile("file"); err == nil {
+			defer file.Close()
+			reader = file
+		}
+		data, err := io.ReadAll(io.LimitReader(reader, maxRatesImportSize+1))
+		if err != nil {
+			return e.BadRequestError("Failed to read the rates file", err)
+		}
+		if len(data) > maxRatesImportSize {
+			return e.BadRequestError("Rates file too large", nil)
+		}
+
+		rates, source, err := services.ParseExchangeRates(data)
+		if err != nil {
+			return e.BadRequestError("Invalid rates file: "+err.Error(), nil)
+		}
+
+		// Raw upsert: a full ECB history is ~200k rates, too many for one Save each
+		now := time.Now().UTC().Format("2006-01-02 15:04:05.000Z")
+		err = app.RunInTransaction(func(txApp core.App) error {
+			for _, r := range rates {
+				_, err := txApp.DB().NewQuery(`
+					INSERT INTO exchange_rates (id, currency, date, rate, source, created, updated)
+					VALUES ({:id}, {:currency}, {:date}, {:rate}, {:source}, {:now}, {:now})
+					ON CONFLICT (currency, date) DO UPDATE SET
+						rate = excluded.rate, source = excluded.source, updated = excluded.updated
+				`).Bind(dbx.Params{
+					"id":       core.GenerateDefaultRandomId(),
+					"currency": r.Currency,
+					"date":     r.Date + " 00:00:00.000Z",
+					"rate":     r.Rate,
+					"source":   source,
+					"now":      now,
+				}).Execute()
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return e.InternalServerError("Failed to store exchange rates", err)
+		}
+
+		currencies := map[string]bool{}
+		from, to := rates[0].Date, rates[0].Date
+		for _, r := range rates {
+			currencies[r.Currency] = true
+			if r.Date < from {
+				from = r.Date
+			}
+			if r.Date > to {
+				to = r.Date
+			}
+		}
+		codes := make([]string, 0, len(currencies))
+		for c := range currencies {
+			codes = append(codes, c)
+		}
+		sort.Strings(codes)
+
+		log.Printf("[fx] Imported %d exchange rates (%s, %s → %s)", len(rates), source, from, to)
+		return e.JSON(http.StatusOK, map[string]interface{}{
+			"imported":   len(rates),
+			"source":     source,
+			"currencies": codes,
+			"from":       from,
+			"to":         to,
+		})
+	}
+}
+
+// ─── Stats conversion ────────────────────────────────────────────────────────
+
+// statsCurrency returns the reporting currency of a stats request
+// (?currency=, default REPORTING_CURRENCY). Queries bind it as {:currency}.
+func statsCurrency(app core.App, e *core.RequestEvent) (string, error) {
+	code := strings.ToUpper(strings.TrimSpace(e.Request.URL.Query().Get("currency")))
+	if code == "" {
+		code = services.ReportingCurrency()
+	}
+	if !services.IsCurrencyCode(code) {
+		return "", fmt.Errorf("invalid currency %q", code)
+	}
+	if !services.HasExchangeRate(app, code) {
+		return "", fmt.Errorf("no exchange rate for %s", code)
+	}
+	return code, nil
+}
+
+// fxRateSQL is the SQL rate of currency (units for 1 EUR) at date.
+// Both arguments must be table-qualified column expressions.
+func fxRateSQL(currency, date string) string {
+	return `(CASE WHEN ` + currency + ` = '` + services.FxBaseCurrency + `' THEN 1.0 ELSE COALESCE(
+		(SELECT fx.rate FROM exchange_rates fx
+		 WHERE fx.currency = ` + currency + ` AND fx.date < date(` + date + `, '+1 day')
+		 ORDER BY fx.date DESC LIMIT 1),
+		(SELECT fx.rate FROM exchange_rates fx WHERE fx.currency = ` + currency + ` ORDER BY fx.date ASC LIMIT 1)
+	) END)`
+}
+
+// fxConvertSQL converts amount from currency into {:currency} at date.
+func fxConvertSQL(amount, currency, date string) string {
+	cur := `COALESCE(NULLIF(` + currency + `, ''), '` + services.FxBaseCurrency + `')`
+	return `(CASE WHEN ` + cur + ` = {:currency} THEN COALESCE(` + amount + `, 0)
+		ELSE COALESCE(` + amount + `, 0) * ` + fxRateSQL("{:currency}", date) + ` / ` + fxRateSQL(cur, date) + ` END)`
+}
+
+// leadValueSQL is a lead's value in the reporting currency, at the rate of its
+// closing date (today for open leads). t is the leads table name or alias.
+func leadValueSQL(t string) string {
+	return fxConvertSQL(t+".value", t+".currency", `COALESCE(NULLIF(`+t+`.closed_at, ''), datetime('now'))`)
+}
+
+// invoiceTotalSQL is an invoice total in the reporting currency, at the rate
+// of its payment date (else issue date, else creation).
+func invoiceTotalSQL(t string) string {
+	return fxConvertSQL(t+".total", t+".currency",
+		`COALESCE(NULLIF(`+t+`.paid_at, ''), NULLIF(`+t+`.issued_at, ''), `+t+`.created)`)
+}
+
+// expenseAmountSQL is a marketing expense in the reporting currency at its date.
+func expenseAmountSQL(t string) string {
+	return fxConvertSQL(t+".amount", t+".currency", t+".date")
+}
@@ -0,0 +1,138 @@
+package hooks
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/hook"
+)
+
+// idempotencyKeyTTL is how long a stored response can be replayed.
+const idempotencyKeyTTL = 24 * time.Hour
+
+// requireIdempotency honours the optional Idempotency-Key request header:
+//
+//   - first request with a key: the handler runs and its 2xx JSON response is stored
+//   - retry with the same key and body: the stored response is replayed, nothing is re-sent
+//   - retry while the first request is still running: 409 Conflict
+//   - same key reused with a different body: 422
+//
+// Failed requests (non-2xx) release the key so the client can retry.
+// Keys are scoped per user and route and expire after 24 h.
+func requireIdempotency(app core.App) *hook.Handler[*core.RequestEvent] {
+	return &hook.Handler[*core.RequestEvent]{
+		Func: func(e *core.RequestEvent) error {
+			key := e.Request.Header.Get("Idempotency-Key")
+			if key == "" || e.Auth == nil {
+				return e.Next()
+			}
+			if len(key) > 255 {
+				return e.BadRequestError("Idempotency-Key must not exceed 255 characters", nil)
+			}
+
+			rawBody, err := io.ReadAll(e.Request.Body)
+			if err != nil {
+				return e.BadRequestError("Failed to read request body", err)
+			}
+			e.Request.Body = io.NopCloser(bytes.NewReader(rawBody))
+			sum := sha256.Sum256(rawBody)
+			requestHash := hex.EncodeToString(sum[:])
+			route := e.Request.Method + " " + e.Request.URL.Path
+
+			purgeExpiredIdempotencyKeys(app)
+
+			existing, err := app.FindFirstRecordByFilter("idempotency_keys",
+				"key = {:key} && user_id = {:user} && route = {:route}",
+				dbx.Params{"key": key, "user": e.Auth.Id, "route": route},
+			)
+			if err == nil {
+				if existing.GetString("request_hash") != requestHash {
+					return e.Error(http.StatusUnprocessableEntity, "Idempotency-Key already used with a different request body", nil)
+				}
+				if existing.GetString("status") != "termine" {
+					return e.Error(http.StatusConflict, "A request with this Idempotency-Key is already in progress", nil)
+				}
+				e.Response.Header().Set("Idempotent-Replayed", "true")
+				return e.JSON(existing.GetInt("response_status"), existing.Get("response_body"))
+			}
+
+			col, err := app.FindCollectionByNameOrId("idempotency_keys")
+			if err != nil {
+				return e.InternalServerError("idempotency_keys collection not found", err)
+			}
+			rec := core.NewRecord(col)
+			rec.Set("key", key)
+			rec.Set("user_id", e.Auth.Id)
+			rec.Set("route", route)
+			rec.Set("request_hash", requestHash)
+			rec.Set("status", "en_cours")
+			if err := app.Save(rec); err != nil {
+				// Unique index violation: a concurrent request won the race
+				return e.Error(http.StatusConflict, "A request with this Idempotency-Key is already in progress", nil)
+			}
+
+			recorder := &responseRecorder{ResponseWriter: e.Response}
+			original := e.Response
+			e.Response = recorder
+			nextErr := e.Next()
+			e.Response = original
+
+			if nextErr != nil || recorder.status < 200 || recorder.status >= 300 {
+				if err := app.Delete(rec); err != nil {
+					log.Printf("[idempotency] failed to release key %s: %v", key, err)
+				}
+				return nextErr
+			}
+
+			rec.Set("status", "termine")
+			rec.Set("response_status", recorder.status)
+			if json.Valid(recorder.body.Bytes()) {
+				rec.Set("response_body", json.RawMessage(recorder.body.Bytes()))
+			}
+			if err := app.Save(rec); err != nil {
+				log.Printf("[idempotency] failed to store response for key %s: %v", key, err)
+			}
+			return nil
+		},
+	}
+}
+
+// purgeExpiredIdempotencyKeys deletes keys older than idempotencyKeyTTL.
+func purgeExpiredIdempotencyKeys(app core.App) {
+	cutoff := time.Now().UTC().Add(-idempotencyKeyTTL).Format("2006-01-02 15:04:05.000Z")
+	app.DB().NewQuery("DELETE FROM idempotency_keys WHERE created < {:cutoff}"). //nolint:errcheck
+											Bind(dbx.Params{"cutoff": cutoff}).Execute()
+}
+
+// responseRecorder tees the handler's response so it can be stored for replay.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
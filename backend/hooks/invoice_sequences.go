@@ -0,0 +1,225 @@
+package hooks
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// ─── Invoice numbering ───────────────────────────────────────────────────────
+//
+// Drafts have no number. When an invoice leaves brouillon (to any status but
+// annulee) it takes the next number of its sequence (the default one when
+// empty), e.g. FA-2026-00042. The counter is advanced in the same transaction
+// as the invoice write, so a failed save consumes no number and concurrent
+// issuances are serialized by SQLite. Once assigned, a number never changes,
+// the invoice cannot go back to brouillon nor be deleted (cancel it instead),
+// and idx_invoices_number rejects any duplicate.
+
+// defaultSequencePadding is the counter width of a sequence created without one.
+const defaultSequencePadding = 5
+
+// RegisterInvoiceSequenceHooks manages invoice_sequences and numbers invoices
+// on issuance.
+func RegisterInvoiceSequenceHooks(app core.App) {
+	// ── Sequences ─────────────────────────────────────────────────────────────
+	app.OnRecordCreate("invoice_sequences").BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.GetInt("padding") == 0 {
+			e.Record.Set("padding", defaultSequencePadding)
+		}
+		// last_number set on create continues a series of the current year
+		if e.Record.GetInt("last_year") == 0 {
+			e.Record.Set("last_year", time.Now().UTC().Year())
+		}
+		if defaultInvoiceSequence(app) == "" {
+			e.Record.Set("is_default", true)
+		}
+		return e.Next()
+	})
+
+	// The counter can be raised (to continue a series numbered elsewhere this
+	// year) but never lowered: that would reuse issued numbers.
+	app.OnRecordCreateRequest("invoice_sequences").BindFunc(func(e *core.RecordRequestEvent) error {
+		e.Record.Set("last_year", nil)
+		return e.Next()
+	})
+	app.OnRecordUpdateRequest("invoice_sequences").BindFunc(func(e *core.RecordRequestEvent) error {
+		original := e.Record.Original()
+		e.Record.Set("last_year", original.Get("last_year"))
+		switch last := e.Record.GetInt("last_number"); {
+		case last < original.GetInt("last_number"):
+			return e.BadRequestError("The counter of a sequence cannot be lowered", validation.Errors{
+				"last_number": validation.NewError("validation_counter_lowered",
+					"le compteur ne peut pas être diminué : des numéros déjà émis seraient réutilisés"),
+			})
+		case last > original.GetInt("last_number"):
+			e.Record.Set("last_year", time.Now().UTC().Year())
+		}
+		if !e.Record.GetBool("is_default") && e.Record.Original().GetBool("is_default") {
+			return e.BadRequestError("Choose another default sequence instead", validation.Errors{
+				"is_default": validation.NewError("validation_default_sequence",
+					"désignez une autre séquence par défaut à la place"),
+			})
+		}
+		return e.Next()
+	})
+	app.OnRecordDeleteRequest("invoice_sequences").BindFunc(func(e *core.RecordRequestEvent) error {
+		var used int
+		app.DB().NewQuery("SELECT COUNT(*) FROM invoices WHERE sequence = {:id}").
+			Bind(dbx.Params{"id": e.Record.Id}).Row(&used) //nolint:errcheck
+		if used > 0 || e.Record.GetInt("last_number") > 0 || e.Record.GetBool("is_default") {
+			return e.BadRequestError("This sequence has issued numbers or is the default one", nil)
+		}
+		return e.Next()
+	})
+
+	// One default sequence
+	unsetOtherDefaults := func(e *core.RecordEvent) error {
+		if e.Record.GetBool("is_default") {
+			if _, err := e.App.DB().NewQuery("UPDATE invoice_sequences SET is_default = FALSE WHERE id != {:id} AND is_default = TRUE").
+				Bind(dbx.Params{"id": e.Record.Id}).Execute(); err != nil {
+				log.Printf("[invoices] failed to unset default sequences: %v", err)
+			}
+		}
+		return e.Next()
+	}
+	app.OnRecordAfterCreateSuccess("invoice_sequences").BindFunc(unsetOtherDefaults)
+	app.OnRecordAfterUpdateSuccess("invoice_sequences").BindFunc(unsetOtherDefaults)
+
+	// ── Invoices: the number is never set through the API ─────────────────────
+	app.OnRecordCreateRequest("invoices").BindFunc(func(e *core.RecordRequestEvent) error {
+		e.Record.Set("number", "")
+		return e.Next()
+	})
+	app.OnRecordUpdateRequest("invoices").BindFunc(func(e *core.RecordRequestEvent) error {
+		e.Record.Set("number", e.Record.Original().GetString("number"))
+		return e.Next()
+	})
+	app.OnRecordDeleteRequest("invoices").BindFunc(func(e *core.RecordRequestEvent) error {
+		if e.Record.GetString("number") != "" {
+			return e.BadRequestError("Issued invoices cannot be deleted, cancel them instead", validation.Errors{
+				"number": validation.NewError("validation_invoice_issued",
+					"une facture numérotée ne peut pas être supprimée : annulez-la"),
+			})
+		}
+		return e.Next()
+	})
+
+	app.OnRecordUpdate("invoices").BindFunc(func(e *core.RecordEvent) error {
+		if err := validateInvoiceNumber(e.Record); err != nil {
+			return err
+		}
+		return e.Next()
+	})
+
+	app.OnRecordCreateExecute("invoices").BindFunc(assignInvoiceNumber)
+	app.OnRecordUpdateExecute("invoices").BindFunc(assignInvoiceNumber)
+
+	log.Println("[hooks] Invoice sequence hooks registered (numbering on issuance, locked numbers)")
+}
+
+// validateInvoiceNumber keeps the number of an issued invoice and forbids
+// turning it back into a draft.
+func validateInvoiceNumber(invoice *core.Record) error {
+	number := invoice.Original().GetString("number")
+	if number == "" {
+		return nil
+	}
+	if invoice.GetString("number") != number {
+		return validation.Errors{
+			"number": validation.NewError("validation_invoice_number_locked", "le numéro d'une facture émise ne peut pas changer"),
+		}
+	}
+	if invoice.GetString("status") == "brouillon" {
+		return validation.Errors{
+			"status": validation.NewError("validation_invoice_issued", "une facture émise ne peut pas redevenir un brouillon"),
+		}
+	}
+	return nil
+}
+
+// assignInvoiceNumber numbers an invoice leaving brouillon, inside the
+// transaction writing it.
+func assignInvoiceNumber(e *core.RecordEvent) error {
+	status := e.Record.GetString("status")
+	if e.Record.GetString("number") != "" || status == "" || status == "brouillon" || status == "annulee" {
+		return e.Next()
+	}
+
+	originalApp := e.App
+	defer func() { e.App = originalApp }()
+	return e.App.RunInTransaction(func(txApp core.App) error {
+		e.App = txApp
+
+		now := time.Now().UTC()
+		number, sequence, err := nextInvoiceNumber(txApp, e.Record.GetString("sequence"), now)
+		if err != nil {
+			return err
+		}
+		e.Record.Set("number", number)
+		e.Record.Set("sequence", sequence)
+		if e.Record.GetString("issued_at") == "" {
+			e.Record.Set("issued_at", now.Format("2006-01-02")+" 00:00:00.000Z")
+		}
+		if err := e.Next(); err != nil {
+			e.Record.Set("number", "") // rolled back with the counter
+			return err
+		}
+		return nil
+	})
+}
+
+// nextInvoiceNumber advances the counter of a sequence (the default one when
+// sequenceID is empty) and formats the number it yields. txApp must be
+// transactional so that the counter is rolled back with a failed save.
+func nextInvoiceNumber(txApp core.App, sequenceID string, now time.Time) (string, string, error) {
+	if sequenceID == "" {
+		sequenceID = defaultInvoiceSequence(txApp)
+		if sequenceID == "" {
+			return "", "", validation.Errors{
+				"sequence": validation.NewError("validation_no_invoice_sequence", "aucune séquence de numérotation par défaut"),
+			}
+		}
+	}
+
+	var seq struct {
+		Prefix      string `db:"prefix"`
+		ResetYearly bool   `db:"reset_yearly"`
+		Padding     int    `db:"padding"`
+		LastNumber  int    `db:"last_number"`
+	}
+	err := txApp.DB().NewQuery(`
+		UPDATE invoice_sequences SET
+			last_number = CASE WHEN reset_yearly AND last_year != {:year} THEN 1 ELSE last_number + 1 END,
+			last_year = {:year}
+		WHERE id = {:id}
+		RETURNING prefix, reset_yearly, padding, last_number
+	`).Bind(dbx.Params{"id": sequenceID, "year": now.Year()}).One(&seq)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", validation.Errors{
+			"sequence": validation.NewError("validation_invalid_sequence", "séquence de numérotation introuvable"),
+		}
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("invoice sequence %s: %w", sequenceID, err)
+	}
+
+	padding := max(seq.Padding, 1)
+	if seq.ResetYearly {
+		return fmt.Sprintf("%s%d-%0*d", seq.Prefix, now.Year(), padding, seq.LastNumber), sequenceID, nil
+	}
+	return fmt.Sprintf("%s%0*d", seq.Prefix, padding, seq.LastNumber), sequenceID, nil
+}
+
+// defaultInvoiceSequence returns the id of the default sequence ("" if none).
+func defaultInvoiceSequence(app core.App) string {
+	var id string
+	app.DB().NewQuery("SELECT id FROM invoice_sequences WHERE is_default = TRUE ORDER BY created LIMIT 1").Row(&id) //nolint:errcheck
+	return id
+}
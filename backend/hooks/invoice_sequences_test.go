@@ -0,0 +1,327 @@
+package hooks
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/hook"
+)
+
+// newInvoiceTestApp returns a test app with the invoice hooks and the user
+// owning the test invoices.
+func newInvoiceTestApp(t *testing.T) (core.App, *core.Record) {
+	t.Helper()
+	app := newTestApp(t, RegisterInvoiceHooks, RegisterInvoiceSequenceHooks)
+	owner := testRecord(t, app, "users", map[string]any{"email": "compta@acme.fr", "password": "compta123456", "name": "Compta", "role": "admin"})
+	return app, owner
+}
+
+// testInvoice returns an unsaved invoice of owner with one line of 100.
+func testInvoice(t *testing.T, app core.App, owner *core.Record, status string) *core.Record {
+	t.Helper()
+	col, err := app.FindCollectionByNameOrId("invoices")
+	if err != nil {
+		t.Fatal(err)
+	}
+	invoice := core.NewRecord(col)
+	invoice.Set("owner", owner.Id)
+	invoice.Set("status", status)
+	invoice.Set("tax_rate", 20)
+	invoice.Set("items", []map[string]any{{"description": "Conseil", "qty": 1, "unit_price": 100}})
+	return invoice
+}
+
+// triggerRecordRequest runs the request hooks of h on record as an API
+// request from a superuser would.
+func triggerRecordRequest(t *testing.T, app core.App, h *hook.TaggedHook[*core.RecordRequestEvent], record *core.Record) error {
+	t.Helper()
+	e := &core.RecordRequestEvent{RequestEvent: &core.RequestEvent{App: app}, Record: record}
+	e.Collection = record.Collection()
+	return h.Trigger(e, func(*core.RecordRequestEvent) error { return nil })
+}
+
+// hasErrorCode reports whether err is a validation error with code on field.
+func hasErrorCode(err error, field, code string) bool {
+	var errs validation.Errors
+	if !errors.As(err, &errs) {
+		return false
+	}
+	var fieldErr validation.Error
+	return errors.As(errs[field], &fieldErr) && fieldErr.Code() == code
+}
+
+func TestNextInvoiceNumber(t *testing.T) {
+	app, _ := newInvoiceTestApp(t)
+	now := time.Date(2026, 3, 14, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		prefix      string
+		resetYearly bool
+		padding     int
+		lastNumber  int
+		lastYear    int
+		want        string
+	}{
+		{"yearly series", "FA-", true, 5, 41, 2026, "FA-2026-00042"},
+		{"new year restarts at one", "FA-", true, 5, 41, 2025, "FA-2026-00001"},
+		{"continuous series", "INV", false, 3, 9, 2025, "INV010"},
+		{"counter wider than the padding", "F", false, 2, 99, 2026, "F100"},
+		{"no prefix", "", true, 1, 0, 2026, "2026-1"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seq := testRecord(t, app, "invoice_sequences", map[string]any{
+				"name": fmt.Sprint("Série ", i), "prefix": tt.prefix, "reset_yearly": tt.resetYearly,
+				"padding": tt.padding, "last_number": tt.lastNumber, "last_year": tt.lastYear,
+			})
+			var got string
+			err := app.RunInTransaction(func(txApp core.App) error {
+				var err error
+				got, _, err = nextInvoiceNumber(txApp, seq.Id, now)
+				return err
+			})
+			if err != nil {
+				t.Fatalf("nextInvoiceNumber() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("nextInvoiceNumber() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("unknown sequence", func(t *testing.T) {
+		_, _, err := nextInvoiceNumber(app, "missing", now)
+		if !hasErrorCode(err, "sequence", "validation_invalid_sequence") {
+			t.Errorf("nextInvoiceNumber(missing) error = %v, want validation_invalid_sequence", err)
+		}
+	})
+}
+
+func TestInvoiceNumberingSequential(t *testing.T) {
+	app, owner := newInvoiceTestApp(t)
+	// A save failing after the number is taken rolls the counter back
+	app.OnRecordCreateExecute("invoices").BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.GetString("notes") == "échec" {
+			return errors.New("write failed")
+		}
+		return e.Next()
+	})
+	year := time.Now().UTC().Year()
+	draft := testInvoice(t, app, owner, "brouillon")
+
+	tests := []struct {
+		name    string
+		save    func() (*core.Record, error)
+		want    string
+		wantErr bool
+	}{
+		{"draft has no number", func() (*core.Record, error) {
+			return draft, app.Save(draft)
+		}, "", false},
+		{"issued draft takes the first number", func() (*core.Record, error) {
+			draft.Set("status", "emise")
+			return draft, app.Save(draft)
+		}, fmt.Sprintf("FA-%d-00001", year), false},
+		{"invoice created issued takes the next one", func() (*core.Record, error) {
+			invoice := testInvoice(t, app, owner, "emise")
+			return invoice, app.Save(invoice)
+		}, fmt.Sprintf("FA-%d-00002", year), false},
+		{"cancelled draft has no number", func() (*core.Record, error) {
+			invoice := testInvoice(t, app, owner, "annulee")
+			return invoice, app.Save(invoice)
+		}, "", false},
+		{"failed save consumes no number", func() (*core.Record, error) {
+			invoice := testInvoice(t, app, owner, "emise")
+			invoice.Set("notes", "échec")
+			return invoice, app.Save(invoice)
+		}, "", true},
+		{"resaved issued invoice keeps its number", func() (*core.Record, error) {
+			draft.Set("status", "payee")
+			return draft, app.Save(draft)
+		}, fmt.Sprintf("FA-%d-00001", year), false},
+		{"paid invoice takes the next number", func() (*core.Record, error) {
+			invoice := testInvoice(t, app, owner, "payee")
+			return invoice, app.Save(invoice)
+		}, fmt.Sprintf("FA-%d-00003", year), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invoice, err := tt.save()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("save error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := invoice.GetString("number"); got != tt.want {
+				t.Errorf("number = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvoiceNumberingConcurrent(t *testing.T) {
+	app, owner := newInvoiceTestApp(t)
+	const n = 20
+
+	drafts := make([]*core.Record, n)
+	for i := range drafts {
+		drafts[i] = testInvoice(t, app, owner, "brouillon")
+		if err := app.Save(drafts[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i, draft := range drafts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			draft.Set("status", "emise")
+			errs[i] = app.Save(draft)
+		}()
+	}
+	wg.Wait()
+
+	year := time.Now().UTC().Year()
+	got := make([]string, 0, n)
+	for i, draft := range drafts {
+		if errs[i] != nil {
+			t.Fatalf("issue invoice %d: %v", i, errs[i])
+		}
+		got = append(got, draft.GetString("number"))
+	}
+	slices.Sort(got)
+	for i, number := range got {
+		// Every number once, no gap
+		if want := fmt.Sprintf("FA-%d-%05d", year, i+1); number != want {
+			t.Errorf("numbers[%d] = %q, want %q (got %v)", i, number, want, got)
+			break
+		}
+	}
+
+	seq, err := app.FindRecordById("invoice_sequences", defaultInvoiceSequence(app))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := seq.GetInt("last_number"); last != n {
+		t.Errorf("last_number = %d, want %d", last, n)
+	}
+}
+
+func TestIssuedInvoiceNumberLocked(t *testing.T) {
+	app, owner := newInvoiceTestApp(t)
+
+	tests := []struct {
+		name     string
+		edit     func(invoice *core.Record)
+		field    string
+		wantCode string
+	}{
+		{"number changed", func(r *core.Record) { r.Set("number", "FA-0001") }, "number", "validation_invoice_number_locked"},
+		{"number cleared", func(r *core.Record) { r.Set("number", "") }, "number", "validation_invoice_number_locked"},
+		{"back to draft", func(r *core.Record) { r.Set("status", "brouillon") }, "status", "validation_invoice_issued"},
+		{"paid", func(r *core.Record) { r.Set("status", "payee") }, "", ""},
+		{"cancelled", func(r *core.Record) { r.Set("status", "annulee") }, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invoice := testInvoice(t, app, owner, "emise")
+			if err := app.Save(invoice); err != nil {
+				t.Fatal(err)
+			}
+			issued, err := app.FindRecordById("invoices", invoice.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			number := issued.GetString("number")
+			tt.edit(issued)
+			err = app.Save(issued)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Fatalf("save error = %v, want nil", err)
+				}
+			} else if !hasErrorCode(err, tt.field, tt.wantCode) {
+				t.Fatalf("save error = %v, want %s on %s", err, tt.wantCode, tt.field)
+			}
+
+			stored, err := app.FindRecordById("invoices", invoice.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := stored.GetString("number"); got != number {
+				t.Errorf("stored number = %q, want %q", got, number)
+			}
+		})
+	}
+}
+
+func TestInvoiceDeleteRequest(t *testing.T) {
+	app, owner := newInvoiceTestApp(t)
+
+	tests := []struct {
+		name    string
+		status  string
+		wantErr bool
+	}{
+		{"draft", "brouillon", false},
+		{"cancelled draft", "annulee", false},
+		{"issued", "emise", true},
+		{"paid", "payee", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invoice := testInvoice(t, app, owner, tt.status)
+			if err := app.Save(invoice); err != nil {
+				t.Fatal(err)
+			}
+			err := triggerRecordRequest(t, app, app.OnRecordDeleteRequest(), invoice)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("delete request of a %s invoice error = %v, wantErr %v", tt.status, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInvoiceSequenceUpdateRequest(t *testing.T) {
+	app, _ := newInvoiceTestApp(t)
+	year := time.Now().UTC().Year()
+
+	tests := []struct {
+		name         string
+		lastNumber   int
+		wantErr      bool
+		wantLastYear int
+	}{
+		{"lowered", 41, true, 0},
+		{"unchanged", 42, false, year - 1},
+		{"raised to continue a series", 100, false, year},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seq := testRecord(t, app, "invoice_sequences", map[string]any{
+				"name": fmt.Sprint("Série ", i), "prefix": "F-", "last_number": 42, "last_year": year - 1,
+			})
+			seq, err := app.FindRecordById("invoice_sequences", seq.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			seq.Set("last_number", tt.lastNumber)
+			err = triggerRecordRequest(t, app, app.OnRecordUpdateRequest(), seq)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("update request error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := seq.GetInt("last_year"); got != tt.wantLastYear {
+				t.Errorf("last_year = %d, want %d", got, tt.wantLastYear)
+			}
+		})
+	}
+}
@@ -2,16 +2,27 @@ package hooks
 
 import (
 	"log"
+	"reflect"
 	"time"
 
+	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/pocketbase/pocketbase/core"
+	"pocket-crm/services"
 )
 
 // RegisterInvoiceHooks attaches lifecycle hooks to the invoices collection.
 //
-// Hook 1 — Auto-calculate total TTC on create/update:
+// Hook 1 — Validate the lines and compute the totals on create/update:
 //
-//	total = amount * (1 + tax_rate / 100)
+//	line total = qty * unit_price * (1 - discount / 100), rounded to the cent
+//	amount     = sum of line totals (excl. tax)
+//	taxes      = per tax rate: base and tax rounded once on the summed base
+//	total      = amount + tax_total
+//
+// A line without tax_rate takes the invoice tax_rate (default rate); a line
+// keeps its own tax_rate only when it differs from the default. Once issued
+// (numbered or out of brouillon), the lines and tax rate cannot change and the
+// totals stay as issued.
 //
 // Hook 2 — Mark overdue invoices:
 //
@@ -21,7 +32,9 @@ func RegisterInvoiceHooks(app core.App) {
 	// HOOK 1 & 2 — Before create
 	// ----------------------------------------------------------------
 	app.OnRecordCreate("invoices").BindFunc(func(e *core.RecordEvent) error {
-		calcTotal(e.Record)
+		if err := priceInvoice(e.Record); err != nil {
+			return err
+		}
 		checkOverdue(e.Record)
 		return e.Next()
 	})
@@ -30,20 +43,137 @@ func RegisterInvoiceHooks(app core.App) {
 	// HOOK 1 & 2 — Before update
 	// ----------------------------------------------------------------
 	app.OnRecordUpdate("invoices").BindFunc(func(e *core.RecordEvent) error {
-		calcTotal(e.Record)
+		if invoiceIssued(e.Record.Original()) {
+			if err := lockIssuedInvoice(e.Record); err != nil {
+				return err
+			}
+		} else if err := priceInvoice(e.Record); err != nil {
+			return err
+		}
 		checkOverdue(e.Record)
 		return e.Next()
 	})
 
-	log.Println("[hooks] Invoice hooks registered (auto-total, overdue check)")
+	log.Println("[hooks] Invoice hooks registered (line totals, per-rate tax, overdue check)")
+}
+
+// invoiceLine is an entry of invoices.items: tax_rate is optional and
+// defaults to the invoice rate.
+type invoiceLine struct {
+	services.LineItem
+	TaxRate *float64 `json:"tax_rate,omitempty"`
+}
+
+// invoiceItems decodes the lines of an invoice, applying its default rate.
+func invoiceItems(invoice *core.Record) ([]services.LineItem, error) {
+	var lines []invoiceLine
+	if raw := invoice.GetString("items"); raw != "" && raw != "null" {
+		if err := invoice.UnmarshalJSONField("items", &lines); err != nil {
+			return nil, validation.Errors{"items": validation.NewError("validation_invalid_items", "format des lignes invalide")}
+		}
+	}
+	items := make([]services.LineItem, 0, len(lines))
+	for _, line := range lines {
+		item := line.LineItem
+		item.TaxRate = invoice.GetFloat("tax_rate")
+		if line.TaxRate != nil {
+			item.TaxRate = *line.TaxRate
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// priceInvoice validates the lines and stores them with the invoice totals;
+// an invoice leaving brouillon needs at least one line.
+func priceInvoice(invoice *core.Record) error {
+	items, err := invoiceItems(invoice)
+	if err != nil {
+		return err
+	}
+	if err := services.ValidateLineItems(items); err != nil {
+		return validation.Errors{"items": validation.NewError("validation_invalid_items", err.Error())}
+	}
+	if status := invoice.GetString("status"); len(items) == 0 && status != "brouillon" && status != "annulee" {
+		return validation.Errors{"items": validation.NewError("validation_invoice_no_items", "une facture émise doit avoir au moins une ligne")}
+	}
+	totals := services.ComputeLineTotals(items)
+	invoice.Set("items", invoiceLines(items, invoice.GetFloat("tax_rate")))
+	invoice.Set("amount", totals.Subtotal)
+	invoice.Set("taxes", totals.Taxes)
+	invoice.Set("tax_total", totals.TaxTotal)
+	invoice.Set("total", totals.Total)
+	return nil
+}
+
+// invoiceIssued reports whether a stored invoice has been issued: numbered
+// or out of brouillon (a blank record is a new one).
+func invoiceIssued(stored *core.Record) bool {
+	if stored.Id == "" {
+		return false
+	}
+	return stored.GetString("number") != "" || stored.GetString("status") != "brouillon"
+}
+
+// lockIssuedInvoice rejects a change of the lines or tax rate of an issued
+// invoice and keeps its totals as issued: those of invoices issued before
+// line items may not reconcile with their lines (see migration 0026).
+func lockIssuedInvoice(invoice *core.Record) error {
+	original := invoice.Original()
+	if invoice.GetFloat("tax_rate") != original.GetFloat("tax_rate") {
+		return validation.Errors{
+			"tax_rate": validation.NewError("validation_invoice_issued", "le taux de TVA d'une facture émise ne peut pas changer"),
+		}
+	}
+	items, err := invoiceItems(invoice)
+	if err != nil {
+		return err
+	}
+	issued, err := invoiceItems(original)
+	if err != nil || !reflect.DeepEqual(linesOf(items), linesOf(issued)) {
+		return validation.Errors{
+			"items": validation.NewError("validation_invoice_issued", "les lignes d'une facture émise ne peuvent pas changer"),
+		}
+	}
+	for _, field := range []string{"items", "amount", "taxes", "tax_total", "total"} {
+		invoice.Set(field, original.Get(field))
+	}
+	return nil
 }
 
-// calcTotal computes total TTC from amount and tax_rate.
-func calcTotal(record *core.Record) {
-	amount := record.GetFloat("amount")
-	taxRate := record.GetFloat("tax_rate")
-	total := amount * (1 + taxRate/100)
-	record.Set("total", total)
+// linesOf returns items with their line totals computed, for comparison.
+func linesOf(items []services.LineItem) []services.LineItem {
+	services.ComputeLineTotals(items)
+	return items
+}
+
+// invoiceLines returns the lines to store: only the rates overriding the
+// default one are kept, so that the lines follow a change of the default.
+func invoiceLines(items []services.LineItem, defaultRate float64) []invoiceLine {
+	lines := make([]invoiceLine, 0, len(items))
+	for _, item := range items {
+		line := invoiceLine{LineItem: item}
+		if item.TaxRate != defaultRate {
+			rate := item.TaxRate
+			line.TaxRate = &rate
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// commonTaxRate returns the rate shared by all lines (0 when they differ),
+// stored as the default rate of generated invoices.
+func commonTaxRate(items []services.LineItem) float64 {
+	if len(items) == 0 {
+		return 0
+	}
+	for _, it := range items[1:] {
+		if it.TaxRate != items[0].TaxRate {
+			return 0
+		}
+	}
+	return items[0].TaxRate
 }
 
 // checkOverdue transitions "emise" invoices past their due_at to "en_retard".
@@ -0,0 +1,241 @@
+package hooks
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"pocket-crm/services"
+)
+
+func TestInvoiceLines(t *testing.T) {
+	item := func(rate float64) services.LineItem {
+		return services.LineItem{Description: "Conseil", Qty: 1, UnitPrice: 100, TaxRate: rate}
+	}
+	tests := []struct {
+		name        string
+		items       []services.LineItem
+		defaultRate float64
+		want        []string
+	}{
+		{"default rate dropped", []services.LineItem{item(20)}, 20, []string{""}},
+		{"overriding rate kept", []services.LineItem{item(20), item(5.5)}, 20, []string{"", "5.5"}},
+		{"zero rate overriding the default", []services.LineItem{item(0)}, 20, []string{"0"}},
+		{"no lines", nil, 20, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := lineRates(invoiceLines(tt.items, tt.defaultRate))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("invoiceLines() rates = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// lineRates returns the stored rate of each line, "" for the default one.
+func lineRates(lines []invoiceLine) []string {
+	rates := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if line.TaxRate == nil {
+			rates = append(rates, "")
+		} else {
+			rates = append(rates, fmt.Sprint(*line.TaxRate))
+		}
+	}
+	return rates
+}
+
+func TestPriceInvoice(t *testing.T) {
+	app, owner := newInvoiceTestApp(t)
+
+	tests := []struct {
+		name      string
+		status    string
+		items     []map[string]any
+		wantCode  string
+		wantRates []string
+		wantTax   float64
+		wantTotal float64
+	}{
+		{
+			name:      "default rate",
+			status:    "brouillon",
+			items:     []map[string]any{{"description": "Conseil", "qty": 2, "unit_price": 150}},
+			wantRates: []string{""},
+			wantTax:   60, wantTotal: 360,
+		},
+		{
+			name:      "discount rounded to the cent",
+			status:    "brouillon",
+			items:     []map[string]any{{"description": "Licence", "qty": 3, "unit_price": 33.33, "discount": 10}},
+			wantRates: []string{""},
+			wantTax:   18, wantTotal: 107.99,
+		},
+		{
+			name:   "tax per rate",
+			status: "emise",
+			items: []map[string]any{
+				{"description": "Conseil", "qty": 1, "unit_price": 100},
+				{"description": "Livre", "qty": 1, "unit_price": 50, "tax_rate": 5.5},
+				{"description": "Formation", "qty": 1, "unit_price": 10, "tax_rate": 20},
+			},
+			wantRates: []string{"", "5.5", ""},
+			wantTax:   24.75, wantTotal: 184.75,
+		},
+		{name: "draft without lines", status: "brouillon", wantRates: []string{}},
+		{name: "issued without lines", status: "emise", wantCode: "validation_invoice_no_items"},
+		{
+			name:     "invalid line",
+			status:   "brouillon",
+			items:    []map[string]any{{"description": "Conseil", "qty": 0, "unit_price": 100}},
+			wantCode: "validation_invalid_items",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invoice := testInvoice(t, app, owner, tt.status)
+			invoice.Set("items", tt.items)
+			err := app.Save(invoice)
+			if tt.wantCode != "" {
+				if !hasErrorCode(err, "items", tt.wantCode) {
+					t.Errorf("save error = %v, want %s", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("save error = %v", err)
+			}
+
+			stored, err := app.FindRecordById("invoices", invoice.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var lines []invoiceLine
+			if err := stored.UnmarshalJSONField("items", &lines); err != nil {
+				t.Fatal(err)
+			}
+			if got := lineRates(lines); !reflect.DeepEqual(got, tt.wantRates) {
+				t.Errorf("stored rates = %q, want %q", got, tt.wantRates)
+			}
+			if got := stored.GetFloat("tax_total"); got != tt.wantTax {
+				t.Errorf("tax_total = %v, want %v", got, tt.wantTax)
+			}
+			if got := stored.GetFloat("total"); got != tt.wantTotal {
+				t.Errorf("total = %v, want %v", got, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestIssuedInvoiceEdits(t *testing.T) {
+	app, owner := newInvoiceTestApp(t)
+
+	tests := []struct {
+		name       string
+		edit       func(invoice *core.Record)
+		field      string
+		wantCode   string
+		wantAmount float64
+		wantTotal  float64
+	}{
+		{
+			name: "line price changed",
+			edit: func(r *core.Record) {
+				r.Set("items", []map[string]any{{"description": "Conseil", "qty": 1, "unit_price": 200}})
+			},
+			field: "items", wantCode: "validation_invoice_issued",
+		},
+		{
+			name: "line added",
+			edit: func(r *core.Record) {
+				r.Set("items", []map[string]any{
+					{"description": "Conseil", "qty": 1, "unit_price": 100},
+					{"description": "Frais", "qty": 1, "unit_price": 10},
+				})
+			},
+			field: "items", wantCode: "validation_invoice_issued",
+		},
+		{
+			name:  "tax rate changed",
+			edit:  func(r *core.Record) { r.Set("tax_rate", 10) },
+			field: "tax_rate", wantCode: "validation_invoice_issued",
+		},
+		{
+			name:       "totals patched",
+			edit:       func(r *core.Record) { r.Set("amount", 1); r.Set("total", 1.2) },
+			wantAmount: 100, wantTotal: 120,
+		},
+		{
+			name: "default rate written on the line",
+			edit: func(r *core.Record) {
+				r.Set("items", []map[string]any{{"description": "Conseil", "qty": 1, "unit_price": 100, "tax_rate": 20}})
+			},
+			wantAmount: 100, wantTotal: 120,
+		},
+		{
+			name:       "paid",
+			edit:       func(r *core.Record) { r.Set("status", "payee") },
+			wantAmount: 100, wantTotal: 120,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invoice := testInvoice(t, app, owner, "emise")
+			if err := app.Save(invoice); err != nil {
+				t.Fatal(err)
+			}
+			issued, err := app.FindRecordById("invoices", invoice.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.edit(issued)
+			err = app.Save(issued)
+			if tt.wantCode != "" {
+				if !hasErrorCode(err, tt.field, tt.wantCode) {
+					t.Errorf("save error = %v, want %s on %s", err, tt.wantCode, tt.field)
+				}
+				tt.wantAmount, tt.wantTotal = 100, 120
+			} else if err != nil {
+				t.Fatalf("save error = %v", err)
+			}
+
+			stored, err := app.FindRecordById("invoices", invoice.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if amount, total := stored.GetFloat("amount"), stored.GetFloat("total"); amount != tt.wantAmount || total != tt.wantTotal {
+				t.Errorf("stored amount, total = %v, %v, want %v, %v", amount, total, tt.wantAmount, tt.wantTotal)
+			}
+		})
+	}
+
+	// An invoice issued before line items keeps totals its lines do not add
+	// up to (see migration 0026)
+	t.Run("issued totals not reconciling with the lines", func(t *testing.T) {
+		invoice := testInvoice(t, app, owner, "emise")
+		if err := app.Save(invoice); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := app.DB().Update("invoices", dbx.Params{"amount": 90, "total": 108}, dbx.HashExp{"id": invoice.Id}).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		issued, err := app.FindRecordById("invoices", invoice.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		issued.Set("status", "payee")
+		if err := app.Save(issued); err != nil {
+			t.Fatalf("save error = %v", err)
+		}
+		stored, err := app.FindRecordById("invoices", invoice.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if amount, total := stored.GetFloat("amount"), stored.GetFloat("total"); amount != 90 || total != 108 {
+			t.Errorf("stored amount, total = %v, %v, want 90, 108", amount, total)
+		}
+	})
+}
@@ -0,0 +1,207 @@
+package hooks
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// ─── Automatic lead assignment ───────────────────────────────────────────────
+//
+// Leads created without an owner get one in the leads create hook:
+//
+//  1. the first active lead_assignment_rules record (by order) matching the
+//     lead's source, region (company city/country) or company size, with an
+//     available assignee, picks among its assignees
+//  2. otherwise every commercial is a candidate
+//
+// Candidates that are out of office or at capacity (open leads ≥ lead_capacity)
+// are skipped. LEAD_ASSIGNMENT_MODE chooses among the remaining ones:
+//
+//	round_robin (default) : the one assigned least recently
+//	weighted              : the lowest (leads assigned over 30 days) / assignment_weight
+//	off                   : no automatic assignment
+
+const (
+	assignmentRoundRobin = "round_robin"
+	assignmentWeighted   = "weighted"
+	assignmentOff        = "off"
+
+	weightedWindowDays = 30
+)
+
+// leadAssignment is the outcome of assignLeadOwner.
+type leadAssignment struct {
+	Owner  *core.Record
+	Reason string
+}
+
+func leadAssignmentMode() string {
+	switch mode := strings.TrimSpace(os.Getenv("LEAD_ASSIGNMENT_MODE")); mode {
+	case assignmentWeighted, assignmentOff:
+		return mode
+	default:
+		return assignmentRoundRobin
+	}
+}
+
+// assignLeadOwner sets the owner of an unsaved lead and returns the decision,
+// or nil when automatic assignment is off or nobody is available.
+func assignLeadOwner(app core.App, lead *core.Record, now time.Time) (*leadAssignment, error) {
+	mode := leadAssignmentMode()
+	if mode == assignmentOff {
+		return nil, nil
+	}
+
+	rules, err := app.FindRecordsByFilter("lead_assignment_rules", "active = true", "order", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	facts := leadAssignmentFacts(app, lead)
+	for _, rule := range rules {
+		if !assignmentRuleMatches(rule, facts) {
+			continue
+		}
+		pool, err := app.FindRecordsByIds("users", rule.GetStringSlice("assignees"))
+		if err != nil {
+			return nil, err
+		}
+		if owner := pickAssignee(app, availableAssignees(app, pool, now), mode, now); owner != nil {
+			lead.Set("owner", owner.Id)
+			return &leadAssignment{owner, fmt.Sprintf("règle « %s »", rule.GetString("name"))}, nil
+		}
+	}
+
+	pool, err := app.FindAllRecords("users", dbx.HashExp{"role": "commercial"})
+	if err != nil {
+		return nil, err
+	}
+	owner := pickAssignee(app, availableAssignees(app, pool, now), mode, now)
+	if owner == nil {
+		return nil, nil
+	}
+	lead.Set("owner", owner.Id)
+	reason := "round-robin"
+	if mode == assignmentWeighted {
+		reason = "répartition pondérée"
+	}
+	return &leadAssignment{owner, reason}, nil
+}
+
+// leadAssignmentFacts collects the lowercased values rules are matched against.
+func leadAssignmentFacts(app core.App, lead *core.Record) map[string][]string {
+	facts := map[string][]string{
+		"source": {strings.ToLower(lead.GetString("source"))},
+	}
+	if companyID := lead.GetString("company"); companyID != "" {
+		if company, err := app.FindRecordById("companies", companyID); err == nil {
+			facts["company_size"] = []string{strings.ToLower(company.GetString("size"))}
+			facts["region"] = []string{
+				strings.ToLower(company.GetString("city")),
+				strings.ToLower(company.GetString("country")),
+			}
+		}
+	}
+	return facts
+}
+
+func assignmentRuleMatches(rule *core.Record, facts map[string][]string) bool {
+	expected := strings.ToLower(strings.TrimSpace(rule.GetString("value")))
+	for _, v := range facts[rule.GetString("criterion")] {
+		if v != "" && v == expected {
+			return true
+		}
+	}
+	return false
+}
+
+// availableAssignees drops users who are out of office or at capacity.
+func availableAssignees(app core.App, users []*core.Record, now time.Time) []*core.Record {
+	available := make([]*core.Record, 0, len(users))
+	for _, u := range users {
+		if isOutOfOffice(u, now) {
+			continue
+		}
+		if capacity := u.GetInt("lead_capacity"); capacity > 0 && countOpenLeads(app, u.Id) >= capacity {
+			continue
+		}
+		available = append(available, u)
+	}
+	return available
+}
+
+func isOutOfOffice(user *core.Record, now time.Time) bool {
+	if !user.GetBool("out_of_office") {
+		return false
+	}
+	until := user.GetDateTime("out_of_office_until")
+	return until.IsZero() || until.Time().After(now)
+}
+
+func countOpenLeads(app core.App, userID string) int {
+	var count int
+	app.DB().NewQuery(`
+		SELECT COUNT(*) FROM leads
+		WHERE owner = {:owner}
+		  AND stage IN (SELECT id FROM pipeline_stages WHERE is_won = FALSE AND is_lost = FALSE)
+	`).Bind(dbx.Params{"owner": userID}).Row(&count) //nolint:errcheck
+	return count
+}
+
+// pickAssignee chooses one candidate according to the assignment mode.
+func pickAssignee(app core.App, candidates []*core.Record, mode string, now time.Time) *core.Record {
+	if len(candidates) == 0 {
+		return nil
+	}
+	// Least recently assigned first (never assigned = first); ties by id for stability
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i].GetString("last_assigned_at"), candidates[j].GetString("last_assigned_at")
+		if a != b {
+			return a < b
+		}
+		return candidates[i].Id < candidates[j].Id
+	})
+	if mode != assignmentWeighted {
+		return candidates[0]
+	}
+
+	since := now.AddDate(0, 0, -weightedWindowDays).Format("2006-01-02 15:04:05.000Z")
+	var best *core.Record
+	bestLoad := 0.0
+	for _, u := range candidates {
+		var recent int
+		app.DB().NewQuery(`SELECT COUNT(*) FROM leads WHERE owner = {:owner} AND created >= {:since}`). //nolint:errcheck
+														Bind(dbx.Params{"owner": u.Id, "since": since}).Row(&recent)
+		weight := u.GetFloat("assignment_weight")
+		if weight <= 0 {
+			weight = 1
+		}
+		if load := float64(recent) / weight; best == nil || load < bestLoad {
+			best, bestLoad = u, load
+		}
+	}
+	return best
+}
+
+// completeLeadAssignment runs after the lead is saved: it stamps the owner's
+// last_assigned_at, logs an "attribution" activity and queues the owner's
+// notification, sent once the save is committed (see notifyLeadOwner).
+func completeLeadAssignment(app core.App, lead *core.Record, assignment *leadAssignment) {
+	owner := assignment.Owner
+	owner.Set("last_assigned_at", time.Now().UTC().Format("2006-01-02 15:04:05.000Z"))
+	if err := app.Save(owner); err != nil {
+		log.Printf("[leads] failed to stamp last_assigned_at on %s: %v", owner.Id, err)
+	}
+
+	createLeadActivity(app, lead, "attribution",
+		fmt.Sprintf("Opportunité \"%s\" attribuée automatiquement à %s (%s)",
+			lead.GetString("title"), owner.GetString("name"), assignment.Reason),
+	)
+	lead.Set(ownerNoticeKey, owner.Id)
+}
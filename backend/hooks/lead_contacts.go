@@ -0,0 +1,176 @@
+package hooks
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// ─── Lead contacts & buying roles ────────────────────────────────────────────
+//
+// lead_contacts links a lead to every contact involved in the deal, with its
+// buying role. leads.contact stays the main contact and always has a row
+// (added by the lead hooks); activities of the lead list all these contacts
+// and emails can be sent to them by role (see buildSendEmail).
+
+// buyingRoleLabels names the lead_contacts roles in activity descriptions.
+var buyingRoleLabels = map[string]string{
+	"decideur":    "décideur",
+	"influenceur": "influenceur",
+	"utilisateur": "utilisateur",
+	"achats":      "achats",
+}
+
+// RegisterLeadContactHooks validates lead_contacts and logs their changes on
+// the lead.
+func RegisterLeadContactHooks(app core.App) {
+	unique := func(e *core.RecordEvent) error {
+		if err := validateLeadContact(e.App, e.Record); err != nil {
+			return err
+		}
+		return e.Next()
+	}
+	app.OnRecordCreate("lead_contacts").BindFunc(unique)
+	app.OnRecordUpdate("lead_contacts").BindFunc(unique)
+
+	// Activities are logged for API changes only: the lead hooks add the main
+	// contact themselves and already log the lead creation.
+	app.OnRecordCreateRequest("lead_contacts").BindFunc(func(e *core.RecordRequestEvent) error {
+		if err := e.Next(); err != nil {
+			return err
+		}
+		logLeadContactActivity(app, e.Record, "ajouté à l'opportunité")
+		return nil
+	})
+	app.OnRecordUpdateRequest("lead_contacts").BindFunc(func(e *core.RecordRequestEvent) error {
+		oldRole := e.Record.Original().GetString("role")
+		if err := e.Next(); err != nil {
+			return err
+		}
+		if e.Record.GetString("role") != oldRole {
+			logLeadContactActivity(app, e.Record, "change de rôle")
+		}
+		return nil
+	})
+	app.OnRecordDeleteRequest("lead_contacts").BindFunc(func(e *core.RecordRequestEvent) error {
+		if lead, err := app.FindRecordById("leads", e.Record.GetString("lead")); err == nil &&
+			lead.GetString("contact") == e.Record.GetString("contact") {
+			return e.BadRequestError("The main contact of the lead cannot be removed", validation.Errors{
+				"contact": validation.NewError("validation_main_contact",
+					"c'est le contact principal de l'opportunité : changez-le d'abord"),
+			})
+		}
+		if err := e.Next(); err != nil {
+			return err
+		}
+		logLeadContactActivity(app, e.Record, "retiré de l'opportunité")
+		return nil
+	})
+
+	log.Println("[hooks] Lead contact hooks registered (buying roles, one row per lead and contact)")
+}
+
+// validateLeadContact rejects a second row for the same lead and contact.
+func validateLeadContact(app core.App, rec *core.Record) error {
+	var count int
+	app.DB().NewQuery(`
+		SELECT COUNT(*) FROM lead_contacts WHERE lead = {:lead} AND contact = {:contact} AND id != {:id}
+	`).Bind(dbx.Params{"lead": rec.GetString("lead"), "contact": rec.GetString("contact"), "id": rec.Id}).Row(&count) //nolint:errcheck
+	if count > 0 {
+		return validation.Errors{
+			"contact": validation.NewError("validation_lead_contact_exists", "ce contact est déjà lié à l'opportunité"),
+		}
+	}
+	return nil
+}
+
+// logLeadContactActivity records a "modification" activity on the lead of rec.
+func logLeadContactActivity(app core.App, rec *core.Record, what string) {
+	lead, err := app.FindRecordById("leads", rec.GetString("lead"))
+	if err != nil {
+		return
+	}
+	name := rec.GetString("contact")
+	if contact, err := app.FindRecordById("contacts", rec.GetString("contact")); err == nil {
+		name = recordLabel(contact)
+	}
+	desc := fmt.Sprintf("Contact « %s » %s", name, what)
+	if role := buyingRoleLabels[rec.GetString("role")]; role != "" {
+		desc += fmt.Sprintf(" (%s)", role)
+	}
+	createLeadActivity(app, lead, "modification", desc)
+}
+
+// ensureLeadContact adds the main contact of the lead to lead_contacts when
+// missing (best-effort).
+func ensureLeadContact(app core.App, lead *core.Record) {
+	contactID := lead.GetString("contact")
+	if contactID == "" {
+		return
+	}
+	var count int
+	app.DB().NewQuery(`SELECT COUNT(*) FROM lead_contacts WHERE lead = {:lead} AND contact = {:contact}`).
+		Bind(dbx.Params{"lead": lead.Id, "contact": contactID}).Row(&count) //nolint:errcheck
+	if count > 0 {
+		return
+	}
+	col, err := app.FindCollectionByNameOrId("lead_contacts")
+	if err != nil {
+		log.Printf("[leads] lead_contacts collection not found: %v", err)
+		return
+	}
+	rec := core.NewRecord(col)
+	rec.Set("lead", lead.Id)
+	rec.Set("contact", contactID)
+	if err := app.Save(rec); err != nil {
+		log.Printf("[leads] failed to link contact %s to lead %s: %v", contactID, lead.Id, err)
+	}
+}
+
+// leadContactIDs returns the contacts involved in a lead, main contact first.
+func leadContactIDs(app core.App, lead *core.Record) []string {
+	ids := make([]string, 0)
+	if main := lead.GetString("contact"); main != "" {
+		ids = append(ids, main)
+	}
+	others := make([]string, 0)
+	app.DB().NewQuery(`
+		SELECT contact FROM lead_contacts WHERE lead = {:lead} AND contact != {:main} ORDER BY created
+	`).Bind(dbx.Params{"lead": lead.Id, "main": lead.GetString("contact")}).Column(&others) //nolint:errcheck
+	return append(ids, others...)
+}
+
+// leadContactsWithRoles loads the contacts of a lead having one of roles
+// (all of them when roles is empty), main contact first.
+func leadContactsWithRoles(app core.App, lead *core.Record, roles []string) ([]*core.Record, error) {
+	wanted := map[string]bool{}
+	for _, role := range roles {
+		role = strings.TrimSpace(role)
+		if _, ok := buyingRoleLabels[role]; !ok {
+			return nil, fmt.Errorf("unknown buying role %q", role)
+		}
+		wanted[role] = true
+	}
+	links, err := app.FindAllRecords("lead_contacts", dbx.HashExp{"lead": lead.Id})
+	if err != nil {
+		return nil, err
+	}
+	roleOf := make(map[string]string, len(links))
+	for _, link := range links {
+		roleOf[link.GetString("contact")] = link.GetString("role")
+	}
+	contacts := make([]*core.Record, 0, len(links))
+	for _, id := range leadContactIDs(app, lead) {
+		if len(wanted) > 0 && !wanted[roleOf[id]] {
+			continue
+		}
+		if contact, err := app.FindRecordById("contacts", id); err == nil {
+			contacts = append(contacts, contact)
+		}
+	}
+	return contacts, nil
+}
@@ -0,0 +1,226 @@
+package hooks
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"pocket-crm/services"
+)
+
+// ─── Won lead conversion ─────────────────────────────────────────────────────
+//
+// When a lead is created in or enters a won stage of a pipeline with
+// convert_on_won, the transaction saving it also:
+//
+//  1. tags the lead's contact as "client"
+//  2. creates a draft invoice from the lead's line items, or its value when it
+//     has none (create_invoice_on_won), unless an accepted quote already did
+//  3. creates the onboarding tasks of the pipeline's onboarding_template
+//  4. logs a "conversion" activity and stamps leads.converted_at
+//
+// converted_at makes the conversion run once, even if the lead is reopened
+// and won again.
+
+// conversionTask mirrors an entry of task_templates.tasks.
+type conversionTask struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Type        string `json:"type"`
+	Priority    string `json:"priority"`
+	DueInDays   int    `json:"due_in_days"`
+}
+
+// convertWonLead runs the conversion if the lead's stage and pipeline call for it.
+func convertWonLead(app core.App, lead *core.Record, actorID string) error {
+	if lead.GetString("converted_at") != "" {
+		return nil
+	}
+	stage, err := app.FindRecordById("pipeline_stages", lead.GetString("stage"))
+	if err != nil || !stage.GetBool("is_won") {
+		return nil
+	}
+	pipeline, err := app.FindRecordById("pipelines", lead.GetString("pipeline"))
+	if err != nil || !pipeline.GetBool("convert_on_won") {
+		return nil
+	}
+
+	return app.RunInTransaction(func(txApp core.App) error {
+		now := time.Now().UTC()
+		var done []string
+
+		// 1. Contact → client
+		if contactID := lead.GetString("contact"); contactID != "" {
+			contact, err := txApp.FindRecordById("contacts", contactID)
+			if err != nil {
+				return fmt.Errorf("contact %s: %w", contactID, err)
+			}
+			if tags := contact.GetStringSlice("tags"); !slices.Contains(tags, "client") {
+				tags = slices.DeleteFunc(tags, func(t string) bool { return t == "prospect" })
+				contact.Set("tags", append(tags, "client"))
+				if err := txApp.Save(contact); err != nil {
+					return fmt.Errorf("tag contact: %w", err)
+				}
+			}
+			done = append(done, "contact passé en client")
+		}
+
+		owner := lead.GetString("owner")
+		if owner == "" {
+			owner = actorID
+		}
+
+		// 2. Draft invoice (unless an accepted quote already created it)
+		if acceptedQuoteInvoice(txApp, lead.Id) != "" {
+			done = append(done, "facture brouillon issue du devis accepté")
+		} else if pipeline.GetBool("create_invoice_on_won") && owner != "" {
+			if _, err := createConversionInvoice(txApp, lead, pipeline, owner, now); err != nil {
+				return fmt.Errorf("draft invoice: %w", err)
+			}
+			done = append(done, "facture brouillon")
+		}
+
+		// 3. Onboarding tasks
+		if templateID := pipeline.GetString("onboarding_template"); templateID != "" {
+			n, err := createOnboardingTasks(txApp, lead, templateID, owner, now)
+			if err != nil {
+				return fmt.Errorf("onboarding tasks: %w", err)
+			}
+			if n > 0 {
+				done = append(done, fmt.Sprintf("%d tâche(s) d'onboarding", n))
+			}
+		}
+
+		// 4. Activity (needs a user) + converted_at (raw update: the lead hooks
+		// already ran for this save)
+		if owner != "" {
+			activities, err := txApp.FindCollectionByNameOrId("activities")
+			if err != nil {
+				return err
+			}
+			activity := core.NewRecord(activities)
+			activity.Set("type", "conversion")
+			description := fmt.Sprintf("Opportunité \"%s\" convertie", lead.GetString("title"))
+			if len(done) > 0 {
+				description += " : " + strings.Join(done, ", ")
+			}
+			activity.Set("description", description)
+			activity.Set("user", owner)
+			activity.Set("lead", lead.Id)
+			activity.Set("company", lead.GetString("company"))
+			activity.Set("contact", lead.GetString("contact"))
+			if err := txApp.Save(activity); err != nil {
+				return fmt.Errorf("activity: %w", err)
+			}
+		}
+
+		convertedAt := now.Format("2006-01-02 15:04:05.000Z")
+		if _, err := txApp.DB().NewQuery("UPDATE leads SET converted_at = {:at} WHERE id = {:id}").
+			Bind(dbx.Params{"at": convertedAt, "id": lead.Id}).Execute(); err != nil {
+			return err
+		}
+		lead.Set("converted_at", convertedAt)
+		return nil
+	})
+}
+
+func createConversionInvoice(txApp core.App, lead, pipeline *core.Record, owner string, now time.Time) (*core.Record, error) {
+	col, err := txApp.FindCollectionByNameOrId("invoices")
+	if err != nil {
+		return nil, err
+	}
+	taxRate := pipeline.GetFloat("invoice_tax_rate")
+	items := []services.LineItem{{Description: lead.GetString("title"), Qty: 1, UnitPrice: lead.GetFloat("value"), TaxRate: taxRate}}
+
+	lines, err := txApp.FindRecordsByFilter("lead_items", "lead = {:lead}", "order,created", 0, 0, dbx.Params{"lead": lead.Id})
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) > 0 {
+		items = make([]services.LineItem, 0, len(lines))
+		for _, line := range lines {
+			items = append(items, services.LineItem{
+				Product:     line.GetString("product"),
+				Description: line.GetString("description"),
+				Qty:         line.GetFloat("quantity"),
+				UnitPrice:   line.GetFloat("unit_price"),
+				Discount:    line.GetFloat("discount"),
+				TaxRate:     line.GetFloat("tax_rate"),
+			})
+		}
+		// Lines sharing one product tax rate set the invoice default rate
+		if rate := commonTaxRate(items); rate > 0 {
+			taxRate = rate
+		}
+	}
+
+	invoice := core.NewRecord(col)
+	invoice.Set("contact", lead.GetString("contact"))
+	invoice.Set("company", lead.GetString("company"))
+	invoice.Set("lead", lead.Id)
+	invoice.Set("owner", owner)
+	invoice.Set("currency", lead.GetString("currency"))
+	invoice.Set("tax_rate", taxRate)
+	invoice.Set("status", "brouillon")
+	invoice.Set("items", items)
+	invoice.Set("notes", fmt.Sprintf("<p>Créée automatiquement à la signature de l'opportunité « %s » le %s.</p>",
+		lead.GetString("title"), now.Format("02/01/2006")))
+	if err := txApp.Save(invoice); err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// acceptedQuoteInvoice returns the invoice created by the lead's accepted quote, if any.
+func acceptedQuoteInvoice(app core.App, leadID string) string {
+	var invoiceID string
+	app.DB().NewQuery(`
+		SELECT invoice FROM quotes WHERE lead = {:lead} AND status = 'accepte' AND invoice != '' LIMIT 1
+	`).Bind(dbx.Params{"lead": leadID}).Row(&invoiceID) //nolint:errcheck
+	return invoiceID
+}
+
+func createOnboardingTasks(txApp core.App, lead *core.Record, templateID, owner string, now time.Time) (int, error) {
+	template, err := txApp.FindRecordById("task_templates", templateID)
+	if err != nil {
+		return 0, err
+	}
+	var items []conversionTask
+	if err := template.UnmarshalJSONField("tasks", &items); err != nil {
+		return 0, err
+	}
+	col, err := txApp.FindCollectionByNameOrId("tasks")
+	if err != nil {
+		return 0, err
+	}
+	for _, item := range items {
+		task := core.NewRecord(col)
+		task.Set("title", item.Title)
+		task.Set("description", item.Description)
+		task.Set("type", orDefault(item.Type, "suivi"))
+		task.Set("status", "a_faire")
+		task.Set("priority", orDefault(item.Priority, "moyenne"))
+		task.Set("due_date", now.AddDate(0, 0, item.DueInDays).Format("2006-01-02 15:04:05.000Z"))
+		task.Set("lead", lead.Id)
+		task.Set("contact", lead.GetString("contact"))
+		task.Set("company", lead.GetString("company"))
+		if owner != "" {
+			task.Set("assignee", owner)
+			task.Set("created_by", owner)
+		}
+		if err := txApp.Save(task); err != nil {
+			return 0, fmt.Errorf("task %q: %w", item.Title, err)
+		}
+	}
+	return len(items), nil
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
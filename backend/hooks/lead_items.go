@@ -0,0 +1,202 @@
+package hooks
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"pocket-crm/services"
+)
+
+// ─── Products & lead line items ──────────────────────────────────────────────
+//
+// A lead with line items has its value computed from them:
+//
+//	line total = quantity × unit_price × (1 − discount / 100)
+//	lead value = Σ line totals
+//
+// Lines are in the lead's currency. A line picking a product inherits its
+// name, unit price (converted from the product currency), tax rate and
+// recurring flag unless they are given. Archived products cannot be added.
+
+// RegisterLeadItemHooks normalises product SKUs, prices lead_items and keeps
+// leads.value equal to the sum of their lines.
+func RegisterLeadItemHooks(app core.App) {
+	normaliseSKU := func(e *core.RecordEvent) error {
+		e.Record.Set("sku", strings.ToUpper(strings.TrimSpace(e.Record.GetString("sku"))))
+		return e.Next()
+	}
+	app.OnRecordCreate("products").BindFunc(normaliseSKU)
+	app.OnRecordUpdate("products").BindFunc(normaliseSKU)
+
+	// Fields sent by the client are kept even when zero: only the others are
+	// inherited from the product
+	markGivenFields := func(e *core.RecordRequestEvent) error {
+		if info, err := e.RequestInfo(); err == nil {
+			given := make([]string, 0, len(productDefaultedFields))
+			for _, field := range productDefaultedFields {
+				if _, ok := info.Body[field]; ok {
+					given = append(given, field)
+				}
+			}
+			e.Record.Set(givenFieldsKey, given)
+		}
+		return e.Next()
+	}
+	app.OnRecordCreateRequest("lead_items").BindFunc(markGivenFields)
+	app.OnRecordUpdateRequest("lead_items").BindFunc(markGivenFields)
+
+	priceItem := func(e *core.RecordEvent) error {
+		if err := priceLeadItem(e.App, e.Record); err != nil {
+			return err
+		}
+		return e.Next()
+	}
+	app.OnRecordCreate("lead_items").BindFunc(priceItem)
+	app.OnRecordUpdate("lead_items").BindFunc(priceItem)
+
+	// The lead value is saved in the transaction of the line: a line whose
+	// lead cannot be updated is not saved either
+	refresh := func(e *core.RecordEvent) error {
+		leadIDs := []string{e.Record.GetString("lead")}
+		if prev := e.Record.Original().GetString("lead"); prev != "" && prev != leadIDs[0] {
+			leadIDs = append(leadIDs, prev)
+		}
+		originalApp := e.App
+		defer func() { e.App = originalApp }()
+		return e.App.RunInTransaction(func(txApp core.App) error {
+			e.App = txApp
+			if err := e.Next(); err != nil {
+				return err
+			}
+			for _, id := range leadIDs {
+				if err := refreshLeadValue(txApp, id); err != nil {
+					return fmt.Errorf("value of lead %s: %w", id, err)
+				}
+			}
+			return nil
+		})
+	}
+	app.OnRecordCreate("lead_items").BindFunc(refresh)
+	app.OnRecordUpdate("lead_items").BindFunc(refresh)
+	app.OnRecordDelete("lead_items").BindFunc(refresh)
+
+	log.Println("[hooks] Lead item hooks registered (line totals, lead value from lines)")
+}
+
+// productDefaultedFields are the line fields a product fills when not given.
+var productDefaultedFields = []string{"unit_price", "tax_rate", "recurring"}
+
+// givenFieldsKey is a non-persisted record key listing the
+// productDefaultedFields present in the request body.
+const givenFieldsKey = "@given_fields"
+
+// fieldGiven reports whether a line field was set by the client rather than
+// left for the product to fill. Outside API requests, a new line gives its
+// non-zero fields and an updated one the fields it changes.
+func fieldGiven(item *core.Record, field string) bool {
+	if given, ok := item.GetRaw(givenFieldsKey).([]string); ok {
+		return slices.Contains(given, field)
+	}
+	if item.IsNew() {
+		return item.GetFloat(field) != 0 || item.GetBool(field)
+	}
+	return item.Get(field) != item.Original().Get(field)
+}
+
+// priceLeadItem fills a line from its product and computes its total.
+func priceLeadItem(app core.App, item *core.Record) error {
+	lead, err := app.FindRecordById("leads", item.GetString("lead"))
+	if err != nil {
+		return validation.Errors{"lead": validation.NewError("validation_invalid_lead", "opportunité introuvable")}
+	}
+
+	productChanged := item.IsNew() || item.GetString("product") != item.Original().GetString("product")
+	if productID := item.GetString("product"); productID != "" && productChanged {
+		product, err := app.FindRecordById("products", productID)
+		if err != nil {
+			return validation.Errors{"product": validation.NewError("validation_invalid_product", "produit introuvable")}
+		}
+		if product.GetBool("archived") {
+			return validation.Errors{"product": validation.NewError("validation_archived_product",
+				fmt.Sprintf("le produit %s n'est plus commercialisé", product.GetString("sku")))}
+		}
+		if strings.TrimSpace(item.GetString("description")) == "" {
+			item.Set("description", product.GetString("name"))
+		}
+		if !fieldGiven(item, "unit_price") {
+			price, err := services.ConvertAmount(app, product.GetFloat("unit_price"),
+				product.GetString("currency"), lead.GetString("currency"), time.Now().UTC())
+			if err != nil {
+				return validation.Errors{"unit_price": validation.NewError("validation_unknown_currency",
+					fmt.Sprintf("impossible de convertir le prix du produit en %s", lead.GetString("currency")))}
+			}
+			item.Set("unit_price", roundCents(price))
+		}
+		if !fieldGiven(item, "tax_rate") {
+			item.Set("tax_rate", product.GetFloat("tax_rate"))
+		}
+		if !fieldGiven(item, "recurring") {
+			item.Set("recurring", product.GetBool("recurring"))
+		}
+	}
+
+	if item.GetString("product") == "" && strings.TrimSpace(item.GetString("description")) == "" {
+		return validation.Errors{"description": validation.NewError("validation_required", "la description est requise pour une ligne sans produit")}
+	}
+	if item.GetFloat("quantity") <= 0 {
+		return validation.Errors{"quantity": validation.NewError("validation_min_greater_than", "la quantité doit être supérieure à 0")}
+	}
+
+	item.Set("total", leadItemTotal(item.GetFloat("quantity"), item.GetFloat("unit_price"), item.GetFloat("discount")))
+	return nil
+}
+
+func leadItemTotal(quantity, unitPrice, discount float64) float64 {
+	return roundCents(quantity * unitPrice * (1 - discount/100))
+}
+
+func roundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// leadItemsValue returns the sum of a lead's line totals and whether it has lines.
+func leadItemsValue(app core.App, leadID string) (float64, bool) {
+	var count int
+	var sum float64
+	app.DB().NewQuery(`SELECT COUNT(*), COALESCE(SUM(total), 0) FROM lead_items WHERE lead = {:lead}`).
+		Bind(dbx.Params{"lead": leadID}).Row(&count, &sum) //nolint:errcheck
+	return roundCents(sum), count > 0
+}
+
+// applyLeadItemsValue overrides the value of an unsaved lead that has lines.
+func applyLeadItemsValue(app core.App, lead *core.Record) {
+	if lead.IsNew() {
+		return
+	}
+	if value, ok := leadItemsValue(app, lead.Id); ok {
+		lead.Set("value", value)
+	}
+}
+
+// refreshLeadValue saves a lead whose lines changed (a lead whose last line
+// was removed drops to 0). A lead being deleted is already gone when its
+// lines are.
+func refreshLeadValue(app core.App, leadID string) error {
+	lead, err := app.FindRecordById("leads", leadID)
+	if err != nil {
+		return nil // deleted together with its lines
+	}
+	value, _ := leadItemsValue(app, leadID)
+	if value == lead.GetFloat("value") {
+		return nil
+	}
+	lead.Set("value", value)
+	return app.Save(lead)
+}
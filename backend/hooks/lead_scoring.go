@@ -0,0 +1,108 @@
+package hooks
+
+import (
+	"log"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"pocket-crm/services"
+)
+
+// RegisterLeadScoringHooks keeps lead scores current when the data the
+// scoring rules look at changes outside the lead itself. The leads hooks
+// score the lead on every save; these hooks only trigger that save.
+//
+// Hook 1 — contacts / companies updated: rescore their open leads.
+// Hook 2 — email_logs updated (opens, clicks): rescore the recipient's open leads.
+// Hook 3 — activities created by users (call, email, note): rescore the lead.
+// Hook 4 — lead_scoring_rules changed: rescore every open lead in the background.
+func RegisterLeadScoringHooks(app core.App) {
+	app.OnRecordAfterUpdateSuccess("contacts").BindFunc(func(e *core.RecordEvent) error {
+		rescoreOpenLeads(app, dbx.HashExp{"contact": e.Record.Id})
+		return e.Next()
+	})
+	app.OnRecordAfterUpdateSuccess("companies").BindFunc(func(e *core.RecordEvent) error {
+		rescoreOpenLeads(app, dbx.HashExp{"company": e.Record.Id})
+		return e.Next()
+	})
+
+	app.OnRecordAfterUpdateSuccess("email_logs").BindFunc(func(e *core.RecordEvent) error {
+		if contactID := e.Record.GetString("recipient_contact"); contactID != "" {
+			rescoreOpenLeads(app, dbx.HashExp{"contact": contactID})
+		}
+		return e.Next()
+	})
+
+	app.OnRecordAfterCreateSuccess("activities").BindFunc(func(e *core.RecordEvent) error {
+		// creation / statut_change entries are written by the leads hooks themselves
+		switch e.Record.GetString("type") {
+		case "email", "appel", "note":
+			if leadID := e.Record.GetString("lead"); leadID != "" {
+				if err := services.RefreshLeadScore(app, leadID); err != nil {
+					log.Printf("[scoring] Lead %s: %v", leadID, err)
+				}
+			}
+		}
+		return e.Next()
+	})
+
+	rescoreAll := func(e *core.RecordEvent) error {
+		go rescoreOpenLeads(app, nil)
+		return e.Next()
+	}
+	app.OnRecordAfterCreateSuccess("lead_scoring_rules").BindFunc(rescoreAll)
+	app.OnRecordAfterUpdateSuccess("lead_scoring_rules").BindFunc(rescoreAll)
+	app.OnRecordAfterDeleteSuccess("lead_scoring_rules").BindFunc(rescoreAll)
+
+	log.Println("[hooks] Lead scoring hooks registered (contacts, companies, email_logs, activities, rules)")
+}
+
+// RegisterLeadScoringScheduler rescores every open lead once a day, so the
+// 30/90-day activity and email windows keep sliding for idle leads.
+func RegisterLeadScoringScheduler(app core.App) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		go func() {
+			ticker := time.NewTicker(24 * time.Hour)
+			defer ticker.Stop()
+			for range ticker.C {
+				rescoreOpenLeads(app, nil)
+			}
+		}()
+		return se.Next()
+	})
+	log.Println("[hooks] Lead scoring scheduler registered (24h interval)")
+}
+
+// rescoreOpenLeads refreshes the score of open leads matching the filter (nil = all).
+func rescoreOpenLeads(app core.App, filter dbx.Expression) {
+	exprs := []dbx.Expression{
+		dbx.NewExp("stage IN (SELECT id FROM pipeline_stages WHERE is_won = FALSE AND is_lost = FALSE)"),
+	}
+	if filter != nil {
+		exprs = append(exprs, filter)
+	}
+	leads, err := app.FindAllRecords("leads", exprs...)
+	if err != nil {
+		log.Printf("[scoring] Failed to list leads: %v", err)
+		return
+	}
+	for _, lead := range leads {
+		if err := services.RefreshLeadScore(app, lead.Id); err != nil {
+			log.Printf("[scoring] Lead %s: %v", lead.Id, err)
+		}
+	}
+	if filter == nil {
+		log.Printf("[scoring] Rescored %d open leads", len(leads))
+	}
+}
+
+// logLeadScoreChange records a score change in lead_score_history (best-effort).
+func logLeadScoreChange(app core.App, lead *core.Record, change *services.LeadScoreChange) {
+	if change == nil {
+		return
+	}
+	if err := services.LogLeadScoreChange(app, lead.Id, change); err != nil {
+		log.Printf("[scoring] failed to log score change for lead %s: %v", lead.Id, err)
+	}
+}
@@ -6,77 +6,254 @@ import (
 	"net/mail"
 	"time"
 
+	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/tools/mailer"
+	"pocket-crm/services"
 )
 
 // RegisterLeadHooks attaches lifecycle hooks to the leads collection.
 //
-// Hook 1 — OnRecordCreate: create a "creation" activity entry.
-// Hook 2 — OnRecordUpdate: detect status changes (create statut_change activity)
+// Hook 1 — OnRecordCreate: resolve pipeline/stage, enforce the initial stage's
+// rules (allowed_from, required_fields, close_reason), assign an owner when
+// none is set (see lead_assignment.go), compute the score, create a "creation"
+// activity entry, the first lead_stage_history entry and the lead_contacts row
+// of the main contact (see lead_contacts.go).
 //
-//	and owner changes (send notification email to the new owner).
+// Hook 2 — OnRecordCreateRequest / OnRecordUpdateRequest: remember the
+// author and enforce the allowed_roles of the initial or target stage.
+//
+// Hook 3 — OnRecordUpdate: derive the value from line items, keep stage and
+// status in sync, enforce the stage transition rules (allowed_from,
+// required_fields, close_reason), recompute the score, link a new main
+// contact to the lead contacts, detect stage changes
+// (lead_stage_history entry + statut_change activity), expected_close changes
+// (lead_close_date_history entry + slip count, see close_date_slippage.go) and
+// owner changes.
+//
+// Hook 4 — OnRecordAfterCreateSuccess / OnRecordAfterUpdateSuccess: email the
+// new owner once the save is committed, so a rolled back save notifies no one
+// and the SMTP round-trip does not hold the write lock.
+//
+// Hooks 1 and 3 write through the transaction of the save, which also
+// converts leads created in or moved to a won stage (see lead_conversion.go):
+// a failed conversion fails the save. They may run inside a caller's
+// transaction (e.g. a duplicate merge), so every write goes through e.App.
 func RegisterLeadHooks(app core.App) {
 	// ── After creation: record creation activity ──────────────────────────────
 	app.OnRecordCreate("leads").BindFunc(func(e *core.RecordEvent) error {
-		// Auto-set closed_at when created directly as won or lost
-		if status := e.Record.GetString("status"); (status == "gagne" || status == "perdu") && e.Record.GetString("closed_at") == "" {
-			e.Record.Set("closed_at", time.Now().UTC().Format("2006-01-02 15:04:05.000Z"))
+		originalApp := e.App
+		defer func() { e.App = originalApp }()
+		e.Record.Set(ownerNoticeKey, "")
+		return e.App.RunInTransaction(func(txApp core.App) error {
+			e.App = txApp
+
+			stage, err := resolveLeadStage(txApp, e.Record, true)
+			if err != nil {
+				return err
+			}
+			// The initial stage is entered from no stage: its allowed_from, required
+			// fields and close reason apply as on any move
+			if err := validateStageTransition(txApp, e.Record, "", stage); err != nil {
+				return err
+			}
+			// Auto-set closed_at when created directly in a won or lost stage
+			if isClosedStage(stage) && e.Record.GetString("closed_at") == "" {
+				e.Record.Set("closed_at", time.Now().UTC().Format("2006-01-02 15:04:05.000Z"))
+			}
+			// Automatic owner for open leads created without one
+			var assignment *leadAssignment
+			if e.Record.GetString("owner") == "" && !isClosedStage(stage) {
+				if assignment, err = assignLeadOwner(txApp, e.Record, time.Now().UTC()); err != nil {
+					log.Printf("[leads] automatic assignment failed: %v", err)
+				}
+			}
+			e.Record.Set("close_date_slips", 0)
+			scoreChange, err := services.ApplyLeadScore(txApp, e.Record)
+			if err != nil {
+				log.Printf("[leads] failed to score lead: %v", err)
+			}
+			if err := e.Next(); err != nil {
+				return err
+			}
+			logLeadScoreChange(txApp, e.Record, scoreChange)
+			ensureLeadContact(txApp, e.Record)
+			recordStageChange(txApp, e.Record, nil, stage, leadChangedBy(e.Record))
+			createLeadActivity(txApp, e.Record, "creation",
+				fmt.Sprintf("Opportunité \"%s\" créée", e.Record.GetString("title")),
+			)
+			if assignment != nil {
+				completeLeadAssignment(txApp, e.Record, assignment)
+			}
+			// Created directly in a won stage: converted with the creation
+			if err := convertWonLead(txApp, e.Record, leadChangedBy(e.Record)); err != nil {
+				return fmt.Errorf("conversion: %w", err)
+			}
+			return nil
+		})
+	})
+
+	// ── Create request: author + role restrictions of the initial stage ───────
+	app.OnRecordCreateRequest("leads").BindFunc(func(e *core.RecordRequestEvent) error {
+		if e.Auth != nil && e.Auth.Collection().Name == "users" {
+			e.Record.Set(changedByKey, e.Auth.Id)
+		}
+
+		if e.Auth == nil || e.HasSuperuserAuth() {
+			return e.Next()
+		}
+		stage, err := resolveLeadStage(app, e.Record, true)
+		if err != nil {
+			return e.BadRequestError(err.Error(), nil)
 		}
-		if err := e.Next(); err != nil {
-			return err
+		if !stageAllowsRole(stage, e.Auth.GetString("role")) {
+			return stageRoleError(e, stage)
 		}
-		createLeadActivity(app, e.Record, "creation",
-			fmt.Sprintf("Opportunité \"%s\" créée", e.Record.GetString("title")),
-		)
-		return nil
+		return e.Next()
 	})
 
-	// ── Before update: detect status / owner changes ──────────────────────────
-	app.OnRecordUpdate("leads").BindFunc(func(e *core.RecordEvent) error {
-		// Fetch current state from DB before the save
+	// ── Update request: author + role restrictions of the target stage ────────
+	// Runs on API requests only (the record hook below has no auth context).
+	app.OnRecordUpdateRequest("leads").BindFunc(func(e *core.RecordRequestEvent) error {
+		changedBy := ""
+		if e.Auth != nil && e.Auth.Collection().Name == "users" {
+			changedBy = e.Auth.Id
+		}
+		e.Record.Set(changedByKey, changedBy)
+
+		if e.Auth == nil || e.HasSuperuserAuth() {
+			return e.Next()
+		}
 		oldRecord, err := app.FindRecordById("leads", e.Record.Id)
 		if err != nil {
-			// Cannot compare — just proceed
 			return e.Next()
 		}
+		stage, err := resolveLeadStage(app, e.Record, e.Record.GetString("stage") != oldRecord.GetString("stage"))
+		if err != nil {
+			return e.BadRequestError(err.Error(), nil)
+		}
+		if stage.Id != oldRecord.GetString("stage") && !stageAllowsRole(stage, e.Auth.GetString("role")) {
+			return stageRoleError(e, stage)
+		}
+		return e.Next()
+	})
 
-		newStatus := e.Record.GetString("status")
-		oldStatus := oldRecord.GetString("status")
-		newOwner := e.Record.GetString("owner")
-		oldOwner := oldRecord.GetString("owner")
-		leadTitle := e.Record.GetString("title")
+	// ── Before update: detect status / owner changes ──────────────────────────
+	app.OnRecordUpdate("leads").BindFunc(func(e *core.RecordEvent) error {
+		originalApp := e.App
+		defer func() { e.App = originalApp }()
+		e.Record.Set(ownerNoticeKey, "")
+		return e.App.RunInTransaction(func(txApp core.App) error {
+			e.App = txApp
 
-		// Auto-set closed_at when transitioning to won or lost
-		if (newStatus == "gagne" || newStatus == "perdu") && newStatus != oldStatus && e.Record.GetString("closed_at") == "" {
-			e.Record.Set("closed_at", time.Now().UTC().Format("2006-01-02 15:04:05.000Z"))
-		}
+			// Fetch current state from DB before the save
+			oldRecord, err := txApp.FindRecordById("leads", e.Record.Id)
+			if err != nil {
+				// Cannot compare — just proceed
+				return e.Next()
+			}
 
-		if err := e.Next(); err != nil {
-			return err
-		}
+			// A lead with line items is worth the sum of its lines (see lead_items.go)
+			applyLeadItemsValue(txApp, e.Record)
 
-		// Status change activity
-		if newStatus != oldStatus {
-			desc := fmt.Sprintf(
-				"Opportunité \"%s\" : statut changé de \"%s\" → \"%s\"",
-				leadTitle, oldStatus, newStatus,
-			)
-			createLeadActivity(app, e.Record, "statut_change", desc)
-		}
+			stage, err := resolveLeadStage(txApp, e.Record, e.Record.GetString("stage") != oldRecord.GetString("stage"))
+			if err != nil {
+				return err
+			}
+			if stage.Id != oldRecord.GetString("stage") {
+				if err := validateStageTransition(txApp, e.Record, oldRecord.GetString("stage"), stage); err != nil {
+					return err
+				}
+			} else if e.Record.GetString("close_reason") != oldRecord.GetString("close_reason") {
+				if err := validateCloseReason(txApp, e.Record, stage); err != nil {
+					return validation.Errors{"close_reason": err}
+				}
+			}
 
-		// Owner change notification
-		if newOwner != oldOwner && newOwner != "" {
-			sendLeadAssignmentEmail(app, e.Record, newOwner)
-		}
+			newStatus := e.Record.GetString("status")
+			oldStatus := oldRecord.GetString("status")
+			newOwner := e.Record.GetString("owner")
+			oldOwner := oldRecord.GetString("owner")
+			leadTitle := e.Record.GetString("title")
+
+			// Auto-set closed_at when transitioning to a won or lost stage
+			if isClosedStage(stage) && e.Record.GetString("stage") != oldRecord.GetString("stage") && e.Record.GetString("closed_at") == "" {
+				e.Record.Set("closed_at", time.Now().UTC().Format("2006-01-02 15:04:05.000Z"))
+			}
+
+			// Expected close pushed back: one more slip (the counter is server-side only)
+			oldClose := oldRecord.GetString("expected_close")
+			newClose := e.Record.GetString("expected_close")
+			slips := oldRecord.GetInt("close_date_slips")
+			if closeDateSlipped(dayOf(oldClose), dayOf(newClose)) {
+				slips++
+			}
+			e.Record.Set("close_date_slips", slips)
+
+			scoreChange, err := services.ApplyLeadScore(txApp, e.Record)
+			if err != nil {
+				log.Printf("[leads] failed to score lead %s: %v", e.Record.Id, err)
+			}
+
+			if err := e.Next(); err != nil {
+				return err
+			}
+			logLeadScoreChange(txApp, e.Record, scoreChange)
+
+			// New main contact: also one of the lead contacts
+			if e.Record.GetString("contact") != oldRecord.GetString("contact") {
+				ensureLeadContact(txApp, e.Record)
+			}
+
+			// Stage history
+			if stage.Id != oldRecord.GetString("stage") {
+				oldStage, _ := txApp.FindRecordById("pipeline_stages", oldRecord.GetString("stage"))
+				recordStageChange(txApp, e.Record, oldStage, stage, leadChangedBy(e.Record))
+			}
 
-		return nil
+			// Expected close history
+			if dayOf(newClose) != dayOf(oldClose) {
+				recordCloseDateChange(txApp, e.Record, oldClose, newClose, leadChangedBy(e.Record))
+			}
+
+			// Status change activity
+			if newStatus != oldStatus {
+				desc := fmt.Sprintf(
+					"Opportunité \"%s\" : statut changé de \"%s\" → \"%s\"",
+					leadTitle, oldStatus, newStatus,
+				)
+				createLeadActivity(txApp, e.Record, "statut_change", desc)
+			}
+
+			// Won stage entered: converted in the same transaction
+			if stage.Id != oldRecord.GetString("stage") {
+				if err := convertWonLead(txApp, e.Record, leadChangedBy(e.Record)); err != nil {
+					return fmt.Errorf("conversion: %w", err)
+				}
+			}
+
+			// Owner change notification, sent after commit
+			if newOwner != oldOwner && newOwner != "" {
+				e.Record.Set(ownerNoticeKey, newOwner)
+			}
+
+			return nil
+		})
 	})
 
-	log.Println("[hooks] Lead hooks registered (activity tracking, owner notification)")
+	// ── After commit: notify the new owner ───────────────────────────────────
+	app.OnRecordAfterCreateSuccess("leads").BindFunc(notifyLeadOwner)
+	app.OnRecordAfterUpdateSuccess("leads").BindFunc(notifyLeadOwner)
+
+	log.Println("[hooks] Lead hooks registered (activity tracking, owner notification, won conversion)")
 }
 
-// createLeadActivity inserts a new record into the activities collection.
+// maxActivityContacts is the MaxSelect of activities.contacts.
+const maxActivityContacts = 50
+
+// createLeadActivity inserts a new record into the activities collection,
+// linked to the lead, its company and every contact involved in it (the
+// first maxActivityContacts, main contact first).
 func createLeadActivity(app core.App, lead *core.Record, activityType, description string) {
 	col, err := app.FindCollectionByNameOrId("activities")
 	if err != nil {
@@ -94,12 +271,40 @@ func createLeadActivity(app core.App, lead *core.Record, activityType, descripti
 	if contactID := lead.GetString("contact"); contactID != "" {
 		rec.Set("contact", contactID)
 	}
+	// Every contact involved in the deal (see lead_contacts.go)
+	contacts := leadContactIDs(app, lead)
+	if len(contacts) > maxActivityContacts {
+		contacts = contacts[:maxActivityContacts]
+	}
+	rec.Set("contacts", contacts)
 	if err := app.Save(rec); err != nil {
 		log.Printf("[leads] failed to create activity for lead %s: %v", lead.Id, err)
 	}
 }
 
-// sendLeadAssignmentEmail notifies the new owner by email (best-effort).
+// stageRoleError rejects a lead put in a stage the user's role does not allow.
+func stageRoleError(e *core.RecordRequestEvent, stage *core.Record) error {
+	return e.ForbiddenError("Not allowed to move the lead to this stage", validation.Errors{
+		"stage": validation.NewError("validation_stage_role",
+			fmt.Sprintf("votre rôle ne permet pas de passer l'opportunité à l'étape \"%s\"", stage.GetString("name"))),
+	})
+}
+
+// ownerNoticeKey is a non-persisted record key carrying the id of the owner
+// to notify once the save that assigned the lead is committed.
+const ownerNoticeKey = "@notify_owner"
+
+// notifyLeadOwner sends the notification queued under ownerNoticeKey.
+func notifyLeadOwner(e *core.RecordEvent) error {
+	if ownerID, ok := e.Record.GetRaw(ownerNoticeKey).(string); ok && ownerID != "" {
+		e.Record.Set(ownerNoticeKey, "")
+		sendLeadAssignmentEmail(e.App, e.Record, ownerID)
+	}
+	return e.Next()
+}
+
+// sendLeadAssignmentEmail notifies the new owner by email (best-effort),
+// in the owner's preferred language.
 func sendLeadAssignmentEmail(app core.App, lead *core.Record, newOwnerID string) {
 	owner, err := app.FindRecordById("users", newOwnerID)
 	if err != nil {
@@ -111,31 +316,32 @@ func sendLeadAssignmentEmail(app core.App, lead *core.Record, newOwnerID string)
 		return
 	}
 
-	senderAddr := app.Settings().Meta.SenderAddress
-	senderName := app.Settings().Meta.SenderName
-	if senderAddr == "" {
-		senderAddr = "noreply@pocketcrm.app"
-	}
-	if senderName == "" {
-		senderName = "Pocket CRM"
-	}
-
 	leadTitle := lead.GetString("title")
 	ownerName := owner.GetString("name")
 
+	locale := services.ResolveUserLocale(owner)
+
 	msg := &mailer.Message{
-		From: mail.Address{Address: senderAddr, Name: senderName},
-		To:   []mail.Address{{Address: ownerEmail, Name: ownerName}},
-		Subject: fmt.Sprintf("[CRM] Opportunité assignée : %s", leadTitle),
-		HTML: fmt.Sprintf(`
-<p>Bonjour %s,</p>
-<p>L'opportunité <strong>%s</strong> vous a été assignée.</p>
-<p>Connectez-vous à Pocket CRM pour en voir les détails.</p>
-<p>— L'équipe Pocket CRM</p>
-`, ownerName, leadTitle),
+		From:    notificationSender(app),
+		To:      []mail.Address{{Address: ownerEmail, Name: ownerName}},
+		Subject: fmt.Sprintf(services.T(locale, "lead_assigned.subject"), leadTitle),
+		HTML:    fmt.Sprintf(services.T(locale, "lead_assigned.body"), ownerName, leadTitle),
 	}
 
 	if err := app.NewMailClient().Send(msg); err != nil {
 		log.Printf("[leads] failed to send assignment email to %s: %v", ownerEmail, err)
 	}
 }
+
+// notificationSender returns the From address of system notifications.
+func notificationSender(app core.App) mail.Address {
+	senderAddr := app.Settings().Meta.SenderAddress
+	senderName := app.Settings().Meta.SenderName
+	if senderAddr == "" {
+		senderAddr = "noreply@pocketcrm.app"
+	}
+	if senderName == "" {
+		senderName = "Pocket CRM"
+	}
+	return mail.Address{Address: senderAddr, Name: senderName}
+}
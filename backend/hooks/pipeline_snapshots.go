@@ -0,0 +1,617 @@
+package hooks
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// ─── Pipeline snapshots ──────────────────────────────────────────────────────
+//
+// Once a day the open pipeline (one row per open lead: stage, owner, value,
+// weighted value, expected close) is copied into pipeline_snapshots, so past
+// pipelines can be reported on. Amounts are stored in the lead currency and
+// converted at the snapshot date when reported.
+
+// snapshotDay is the SQL day (YYYY-MM-DD) of a snapshot row. Filters compare
+// s.snapshot_date to day bounds instead (see snapshotDayBounds) so that
+// idx_pipeline_snapshots_date_lead is used.
+const snapshotDay = `substr(s.snapshot_date, 1, 10)`
+
+// snapshotDayBounds returns the {:day} and {:next} parameters of the
+// predicate s.snapshot_date >= {:day} AND s.snapshot_date < {:next}, which
+// selects the snapshot rows of day (YYYY-MM-DD).
+func snapshotDayBounds(day string) dbx.Params {
+	return dbx.Params{"day": day, "next": nextSnapshotDay(day)}
+}
+
+// nextSnapshotDay returns the day after day (YYYY-MM-DD).
+func nextSnapshotDay(day string) string {
+	t, err := time.Parse("2006-01-02", day)
+	if err != nil {
+		return day
+	}
+	return t.AddDate(0, 0, 1).Format("2006-01-02")
+}
+
+// RegisterPipelineSnapshotScheduler takes today's snapshot at startup if it is
+// missing, then checks every hour so each day is captured shortly after midnight.
+func RegisterPipelineSnapshotScheduler(app core.App) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		go func() {
+			ensurePipelineSnapshot(app)
+			ticker := time.NewTicker(time.Hour)
+			defer ticker.Stop()
+			for range ticker.C {
+				ensurePipelineSnapshot(app)
+			}
+		}()
+		return se.Next()
+	})
+	log.Println("[hooks] Pipeline snapshot scheduler registered (daily, checked hourly)")
+}
+
+// ensurePipelineSnapshot snapshots the pipeline unless today's snapshot exists.
+func ensurePipelineSnapshot(app core.App) {
+	today := time.Now().UTC().Format("2006-01-02")
+	var count int
+	app.DB().NewQuery(`SELECT COUNT(*) FROM pipeline_snapshots s WHERE s.snapshot_date >= {:day} AND s.snapshot_date < {:next}`).
+		Bind(snapshotDayBounds(today)).Row(&count) //nolint:errcheck
+	if count > 0 {
+		return
+	}
+	n, err := takePipelineSnapshot(app, time.Now().UTC())
+	if err != nil {
+		log.Printf("[snapshots] Failed to snapshot pipeline: %v", err)
+		return
+	}
+	log.Printf("[snapshots] Pipeline snapshot %s: %d open leads", today, n)
+}
+
+// takePipelineSnapshot replaces the snapshot of day with the current open leads.
+func takePipelineSnapshot(app core.App, day time.Time) (int, error) {
+	type openLeadRow struct {
+		ID            string  `db:"id"`
+		Title         string  `db:"title"`
+		Pipeline      string  `db:"pipeline"`
+		Stage         string  `db:"stage"`
+		StageName     string  `db:"stage_name"`
+		StageOrder    float64 `db:"stage_order"`
+		Probability   float64 `db:"probability"`
+		Owner         string  `db:"owner"`
+		Value         float64 `db:"value"`
+		Currency      string  `db:"currency"`
+		ExpectedClose string  `db:"expected_close"`
+	}
+	rows := make([]openLeadRow, 0)
+	err := app.DB().NewQuery(`
+		SELECT l.id, l.title, COALESCE(l.pipeline, '') AS pipeline, l.stage,
+		       ps.name AS stage_name, COALESCE(ps."order", 0) AS stage_order,
+		       COALESCE(ps.probability, 0) AS probability, COALESCE(l.owner, '') AS owner,
+		       COALESCE(l.value, 0) AS value, COALESCE(l.currency, '') AS currency,
+		       COALESCE(l.expected_close, '') AS expected_close
+		FROM leads l
+		JOIN pipeline_stages ps ON ps.id = l.stage
+		WHERE ps.is_won = FALSE AND ps.is_lost = FALSE
+	`).All(&rows)
+	if err != nil {
+		return 0, err
+	}
+
+	date := day.UTC().Format("2006-01-02") + " 00:00:00.000Z"
+	err = app.RunInTransaction(func(txApp core.App) error {
+		if _, err := txApp.DB().NewQuery(`DELETE FROM pipeline_snapshots WHERE snapshot_date = {:date}`).
+			Bind(dbx.Params{"date": date}).Execute(); err != nil {
+			return err
+		}
+		col, err := txApp.FindCollectionByNameOrId("pipeline_snapshots")
+		if err != nil {
+			return err
+		}
+		for _, r := range rows {
+			rec := core.NewRecord(col)
+			rec.Set("snapshot_date", date)
+			rec.Set("lead", r.ID)
+			rec.Set("title", r.Title)
+			rec.Set("pipeline", r.Pipeline)
+			rec.Set("stage", r.Stage)
+			rec.Set("stage_name", r.StageName)
+			rec.Set("stage_order", r.StageOrder)
+			rec.Set("probability", r.Probability)
+			rec.Set("owner", r.Owner)
+			rec.Set("value", r.Value)
+			rec.Set("weighted_value", roundCents(r.Value*r.Probability/100))
+			rec.Set("currency", r.Currency)
+			rec.Set("expected_close", r.ExpectedClose)
+			if err := txApp.Save(rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(rows), nil
+}
+
+// snapshotLead is one lead of a snapshot, amounts in the reporting currency.
+type snapshotLead struct {
+	Lead          string  `db:"lead"`
+	Title         string  `db:"title"`
+	Stage         string  `db:"stage"`
+	StageName     string  `db:"stage_name"`
+	StageOrder    float64 `db:"stage_order"`
+	Owner         string  `db:"owner"`
+	Value         float64 `db:"value"`
+	Weighted      float64 `db:"weighted_value"`
+	ExpectedClose string  `db:"expected_close"`
+}
+
+// snapshotDayOnOrBefore returns the latest snapshot day <= day ("" if none).
+func snapshotDayOnOrBefore(app core.App, day string) string {
+	var found string
+	app.DB().NewQuery(`SELECT COALESCE(MAX(s.snapshot_date), '') FROM pipeline_snapshots s WHERE s.snapshot_date < {:next}`).
+		Bind(snapshotDayBounds(day)).Row(&found) //nolint:errcheck
+	if len(found) > 10 {
+		found = found[:10]
+	}
+	return found
+}
+
+// loadSnapshot returns the leads of the snapshot taken on day, by lead id.
+func loadSnapshot(app core.App, day, pipeline, currency string) (map[string]snapshotLead, error) {
+	rows := make([]snapshotLead, 0)
+	err := app.DB().NewQuery(`
+		SELECT s.lead, s.title, COALESCE(s.stage, '') AS stage, s.stage_name,
+		       COALESCE(s.stage_order, 0) AS stage_order, COALESCE(s.owner, '') AS owner,
+		       ` + fxConvertSQL("s.value", "s.currency", "s.snapshot_date") + ` AS value,
+		       ` + fxConvertSQL("s.weighted_value", "s.currency", "s.snapshot_date") + ` AS weighted_value,
+		       COALESCE(s.expected_close, '') AS expected_close
+		FROM pipeline_snapshots s
+		WHERE s.snapshot_date >= {:day} AND s.snapshot_date < {:next}
+		  AND ({:pipeline} = '' OR s.pipeline = {:pipeline})
+	`).Bind(dbx.Params{"day": day, "next": nextSnapshotDay(day), "pipeline": pipeline, "currency": currency}).All(&rows)
+	if err != nil {
+		return nil, err
+	}
+	leads := make(map[string]snapshotLead, len(rows))
+	for _, r := range rows {
+		leads[r.Lead] = r
+	}
+	return leads, nil
+}
+
+// snapshotDateRange reads ?from= and ?to= (YYYY-MM-DD); to defaults to today
+// and from to defaultDays before to.
+func snapshotDateRange(e *core.RequestEvent, defaultDays int) (from, to time.Time, ok bool) {
+	q := e.Request.URL.Query()
+	to = time.Now().UTC().Truncate(24 * time.Hour)
+	if v := q.Get("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return from, to, false
+		}
+		to = t
+	}
+	from = to.AddDate(0, 0, -defaultDays)
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return from, to, false
+		}
+		from = t
+	}
+	return from, to, !from.After(to)
+}
+
+// ─── Pipeline over time ──────────────────────────────────────────────────────
+
+// pipelineHistoryBuckets groups snapshot days by interval; the last snapshot of
+// each bucket represents it.
+var pipelineHistoryBuckets = map[string]string{
+	"day":   snapshotDay,
+	"week":  `strftime('%Y-%W', s.snapshot_date)`,
+	"month": `strftime('%Y-%m', s.snapshot_date)`,
+}
+
+// buildPipelineHistory serves
+// GET /api/crm/stats/pipeline-history?from=&to=&interval=day|week|month&group=stage|owner&pipeline=&currency=
+// Each point is the open pipeline of a snapshot, with its breakdown by stage or owner.
+func buildPipelineHistory(app core.App) func(*core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		q := e.Request.URL.Query()
+		rc, err := statsCurrency(app, e)
+		if err != nil {
+			return e.BadRequestError(err.Error(), nil)
+		}
+		from, to, ok := snapshotDateRange(e, 90)
+		if !ok {
+			return e.BadRequestError("from and to must be YYYY-MM-DD, from before to", nil)
+		}
+		interval := q.Get("interval")
+		if interval == "" {
+			interval = "week"
+		}
+		bucket, ok := pipelineHistoryBuckets[interval]
+		if !ok {
+			return e.BadRequestError("interval must be day, week or month", nil)
+		}
+		group := q.Get("group")
+		if group == "" {
+			group = "stage"
+		}
+		var key, label, join, order string
+		switch group {
+		case "stage":
+			key, label, order = "COALESCE(s.stage, '')", "MAX(s.stage_name)", "MIN(s.stage_order)"
+		case "owner":
+			key, label, order = "COALESCE(s.owner, '')", "COALESCE(MAX(u.name), 'Non attribué')", "value DESC"
+			join = "LEFT JOIN users u ON u.id = s.owner"
+		default:
+			return e.BadRequestError("group must be stage or owner", nil)
+		}
+
+		type breakdownRow struct {
+			Day           string  `db:"day" json:"-"`
+			Key           string  `db:"key" json:"key"`
+			Label         string  `db:"label" json:"label"`
+			Count         int     `db:"count" json:"count"`
+			Value         float64 `db:"value" json:"value"`
+			WeightedValue float64 `db:"weighted_value" json:"weighted_value"`
+		}
+		rows := make([]breakdownRow, 0)
+		err = app.DB().NewQuery(`
+			WITH picked AS (
+				SELECT MAX(s.snapshot_date) AS snapshot_date
+				FROM pipeline_snapshots s
+				WHERE s.snapshot_date >= {:from} AND s.snapshot_date < {:until}
+				GROUP BY ` + bucket + `
+			)
+			SELECT ` + snapshotDay + ` AS day, ` + key + ` AS key, ` + label + ` AS label, COUNT(*) AS count,
+			       COALESCE(SUM(` + fxConvertSQL("s.value", "s.currency", "s.snapshot_date") + `), 0) AS value,
+			       COALESCE(SUM(` + fxConvertSQL("s.weighted_value", "s.currency", "s.snapshot_date") + `), 0) AS weighted_value
+			FROM pipeline_snapshots s
+			JOIN picked p ON p.snapshot_date = s.snapshot_date
+			` + join + `
+			WHERE {:pipeline} = '' OR s.pipeline = {:pipeline}
+			GROUP BY day, key
+			ORDER BY day, ` + order + `
+		`).Bind(dbx.Params{
+			"from":     from.Format("2006-01-02"),
+			"until":    to.AddDate(0, 0, 1).Format("2006-01-02"),
+			"pipeline": q.Get("pipeline"),
+			"currency": rc,
+		}).All(&rows)
+		if err != nil {
+			return e.InternalServerError("Failed to load pipeline snapshots", err)
+		}
+
+		type historyPoint struct {
+			Date          string         `json:"date"`
+			Count         int            `json:"count"`
+			Value         float64        `json:"value"`
+			WeightedValue float64        `json:"weighted_value"`
+			Breakdown     []breakdownRow `json:"breakdown"`
+		}
+		points := make([]historyPoint, 0)
+		for _, r := range rows {
+			if len(points) == 0 || points[len(points)-1].Date != r.Day {
+				points = append(points, historyPoint{Date: r.Day, Breakdown: make([]breakdownRow, 0)})
+			}
+			p := &points[len(points)-1]
+			r.Value, r.WeightedValue = roundCents(r.Value), roundCents(r.WeightedValue)
+			p.Count += r.Count
+			p.Value = roundCents(p.Value + r.Value)
+			p.WeightedValue = roundCents(p.WeightedValue + r.WeightedValue)
+			p.Breakdown = append(p.Breakdown, r)
+		}
+
+		return e.JSON(http.StatusOK, map[string]interface{}{
+			"from":     from.Format("2006-01-02"),
+			"to":       to.Format("2006-01-02"),
+			"interval": interval,
+			"group":    group,
+			"points":   points,
+			"currency": rc,
+		})
+	}
+}
+
+// ─── Pipeline waterfall ──────────────────────────────────────────────────────
+
+// waterfallBucket is a count and an amount in the reporting currency.
+type waterfallBucket struct {
+	Count int     `json:"count"`
+	Value float64 `json:"value"`
+}
+
+func (b *waterfallBucket) add(v float64) {
+	b.Count++
+	b.Value = roundCents(b.Value + v)
+}
+
+// slippedLead is a lead whose expected close was pushed past the window.
+type slippedLead struct {
+	ID               string  `json:"id"`
+	Title            string  `json:"title"`
+	Value            float64 `json:"value"`
+	ExpectedCloseWas string  `json:"expected_close_was"`
+	ExpectedCloseNow string  `json:"expected_close_now"`
+}
+
+// buildPipelineWaterfall serves
+// GET /api/crm/stats/pipeline-waterfall?from=&to=&pipeline=&currency=
+// It compares the snapshots taken on (or just before) from and to:
+//
+//	end = start + added + increased − decreased − won − lost − removed
+//
+// Leads closed between the snapshots are valued as in the start snapshot;
+// leads created and closed between them count as added and won / lost.
+// progressed / regressed (stage order) and slipped (expected close moved
+// past to while still open) describe leads open in both snapshots.
+func buildPipelineWaterfall(app core.App) func(*core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		pipeline := e.Request.URL.Query().Get("pipeline")
+		rc, err := statsCurrency(app, e)
+		if err != nil {
+			return e.BadRequestError(err.Error(), nil)
+		}
+		from, to, ok := snapshotDateRange(e, 30)
+		if !ok {
+			return e.BadRequestError("from and to must be YYYY-MM-DD, from before to", nil)
+		}
+		fromDay := snapshotDayOnOrBefore(app, from.Format("2006-01-02"))
+		toDay := snapshotDayOnOrBefore(app, to.Format("2006-01-02"))
+		if fromDay == "" || toDay == "" {
+			return e.NotFoundError("No pipeline snapshot on or before "+from.Format("2006-01-02"), nil)
+		}
+		start, err := loadSnapshot(app, fromDay, pipeline, rc)
+		if err != nil {
+			return e.InternalServerError("Failed to load pipeline snapshots", err)
+		}
+		end, err := loadSnapshot(app, toDay, pipeline, rc)
+		if err != nil {
+			return e.InternalServerError("Failed to load pipeline snapshots", err)
+		}
+
+		// Leads closed between the two snapshots
+		type closedRow struct {
+			ID      string  `db:"id"`
+			Won     bool    `db:"is_won"`
+			Created string  `db:"created"`
+			Value   float64 `db:"value"`
+		}
+		closedRows := make([]closedRow, 0)
+		app.DB().NewQuery(`
+			SELECT l.id, ps.is_won, l.created, ` + leadValueSQL("l") + ` AS value
+			FROM leads l
+			JOIN pipeline_stages ps ON ps.id = l.stage
+			WHERE (ps.is_won = TRUE OR ps.is_lost = TRUE)
+			  AND substr(l.closed_at, 1, 10) > {:from} AND substr(l.closed_at, 1, 10) <= {:to}
+			  AND ({:pipeline} = '' OR l.pipeline = {:pipeline})
+		`).Bind(dbx.Params{"from": fromDay, "to": toDay, "pipeline": pipeline, "currency": rc}).All(&closedRows) //nolint:errcheck
+		closed := make(map[string]closedRow, len(closedRows))
+		for _, c := range closedRows {
+			closed[c.ID] = c
+		}
+
+		var startB, endB, added, increased, decreased, won, lost, removed, progressed, regressed, slipped waterfallBucket
+		slippedLeads := make([]slippedLead, 0)
+		toDate := to.Format("2006-01-02")
+		for id, s := range start {
+			startB.add(s.Value)
+			n, open := end[id]
+			if !open {
+				c, isClosed := closed[id]
+				switch {
+				case isClosed && c.Won:
+					won.add(s.Value)
+				case isClosed:
+					lost.add(s.Value)
+				default: // deleted, or moved to another pipeline
+					removed.add(s.Value)
+				}
+				continue
+			}
+			if diff := roundCents(n.Value - s.Value); diff > 0 {
+				increased.add(diff)
+			} else if diff < 0 {
+				decreased.add(-diff)
+			}
+			if n.Stage != s.Stage {
+				if n.StageOrder > s.StageOrder {
+					progressed.add(n.Value)
+				} else {
+					regressed.add(n.Value)
+				}
+			}
+			was, now := dayOf(s.ExpectedClose), dayOf(n.ExpectedClose)
+			if was != "" && was <= toDate && (now == "" || now > toDate) {
+				slipped.add(n.Value)
+				slippedLeads = append(slippedLeads, slippedLead{id, n.Title, n.Value, was, now})
+			}
+		}
+		for id, n := range end {
+			endB.add(n.Value)
+			if _, ok := start[id]; !ok {
+				added.add(n.Value)
+			}
+		}
+		// Created and closed between the snapshots: in neither of them
+		for id, c := range closed {
+			if _, ok := start[id]; ok || dayOf(c.Created) <= fromDay {
+				continue
+			}
+			if _, ok := end[id]; ok {
+				continue
+			}
+			added.add(c.Value)
+			if c.Won {
+				won.add(c.Value)
+			} else {
+				lost.add(c.Value)
+			}
+		}
+		sort.Slice(slippedLeads, func(i, j int) bool { return slippedLeads[i].Value > slippedLeads[j].Value })
+
+		return e.JSON(http.StatusOK, map[string]interface{}{
+			"from":          fromDay,
+			"to":            toDay,
+			"start":         startB,
+			"added":         added,
+			"increased":     increased,
+			"decreased":     decreased,
+			"won":           won,
+			"lost":          lost,
+			"removed":       removed,
+			"end":           endB,
+			"progressed":    progressed,
+			"regressed":     regressed,
+			"slipped":       slipped,
+			"slipped_leads": slippedLeads,
+			"currency":      rc,
+		})
+	}
+}
+
+// dayOf returns the YYYY-MM-DD part of a stored date ("" if empty).
+func dayOf(date string) string {
+	if len(date) < 10 {
+		return ""
+	}
+	return date[:10]
+}
+
+// ─── Forecast accuracy ───────────────────────────────────────────────────────
+
+// buildForecastAccuracy serves
+// GET /api/crm/stats/forecast-accuracy?from=&to=&pipeline=&currency=
+// For each month between from and to (default: the last 6), the forecast is
+// read from the snapshot taken on (or just before) the first day of the month:
+// the weighted value of open leads expected to close in that month. It is
+// compared with the value actually won in the month; slipped_* are the
+// forecast leads whose expected close was later pushed past the month.
+func buildForecastAccuracy(app core.App) func(*core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		pipeline := e.Request.URL.Query().Get("pipeline")
+		rc, err := statsCurrency(app, e)
+		if err != nil {
+			return e.BadRequestError(err.Error(), nil)
+		}
+		from, to, ok := snapshotDateRange(e, 150)
+		if !ok {
+			return e.BadRequestError("from and to must be YYYY-MM-DD, from before to", nil)
+		}
+
+		type monthRow struct {
+			Month    string `json:"month"`
+			Snapshot string `json:"snapshot"` // "" when no snapshot predates the month
+			// Forecast: weighted value of leads expected to close in the month;
+			// Pipeline: their unweighted value
+			Forecast      float64 `json:"forecast"`
+			Pipeline      float64 `json:"pipeline"`
+			ForecastCount int     `json:"forecast_count"`
+			Actual        float64 `json:"actual"`
+			ActualCount   int     `json:"actual_count"`
+			// ActualForecasted: part of Actual won from the forecast leads
+			ActualForecasted float64 `json:"actual_forecasted"`
+			// Slipped: forecast leads whose expected close was pushed past the month
+			SlippedCount int      `json:"slipped_count"`
+			SlippedValue float64  `json:"slipped_value"`
+			AccuracyPct  *float64 `json:"accuracy_pct"`
+			ErrorPct     *float64 `json:"error_pct"`
+		}
+		months := make([]monthRow, 0)
+		var totalForecast, totalActual, absErrors float64
+		var errorMonths int
+		for m := quotaPeriodAt("mois", from).Start; !m.After(to); m = m.AddDate(0, 1, 0) {
+			row := monthRow{Month: m.Format("2006-01")}
+			monthEnd := m.AddDate(0, 1, 0)
+
+			forecasted := map[string]bool{}
+			if day := snapshotDayOnOrBefore(app, m.Format("2006-01-02")); day != "" {
+				snapshot, err := loadSnapshot(app, day, pipeline, rc)
+				if err != nil {
+					return e.InternalServerError("Failed to load pipeline snapshots", err)
+				}
+				row.Snapshot = day
+				slipped := leadsSlippedOutOf(app, m.Format("2006-01-02"), monthEnd.Format("2006-01-02"))
+				for id, s := range snapshot {
+					if closeDay := dayOf(s.ExpectedClose); closeDay >= m.Format("2006-01-02") && closeDay < monthEnd.Format("2006-01-02") {
+						forecasted[id] = true
+						row.Forecast += s.Weighted
+						row.Pipeline += s.Value
+						row.ForecastCount++
+						if slipped[id] {
+							row.SlippedCount++
+							row.SlippedValue += s.Value
+						}
+					}
+				}
+			}
+
+			type wonRow struct {
+				ID    string  `db:"id"`
+				Value float64 `db:"value"`
+			}
+			wonRows := make([]wonRow, 0)
+			app.DB().NewQuery(`
+				SELECT l.id, ` + leadValueSQL("l") + ` AS value
+				FROM leads l
+				WHERE l.stage IN (SELECT id FROM pipeline_stages WHERE is_won = TRUE)
+				  AND l.closed_at >= {:start} AND l.closed_at < {:end}
+				  AND ({:pipeline} = '' OR l.pipeline = {:pipeline})
+			`).Bind(dbx.Params{
+				"start":    m.Format("2006-01-02 15:04:05.000Z"),
+				"end":      monthEnd.Format("2006-01-02 15:04:05.000Z"),
+				"pipeline": pipeline,
+				"currency": rc,
+			}).All(&wonRows) //nolint:errcheck
+			for _, w := range wonRows {
+				row.Actual += w.Value
+				row.ActualCount++
+				if forecasted[w.ID] {
+					row.ActualForecasted += w.Value
+				}
+			}
+
+			row.Forecast, row.Pipeline = roundCents(row.Forecast), roundCents(row.Pipeline)
+			row.Actual, row.ActualForecasted = roundCents(row.Actual), roundCents(row.ActualForecasted)
+			row.SlippedValue = roundCents(row.SlippedValue)
+			if row.Snapshot != "" && row.Forecast > 0 {
+				accuracy := round1(row.Actual / row.Forecast * 100)
+				errPct := round1((row.Actual - row.Forecast) / row.Forecast * 100)
+				row.AccuracyPct, row.ErrorPct = &accuracy, &errPct
+				totalForecast += row.Forecast
+				totalActual += row.Actual
+				absErrors += math.Abs(errPct)
+				errorMonths++
+			}
+			months = append(months, row)
+		}
+
+		summary := map[string]interface{}{
+			"forecast":     roundCents(totalForecast),
+			"actual":       roundCents(totalActual),
+			"accuracy_pct": nil,
+			// mean absolute percentage error over the months with a forecast
+			"mape": nil,
+		}
+		if errorMonths > 0 {
+			summary["accuracy_pct"] = round1(totalActual / totalForecast * 100)
+			summary["mape"] = round1(absErrors / float64(errorMonths))
+		}
+
+		return e.JSON(http.StatusOK, map[string]interface{}{
+			"months":   months,
+			"summary":  summary,
+			"currency": rc,
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package hooks
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// RegisterPipelineHooks attaches validation hooks to pipelines and pipeline_stages.
+//
+// Hook 1 — pipelines: at most one pipeline is flagged is_default.
+// Hook 2 — pipeline_stages: a stage cannot be both won and lost.
+// Hook 3 — pipeline_stages: a stage still holding leads cannot be deleted.
+func RegisterPipelineHooks(app core.App) {
+	// ── Single default pipeline ───────────────────────────────────────────────
+	app.OnRecordAfterCreateSuccess("pipelines").BindFunc(func(e *core.RecordEvent) error {
+		clearOtherDefaultPipelines(app, e.Record)
+		return e.Next()
+	})
+	app.OnRecordAfterUpdateSuccess("pipelines").BindFunc(func(e *core.RecordEvent) error {
+		clearOtherDefaultPipelines(app, e.Record)
+		return e.Next()
+	})
+
+	// ── Stage flags consistency ───────────────────────────────────────────────
+	validateStage := func(e *core.RecordEvent) error {
+		if e.Record.GetBool("is_won") && e.Record.GetBool("is_lost") {
+			return errors.New("une étape ne peut pas être à la fois gagnée et perdue")
+		}
+		return e.Next()
+	}
+	app.OnRecordCreate("pipeline_stages").BindFunc(validateStage)
+	app.OnRecordUpdate("pipeline_stages").BindFunc(validateStage)
+
+	// ── Stage deletion guard ──────────────────────────────────────────────────
+	app.OnRecordDelete("pipeline_stages").BindFunc(func(e *core.RecordEvent) error {
+		var count int
+		app.DB().NewQuery("SELECT COUNT(*) FROM leads WHERE stage = {:id}"). //nolint:errcheck
+											Bind(dbx.Params{"id": e.Record.Id}).Row(&count)
+		if count > 0 {
+			return fmt.Errorf("impossible de supprimer l'étape \"%s\" : %d opportunité(s) y sont encore", e.Record.GetString("name"), count)
+		}
+		return e.Next()
+	})
+
+	log.Println("[hooks] Pipeline hooks registered (default pipeline, stage validation)")
+}
+
+// clearOtherDefaultPipelines unsets is_default on every other pipeline when
+// the given one is the default.
+func clearOtherDefaultPipelines(app core.App, pipeline *core.Record) {
+	if !pipeline.GetBool("is_default") {
+		return
+	}
+	others, err := app.FindAllRecords("pipelines",
+		dbx.HashExp{"is_default": true},
+		dbx.Not(dbx.HashExp{"id": pipeline.Id}),
+	)
+	if err != nil {
+		return
+	}
+	for _, other := range others {
+		other.Set("is_default", false)
+		if err := app.Save(other); err != nil {
+			log.Printf("[pipelines] failed to unset default on %s: %v", other.Id, err)
+		}
+	}
+}
+
+// findDefaultPipeline returns the pipeline flagged is_default, or the oldest one.
+func findDefaultPipeline(app core.App) (*core.Record, error) {
+	records, err := app.FindRecordsByFilter("pipelines", "is_default = true", "", 1, 0)
+	if err == nil && len(records) > 0 {
+		return records[0], nil
+	}
+	records, err = app.FindRecordsByFilter("pipelines", "", "created", 1, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("aucun pipeline configuré")
+	}
+	return records[0], nil
+}
+
+// findFirstStage returns the lowest-ordered stage of a pipeline.
+func findFirstStage(app core.App, pipelineID string) (*core.Record, error) {
+	records, err := app.FindRecordsByFilter("pipeline_stages",
+		"pipeline = {:pipeline}", "order", 1, 0,
+		dbx.Params{"pipeline": pipelineID},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("le pipeline n'a aucune étape")
+	}
+	return records[0], nil
+}
+
+// resolveLeadStage makes a lead's pipeline, stage and status consistent and
+// returns its stage record. The stage relation wins over the status key:
+//
+//  1. pipeline defaults to the default pipeline
+//  2. stage set → status is copied from stage.key (the stage must belong to the pipeline)
+//  3. else status set → stage is looked up by key within the pipeline
+//  4. else the lead goes to the pipeline's first stage
+//
+// When stageChanged is false (an update that did not touch stage), a changed
+// status is resolved by key instead, so clients that only send status keep working.
+func resolveLeadStage(app core.App, lead *core.Record, stageChanged bool) (*core.Record, error) {
+	pipelineID := lead.GetString("pipeline")
+	if pipelineID == "" {
+		pipeline, err := findDefaultPipeline(app)
+		if err != nil {
+			return nil, err
+		}
+		pipelineID = pipeline.Id
+		lead.Set("pipeline", pipelineID)
+	}
+
+	var stage *core.Record
+	if stageID := lead.GetString("stage"); stageID != "" && stageChanged {
+		s, err := app.FindRecordById("pipeline_stages", stageID)
+		if err != nil {
+			return nil, fmt.Errorf("étape introuvable : %w", err)
+		}
+		stage = s
+	} else if status := lead.GetString("status"); status != "" {
+		s, err := app.FindFirstRecordByFilter("pipeline_stages",
+			"pipeline = {:pipeline} && key = {:key}",
+			dbx.Params{"pipeline": pipelineID, "key": status},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("statut \"%s\" inconnu dans ce pipeline", status)
+		}
+		stage = s
+	} else {
+		s, err := findFirstStage(app, pipelineID)
+		if err != nil {
+			return nil, err
+		}
+		stage = s
+	}
+
+	if stage.GetString("pipeline") != pipelineID {
+		return nil, errors.New("l'étape n'appartient pas au pipeline de l'opportunité")
+	}
+
+	lead.Set("stage", stage.Id)
+	lead.Set("status", stage.GetString("key"))
+	return stage, nil
+}
+
+// isClosedStage reports whether a stage ends the deal (won or lost).
+func isClosedStage(stage *core.Record) bool {
+	return stage != nil && (stage.GetBool("is_won") || stage.GetBool("is_lost"))
+}
+
+// ─── Stage transition rules ──────────────────────────────────────────────────
+
+// requiredFieldLabels names the lead fields a stage can require (pipeline_stages.required_fields).
+var requiredFieldLabels = map[string]string{
+	"value":          "le montant",
+	"contact":        "le contact",
+	"company":        "l'entreprise",
+	"owner":          "le responsable",
+	"expected_close": "la date de clôture prévue",
+	"source":         "la source",
+	"notes":          "les notes",
+}
+
+// validateStageTransition checks that a lead may move from one stage to another:
+// the move must be listed in the target's allowed_from (when set), the target's
+// required_fields must be filled, a lost stage needs a loss close_reason and
+// a won stage only accepts a win close_reason (see validateCloseReason).
+// Errors are keyed by lead field so the UI can show them next to each input.
+func validateStageTransition(app core.App, lead *core.Record, fromStageID string, to *core.Record) error {
+	errs := validation.Errors{}
+
+	if allowed := to.GetStringSlice("allowed_from"); len(allowed) > 0 && !slices.Contains(allowed, fromStageID) {
+		errs["stage"] = validation.NewError("validation_stage_transition",
+			fmt.Sprintf("passage vers l'étape \"%s\" non autorisé depuis l'étape actuelle", to.GetString("name")))
+	}
+
+	for _, field := range to.GetStringSlice("required_fields") {
+		filled := lead.GetString(field) != ""
+		if field == "value" {
+			filled = lead.GetFloat("value") > 0
+		}
+		if !filled {
+			errs[field] = validation.NewError("validation_required",
+				fmt.Sprintf("%s est requis pour l'étape \"%s\"", requiredFieldLabels[field], to.GetString("name")))
+		}
+	}
+
+	if err := validateCloseReason(app, lead, to); err != nil {
+		errs["close_reason"] = err
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validateCloseReason checks the close_reason of a lead in a closing stage: an
+// active deal_reasons entry of the matching type, required when lost.
+func validateCloseReason(app core.App, lead, stage *core.Record) error {
+	if !isClosedStage(stage) {
+		return nil
+	}
+	reasonType := "gain"
+	if stage.GetBool("is_lost") {
+		reasonType = "perte"
+	}
+	reasonID := lead.GetString("close_reason")
+	if reasonID == "" {
+		if stage.GetBool("is_lost") {
+			return validation.NewError("validation_required", "la raison de la perte est requise")
+		}
+		return nil
+	}
+	reason, err := app.FindRecordById("deal_reasons", reasonID)
+	if err != nil || reason.GetString("type") != reasonType {
+		return validation.NewError("validation_invalid_reason", fmt.Sprintf("choisissez une raison de %s", reasonType))
+	}
+	if !reason.GetBool("active") {
+		return validation.NewError("validation_inactive_reason",
+			fmt.Sprintf("la raison \"%s\" n'est plus proposée", reason.GetString("name")))
+	}
+	return nil
+}
+
+// stageAllowsRole reports whether a user role may move leads into the stage.
+func stageAllowsRole(stage *core.Record, role string) bool {
+	roles := stage.GetStringSlice("allowed_roles")
+	return len(roles) == 0 || slices.Contains(roles, role)
+}
@@ -0,0 +1,174 @@
+package hooks
+
+import (
+	"testing"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// testStage builds an unsaved pipeline_stages record.
+func testStage(id, name string, won, lost bool, allowedFrom, requiredFields, allowedRoles []string) *core.Record {
+	col := core.NewBaseCollection("pipeline_stages")
+	col.Fields.Add(
+		&core.TextField{Name: "name"},
+		&core.BoolField{Name: "is_won"},
+		&core.BoolField{Name: "is_lost"},
+		&core.RelationField{Name: "allowed_from", MaxSelect: 50},
+		&core.SelectField{Name: "required_fields", MaxSelect: 7, Values: []string{"value", "contact", "company", "owner", "expected_close", "source", "notes"}},
+		&core.SelectField{Name: "allowed_roles", MaxSelect: 3, Values: []string{"admin", "commercial", "standard"}},
+	)
+	stage := core.NewRecord(col)
+	stage.Id = id
+	stage.Set("name", name)
+	stage.Set("is_won", won)
+	stage.Set("is_lost", lost)
+	stage.Set("allowed_from", allowedFrom)
+	stage.Set("required_fields", requiredFields)
+	stage.Set("allowed_roles", allowedRoles)
+	return stage
+}
+
+// testLead builds an unsaved lead without close_reason (validateCloseReason
+// only reads deal_reasons when one is set).
+func testLead(fields map[string]any) *core.Record {
+	col := core.NewBaseCollection("leads")
+	col.Fields.Add(
+		&core.NumberField{Name: "value"},
+		&core.TextField{Name: "contact"},
+		&core.TextField{Name: "company"},
+		&core.TextField{Name: "owner"},
+		&core.TextField{Name: "expected_close"},
+		&core.TextField{Name: "source"},
+		&core.TextField{Name: "notes"},
+		&core.TextField{Name: "close_reason"},
+	)
+	lead := core.NewRecord(col)
+	for k, v := range fields {
+		lead.Set(k, v)
+	}
+	return lead
+}
+
+func TestValidateStageTransition(t *testing.T) {
+	tests := []struct {
+		name       string
+		lead       map[string]any
+		from       string
+		to         *core.Record
+		wantFields []string
+		wantCodes  map[string]string
+	}{
+		{
+			name: "open stage without rules",
+			from: "nouveau",
+			to:   testStage("qualifie", "Qualifié", false, false, nil, nil, nil),
+		},
+		{
+			name: "allowed predecessor",
+			from: "qualifie",
+			to:   testStage("proposition", "Proposition", false, false, []string{"qualifie"}, nil, nil),
+		},
+		{
+			name:       "stage skipped",
+			from:       "nouveau",
+			to:         testStage("proposition", "Proposition", false, false, []string{"qualifie"}, nil, nil),
+			wantFields: []string{"stage"},
+			wantCodes:  map[string]string{"stage": "validation_stage_transition"},
+		},
+		{
+			name:       "creation in a stage with predecessors",
+			from:       "",
+			to:         testStage("proposition", "Proposition", false, false, []string{"qualifie"}, nil, nil),
+			wantFields: []string{"stage"},
+		},
+		{
+			name: "required fields filled",
+			lead: map[string]any{"value": 1200, "contact": "c1", "expected_close": "2026-12-01 00:00:00.000Z"},
+			from: "qualifie",
+			to:   testStage("proposition", "Proposition", false, false, nil, []string{"value", "contact", "expected_close"}, nil),
+		},
+		{
+			name:       "required fields missing",
+			lead:       map[string]any{"contact": "c1"},
+			from:       "qualifie",
+			to:         testStage("proposition", "Proposition", false, false, nil, []string{"value", "contact", "expected_close"}, nil),
+			wantFields: []string{"value", "expected_close"},
+			wantCodes:  map[string]string{"value": "validation_required", "expected_close": "validation_required"},
+		},
+		{
+			name:       "zero value does not fill value",
+			lead:       map[string]any{"value": 0},
+			from:       "qualifie",
+			to:         testStage("proposition", "Proposition", false, false, nil, []string{"value"}, nil),
+			wantFields: []string{"value"},
+		},
+		{
+			name: "won without reason",
+			from: "negociation",
+			to:   testStage("gagne", "Gagné", true, false, nil, nil, nil),
+		},
+		{
+			name:       "lost without reason",
+			from:       "negociation",
+			to:         testStage("perdu", "Perdu", false, true, nil, nil, nil),
+			wantFields: []string{"close_reason"},
+			wantCodes:  map[string]string{"close_reason": "validation_required"},
+		},
+		{
+			name:       "every rule broken at once",
+			from:       "nouveau",
+			to:         testStage("perdu", "Perdu", false, true, []string{"negociation"}, []string{"owner"}, nil),
+			wantFields: []string{"stage", "owner", "close_reason"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateStageTransition(nil, testLead(tt.lead), tt.from, tt.to)
+			if len(tt.wantFields) == 0 {
+				if err != nil {
+					t.Fatalf("validateStageTransition() = %v, want nil", err)
+				}
+				return
+			}
+			errs, ok := err.(validation.Errors)
+			if !ok {
+				t.Fatalf("validateStageTransition() = %v, want validation.Errors", err)
+			}
+			if len(errs) != len(tt.wantFields) {
+				t.Errorf("got errors on %v, want %v", errs, tt.wantFields)
+			}
+			for _, field := range tt.wantFields {
+				if errs[field] == nil {
+					t.Errorf("missing error on %s in %v", field, errs)
+				}
+			}
+			for field, code := range tt.wantCodes {
+				if verr, ok := errs[field].(validation.Error); !ok || verr.Code() != code {
+					t.Errorf("error on %s = %v, want code %s", field, errs[field], code)
+				}
+			}
+		})
+	}
+}
+
+func TestStageAllowsRole(t *testing.T) {
+	tests := []struct {
+		name  string
+		roles []string
+		role  string
+		want  bool
+	}{
+		{"any role when empty", nil, "standard", true},
+		{"listed role", []string{"admin", "commercial"}, "commercial", true},
+		{"unlisted role", []string{"admin"}, "standard", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stage := testStage("s", "Étape", false, false, nil, nil, tt.roles)
+			if got := stageAllowsRole(stage, tt.role); got != tt.want {
+				t.Errorf("stageAllowsRole(%v, %q) = %v, want %v", tt.roles, tt.role, got, tt.want)
+			}
+		})
+	}
+}
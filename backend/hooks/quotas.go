@@ -0,0 +1,393 @@
+package hooks
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"pocket-crm/services"
+)
+
+// ─── Sales quotas ────────────────────────────────────────────────────────────
+//
+// A quota sets a revenue and/or deal count target for a user or a team over a
+// calendar month or quarter. Attainment counts the leads won (closed_at) in
+// the period: owned by the user, or by the team's current members. A subject
+// without a quarterly quota gets the sum of its monthly quotas of the quarter.
+
+// quotaPeriod is a calendar month or quarter, [Start, End).
+type quotaPeriod struct {
+	Type  string // mois | trimestre
+	Start time.Time
+	End   time.Time
+}
+
+// quotaPeriodAt returns the month or quarter containing t.
+func quotaPeriodAt(periodType string, t time.Time) quotaPeriod {
+	t = t.UTC()
+	month := t.Month()
+	months := 1
+	if periodType == "trimestre" {
+		month = time.Month((int(month)-1)/3*3 + 1)
+		months = 3
+	}
+	start := time.Date(t.Year(), month, 1, 0, 0, 0, 0, time.UTC)
+	return quotaPeriod{Type: periodType, Start: start, End: start.AddDate(0, months, 0)}
+}
+
+// quotaPeriodType maps the stats ?period= values onto quota periods:
+// quarter → trimestre, anything else → mois.
+func quotaPeriodType(period string) string {
+	if period == "quarter" || period == "trimestre" {
+		return "trimestre"
+	}
+	return "mois"
+}
+
+// elapsed is the share of the period already past at now (0–1).
+func (p quotaPeriod) elapsed(now time.Time) float64 {
+	switch {
+	case !now.After(p.Start):
+		return 0
+	case !now.Before(p.End):
+		return 1
+	}
+	return now.Sub(p.Start).Seconds() / p.End.Sub(p.Start).Seconds()
+}
+
+// quotaAttainment is a subject's progress against its quota.
+type quotaAttainment struct {
+	Scope       string `json:"scope"` // user | team
+	SubjectID   string `json:"subject_id"`
+	Name        string `json:"name"`
+	QuotaID     string `json:"quota_id"` // empty when summed from monthly quotas
+	PeriodType  string `json:"period_type"`
+	PeriodStart string `json:"period_start"`
+	PeriodEnd   string `json:"period_end"`
+
+	RevenueTarget    float64 `json:"revenue_target"`
+	Revenue          float64 `json:"revenue"`
+	AttainmentPct    float64 `json:"attainment_pct"`
+	ExpectedToDate   float64 `json:"expected_to_date"`
+	ProjectedRevenue float64 `json:"projected_revenue"`
+	ProjectedPct     float64 `json:"projected_pct"`
+	RevenueGap       float64 `json:"revenue_gap"`
+
+	DealsTarget        int     `json:"deals_target"`
+	Deals              int     `json:"deals"`
+	DealsAttainmentPct float64 `json:"deals_attainment_pct"`
+	ProjectedDeals     float64 `json:"projected_deals"`
+
+	ElapsedPct float64 `json:"elapsed_pct"`
+	DaysLeft   int     `json:"days_left"`
+	// Status: atteint, en_avance (pace above target), en_retard, a_venir
+	Status string `json:"status"`
+}
+
+// RegisterQuotaHooks validates sales quotas and serves
+// GET /api/crm/quotas/attainment.
+func RegisterQuotaHooks(app core.App) {
+	app.OnRecordCreate("sales_quotas").BindFunc(validateSalesQuota)
+	app.OnRecordUpdate("sales_quotas").BindFunc(validateSalesQuota)
+
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.GET("/api/crm/quotas/attainment", buildQuotaAttainment(app)).Bind(apis.RequireAuth())
+		return se.Next()
+	})
+
+	log.Println("[hooks] Quota hooks registered (validation, /api/crm/quotas/attainment)")
+}
+
+// validateSalesQuota requires exactly one of user / team, at least one target,
+// and moves period_start to the first day of its month or quarter.
+func validateSalesQuota(e *core.RecordEvent) error {
+	user, team := e.Record.GetString("user"), e.Record.GetString("team")
+	if (user == "") == (team == "") {
+		return validation.Errors{
+			"user": validation.NewError("validation_quota_subject", "un objectif concerne soit un utilisateur, soit une équipe"),
+		}
+	}
+	if e.Record.GetFloat("revenue_target") <= 0 && e.Record.GetInt("deals_target") <= 0 {
+		return validation.Errors{
+			"revenue_target": validation.NewError("validation_quota_target", "renseignez un objectif de chiffre d'affaires ou de nombre d'affaires"),
+		}
+	}
+
+	start := e.Record.GetDateTime("period_start")
+	if !start.IsZero() {
+		period := quotaPeriodAt(e.Record.GetString("period_type"), start.Time())
+		normalised, _ := types.ParseDateTime(period.Start)
+		e.Record.Set("period_start", normalised)
+	}
+	return e.Next()
+}
+
+// buildQuotaAttainment serves
+// GET /api/crm/quotas/attainment?period=month|quarter&date=&user=&team=&currency=
+// date (YYYY-MM-DD, default today) selects the month or quarter.
+func buildQuotaAttainment(app core.App) func(*core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		q := e.Request.URL.Query()
+		rc, err := statsCurrency(app, e)
+		if err != nil {
+			return e.BadRequestError(err.Error(), nil)
+		}
+		at := time.Now().UTC()
+		if d := q.Get("date"); d != "" {
+			if at, err = time.Parse("2006-01-02", d); err != nil {
+				return e.BadRequestError("date must be YYYY-MM-DD", nil)
+			}
+		}
+		period := quotaPeriodAt(quotaPeriodType(q.Get("period")), at)
+
+		rows, err := computeQuotaAttainment(app, period, rc, time.Now().UTC())
+		if err != nil {
+			return e.InternalServerError("Failed to compute quota attainment", err)
+		}
+		filtered := make([]quotaAttainment, 0, len(rows))
+		for _, r := range rows {
+			if (q.Get("user") != "" && (r.Scope != "user" || r.SubjectID != q.Get("user"))) ||
+				(q.Get("team") != "" && (r.Scope != "team" || r.SubjectID != q.Get("team"))) {
+				continue
+			}
+			filtered = append(filtered, r)
+		}
+
+		return e.JSON(http.StatusOK, map[string]interface{}{
+			"period_type":  period.Type,
+			"period_start": period.Start.Format("2006-01-02"),
+			"period_end":   period.End.AddDate(0, 0, -1).Format("2006-01-02"),
+			"elapsed_pct":  round1(period.elapsed(time.Now().UTC()) * 100),
+			"attainment":   filtered,
+			"currency":     rc,
+		})
+	}
+}
+
+// computeQuotaAttainment returns the attainment of every user and team with a
+// quota for period, users first, best attainment first. Targets and revenue
+// are in currency rc (targets converted at the period start).
+func computeQuotaAttainment(app core.App, period quotaPeriod, rc string, now time.Time) ([]quotaAttainment, error) {
+	type quotaRow struct {
+		ID            string  `db:"id"`
+		User          string  `db:"user"`
+		Team          string  `db:"team"`
+		PeriodType    string  `db:"period_type"`
+		RevenueTarget float64 `db:"revenue_target"`
+		DealsTarget   int     `db:"deals_target"`
+		Currency      string  `db:"currency"`
+	}
+	quotas := make([]quotaRow, 0)
+	// The period's own quotas, plus the monthly quotas inside a quarter
+	err := app.DB().NewQuery(`
+		SELECT id, COALESCE(user, '') AS user, COALESCE(team, '') AS team, period_type,
+		       COALESCE(revenue_target, 0) AS revenue_target, COALESCE(deals_target, 0) AS deals_target,
+		       COALESCE(currency, '') AS currency
+		FROM sales_quotas
+		WHERE period_start >= {:start} AND period_start < {:end}
+		  AND (period_type = {:type} OR {:type} = 'trimestre')
+	`).Bind(dbx.Params{
+		"start": period.Start.Format("2006-01-02 15:04:05.000Z"),
+		"end":   period.End.Format("2006-01-02 15:04:05.000Z"),
+		"type":  period.Type,
+	}).All(&quotas)
+	if err != nil {
+		return nil, err
+	}
+
+	type subjectKey struct{ scope, id string }
+	results := map[subjectKey]*quotaAttainment{}
+	explicit := map[subjectKey]bool{}
+	for _, qr := range quotas {
+		key := subjectKey{"user", qr.User}
+		if qr.Team != "" {
+			key = subjectKey{"team", qr.Team}
+		}
+		own := qr.PeriodType == period.Type
+		if explicit[key] && !own {
+			continue
+		}
+		target := qr.RevenueTarget
+		if qr.Currency != "" && qr.Currency != rc {
+			if target, err = services.ConvertAmount(app, target, qr.Currency, rc, period.Start); err != nil {
+				return nil, err
+			}
+		}
+		r := results[key]
+		if r == nil || (own && !explicit[key]) {
+			// A quarterly quota replaces the monthly ones summed so far
+			r = &quotaAttainment{Scope: key.scope, SubjectID: key.id}
+			results[key] = r
+		}
+		if own {
+			explicit[key] = true
+			r.QuotaID = qr.ID
+		}
+		r.RevenueTarget += target
+		r.DealsTarget += qr.DealsTarget
+	}
+	if len(results) == 0 {
+		return []quotaAttainment{}, nil
+	}
+
+	// Won leads of the period per owner and per owner's team
+	type wonRow struct {
+		Subject string  `db:"subject"`
+		Deals   int     `db:"deals"`
+		Revenue float64 `db:"revenue"`
+	}
+	won := map[subjectKey]wonRow{}
+	for scope, expr := range map[string]string{"user": "l.owner", "team": "u.team"} {
+		rows := make([]wonRow, 0)
+		err := app.DB().NewQuery(`
+			SELECT ` + expr + ` AS subject, COUNT(*) AS deals, COALESCE(SUM(` + leadValueSQL("l") + `), 0) AS revenue
+			FROM leads l
+			JOIN users u ON u.id = l.owner
+			WHERE l.stage IN (SELECT id FROM pipeline_stages WHERE is_won = TRUE)
+			  AND l.closed_at >= {:start} AND l.closed_at < {:end}
+			  AND COALESCE(` + expr + `, '') != ''
+			GROUP BY subject
+		`).Bind(dbx.Params{
+			"start":    period.Start.Format("2006-01-02 15:04:05.000Z"),
+			"end":      period.End.Format("2006-01-02 15:04:05.000Z"),
+			"currency": rc,
+		}).All(&rows)
+		if err != nil {
+			return nil, err
+		}
+		for _, w := range rows {
+			won[subjectKey{scope, w.Subject}] = w
+		}
+	}
+
+	elapsed := period.elapsed(now)
+	daysLeft := 0
+	if now.Before(period.End) {
+		from := period.Start
+		if now.After(from) {
+			from = now
+		}
+		daysLeft = int(period.End.Sub(from).Hours()/24 + 0.999)
+	}
+
+	list := make([]quotaAttainment, 0, len(results))
+	for key, r := range results {
+		collection := "users"
+		if key.scope == "team" {
+			collection = "teams"
+		}
+		if rec, err := app.FindRecordById(collection, key.id); err == nil {
+			r.Name = rec.GetString("name")
+		}
+		r.PeriodType = period.Type
+		r.PeriodStart = period.Start.Format("2006-01-02")
+		r.PeriodEnd = period.End.AddDate(0, 0, -1).Format("2006-01-02")
+		r.RevenueTarget = roundCents(r.RevenueTarget)
+		r.Revenue = roundCents(won[key].Revenue)
+		r.Deals = won[key].Deals
+		r.DaysLeft = daysLeft
+		r.project(elapsed)
+		list = append(list, *r)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Scope != list[j].Scope {
+			return list[i].Scope == "user"
+		}
+		if list[i].AttainmentPct != list[j].AttainmentPct {
+			return list[i].AttainmentPct > list[j].AttainmentPct
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
+
+// project fills the attainment, pace and status from the target and the
+// result so far, elapsed being the share of the period already past.
+func (r *quotaAttainment) project(elapsed float64) {
+	r.ElapsedPct = round1(elapsed * 100)
+	r.ExpectedToDate = roundCents(r.RevenueTarget * elapsed)
+	r.RevenueGap = roundCents(max(0, r.RevenueTarget-r.Revenue))
+	if elapsed > 0 {
+		r.ProjectedRevenue = roundCents(r.Revenue / elapsed)
+		r.ProjectedDeals = round1(float64(r.Deals) / elapsed)
+	}
+	if r.RevenueTarget > 0 {
+		r.AttainmentPct = round1(r.Revenue / r.RevenueTarget * 100)
+		r.ProjectedPct = round1(r.ProjectedRevenue / r.RevenueTarget * 100)
+	}
+	if r.DealsTarget > 0 {
+		r.DealsAttainmentPct = round1(float64(r.Deals) / float64(r.DealsTarget) * 100)
+	}
+	r.Status = quotaStatus(r, elapsed)
+}
+
+// quotaStatus compares the projected result with the target: revenue when a
+// revenue target is set, else the deal count.
+func quotaStatus(r *quotaAttainment, elapsed float64) string {
+	actual, target, projected := r.Revenue, r.RevenueTarget, r.ProjectedRevenue
+	if target <= 0 {
+		actual, target, projected = float64(r.Deals), float64(r.DealsTarget), r.ProjectedDeals
+	}
+	switch {
+	case target > 0 && actual >= target:
+		return "atteint"
+	case elapsed == 0:
+		return "a_venir"
+	case projected >= target:
+		return "en_avance"
+	}
+	return "en_retard"
+}
+
+// quotaGoal sums the attainment rows of one scope (users when any user has a
+// quota, else teams) into the company-wide goal shown on the dashboard.
+// ok is false when the period has no quota.
+func quotaGoal(rows []quotaAttainment) (goal map[string]interface{}, pct float64, ok bool) {
+	scope := ""
+	for _, r := range rows {
+		if scope == "" || r.Scope == "user" {
+			scope = r.Scope
+		}
+	}
+	if scope == "" {
+		return nil, 0, false
+	}
+	var target, revenue, projected, expected float64
+	var dealsTarget, deals int
+	for _, r := range rows {
+		if r.Scope != scope {
+			continue
+		}
+		target += r.RevenueTarget
+		revenue += r.Revenue
+		projected += r.ProjectedRevenue
+		expected += r.ExpectedToDate
+		dealsTarget += r.DealsTarget
+		deals += r.Deals
+	}
+	if target > 0 {
+		pct = revenue / target * 100
+	} else if dealsTarget > 0 {
+		pct = float64(deals) / float64(dealsTarget) * 100
+	}
+	return map[string]interface{}{
+		"scope":             scope,
+		"period_type":       rows[0].PeriodType,
+		"period_start":      rows[0].PeriodStart,
+		"period_end":        rows[0].PeriodEnd,
+		"revenue_target":    roundCents(target),
+		"revenue":           roundCents(revenue),
+		"expected_to_date":  roundCents(expected),
+		"projected_revenue": roundCents(projected),
+		"deals_target":      dealsTarget,
+		"deals":             deals,
+		"elapsed_pct":       rows[0].ElapsedPct,
+	}, pct, true
+}
@@ -0,0 +1,264 @@
+package hooks
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQuotaPeriodAt(t *testing.T) {
+	paris := time.FixedZone("CEST", 2*60*60)
+	tests := []struct {
+		name       string
+		periodType string
+		at         time.Time
+		wantStart  string
+		wantEnd    string
+	}{
+		{"month", "mois", time.Date(2026, 3, 17, 15, 4, 0, 0, time.UTC), "2026-03-01", "2026-04-01"},
+		{"first instant of the month", "mois", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), "2026-03-01", "2026-04-01"},
+		{"december", "mois", time.Date(2026, 12, 31, 23, 0, 0, 0, time.UTC), "2026-12-01", "2027-01-01"},
+		{"first quarter", "trimestre", time.Date(2026, 2, 15, 0, 0, 0, 0, time.UTC), "2026-01-01", "2026-04-01"},
+		{"last month of a quarter", "trimestre", time.Date(2026, 6, 30, 0, 0, 0, 0, time.UTC), "2026-04-01", "2026-07-01"},
+		{"fourth quarter", "trimestre", time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), "2026-10-01", "2027-01-01"},
+		{"converted to UTC", "mois", time.Date(2026, 4, 1, 1, 0, 0, 0, paris), "2026-03-01", "2026-04-01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := quotaPeriodAt(tt.periodType, tt.at)
+			if p.Type != tt.periodType {
+				t.Errorf("Type = %q, want %q", p.Type, tt.periodType)
+			}
+			if got := p.Start.Format("2006-01-02"); got != tt.wantStart {
+				t.Errorf("Start = %s, want %s", got, tt.wantStart)
+			}
+			if got := p.End.Format("2006-01-02"); got != tt.wantEnd {
+				t.Errorf("End = %s, want %s", got, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestQuotaPeriodType(t *testing.T) {
+	tests := []struct {
+		period string
+		want   string
+	}{
+		{"quarter", "trimestre"},
+		{"trimestre", "trimestre"},
+		{"month", "mois"},
+		{"year", "mois"},
+		{"", "mois"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.period, func(t *testing.T) {
+			if got := quotaPeriodType(tt.period); got != tt.want {
+				t.Errorf("quotaPeriodType(%q) = %q, want %q", tt.period, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuotaPeriodElapsed(t *testing.T) {
+	april := quotaPeriodAt("mois", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC))
+	tests := []struct {
+		name string
+		now  time.Time
+		want float64
+	}{
+		{"before the period", time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC), 0},
+		{"at the start", april.Start, 0},
+		{"half way", time.Date(2026, 4, 16, 0, 0, 0, 0, time.UTC), 0.5},
+		{"one day in", time.Date(2026, 4, 2, 0, 0, 0, 0, time.UTC), 1.0 / 30},
+		{"at the end", april.End, 1},
+		{"after the period", time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := april.elapsed(tt.now); got != tt.want {
+				t.Errorf("elapsed(%s) = %v, want %v", tt.now.Format(time.RFC3339), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuotaAttainmentProject(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      quotaAttainment
+		elapsed float64
+		want    quotaAttainment
+	}{
+		{
+			name:    "ahead of pace",
+			in:      quotaAttainment{RevenueTarget: 10000, Revenue: 6000},
+			elapsed: 0.5,
+			want: quotaAttainment{
+				RevenueTarget: 10000, Revenue: 6000, AttainmentPct: 60,
+				ExpectedToDate: 5000, ProjectedRevenue: 12000, ProjectedPct: 120, RevenueGap: 4000,
+				ElapsedPct: 50, Status: "en_avance",
+			},
+		},
+		{
+			name:    "behind pace",
+			in:      quotaAttainment{RevenueTarget: 10000, Revenue: 4000},
+			elapsed: 0.5,
+			want: quotaAttainment{
+				RevenueTarget: 10000, Revenue: 4000, AttainmentPct: 40,
+				ExpectedToDate: 5000, ProjectedRevenue: 8000, ProjectedPct: 80, RevenueGap: 6000,
+				ElapsedPct: 50, Status: "en_retard",
+			},
+		},
+		{
+			name:    "target reached early",
+			in:      quotaAttainment{RevenueTarget: 10000, Revenue: 12500},
+			elapsed: 0.25,
+			want: quotaAttainment{
+				RevenueTarget: 10000, Revenue: 12500, AttainmentPct: 125,
+				ExpectedToDate: 2500, ProjectedRevenue: 50000, ProjectedPct: 500,
+				ElapsedPct: 25, Status: "atteint",
+			},
+		},
+		{
+			name:    "period not started",
+			in:      quotaAttainment{RevenueTarget: 10000},
+			elapsed: 0,
+			want:    quotaAttainment{RevenueTarget: 10000, RevenueGap: 10000, Status: "a_venir"},
+		},
+		{
+			name:    "period over, target missed",
+			in:      quotaAttainment{RevenueTarget: 10000, Revenue: 9000},
+			elapsed: 1,
+			want: quotaAttainment{
+				RevenueTarget: 10000, Revenue: 9000, AttainmentPct: 90,
+				ExpectedToDate: 10000, ProjectedRevenue: 9000, ProjectedPct: 90, RevenueGap: 1000,
+				ElapsedPct: 100, Status: "en_retard",
+			},
+		},
+		{
+			name:    "rounded to one decimal and to the cent",
+			in:      quotaAttainment{RevenueTarget: 3000, Revenue: 1000},
+			elapsed: 1.0 / 3,
+			want: quotaAttainment{
+				RevenueTarget: 3000, Revenue: 1000, AttainmentPct: 33.3,
+				ExpectedToDate: 1000, ProjectedRevenue: 3000, ProjectedPct: 100, RevenueGap: 2000,
+				ElapsedPct: 33.3, Status: "en_avance",
+			},
+		},
+		{
+			name:    "deal count only",
+			in:      quotaAttainment{DealsTarget: 4, Deals: 1},
+			elapsed: 0.2,
+			want: quotaAttainment{
+				DealsTarget: 4, Deals: 1, DealsAttainmentPct: 25, ProjectedDeals: 5,
+				ElapsedPct: 20, Status: "en_avance",
+			},
+		},
+		{
+			name:    "deal count reached",
+			in:      quotaAttainment{DealsTarget: 2, Deals: 2},
+			elapsed: 0.5,
+			want: quotaAttainment{
+				DealsTarget: 2, Deals: 2, DealsAttainmentPct: 100, ProjectedDeals: 4,
+				ElapsedPct: 50, Status: "atteint",
+			},
+		},
+		{
+			name:    "revenue target decides over deals",
+			in:      quotaAttainment{RevenueTarget: 10000, Revenue: 2000, DealsTarget: 2, Deals: 2},
+			elapsed: 0.5,
+			want: quotaAttainment{
+				RevenueTarget: 10000, Revenue: 2000, AttainmentPct: 20,
+				ExpectedToDate: 5000, ProjectedRevenue: 4000, ProjectedPct: 40, RevenueGap: 8000,
+				DealsTarget: 2, Deals: 2, DealsAttainmentPct: 100, ProjectedDeals: 4,
+				ElapsedPct: 50, Status: "en_retard",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.in
+			got.project(tt.elapsed)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("project(%v) = %+v, want %+v", tt.elapsed, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuotaGoal(t *testing.T) {
+	row := func(scope, id string, target, revenue float64, dealsTarget, deals int) quotaAttainment {
+		return quotaAttainment{
+			Scope: scope, SubjectID: id, PeriodType: "mois", PeriodStart: "2026-04-01", PeriodEnd: "2026-04-30",
+			RevenueTarget: target, Revenue: revenue, ExpectedToDate: target / 2, ProjectedRevenue: revenue * 2,
+			DealsTarget: dealsTarget, Deals: deals, ElapsedPct: 50,
+		}
+	}
+	goal := func(scope string, target, revenue float64, dealsTarget, deals int) map[string]interface{} {
+		return map[string]interface{}{
+			"scope":             scope,
+			"period_type":       "mois",
+			"period_start":      "2026-04-01",
+			"period_end":        "2026-04-30",
+			"revenue_target":    target,
+			"revenue":           revenue,
+			"expected_to_date":  target / 2,
+			"projected_revenue": revenue * 2,
+			"deals_target":      dealsTarget,
+			"deals":             deals,
+			"elapsed_pct":       50.0,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		rows     []quotaAttainment
+		wantGoal map[string]interface{}
+		wantPct  float64
+		wantOK   bool
+	}{
+		{name: "no quota", rows: nil},
+		{
+			name: "users are summed, teams ignored",
+			rows: []quotaAttainment{
+				row("team", "t1", 50000, 5000, 0, 0),
+				row("user", "u1", 12000, 4000, 0, 0),
+				row("user", "u2", 8000, 1000, 0, 0),
+			},
+			wantGoal: goal("user", 20000, 5000, 0, 0),
+			wantPct:  25,
+			wantOK:   true,
+		},
+		{
+			name: "teams when no user has a quota",
+			rows: []quotaAttainment{
+				row("team", "t1", 30000, 15000, 0, 0),
+				row("team", "t2", 10000, 5000, 0, 0),
+			},
+			wantGoal: goal("team", 40000, 20000, 0, 0),
+			wantPct:  50,
+			wantOK:   true,
+		},
+		{
+			name: "deal count without revenue target",
+			rows: []quotaAttainment{
+				row("user", "u1", 0, 800, 3, 2),
+				row("user", "u2", 0, 0, 1, 1),
+			},
+			wantGoal: goal("user", 0, 800, 4, 3),
+			wantPct:  75,
+			wantOK:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, pct, ok := quotaGoal(tt.rows)
+			if ok != tt.wantOK || pct != tt.wantPct {
+				t.Errorf("quotaGoal() pct, ok = %v, %v, want %v, %v", pct, ok, tt.wantPct, tt.wantOK)
+			}
+			if !reflect.DeepEqual(got, tt.wantGoal) {
+				t.Errorf("quotaGoal() = %v, want %v", got, tt.wantGoal)
+			}
+		})
+	}
+}
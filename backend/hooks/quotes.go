@@ -0,0 +1,856 @@
+package hooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"pocket-crm/services"
+)
+
+// ─── Quotes (devis) ──────────────────────────────────────────────────────────
+//
+// A quote belongs to a lead and goes through:
+//
+//	brouillon → envoye → accepte | refuse | expire
+//
+// Only drafts can be edited; changing a sent quote means creating a new
+// version (same number, version + 1). Acceptance happens through a signed
+// public link sent with the quote: in one transaction, it marks the quote
+// accepted, creates a draft invoice from its lines and moves the lead to its
+// pipeline's won stage.
+
+// quoteValidityDays is the default validity of a new quote.
+const quoteValidityDays = 30
+
+// quoteContentFields cannot change once a quote has left brouillon.
+var quoteContentFields = []string{"title", "lead", "contact", "company", "currency", "items", "valid_until", "notes"}
+
+// quoteServerFields are never written by API clients.
+var quoteServerFields = []string{"number", "version", "previous_version", "invoice", "accepted_at", "signer_name", "signer_ip"}
+
+// quoteTransitions lists the statuses a quote may move to from each status.
+var quoteTransitions = map[string][]string{
+	"brouillon": {"envoye"},
+	"envoye":    {"accepte", "refuse", "expire"},
+}
+
+var errQuoteNotPending = errors.New("quote is not awaiting an answer")
+
+// RegisterQuoteHooks numbers and prices quotes, enforces their status
+// workflow, serves the quote routes (revise, pdf, send, public acceptance
+// page) and expires unanswered quotes daily.
+func RegisterQuoteHooks(app core.App) {
+	// ── Create request: API clients start a new quote, never a version ────────
+	app.OnRecordCreateRequest("quotes").BindFunc(func(e *core.RecordRequestEvent) error {
+		for _, field := range append([]string{"sent_at", "refused_at", "refusal_reason"}, quoteServerFields...) {
+			e.Record.Set(field, nil)
+		}
+		e.Record.Set("status", "brouillon")
+		if e.Record.GetString("owner") == "" && e.Auth != nil && e.Auth.Collection().Name == "users" {
+			e.Record.Set("owner", e.Auth.Id)
+		}
+		return e.Next()
+	})
+
+	// ── Update request: acceptance and expiry are not manual ──────────────────
+	app.OnRecordUpdateRequest("quotes").BindFunc(func(e *core.RecordRequestEvent) error {
+		// Numbering, versions and signatures are set by the server, drafts included
+		for _, field := range quoteServerFields {
+			e.Record.Set(field, e.Record.Original().Get(field))
+		}
+		status := e.Record.GetString("status")
+		if status != e.Record.Original().GetString("status") && (status == "accepte" || status == "expire") {
+			return e.BadRequestError("Quotes are accepted through their public link and expire automatically", validation.Errors{
+				"status": validation.NewError("validation_quote_status", "ce statut ne peut pas être choisi manuellement"),
+			})
+		}
+		return e.Next()
+	})
+
+	app.OnRecordCreate("quotes").BindFunc(func(e *core.RecordEvent) error {
+		if err := prepareNewQuote(app, e.Record); err != nil {
+			return err
+		}
+		if err := priceQuote(e.Record); err != nil {
+			return err
+		}
+		return e.Next()
+	})
+
+	app.OnRecordUpdate("quotes").BindFunc(func(e *core.RecordEvent) error {
+		original := e.Record.Original()
+		from, to := original.GetString("status"), e.Record.GetString("status")
+		if from != to && !slices.Contains(quoteTransitions[from], to) {
+			return validation.Errors{"status": validation.NewError("validation_quote_transition",
+				fmt.Sprintf("un devis « %s » ne peut pas passer au statut « %s »", from, to))}
+		}
+		if from != "brouillon" {
+			for _, field := range quoteContentFields {
+				if e.Record.GetString(field) != original.GetString(field) {
+					return validation.Errors{field: validation.NewError("validation_quote_locked",
+						"un devis envoyé ne peut plus être modifié, créez-en une nouvelle version")}
+				}
+			}
+		} else if err := priceQuote(e.Record); err != nil {
+			return err
+		}
+
+		now := time.Now().UTC().Format("2006-01-02 15:04:05.000Z")
+		if to == "envoye" && e.Record.GetString("sent_at") == "" {
+			e.Record.Set("sent_at", now)
+		}
+		if to == "refuse" && e.Record.GetString("refused_at") == "" {
+			e.Record.Set("refused_at", now)
+		}
+		return e.Next()
+	})
+
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.POST("/api/crm/quotes/{id}/revise", buildReviseQuote(app)).Bind(apis.RequireAuth())
+		se.Router.GET("/api/crm/quotes/{id}/pdf", buildQuotePDF(app)).Bind(apis.RequireAuth())
+		se.Router.POST("/api/crm/quotes/{id}/send", buildSendQuote(app)).Bind(apis.RequireAuth())
+
+		// ── Public acceptance link (signed, no auth) ──────────────────────────
+		se.Router.GET("/api/crm/public/quotes/{id}", buildPublicQuotePage(app))
+		se.Router.GET("/api/crm/public/quotes/{id}/pdf", buildPublicQuotePDF(app))
+		se.Router.POST("/api/crm/public/quotes/{id}/accept", buildPublicQuoteAnswer(app, true))
+		se.Router.POST("/api/crm/public/quotes/{id}/refuse", buildPublicQuoteAnswer(app, false))
+
+		go func() {
+			expireQuotes(app)
+			ticker := time.NewTicker(24 * time.Hour)
+			defer ticker.Stop()
+			for range ticker.C {
+				expireQuotes(app)
+			}
+		}()
+		return se.Next()
+	})
+
+	log.Println("[hooks] Quote hooks registered (numbering, versions, pdf, send, public acceptance, daily expiry)")
+}
+
+// prepareNewQuote fills a new quote from its lead: number and version,
+// parties, currency, default validity and, when it has none, lines copied
+// from the lead's line items (or a single line worth the lead value).
+func prepareNewQuote(app core.App, quote *core.Record) error {
+	lead, err := app.FindRecordById("leads", quote.GetString("lead"))
+	if err != nil {
+		return validation.Errors{"lead": validation.NewError("validation_invalid_lead", "opportunité introuvable")}
+	}
+
+	now := time.Now().UTC()
+	if number := quote.GetString("number"); number == "" {
+		number, err = nextQuoteNumber(app, now)
+		if err != nil {
+			return err
+		}
+		quote.Set("number", number)
+		quote.Set("version", 1)
+	} else {
+		var last int
+		app.DB().NewQuery("SELECT COALESCE(MAX(version), 0) FROM quotes WHERE number = {:number}").
+			Bind(dbx.Params{"number": number}).Row(&last) //nolint:errcheck
+		quote.Set("version", last+1)
+	}
+	if quote.GetString("status") == "" {
+		quote.Set("status", "brouillon")
+	}
+
+	if quote.GetString("title") == "" {
+		quote.Set("title", lead.GetString("title"))
+	}
+	for _, field := range []string{"contact", "company", "owner"} {
+		if quote.GetString(field) == "" {
+			quote.Set(field, lead.GetString(field))
+		}
+	}
+	quote.Set("currency", lead.GetString("currency"))
+	if quote.GetString("valid_until") == "" {
+		quote.Set("valid_until", now.AddDate(0, 0, quoteValidityDays).Format("2006-01-02")+" 00:00:00.000Z")
+	}
+
+	items, err := quoteItems(quote)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		items, err = leadQuoteItems(app, lead)
+		if err != nil {
+			return err
+		}
+		quote.Set("items", items)
+	}
+	return nil
+}
+
+// nextQuoteNumber returns the next DEV-YYYY-NNNN number of the year.
+func nextQuoteNumber(app core.App, now time.Time) (string, error) {
+	prefix := fmt.Sprintf("DEV-%d-", now.Year())
+	var last int
+	err := app.DB().NewQuery(`
+		SELECT COALESCE(MAX(CAST(SUBSTR(number, {:start}) AS INTEGER)), 0)
+		FROM quotes WHERE number LIKE {:prefix}
+	`).Bind(dbx.Params{"start": len(prefix) + 1, "prefix": prefix + "%"}).Row(&last)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%04d", prefix, last+1), nil
+}
+
+// leadQuoteItems turns a lead's line items into quote lines.
+func leadQuoteItems(app core.App, lead *core.Record) ([]services.LineItem, error) {
+	lines, err := app.FindRecordsByFilter("lead_items", "lead = {:lead}", "order,created", 0, 0, dbx.Params{"lead": lead.Id})
+	if err != nil {
+		return nil, err
+	}
+	items := make([]services.LineItem, 0, len(lines))
+	for _, line := range lines {
+		items = append(items, services.LineItem{
+			Description: line.GetString("description"),
+			Qty:         line.GetFloat("quantity"),
+			UnitPrice:   line.GetFloat("unit_price"),
+			Discount:    line.GetFloat("discount"),
+			TaxRate:     line.GetFloat("tax_rate"),
+		})
+	}
+	if len(items) == 0 && lead.GetFloat("value") > 0 {
+		var taxRate float64
+		if pipeline, err := app.FindRecordById("pipelines", lead.GetString("pipeline")); err == nil {
+			taxRate = pipeline.GetFloat("invoice_tax_rate")
+		}
+		items = append(items, services.LineItem{
+			Description: lead.GetString("title"), Qty: 1, UnitPrice: lead.GetFloat("value"), TaxRate: taxRate,
+		})
+	}
+	return items, nil
+}
+
+func quoteItems(quote *core.Record) ([]services.LineItem, error) {
+	var items []services.LineItem
+	if quote.GetString("items") == "" || quote.GetString("items") == "null" {
+		return items, nil
+	}
+	if err := quote.UnmarshalJSONField("items", &items); err != nil {
+		return nil, validation.Errors{"items": validation.NewError("validation_invalid_items", "format des lignes invalide")}
+	}
+	return items, nil
+}
+
+// priceQuote validates the lines and stores their totals.
+func priceQuote(quote *core.Record) error {
+	items, err := quoteItems(quote)
+	if err != nil {
+		return err
+	}
+	if err := services.ValidateLineItems(items); err != nil {
+		return validation.Errors{"items": validation.NewError("validation_invalid_items", err.Error())}
+	}
+	totals := services.ComputeLineTotals(items)
+	quote.Set("items", items)
+	quote.Set("subtotal", totals.Subtotal)
+	quote.Set("tax_total", totals.TaxTotal)
+	quote.Set("total", totals.Total)
+	return nil
+}
+
+// isLatestQuoteVersion reports whether no newer version of the quote exists.
+func isLatestQuoteVersion(app core.App, quote *core.Record) bool {
+	var newer int
+	app.DB().NewQuery("SELECT COUNT(*) FROM quotes WHERE number = {:number} AND version > {:version}").
+		Bind(dbx.Params{"number": quote.GetString("number"), "version": quote.GetInt("version")}).Row(&newer) //nolint:errcheck
+	return newer == 0
+}
+
+// quoteExpired reports whether today is past the quote's validity date.
+func quoteExpired(quote *core.Record, now time.Time) bool {
+	validUntil := quote.GetString("valid_until")
+	return validUntil != "" && validUntil[:10] < now.Format("2006-01-02")
+}
+
+func canManageQuote(e *core.RequestEvent, quote *core.Record) bool {
+	return e.HasSuperuserAuth() || e.Auth.GetString("role") == "admin" || quote.GetString("owner") == e.Auth.Id
+}
+
+// ─── Revise ──────────────────────────────────────────────────────────────────
+
+func buildReviseQuote(app core.App) func(*core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		quote, err := app.FindRecordById("quotes", e.Request.PathValue("id"))
+		if err != nil {
+			return e.NotFoundError("Quote not found", err)
+		}
+		if !canManageQuote(e, quote) {
+			return e.ForbiddenError("Not allowed to revise this quote", nil)
+		}
+		if quote.GetString("status") == "accepte" {
+			return e.BadRequestError("An accepted quote cannot be revised", nil)
+		}
+		if !isLatestQuoteVersion(app, quote) {
+			return e.BadRequestError("Only the latest version of a quote can be revised", nil)
+		}
+
+		revision := core.NewRecord(quote.Collection())
+		for _, field := range append([]string{"number", "owner"}, quoteContentFields...) {
+			revision.Set(field, quote.Get(field))
+		}
+		revision.Set("valid_until", nil) // new validity period from today
+		revision.Set("status", "brouillon")
+		revision.Set("previous_version", quote.Id)
+		if err := app.Save(revision); err != nil {
+			return e.BadRequestError("Failed to create the new version", err)
+		}
+		return e.JSON(http.StatusOK, revision)
+	}
+}
+
+// ─── PDF ─────────────────────────────────────────────────────────────────────
+
+// quoteDocument gathers what is printed on a quote.
+func quoteDocument(app core.App, quote *core.Record, locale string) (services.QuoteDocument, error) {
+	items, err := quoteItems(quote)
+	if err != nil {
+		return services.QuoteDocument{}, err
+	}
+	acceptURL, err := quotePublicURL(app, quote)
+	if err != nil {
+		return services.QuoteDocument{}, err
+	}
+	doc := services.QuoteDocument{
+		Locale:     locale,
+		SellerName: notificationSender(app).Name,
+		Number:     quote.GetString("number"),
+		Version:    quote.GetInt("version"),
+		IssuedAt:   quote.GetDateTime("created").Time().Format("02/01/2006"),
+		Title:      quote.GetString("title"),
+		Currency:   quote.GetString("currency"),
+		Items:      items,
+		Notes:      services.HTMLToText(quote.GetString("notes")),
+		AcceptURL:  acceptURL,
+	}
+	if sentAt := quote.GetDateTime("sent_at"); !sentAt.IsZero() {
+		doc.IssuedAt = sentAt.Time().Format("02/01/2006")
+	}
+	if validUntil := quote.GetDateTime("valid_until"); !validUntil.IsZero() {
+		doc.ValidUntil = validUntil.Time().Format("02/01/2006")
+	}
+	doc.Totals = services.ComputeLineTotals(doc.Items)
+	if company, err := app.FindRecordById("companies", quote.GetString("company")); err == nil {
+		doc.ClientCompany = company.GetString("name")
+	}
+	if contact, err := app.FindRecordById("contacts", quote.GetString("contact")); err == nil {
+		doc.ClientName = strings.TrimSpace(contact.GetString("first_name") + " " + contact.GetString("last_name"))
+		doc.ClientEmail = contact.GetString("email")
+	}
+	return doc, nil
+}
+
+// quoteLocale is the ?locale= parameter, else the quote contact's language.
+func quoteLocale(app core.App, e *core.RequestEvent, quote *core.Record) string {
+	if l := services.NormalizeLocale(e.Request.URL.Query().Get("locale")); l != "" {
+		return l
+	}
+	if contact, err := app.FindRecordById("contacts", quote.GetString("contact")); err == nil {
+		return services.ResolveContactLocale(app, contact)
+	}
+	return services.DefaultLocale
+}
+
+func quoteFileName(quote *core.Record) string {
+	return fmt.Sprintf("devis-%s-v%d.pdf", quote.GetString("number"), quote.GetInt("version"))
+}
+
+func serveQuotePDF(app core.App, e *core.RequestEvent, quote *core.Record) error {
+	doc, err := quoteDocument(app, quote, quoteLocale(app, e, quote))
+	if err != nil {
+		return e.InternalServerError("Failed to render the quote", err)
+	}
+	e.Response.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, quoteFileName(quote)))
+	return e.Blob(http.StatusOK, "application/pdf", services.RenderQuotePDF(doc))
+}
+
+func buildQuotePDF(app core.App) func(*core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		quote, err := app.FindRecordById("quotes", e.Request.PathValue("id"))
+		if err != nil {
+			return e.NotFoundError("Quote not found", err)
+		}
+		if !canManageQuote(e, quote) {
+			return e.ForbiddenError("Not allowed to view this quote", nil)
+		}
+		return serveQuotePDF(app, e, quote)
+	}
+}
+
+// ─── Send ────────────────────────────────────────────────────────────────────
+
+// buildSendQuote emails the quote PDF to its contact, with the public
+// acceptance link, and marks a draft as sent. Without template_id the
+// built-in localized message is used; templates get the quote_* variables.
+func buildSendQuote(app core.App) func(*core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		var body struct {
+			TemplateID string `json:"template_id"` // optional
+			Locale     string `json:"locale"`      // optional — overrides the contact's locale
+		}
+		if err := e.BindBody(&body); err != nil {
+			return e.BadRequestError("Invalid request body", err)
+		}
+
+		quote, err := app.FindRecordById("quotes", e.Request.PathValue("id"))
+		if err != nil {
+			return e.NotFoundError("Quote not found", err)
+		}
+		if !canManageQuote(e, quote) {
+			return e.ForbiddenError("Not allowed to send this quote", nil)
+		}
+		if status := quote.GetString("status"); status != "brouillon" && status != "envoye" {
+			return e.BadRequestError("Only draft or sent quotes can be sent", nil)
+		}
+		if !isLatestQuoteVersion(app, quote) {
+			return e.BadRequestError("A newer version of this quote exists", nil)
+		}
+		if quote.GetString("status") == "brouillon" && quoteExpired(quote, time.Now().UTC()) {
+			return e.BadRequestError("The quote validity date is in the past", nil)
+		}
+		contact, err := app.FindRecordById("contacts", quote.GetString("contact"))
+		if err != nil || contact.GetString("email") == "" {
+			return e.BadRequestError("The quote has no contact with an email address", nil)
+		}
+
+		locale := services.NormalizeLocale(body.Locale)
+		if locale == "" {
+			locale = services.ResolveContactLocale(app, contact)
+		}
+		doc, err := quoteDocument(app, quote, locale)
+		if err != nil {
+			return e.InternalServerError("Failed to render the quote", err)
+		}
+		total := services.FormatMoney(doc.Totals.Total, doc.Currency, locale)
+		name := strings.TrimSpace(contact.GetString("first_name") + " " + contact.GetString("last_name"))
+
+		params := services.EmailSendParams{
+			TemplateID:         body.TemplateID,
+			RecipientEmail:     contact.GetString("email"),
+			RecipientName:      name,
+			RecipientContactID: contact.Id,
+			LeadID:             quote.GetString("lead"),
+			SentByID:           e.Auth.Id,
+			BaseURL:            app.Settings().Meta.AppURL,
+			Locale:             locale,
+			Variables: map[string]string{
+				"first_name":        contact.GetString("first_name"),
+				"last_name":         contact.GetString("last_name"),
+				"email":             contact.GetString("email"),
+				"quote_number":      doc.Number,
+				"quote_version":     fmt.Sprintf("%d", doc.Version),
+				"quote_title":       doc.Title,
+				"quote_total":       total,
+				"quote_valid_until": doc.ValidUntil,
+				"quote_url":         doc.AcceptURL,
+			},
+			Attachments: map[string][]byte{quoteFileName(quote): services.RenderQuotePDF(doc)},
+		}
+		if body.TemplateID == "" {
+			params.Subject = fmt.Sprintf(services.T(locale, "quote_email.subject"), doc.Number, doc.Title)
+			params.Body = fmt.Sprintf(services.T(locale, "quote_email.body"),
+				html.EscapeString(contact.GetString("first_name")), doc.Number, total, doc.ValidUntil, doc.AcceptURL)
+		}
+		if err := services.SendTemplatedEmail(app, params); err != nil {
+			return e.BadRequestError("Failed to send the quote", err)
+		}
+
+		if quote.GetString("status") == "brouillon" {
+			quote.Set("status", "envoye")
+			if err := app.Save(quote); err != nil {
+				return e.InternalServerError("Quote sent but its status could not be updated", err)
+			}
+		}
+		if lead, err := app.FindRecordById("leads", quote.GetString("lead")); err == nil {
+			createLeadActivity(app, lead, "devis", fmt.Sprintf("Devis %s (v%d) envoyé à %s",
+				doc.Number, doc.Version, contact.GetString("email")))
+		}
+
+		return e.JSON(http.StatusOK, map[string]any{"status": "sent", "quote": quote})
+	}
+}
+
+// ─── Public acceptance link ──────────────────────────────────────────────────
+
+// quoteLinkSecret signs public quote links: QUOTE_LINK_SECRET, else the
+// users collection token secret (rotating it invalidates the links). Without
+// a secret no link is built nor accepted.
+func quoteLinkSecret(app core.App) ([]byte, error) {
+	if secret := os.Getenv("QUOTE_LINK_SECRET"); secret != "" {
+		return []byte(secret), nil
+	}
+	users, err := app.FindCachedCollectionByNameOrId("users")
+	if err != nil {
+		return nil, fmt.Errorf("quote link secret: %w", err)
+	}
+	if users.AuthToken.Secret == "" {
+		return nil, errors.New("quote link secret: the users token secret is empty")
+	}
+	return []byte(users.AuthToken.Secret), nil
+}
+
+// quoteSignature signs a quote version; a revision gets a new link.
+func quoteSignature(app core.App, quote *core.Record) (string, error) {
+	secret, err := quoteLinkSecret(app)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s:%s:%d", quote.Id, quote.GetString("number"), quote.GetInt("version"))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func quotePublicURL(app core.App, quote *core.Record) (string, error) {
+	sig, err := quoteSignature(app, quote)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/api/crm/public/quotes/%s?sig=%s",
+		strings.TrimRight(app.Settings().Meta.AppURL, "/"), quote.Id, sig), nil
+}
+
+// findSignedQuote loads the quote of a public link and its signature, or nil
+// when the id or signature is wrong.
+func findSignedQuote(app core.App, e *core.RequestEvent) (*core.Record, string) {
+	quote, err := app.FindRecordById("quotes", e.Request.PathValue("id"))
+	if err != nil {
+		return nil, ""
+	}
+	want, err := quoteSignature(app, quote)
+	if err != nil {
+		log.Printf("[quotes] cannot verify the link of quote %s: %v", quote.Id, err)
+		return nil, ""
+	}
+	sig := e.Request.URL.Query().Get("sig")
+	if !hmac.Equal([]byte(sig), []byte(want)) {
+		return nil, ""
+	}
+	return quote, sig
+}
+
+func buildPublicQuotePDF(app core.App) func(*core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		quote, _ := findSignedQuote(app, e)
+		if quote == nil {
+			return e.NotFoundError("Quote not found", nil)
+		}
+		return serveQuotePDF(app, e, quote)
+	}
+}
+
+func buildPublicQuotePage(app core.App) func(*core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		quote, sig := findSignedQuote(app, e)
+		if quote == nil {
+			return e.HTML(http.StatusNotFound, quotePage(services.DefaultLocale, services.T(services.DefaultLocale, "quote_page.invalid"), ""))
+		}
+		return e.HTML(http.StatusOK, renderPublicQuote(app, quote, sig, quoteLocale(app, e, quote)))
+	}
+}
+
+// buildPublicQuoteAnswer records the customer's answer. Form posts from the
+// public page get the page back, JSON requests get the quote status.
+func buildPublicQuoteAnswer(app core.App, accept bool) func(*core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		var body struct {
+			Name   string `json:"name" form:"name"`
+			Reason string `json:"reason" form:"reason"`
+		}
+		if err := e.BindBody(&body); err != nil {
+			return e.BadRequestError("Invalid request body", err)
+		}
+		fromForm := strings.HasPrefix(e.Request.Header.Get("Content-Type"), "application/x-www-form-urlencoded")
+
+		quote, sig := findSignedQuote(app, e)
+		if quote == nil {
+			if fromForm {
+				return e.HTML(http.StatusNotFound, quotePage(services.DefaultLocale, services.T(services.DefaultLocale, "quote_page.invalid"), ""))
+			}
+			return e.NotFoundError("Quote not found", nil)
+		}
+		locale := quoteLocale(app, e, quote)
+		name := truncateRunes(strings.TrimSpace(body.Name), 200)
+		if accept && name == "" {
+			return e.BadRequestError("name is required", validation.Errors{
+				"name": validation.NewError("validation_required", "indiquez votre nom pour accepter le devis"),
+			})
+		}
+
+		var answered *core.Record
+		var err error
+		if accept {
+			answered, err = acceptQuote(app, quote.Id, name, e.RealIP())
+		} else {
+			answered, err = refuseQuote(app, quote.Id, name, strings.TrimSpace(body.Reason))
+		}
+		switch {
+		case errors.Is(err, errQuoteNotPending):
+			if fromForm {
+				return e.HTML(http.StatusConflict, renderPublicQuote(app, quote, sig, locale))
+			}
+			return e.Error(http.StatusConflict, "The quote is no longer awaiting an answer", nil)
+		case err != nil:
+			return e.InternalServerError("Failed to record the answer", err)
+		}
+
+		if fromForm {
+			return e.HTML(http.StatusOK, renderPublicQuote(app, answered, sig, locale))
+		}
+		return e.JSON(http.StatusOK, map[string]string{"status": answered.GetString("status")})
+	}
+}
+
+// findPendingQuote loads a quote that can still be answered.
+func findPendingQuote(app core.App, quoteID string) (*core.Record, error) {
+	quote, err := app.FindRecordById("quotes", quoteID)
+	if err != nil {
+		return nil, err
+	}
+	if quote.GetString("status") != "envoye" || quoteExpired(quote, time.Now().UTC()) || !isLatestQuoteVersion(app, quote) {
+		return nil, errQuoteNotPending
+	}
+	return quote, nil
+}
+
+// acceptQuote marks the quote accepted, creates its draft invoice and moves
+// the lead to the won stage of its pipeline in one transaction. A lead the
+// stage rules keep out of the won stage (e.g. missing required fields) stays
+// where it is, with a note: the customer's acceptance is recorded anyway.
+func acceptQuote(app core.App, quoteID, signerName, signerIP string) (*core.Record, error) {
+	var quote *core.Record
+	err := app.RunInTransaction(func(txApp core.App) error {
+		var err error
+		if quote, err = findPendingQuote(txApp, quoteID); err != nil {
+			return err
+		}
+		lead, err := txApp.FindRecordById("leads", quote.GetString("lead"))
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+
+		invoice, err := createQuoteInvoice(txApp, quote, lead, now)
+		if err != nil {
+			return fmt.Errorf("invoice: %w", err)
+		}
+		quote.Set("status", "accepte")
+		quote.Set("accepted_at", now.Format("2006-01-02 15:04:05.000Z"))
+		quote.Set("signer_name", signerName)
+		quote.Set("signer_ip", signerIP)
+		quote.Set("invoice", invoice.Id)
+		if err := txApp.Save(quote); err != nil {
+			return err
+		}
+		createLeadActivity(txApp, lead, "devis", fmt.Sprintf("Devis %s (v%d) accepté par %s — facture brouillon créée",
+			quote.GetString("number"), quote.GetInt("version"), signerName))
+		return moveLeadToWonStage(txApp, lead)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
+
+// createQuoteInvoice creates the draft invoice of an accepted quote.
+func createQuoteInvoice(txApp core.App, quote, lead *core.Record, now time.Time) (*core.Record, error) {
+	col, err := txApp.FindCollectionByNameOrId("invoices")
+	if err != nil {
+		return nil, err
+	}
+	items, err := quoteItems(quote)
+	if err != nil {
+		return nil, err
+	}
+
+	owner := quote.GetString("owner")
+	if owner == "" {
+		owner = lead.GetString("owner")
+	}
+	invoice := core.NewRecord(col)
+	invoice.Set("contact", quote.GetString("contact"))
+	invoice.Set("company", quote.GetString("company"))
+	invoice.Set("lead", lead.Id)
+	invoice.Set("owner", owner)
+	invoice.Set("currency", quote.GetString("currency"))
+	invoice.Set("tax_rate", commonTaxRate(items))
+	invoice.Set("status", "brouillon")
+	invoice.Set("items", items)
+	invoice.Set("notes", fmt.Sprintf("<p>Créée à l'acceptation du devis %s (version %d) le %s.</p>",
+		quote.GetString("number"), quote.GetInt("version"), now.Format("02/01/2006")))
+	if err := txApp.Save(invoice); err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// moveLeadToWonStage moves an open lead to the first won stage of its
+// pipeline. The stage rules are checked first so that a refused move leaves
+// nothing behind but a note on the lead; any other failure is returned.
+func moveLeadToWonStage(txApp core.App, lead *core.Record) error {
+	fromStage := lead.GetString("stage")
+	if stage, err := txApp.FindRecordById("pipeline_stages", fromStage); err == nil && isClosedStage(stage) {
+		return nil
+	}
+	won, err := txApp.FindFirstRecordByFilter("pipeline_stages", "pipeline = {:pipeline} && is_won = true",
+		dbx.Params{"pipeline": lead.GetString("pipeline")})
+	if err != nil {
+		log.Printf("[quotes] no won stage for the pipeline of lead %s", lead.Id)
+		return nil
+	}
+	lead.Set("stage", won.Id)
+	if err := validateStageTransition(txApp, lead, fromStage, won); err != nil {
+		log.Printf("[quotes] lead %s cannot move to stage %q: %v", lead.Id, won.GetString("name"), err)
+		lead.Set("stage", fromStage)
+		createLeadActivity(txApp, lead, "note", fmt.Sprintf("Devis accepté : passage à l'étape \"%s\" impossible (%v)",
+			won.GetString("name"), err))
+		return nil
+	}
+	if err := txApp.Save(lead); err != nil {
+		return fmt.Errorf("move lead to stage %q: %w", won.GetString("name"), err)
+	}
+	return nil
+}
+
+func refuseQuote(app core.App, quoteID, signerName, reason string) (*core.Record, error) {
+	var quote *core.Record
+	err := app.RunInTransaction(func(txApp core.App) error {
+		var err error
+		if quote, err = findPendingQuote(txApp, quoteID); err != nil {
+			return err
+		}
+		reason = truncateRunes(reason, 2000)
+		quote.Set("status", "refuse")
+		quote.Set("signer_name", signerName)
+		quote.Set("refusal_reason", reason)
+		if err := txApp.Save(quote); err != nil {
+			return err
+		}
+		if lead, err := txApp.FindRecordById("leads", quote.GetString("lead")); err == nil {
+			description := fmt.Sprintf("Devis %s (v%d) refusé", quote.GetString("number"), quote.GetInt("version"))
+			if reason != "" {
+				description += " : " + reason
+			}
+			createLeadActivity(txApp, lead, "devis", description)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
+
+// truncateRunes cuts s to at most max characters.
+func truncateRunes(s string, max int) string {
+	if r := []rune(s); len(r) > max {
+		return string(r[:max])
+	}
+	return s
+}
+
+// renderPublicQuote is the page behind the acceptance link (signed by sig):
+// the quote summary and, while it awaits an answer, the accept / refuse forms.
+func renderPublicQuote(app core.App, quote *core.Record, sig, locale string) string {
+	t := func(key string) string { return services.T(locale, key) }
+	number := html.EscapeString(quote.GetString("number"))
+	title := fmt.Sprintf(t("quote_page.title"), number)
+
+	query := "?sig=" + url.QueryEscape(sig) + "&amp;locale=" + locale
+	base := "/api/crm/public/quotes/" + url.PathEscape(quote.Id)
+	var validUntil string
+	if v := quote.GetDateTime("valid_until"); !v.IsZero() {
+		validUntil = v.Time().Format("02/01/2006")
+	}
+
+	var content strings.Builder
+	fmt.Fprintf(&content, "<p>%s</p>\n", html.EscapeString(quote.GetString("title")))
+	fmt.Fprintf(&content, "<p>"+t("quote_page.summary")+"</p>\n",
+		html.EscapeString(services.FormatMoney(quote.GetFloat("total"), quote.GetString("currency"), locale)), validUntil)
+	fmt.Fprintf(&content, `<p><a href="%s/pdf%s">%s</a></p>`+"\n", base, query, t("quote_page.download"))
+
+	switch {
+	case quote.GetString("status") == "accepte":
+		fmt.Fprintf(&content, `<p class="done">`+t("quote_page.accepted")+"</p>",
+			number, quote.GetDateTime("accepted_at").Time().Format("02/01/2006"))
+	case quote.GetString("status") == "refuse":
+		fmt.Fprintf(&content, `<p class="done">`+t("quote_page.refused")+"</p>", number)
+	case !isLatestQuoteVersion(app, quote):
+		fmt.Fprintf(&content, "<p>%s</p>", t("quote_page.superseded"))
+	case quote.GetString("status") != "envoye" || quoteExpired(quote, time.Now().UTC()):
+		fmt.Fprintf(&content, "<p>%s</p>", t("quote_page.expired"))
+	default:
+		fmt.Fprintf(&content, `<form method="post" action="%s/accept%s">
+<label>%s <input name="name" required maxlength="200"></label>
+<button type="submit">%s</button>
+</form>
+<form method="post" action="%s/refuse%s">
+<textarea name="reason" maxlength="2000"></textarea>
+<button type="submit" class="secondary">%s</button>
+</form>`, base, query, t("quote_page.name_label"), t("quote_page.accept"), base, query, t("quote_page.refuse"))
+	}
+	return quotePage(locale, title, content.String())
+}
+
+func quotePage(locale, title, content string) string {
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html lang="%s">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>%s</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; max-width: 640px; margin: 40px auto; padding: 0 16px; color: #222; }
+form { margin: 16px 0; } input, textarea { display: block; width: 100%%; margin: 6px 0 10px; padding: 8px; box-sizing: border-box; }
+button { padding: 10px 18px; border: 0; border-radius: 4px; background: #1f6feb; color: #fff; cursor: pointer; }
+button.secondary { background: #888; } .done { font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>%s</h1>
+%s
+</body>
+</html>`, locale, title, title, content)
+}
+
+// ─── Expiry ──────────────────────────────────────────────────────────────────
+
+// expireQuotes marks sent quotes past their validity date as expired.
+func expireQuotes(app core.App) {
+	today := time.Now().UTC().Format("2006-01-02")
+	quotes, err := app.FindRecordsByFilter("quotes", "status = 'envoye' && valid_until != '' && valid_until < {:today}",
+		"", 0, 0, dbx.Params{"today": today})
+	if err != nil {
+		log.Printf("[quotes] expiry check failed: %v", err)
+		return
+	}
+	for _, quote := range quotes {
+		quote.Set("status", "expire")
+		if err := app.Save(quote); err != nil {
+			log.Printf("[quotes] failed to expire quote %s: %v", quote.Id, err)
+		}
+	}
+	if len(quotes) > 0 {
+		log.Printf("[quotes] %d quote(s) expired", len(quotes))
+	}
+}
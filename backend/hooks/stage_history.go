@@ -0,0 +1,282 @@
+package hooks
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// changedByKey is a non-persisted record key carrying the id of the user who
+// issued an API update, so the record-level hook can attribute the change.
+const changedByKey = "@changed_by"
+
+// recordStageChange appends an entry to lead_stage_history (best-effort).
+// fromStage is nil for the entry written when the lead is created.
+func recordStageChange(app core.App, lead, fromStage, toStage *core.Record, changedBy string) {
+	col, err := app.FindCollectionByNameOrId("lead_stage_history")
+	if err != nil {
+		log.Printf("[leads] lead_stage_history collection not found: %v", err)
+		return
+	}
+	rec := core.NewRecord(col)
+	rec.Set("lead", lead.Id)
+	rec.Set("pipeline", lead.GetString("pipeline"))
+	if fromStage != nil {
+		rec.Set("from_stage", fromStage.Id)
+		rec.Set("from_status", fromStage.GetString("key"))
+	}
+	rec.Set("to_stage", toStage.Id)
+	rec.Set("to_status", toStage.GetString("key"))
+	rec.Set("changed_by", changedBy)
+	changedAt := time.Now().UTC().Format("2006-01-02 15:04:05.000Z")
+	if fromStage == nil && lead.GetString("created") != "" {
+		changedAt = lead.GetString("created") // entry stage: dated from the lead creation
+	}
+	rec.Set("changed_at", changedAt)
+	rec.Set("value", lead.GetFloat("value"))
+	if err := app.Save(rec); err != nil {
+		log.Printf("[leads] failed to record stage history for lead %s: %v", lead.Id, err)
+	}
+}
+
+// leadChangedBy returns the user behind the current change, falling back to
+// the lead owner (same attribution as createLeadActivity).
+func leadChangedBy(lead *core.Record) string {
+	if id, ok := lead.GetRaw(changedByKey).(string); ok && id != "" {
+		return id
+	}
+	return lead.GetString("owner")
+}
+
+// ─── Stage Stats ──────────────────────────────────────────────────────────────
+
+type stageHistoryRow struct {
+	Lead      string  `db:"lead"`
+	ToStage   string  `db:"to_stage"`
+	Owner     string  `db:"owner"`
+	ChangedAt string  `db:"changed_at"`
+	Value     float64 `db:"value"`
+}
+
+// buildStageStats serves GET /api/crm/stats/stages?pipeline=&period=
+//
+//   - time_in_stage: average days spent in each stage (completed stays only)
+//   - conversion:    share of leads entering a stage that moved on to a later
+//     open or won stage (rather than to a lost stage or nowhere yet)
+//   - transitions:   from → to move counts
+//   - velocity:      per owner, (opportunities × win rate × avg won value) / avg cycle days
+//
+// Only history entered within the period counts; the pipeline defaults to
+// the default pipeline.
+func buildStageStats(app core.App) func(*core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		start, _, _ := parsePeriodDates(e)
+		rc, err := statsCurrency(app, e)
+		if err != nil {
+			return e.BadRequestError(err.Error(), nil)
+		}
+
+		pipelineID := e.Request.URL.Query().Get("pipeline")
+		if pipelineID == "" {
+			pipeline, err := findDefaultPipeline(app)
+			if err != nil {
+				return e.NotFoundError("No pipeline configured", err)
+			}
+			pipelineID = pipeline.Id
+		}
+
+		stages, err := app.FindRecordsByFilter("pipeline_stages", "pipeline = {:pipeline}", "order", 0, 0,
+			dbx.Params{"pipeline": pipelineID})
+		if err != nil {
+			return e.InternalServerError("Failed to load stages", err)
+		}
+		stageByID := make(map[string]*core.Record, len(stages))
+		for _, s := range stages {
+			stageByID[s.Id] = s
+		}
+
+		var rows []stageHistoryRow
+		err = app.DB().NewQuery(`
+			SELECT h.lead, COALESCE(h.to_stage, '') AS to_stage, COALESCE(l.owner, '') AS owner,
+			       h.changed_at, ` + fxConvertSQL("h.value", "l.currency", "h.changed_at") + ` AS value
+			FROM lead_stage_history h
+			JOIN leads l ON l.id = h.lead
+			WHERE h.pipeline = {:pipeline}
+			  AND h.lead IN (SELECT lead FROM lead_stage_history WHERE changed_at >= {:start})
+			ORDER BY h.lead, h.changed_at, h.created
+		`).Bind(dbx.Params{"pipeline": pipelineID, "start": start, "currency": rc}).All(&rows)
+		if err != nil {
+			return e.InternalServerError("Failed to load stage history", err)
+		}
+
+		type stageAgg struct {
+			entered  map[string]bool
+			advanced map[string]bool
+			lost     map[string]bool
+			stays    int
+			days     float64
+		}
+		aggs := make(map[string]*stageAgg, len(stages))
+		for _, s := range stages {
+			aggs[s.Id] = &stageAgg{entered: map[string]bool{}, advanced: map[string]bool{}, lost: map[string]bool{}}
+		}
+		type transitionKey struct{ from, to string }
+		transitionCounts := map[transitionKey]int{}
+
+		type ownerAgg struct {
+			opportunities map[string]bool
+			won           int
+			wonValue      float64
+			cycleDays     float64
+		}
+		owners := map[string]*ownerAgg{}
+		leadCreated := map[string]time.Time{}
+
+		for i, r := range rows {
+			at, err := time.Parse("2006-01-02 15:04:05.000Z", r.ChangedAt)
+			if err != nil {
+				continue
+			}
+			stage := stageByID[r.ToStage]
+			first := i == 0 || rows[i-1].Lead != r.Lead
+			if first {
+				leadCreated[r.Lead] = at
+			}
+
+			if stage != nil && r.ChangedAt >= start {
+				aggs[stage.Id].entered[r.Lead] = true
+			}
+
+			if !first {
+				prev := rows[i-1]
+				prevStage := stageByID[prev.ToStage]
+				if prevStage != nil {
+					if prevAt, err := time.Parse("2006-01-02 15:04:05.000Z", prev.ChangedAt); err == nil {
+						aggs[prevStage.Id].stays++
+						aggs[prevStage.Id].days += at.Sub(prevAt).Hours() / 24
+					}
+					if stage != nil {
+						transitionCounts[transitionKey{prevStage.Id, stage.Id}]++
+						if stage.GetBool("is_lost") {
+							aggs[prevStage.Id].lost[r.Lead] = true
+						} else if stage.GetInt("order") > prevStage.GetInt("order") {
+							aggs[prevStage.Id].advanced[r.Lead] = true
+						}
+					}
+				}
+			}
+
+			if r.Owner == "" {
+				continue
+			}
+			o, ok := owners[r.Owner]
+			if !ok {
+				o = &ownerAgg{opportunities: map[string]bool{}}
+				owners[r.Owner] = o
+			}
+			o.opportunities[r.Lead] = true
+			if stage != nil && stage.GetBool("is_won") && r.ChangedAt >= start {
+				o.won++
+				o.wonValue += r.Value
+				o.cycleDays += at.Sub(leadCreated[r.Lead]).Hours() / 24
+			}
+		}
+
+		type timeInStageRow struct {
+			Stage     string  `json:"stage"`
+			StageName string  `json:"stage_name"`
+			AvgDays   float64 `json:"avg_days"`
+			Stays     int     `json:"stays"`
+		}
+		type conversionRow struct {
+			Stage          string  `json:"stage"`
+			StageName      string  `json:"stage_name"`
+			Entered        int     `json:"entered"`
+			Advanced       int     `json:"advanced"`
+			Lost           int     `json:"lost"`
+			ConversionRate float64 `json:"conversion_rate"`
+		}
+		timeInStage := make([]timeInStageRow, 0, len(stages))
+		conversion := make([]conversionRow, 0, len(stages))
+		for _, s := range stages {
+			a := aggs[s.Id]
+			if !s.GetBool("is_won") && !s.GetBool("is_lost") {
+				avg := 0.0
+				if a.stays > 0 {
+					avg = round1(a.days / float64(a.stays))
+				}
+				timeInStage = append(timeInStage, timeInStageRow{s.GetString("key"), s.GetString("name"), avg, a.stays})
+			}
+			entered := len(a.entered)
+			rate := 0.0
+			if entered > 0 {
+				rate = round1(float64(len(a.advanced)) / float64(entered) * 100)
+			}
+			conversion = append(conversion, conversionRow{
+				s.GetString("key"), s.GetString("name"), entered, len(a.advanced), len(a.lost), rate,
+			})
+		}
+
+		type transitionRow struct {
+			From  string `json:"from"`
+			To    string `json:"to"`
+			Count int    `json:"count"`
+		}
+		transitions := make([]transitionRow, 0, len(transitionCounts))
+		for _, from := range stages {
+			for _, to := range stages {
+				if n := transitionCounts[transitionKey{from.Id, to.Id}]; n > 0 {
+					transitions = append(transitions, transitionRow{from.GetString("key"), to.GetString("key"), n})
+				}
+			}
+		}
+
+		type velocityRow struct {
+			UserID        string  `json:"user_id"`
+			Name          string  `json:"name"`
+			Opportunities int     `json:"opportunities"`
+			Won           int     `json:"won"`
+			WinRate       float64 `json:"win_rate"`
+			AvgDealValue  float64 `json:"avg_deal_value"`
+			AvgCycleDays  float64 `json:"avg_cycle_days"`
+			Velocity      float64 `json:"velocity"`
+		}
+		velocity := make([]velocityRow, 0, len(owners))
+		for userID, o := range owners {
+			row := velocityRow{UserID: userID, Opportunities: len(o.opportunities), Won: o.won}
+			if user, err := app.FindRecordById("users", userID); err == nil {
+				row.Name = user.GetString("name")
+			}
+			if row.Opportunities > 0 {
+				row.WinRate = round1(float64(o.won) / float64(row.Opportunities) * 100)
+			}
+			if o.won > 0 {
+				row.AvgDealValue = math.Round(o.wonValue / float64(o.won))
+				row.AvgCycleDays = round1(o.cycleDays / float64(o.won))
+				// Revenue per day: opportunities × win rate × average deal value / cycle length
+				cycle := math.Max(row.AvgCycleDays, 1)
+				row.Velocity = math.Round(float64(row.Opportunities) * (row.WinRate / 100) * row.AvgDealValue / cycle)
+			}
+			velocity = append(velocity, row)
+		}
+		sort.Slice(velocity, func(i, j int) bool { return velocity[i].Velocity > velocity[j].Velocity })
+
+		return e.JSON(http.StatusOK, map[string]interface{}{
+			"pipeline":      pipelineID,
+			"time_in_stage": timeInStage,
+			"conversion":    conversion,
+			"transitions":   transitions,
+			"velocity":      velocity,
+			"currency":      rc,
+		})
+	}
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
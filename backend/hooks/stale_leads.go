@@ -0,0 +1,249 @@
+package hooks
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/mailer"
+	"pocket-crm/services"
+)
+
+// staleLeadRow is an open lead whose stage has a rotting threshold.
+type staleLeadRow struct {
+	ID           string  `db:"id" json:"id"`
+	Title        string  `db:"title" json:"title"`
+	Status       string  `db:"status" json:"status"`
+	StageName    string  `db:"stage_name" json:"stage_name"`
+	Pipeline     string  `db:"pipeline" json:"pipeline"`
+	Owner        string  `db:"owner" json:"owner"`
+	OwnerName    string  `db:"owner_name" json:"owner_name"`
+	Contact      string  `db:"contact" json:"contact"`
+	Company      string  `db:"company" json:"company"`
+	Value        float64 `db:"value" json:"value"`
+	RottingDays  int     `db:"rotting_days" json:"rotting_days"`
+	LastActivity string  `db:"last_activity" json:"last_activity"`
+	StaleSince   string  `db:"stale_since" json:"stale_since"`
+	DaysIdle     int     `db:"-" json:"days_idle"`
+}
+
+// RegisterStaleLeadMonitor flags open leads idle for longer than their stage's
+// rotting_days (no activity and no stage change), once at startup and then
+// every 24 h. Each newly stale lead gets one follow-up task for its owner and
+// owners receive a digest email. GET /api/crm/leads/stale lists rotting deals.
+func RegisterStaleLeadMonitor(app core.App) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.GET("/api/crm/leads/stale", buildStaleLeads(app)).Bind(apis.RequireAuth())
+
+		go func() {
+			checkStaleLeads(app)
+			ticker := time.NewTicker(24 * time.Hour)
+			defer ticker.Stop()
+			for range ticker.C {
+				checkStaleLeads(app)
+			}
+		}()
+		return se.Next()
+	})
+	log.Println("[hooks] Stale lead monitor registered (24h interval, /api/crm/leads/stale)")
+}
+
+// findStaleLeads returns open leads idle for at least their stage's rotting_days.
+// The last activity is the latest of: lead creation, stage change, or a
+// user-logged activity (automatic creation/attribution entries do not count).
+func findStaleLeads(app core.App, now time.Time) ([]staleLeadRow, error) {
+	rows := make([]staleLeadRow, 0)
+	err := app.DB().NewQuery(`
+		SELECT l.id, l.title, l.status, ps.name AS stage_name, COALESCE(l.pipeline, '') AS pipeline,
+		       COALESCE(l.owner, '') AS owner, COALESCE(u.name, '') AS owner_name,
+		       COALESCE(l.contact, '') AS contact, COALESCE(l.company, '') AS company,
+		       COALESCE(l.value, 0) AS value, CAST(ps.rotting_days AS INTEGER) AS rotting_days,
+		       COALESCE(l.stale_since, '') AS stale_since,
+		       MAX(
+		           l.created,
+		           COALESCE((SELECT MAX(a.created) FROM activities a
+		                     WHERE a.lead = l.id AND a.type NOT IN ('creation', 'attribution')), ''),
+		           COALESCE((SELECT MAX(h.changed_at) FROM lead_stage_history h WHERE h.lead = l.id), '')
+		       ) AS last_activity
+		FROM leads l
+		JOIN pipeline_stages ps ON ps.id = l.stage
+		LEFT JOIN users u ON u.id = l.owner
+		WHERE ps.is_won = FALSE AND ps.is_lost = FALSE AND ps.rotting_days > 0
+	`).All(&rows)
+	if err != nil {
+		return nil, err
+	}
+
+	stale := make([]staleLeadRow, 0, len(rows))
+	for _, r := range rows {
+		last, err := time.Parse("2006-01-02 15:04:05.000Z", r.LastActivity)
+		if err != nil {
+			continue
+		}
+		r.DaysIdle = int(now.Sub(last).Hours() / 24)
+		if r.DaysIdle >= r.RottingDays {
+			stale = append(stale, r)
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].DaysIdle > stale[j].DaysIdle })
+	return stale, nil
+}
+
+// checkStaleLeads updates leads.stale_since, creates the follow-up tasks and
+// sends one digest per owner listing the leads that became stale in this run.
+func checkStaleLeads(app core.App) {
+	now := time.Now().UTC()
+	stale, err := findStaleLeads(app, now)
+	if err != nil {
+		log.Printf("[stale] Failed to list stale leads: %v", err)
+		return
+	}
+
+	// Clear the flag on leads that moved again
+	staleIDs := make(map[string]bool, len(stale))
+	for _, r := range stale {
+		staleIDs[r.ID] = true
+	}
+	flagged, err := app.FindAllRecords("leads", dbx.NewExp("stale_since != '' AND stale_since IS NOT NULL"))
+	if err == nil {
+		for _, lead := range flagged {
+			if staleIDs[lead.Id] {
+				continue
+			}
+			lead.Set("stale_since", "")
+			if err := app.Save(lead); err != nil {
+				log.Printf("[stale] Failed to clear lead %s: %v", lead.Id, err)
+			}
+		}
+	}
+
+	digests := map[string][]staleLeadRow{}
+	var created int
+	for _, r := range stale {
+		if r.StaleSince == "" {
+			if lead, err := app.FindRecordById("leads", r.ID); err == nil {
+				lead.Set("stale_since", now.Format("2006-01-02 15:04:05.000Z"))
+				if err := app.Save(lead); err != nil {
+					log.Printf("[stale] Failed to flag lead %s: %v", r.ID, err)
+				}
+			}
+		}
+
+		isNew, err := ensureFollowUpTask(app, r, now)
+		if err != nil {
+			log.Printf("[stale] Failed to create follow-up task for lead %s: %v", r.ID, err)
+			continue
+		}
+		if isNew {
+			created++
+			if r.Owner != "" {
+				digests[r.Owner] = append(digests[r.Owner], r)
+			}
+		}
+	}
+
+	for ownerID, leads := range digests {
+		sendStaleDigestEmail(app, ownerID, leads)
+	}
+	log.Printf("[stale] %d stale leads, %d follow-up tasks created", len(stale), created)
+}
+
+// ensureFollowUpTask creates a "suivi" task on the lead unless an open one
+// already exists, so a rotting lead never gets more than one pending reminder.
+func ensureFollowUpTask(app core.App, r staleLeadRow, now time.Time) (bool, error) {
+	var open int
+	app.DB().NewQuery(`
+		SELECT COUNT(*) FROM tasks
+		WHERE lead = {:lead} AND type = 'suivi' AND status IN ('a_faire', 'en_cours')
+	`).Bind(dbx.Params{"lead": r.ID}).Row(&open) //nolint:errcheck
+	if open > 0 {
+		return false, nil
+	}
+
+	col, err := app.FindCollectionByNameOrId("tasks")
+	if err != nil {
+		return false, err
+	}
+	locale := services.DefaultLocale
+	if owner, err := app.FindRecordById("users", r.Owner); err == nil {
+		locale = services.ResolveUserLocale(owner)
+	}
+
+	task := core.NewRecord(col)
+	task.Set("title", fmt.Sprintf(services.T(locale, "stale_task.title"), r.Title))
+	task.Set("description", fmt.Sprintf(services.T(locale, "stale_task.description"), r.DaysIdle, r.StageName))
+	task.Set("type", "suivi")
+	task.Set("status", "a_faire")
+	task.Set("priority", "haute")
+	task.Set("due_date", now.Format("2006-01-02 15:04:05.000Z"))
+	task.Set("lead", r.ID)
+	if r.Owner != "" {
+		task.Set("assignee", r.Owner)
+		task.Set("created_by", r.Owner)
+	}
+	if r.Contact != "" {
+		task.Set("contact", r.Contact)
+	}
+	if r.Company != "" {
+		task.Set("company", r.Company)
+	}
+	if err := app.Save(task); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// sendStaleDigestEmail sends an owner the list of their newly stale leads (best-effort).
+func sendStaleDigestEmail(app core.App, ownerID string, leads []staleLeadRow) {
+	owner, err := app.FindRecordById("users", ownerID)
+	if err != nil || owner.GetString("email") == "" {
+		return
+	}
+	locale := services.ResolveUserLocale(owner)
+
+	var items strings.Builder
+	for _, l := range leads {
+		items.WriteString(fmt.Sprintf(services.T(locale, "stale_digest.item"), l.Title, l.StageName, l.DaysIdle))
+	}
+
+	msg := &mailer.Message{
+		From:    notificationSender(app),
+		To:      []mail.Address{{Address: owner.GetString("email"), Name: owner.GetString("name")}},
+		Subject: fmt.Sprintf(services.T(locale, "stale_digest.subject"), len(leads)),
+		HTML:    fmt.Sprintf(services.T(locale, "stale_digest.body"), owner.GetString("name"), items.String()),
+	}
+	if err := app.NewMailClient().Send(msg); err != nil {
+		log.Printf("[stale] failed to send digest to %s: %v", owner.GetString("email"), err)
+	}
+}
+
+// buildStaleLeads serves GET /api/crm/leads/stale?owner=&pipeline=
+func buildStaleLeads(app core.App) func(*core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		stale, err := findStaleLeads(app, time.Now().UTC())
+		if err != nil {
+			return e.InternalServerError("Failed to list stale leads", err)
+		}
+
+		q := e.Request.URL.Query()
+		owner, pipeline := q.Get("owner"), q.Get("pipeline")
+		items := make([]staleLeadRow, 0, len(stale))
+		for _, r := range stale {
+			if (owner == "" || r.Owner == owner) && (pipeline == "" || r.Pipeline == pipeline) {
+				items = append(items, r)
+			}
+		}
+
+		return e.JSON(http.StatusOK, map[string]interface{}{
+			"total": len(items),
+			"items": items,
+		})
+	}
+}
@@ -21,9 +21,16 @@ func RegisterStatsRoutes(app core.App) {
 		se.Router.GET("/api/crm/stats/commercials", buildCommercialStats(app)).Bind(apis.RequireAuth())
 		se.Router.GET("/api/crm/stats/financial", buildFinancialStats(app)).Bind(apis.RequireAuth())
 		se.Router.GET("/api/crm/stats/marketing", buildMarketingStats(app)).Bind(apis.RequireAuth())
+		se.Router.GET("/api/crm/stats/stages", buildStageStats(app)).Bind(apis.RequireAuth())
+		se.Router.GET("/api/crm/stats/win-loss", buildWinLossStats(app)).Bind(apis.RequireAuth())
+		se.Router.GET("/api/crm/stats/pipeline-history", buildPipelineHistory(app)).Bind(apis.RequireAuth())
+		se.Router.GET("/api/crm/stats/pipeline-waterfall", buildPipelineWaterfall(app)).Bind(apis.RequireAuth())
+		se.Router.GET("/api/crm/stats/forecast-accuracy", buildForecastAccuracy(app)).Bind(apis.RequireAuth())
+		se.Router.GET("/api/crm/stats/close-date-slippage", buildCloseDateSlippage(app)).Bind(apis.RequireAuth())
+		se.Router.GET("/api/crm/stats/mrr", buildMRRStats(app)).Bind(apis.RequireAuth())
 		return se.Next()
 	})
-	log.Println("[hooks] Stats routes registered (dashboard, sales, clients, commercials, financial, marketing)")
+	log.Println("[hooks] Stats routes registered (dashboard, sales, clients, commercials, financial, marketing, stages, win-loss, pipeline-history, pipeline-waterfall, forecast-accuracy, close-date-slippage, mrr)")
 }
 
 // parsePeriodDates returns ISO8601 strings for current period start,
@@ -71,16 +78,20 @@ func evolutionPct(current, previous float64) float64 {
 func buildDashboardStats(app core.App) func(*core.RequestEvent) error {
 	return func(e *core.RequestEvent) error {
 		start, prevStart, prevEnd := parsePeriodDates(e)
+		rc, err := statsCurrency(app, e)
+		if err != nil {
+			return e.BadRequestError(err.Error(), nil)
+		}
 
 		// Revenue — current period
 		var revCurrent float64
-		app.DB().NewQuery(`SELECT COALESCE(SUM(value), 0) FROM leads WHERE status = 'gagne' AND closed_at >= {:start}`). //nolint:errcheck
-			Bind(dbx.Params{"start": start}).Row(&revCurrent)
+		app.DB().NewQuery(`SELECT COALESCE(SUM(`+leadValueSQL("leads")+`), 0) FROM leads WHERE stage IN (SELECT id FROM pipeline_stages WHERE is_won = TRUE) AND closed_at >= {:start}`). //nolint:errcheck
+			Bind(dbx.Params{"start": start, "currency": rc}).Row(&revCurrent)
 
 		// Revenue — previous period
 		var revPrevious float64
-		app.DB().NewQuery(`SELECT COALESCE(SUM(value), 0) FROM leads WHERE status = 'gagne' AND closed_at >= {:start} AND closed_at < {:end}`). //nolint:errcheck
-			Bind(dbx.Params{"start": prevStart, "end": prevEnd}).Row(&revPrevious)
+		app.DB().NewQuery(`SELECT COALESCE(SUM(`+leadValueSQL("leads")+`), 0) FROM leads WHERE stage IN (SELECT id FROM pipeline_stages WHERE is_won = TRUE) AND closed_at >= {:start} AND closed_at < {:end}`). //nolint:errcheck
+			Bind(dbx.Params{"start": prevStart, "end": prevEnd, "currency": rc}).Row(&revPrevious)
 
 		// New prospects — current period
 		var prospectsCurrent int
@@ -104,17 +115,22 @@ func buildDashboardStats(app core.App) func(*core.RequestEvent) error {
 
 		// Pipeline by stage
 		type pipelineStageRow struct {
-			Stage  string  `db:"stage" json:"stage"`
-			Count  int     `db:"count" json:"count"`
-			Amount float64 `db:"amount" json:"amount"`
+			Stage     string  `db:"stage" json:"stage"`
+			StageName string  `db:"stage_name" json:"stage_name"`
+			Pipeline  string  `db:"pipeline" json:"pipeline"`
+			Count     int     `db:"count" json:"count"`
+			Amount    float64 `db:"amount" json:"amount"`
 		}
 		pipelineRows := make([]pipelineStageRow, 0)
 		app.DB().NewQuery(`
-			SELECT status AS stage, COUNT(*) AS count, COALESCE(SUM(value), 0) AS amount
-			FROM leads
-			WHERE status NOT IN ('gagne', 'perdu')
-			GROUP BY status
-		`).All(&pipelineRows) //nolint:errcheck
+			SELECT ps.key AS stage, ps.name AS stage_name, ps.pipeline AS pipeline,
+			       COUNT(*) AS count, COALESCE(SUM(`+leadValueSQL("l")+`), 0) AS amount
+			FROM leads l
+			JOIN pipeline_stages ps ON ps.id = l.stage
+			WHERE ps.is_won = FALSE AND ps.is_lost = FALSE
+			GROUP BY ps.id
+			ORDER BY ps.pipeline, ps."order"
+		`).Bind(dbx.Params{"currency": rc}).All(&pipelineRows) //nolint:errcheck
 
 		// Recent activities (last 10)
 		type activityRow struct {
@@ -143,16 +159,25 @@ func buildDashboardStats(app core.App) func(*core.RequestEvent) error {
 		}
 		revTrendRows := make([]monthRevRow, 0)
 		app.DB().NewQuery(`
-			SELECT strftime('%Y-%m', closed_at) AS month, COALESCE(SUM(value), 0) AS revenue
+			SELECT strftime('%Y-%m', closed_at) AS month, COALESCE(SUM(`+leadValueSQL("leads")+`), 0) AS revenue
 			FROM leads
-			WHERE status = 'gagne'
+			WHERE stage IN (SELECT id FROM pipeline_stages WHERE is_won = TRUE)
 			GROUP BY month
 			ORDER BY month ASC
-		`).All(&revTrendRows) //nolint:errcheck
+		`).Bind(dbx.Params{"currency": rc}).All(&revTrendRows) //nolint:errcheck
 
-		// Revenue goal: current vs previous period as percentage
+		// Revenue goal: attainment of the current month / quarter quotas,
+		// else current vs previous period as percentage
 		goalPct := 0.0
-		if revPrevious > 0 {
+		goalPeriod := quotaPeriodAt(quotaPeriodType(e.Request.URL.Query().Get("period")), time.Now().UTC())
+		quotaRows, err := computeQuotaAttainment(app, goalPeriod, rc, time.Now().UTC())
+		if err != nil {
+			log.Printf("[stats] quota attainment: %v", err)
+		}
+		goal, quotaPct, hasQuota := quotaGoal(quotaRows)
+		if hasQuota {
+			goalPct = quotaPct
+		} else if revPrevious > 0 {
 			goalPct = revCurrent / revPrevious * 100
 		} else if revCurrent > 0 {
 			goalPct = 100
@@ -175,6 +200,8 @@ func buildDashboardStats(app core.App) func(*core.RequestEvent) error {
 			"recent_activities": activityRows,
 			"revenue_trend":     revTrendRows,
 			"revenue_goal_pct":  fmt.Sprintf("%.1f", goalPct),
+			"revenue_goal":      goal,
+			"currency":          rc,
 		})
 	}
 }
@@ -184,6 +211,10 @@ func buildDashboardStats(app core.App) func(*core.RequestEvent) error {
 func buildSalesStats(app core.App) func(*core.RequestEvent) error {
 	return func(e *core.RequestEvent) error {
 		start, _, _ := parsePeriodDates(e)
+		rc, err := statsCurrency(app, e)
+		if err != nil {
+			return e.BadRequestError(err.Error(), nil)
+		}
 
 		// Revenue by month (all time, ascending)
 		type monthRevRow struct {
@@ -192,12 +223,12 @@ func buildSalesStats(app core.App) func(*core.RequestEvent) error {
 		}
 		revenueByMonth := make([]monthRevRow, 0)
 		app.DB().NewQuery(`
-			SELECT strftime('%Y-%m', closed_at) AS month, COALESCE(SUM(value), 0) AS revenue
+			SELECT strftime('%Y-%m', closed_at) AS month, COALESCE(SUM(`+leadValueSQL("leads")+`), 0) AS revenue
 			FROM leads
-			WHERE status = 'gagne'
+			WHERE stage IN (SELECT id FROM pipeline_stages WHERE is_won = TRUE)
 			GROUP BY month
 			ORDER BY month ASC
-		`).All(&revenueByMonth) //nolint:errcheck
+		`).Bind(dbx.Params{"currency": rc}).All(&revenueByMonth) //nolint:errcheck
 
 		// Revenue by salesperson (current period)
 		type salesRow struct {
@@ -207,43 +238,52 @@ func buildSalesStats(app core.App) func(*core.RequestEvent) error {
 		}
 		bySalesperson := make([]salesRow, 0)
 		app.DB().NewQuery(`
-			SELECT COALESCE(u.name, 'N/A') AS name, COALESCE(SUM(l.value), 0) AS revenue, COUNT(*) AS deals
+			SELECT COALESCE(u.name, 'N/A') AS name, COALESCE(SUM(`+leadValueSQL("l")+`), 0) AS revenue, COUNT(*) AS deals
 			FROM leads l
 			LEFT JOIN users u ON l.owner = u.id
-			WHERE l.status = 'gagne' AND l.closed_at >= {:start}
+			WHERE l.stage IN (SELECT id FROM pipeline_stages WHERE is_won = TRUE) AND l.closed_at >= {:start}
 			GROUP BY l.owner
 			ORDER BY revenue DESC
-		`).Bind(dbx.Params{"start": start}).All(&bySalesperson) //nolint:errcheck
+		`).Bind(dbx.Params{"start": start, "currency": rc}).All(&bySalesperson) //nolint:errcheck
 
 		// Pipeline distribution (active leads only)
 		type pipeRow struct {
-			Stage  string  `db:"stage" json:"stage"`
-			Count  int     `db:"count" json:"count"`
-			Amount float64 `db:"amount" json:"amount"`
+			Stage     string  `db:"stage" json:"stage"`
+			StageName string  `db:"stage_name" json:"stage_name"`
+			Pipeline  string  `db:"pipeline" json:"pipeline"`
+			Count     int     `db:"count" json:"count"`
+			Amount    float64 `db:"amount" json:"amount"`
 		}
 		pipeline := make([]pipeRow, 0)
 		app.DB().NewQuery(`
-			SELECT status AS stage, COUNT(*) AS count, COALESCE(SUM(value), 0) AS amount
-			FROM leads
-			WHERE status NOT IN ('gagne', 'perdu')
-			GROUP BY status
-		`).All(&pipeline) //nolint:errcheck
+			SELECT ps.key AS stage, ps.name AS stage_name, ps.pipeline AS pipeline,
+			       COUNT(*) AS count, COALESCE(SUM(`+leadValueSQL("l")+`), 0) AS amount
+			FROM leads l
+			JOIN pipeline_stages ps ON ps.id = l.stage
+			WHERE ps.is_won = FALSE AND ps.is_lost = FALSE
+			GROUP BY ps.id
+			ORDER BY ps.pipeline, ps."order"
+		`).Bind(dbx.Params{"currency": rc}).All(&pipeline) //nolint:errcheck
 
 		// Conversion funnel (all stages)
 		type funnelRow struct {
-			Stage string `db:"stage" json:"stage"`
-			Count int    `db:"count" json:"count"`
+			Stage     string `db:"stage" json:"stage"`
+			StageName string `db:"stage_name" json:"stage_name"`
+			Pipeline  string `db:"pipeline" json:"pipeline"`
+			Count     int    `db:"count" json:"count"`
 		}
 		funnel := make([]funnelRow, 0)
 		app.DB().NewQuery(`
-			SELECT status AS stage, COUNT(*) AS count
-			FROM leads
-			GROUP BY status
+			SELECT ps.key AS stage, ps.name AS stage_name, ps.pipeline AS pipeline, COUNT(*) AS count
+			FROM leads l
+			JOIN pipeline_stages ps ON ps.id = l.stage
+			GROUP BY ps.id
+			ORDER BY ps.pipeline, ps."order"
 		`).All(&funnel) //nolint:errcheck
 
 		// Conversion rate (won / total)
 		var wonCount, totalCount int
-		app.DB().NewQuery(`SELECT COUNT(*) FROM leads WHERE status = 'gagne'`). //nolint:errcheck
+		app.DB().NewQuery(`SELECT COUNT(*) FROM leads WHERE stage IN (SELECT id FROM pipeline_stages WHERE is_won = TRUE)`). //nolint:errcheck
 			Row(&wonCount)
 		app.DB().NewQuery(`SELECT COUNT(*) FROM leads`). //nolint:errcheck
 			Row(&totalCount)
@@ -257,7 +297,7 @@ func buildSalesStats(app core.App) func(*core.RequestEvent) error {
 		app.DB().NewQuery(`
 			SELECT COALESCE(AVG(julianday(closed_at) - julianday(created)), 0)
 			FROM leads
-			WHERE status = 'gagne' AND closed_at != '' AND closed_at IS NOT NULL
+			WHERE stage IN (SELECT id FROM pipeline_stages WHERE is_won = TRUE) AND closed_at != '' AND closed_at IS NOT NULL
 		`).Row(&avgCloseDays) //nolint:errcheck
 
 		return e.JSON(http.StatusOK, map[string]interface{}{
@@ -267,6 +307,7 @@ func buildSalesStats(app core.App) func(*core.RequestEvent) error {
 			"funnel":           funnel,
 			"conversion_rate":  fmt.Sprintf("%.1f", convRate),
 			"avg_close_days":   fmt.Sprintf("%.0f", avgCloseDays),
+			"currency":         rc,
 		})
 	}
 }
@@ -276,6 +317,10 @@ func buildSalesStats(app core.App) func(*core.RequestEvent) error {
 func buildClientStats(app core.App) func(*core.RequestEvent) error {
 	return func(e *core.RequestEvent) error {
 		start, _, _ := parsePeriodDates(e)
+		rc, err := statsCurrency(app, e)
+		if err != nil {
+			return e.BadRequestError(err.Error(), nil)
+		}
 
 		// Total contacts with 'client' tag
 		var totalClients int
@@ -292,7 +337,7 @@ func buildClientStats(app core.App) func(*core.RequestEvent) error {
 		app.DB().NewQuery(`
 			SELECT COUNT(DISTINCT l.contact)
 			FROM leads l
-			WHERE l.status = 'gagne' AND l.closed_at >= {:start} AND l.contact != ''
+			WHERE l.stage IN (SELECT id FROM pipeline_stages WHERE is_won = TRUE) AND l.closed_at >= {:start} AND l.contact != ''
 		`).Bind(dbx.Params{"start": start}).Row(&activeClients) //nolint:errcheck
 
 		// Segmentation by city
@@ -331,12 +376,12 @@ func buildClientStats(app core.App) func(*core.RequestEvent) error {
 		var avgBasket float64
 		app.DB().NewQuery(`
 			SELECT COALESCE(AVG(lead_total), 0) FROM (
-				SELECT l.contact, SUM(l.value) AS lead_total
+				SELECT l.contact, SUM(`+leadValueSQL("l")+`) AS lead_total
 				FROM leads l
-				WHERE l.status = 'gagne'
+				WHERE l.stage IN (SELECT id FROM pipeline_stages WHERE is_won = TRUE)
 				GROUP BY l.contact
 			)
-		`).Row(&avgBasket) //nolint:errcheck
+		`).Bind(dbx.Params{"currency": rc}).Row(&avgBasket) //nolint:errcheck
 
 		// Top 10 clients by LTV (sum of won lead values)
 		type topClientRow struct {
@@ -348,14 +393,14 @@ func buildClientStats(app core.App) func(*core.RequestEvent) error {
 		app.DB().NewQuery(`
 			SELECT c.id AS contact_id,
 			       c.first_name || ' ' || c.last_name AS name,
-			       COALESCE(SUM(l.value), 0) AS ltv
+			       COALESCE(SUM(`+leadValueSQL("l")+`), 0) AS ltv
 			FROM contacts c
-			LEFT JOIN leads l ON l.contact = c.id AND l.status = 'gagne'
+			LEFT JOIN leads l ON l.contact = c.id AND l.stage IN (SELECT id FROM pipeline_stages WHERE is_won = TRUE)
 			GROUP BY c.id
 			HAVING ltv > 0
 			ORDER BY ltv DESC
 			LIMIT 10
-		`).All(&topClients) //nolint:errcheck
+		`).Bind(dbx.Params{"currency": rc}).All(&topClients) //nolint:errcheck
 
 		return e.JSON(http.StatusOK, map[string]interface{}{
 			"total_clients":  totalClients,
@@ -365,6 +410,7 @@ func buildClientStats(app core.App) func(*core.RequestEvent) error {
 			"by_industry":    byIndustry,
 			"avg_basket":     fmt.Sprintf("%.0f", avgBasket),
 			"top_clients":    topClients,
+			"currency":       rc,
 		})
 	}
 }
@@ -374,6 +420,10 @@ func buildClientStats(app core.App) func(*core.RequestEvent) error {
 func buildCommercialStats(app core.App) func(*core.RequestEvent) error {
 	return func(e *core.RequestEvent) error {
 		start, _, _ := parsePeriodDates(e)
+		rc, err := statsCurrency(app, e)
+		if err != nil {
+			return e.BadRequestError(err.Error(), nil)
+		}
 
 		type leaderRow struct {
 			UserID     string  `db:"user_id" json:"user_id"`
@@ -400,9 +450,9 @@ func buildCommercialStats(app core.App) func(*core.RequestEvent) error {
 				COALESCE(t.total_tasks, 0)  AS total_tasks
 			FROM users u
 			LEFT JOIN (
-				SELECT owner, COUNT(*) AS won, COALESCE(SUM(value), 0) AS revenue
+				SELECT owner, COUNT(*) AS won, COALESCE(SUM(`+leadValueSQL("leads")+`), 0) AS revenue
 				FROM leads
-				WHERE status = 'gagne' AND closed_at >= {:start}
+				WHERE stage IN (SELECT id FROM pipeline_stages WHERE is_won = TRUE) AND closed_at >= {:start}
 				GROUP BY owner
 			) w ON w.owner = u.id
 			LEFT JOIN (
@@ -423,7 +473,7 @@ func buildCommercialStats(app core.App) func(*core.RequestEvent) error {
 			) t ON t.assignee = u.id
 			WHERE u.role IN ('admin', 'commercial')
 			ORDER BY revenue DESC
-		`).Bind(dbx.Params{"start": start}).All(&leaders) //nolint:errcheck
+		`).Bind(dbx.Params{"start": start, "currency": rc}).All(&leaders) //nolint:errcheck
 
 		type leaderResult struct {
 			UserID      string  `json:"user_id"`
@@ -436,7 +486,23 @@ func buildCommercialStats(app core.App) func(*core.RequestEvent) error {
 			Emails      int     `json:"emails"`
 			Meetings    int     `json:"meetings"`
 			TotalTasks  int     `json:"total_tasks"`
+
+			// Quota: attainment of the current month / quarter quota (null without quota)
+			Quota *quotaAttainment `json:"quota"`
+		}
+
+		quotas := map[string]*quotaAttainment{}
+		goalPeriod := quotaPeriodAt(quotaPeriodType(e.Request.URL.Query().Get("period")), time.Now().UTC())
+		quotaRows, err := computeQuotaAttainment(app, goalPeriod, rc, time.Now().UTC())
+		if err != nil {
+			log.Printf("[stats] quota attainment: %v", err)
+		}
+		for i := range quotaRows {
+			if quotaRows[i].Scope == "user" {
+				quotas[quotaRows[i].SubjectID] = &quotaRows[i]
+			}
 		}
+
 		results := make([]leaderResult, len(leaders))
 		for i, l := range leaders {
 			rate := 0.0
@@ -454,11 +520,13 @@ func buildCommercialStats(app core.App) func(*core.RequestEvent) error {
 				Emails:      l.Emails,
 				Meetings:    l.Meetings,
 				TotalTasks:  l.TotalTasks,
+				Quota:       quotas[l.UserID],
 			}
 		}
 
 		return e.JSON(http.StatusOK, map[string]interface{}{
 			"leaderboard": results,
+			"currency":    rc,
 		})
 	}
 }
@@ -467,6 +535,11 @@ func buildCommercialStats(app core.App) func(*core.RequestEvent) error {
 
 func buildFinancialStats(app core.App) func(*core.RequestEvent) error {
 	return func(e *core.RequestEvent) error {
+		rc, err := statsCurrency(app, e)
+		if err != nil {
+			return e.BadRequestError(err.Error(), nil)
+		}
+
 		// Invoice counts/amounts by status
 		type invoiceStatusRow struct {
 			Status string  `db:"status" json:"status"`
@@ -475,10 +548,10 @@ func buildFinancialStats(app core.App) func(*core.RequestEvent) error {
 		}
 		byStatus := make([]invoiceStatusRow, 0)
 		app.DB().NewQuery(`
-			SELECT status, COUNT(*) AS count, COALESCE(SUM(total), 0) AS amount
+			SELECT status, COUNT(*) AS count, COALESCE(SUM(`+invoiceTotalSQL("invoices")+`), 0) AS amount
 			FROM invoices
 			GROUP BY status
-		`).All(&byStatus) //nolint:errcheck
+		`).Bind(dbx.Params{"currency": rc}).All(&byStatus) //nolint:errcheck
 
 		// Average payment delay (days)
 		var avgDelay float64
@@ -493,44 +566,31 @@ func buildFinancialStats(app core.App) func(*core.RequestEvent) error {
 		// Revenue forecast (probability-weighted open leads)
 		var forecast float64
 		app.DB().NewQuery(`
-			SELECT COALESCE(SUM(
-				CASE status
-					WHEN 'nouveau'     THEN value * 0.10
-					WHEN 'contacte'    THEN value * 0.20
-					WHEN 'qualifie'    THEN value * 0.40
-					WHEN 'proposition' THEN value * 0.60
-					WHEN 'negociation' THEN value * 0.80
-					ELSE 0
-				END
-			), 0)
-			FROM leads
-			WHERE status NOT IN ('gagne', 'perdu')
-		`).Row(&forecast) //nolint:errcheck
+			SELECT COALESCE(SUM(`+leadValueSQL("l")+` * ps.probability / 100.0), 0)
+			FROM leads l
+			JOIN pipeline_stages ps ON ps.id = l.stage
+			WHERE ps.is_won = FALSE AND ps.is_lost = FALSE
+		`).Bind(dbx.Params{"currency": rc}).Row(&forecast) //nolint:errcheck
 
 		// Forecast breakdown by stage
 		type forecastRow struct {
 			Stage       string  `db:"stage" json:"stage"`
+			StageName   string  `db:"stage_name" json:"stage_name"`
+			Pipeline    string  `db:"pipeline" json:"pipeline"`
 			TotalAmount float64 `db:"total_amount" json:"total_amount"`
 			Weighted    float64 `db:"weighted" json:"weighted"`
 		}
 		forecastByStage := make([]forecastRow, 0)
 		app.DB().NewQuery(`
-			SELECT status AS stage,
-			       COALESCE(SUM(value), 0) AS total_amount,
-			       COALESCE(SUM(
-			           CASE status
-			               WHEN 'nouveau'     THEN value * 0.10
-			               WHEN 'contacte'    THEN value * 0.20
-			               WHEN 'qualifie'    THEN value * 0.40
-			               WHEN 'proposition' THEN value * 0.60
-			               WHEN 'negociation' THEN value * 0.80
-			               ELSE 0
-			           END
-			       ), 0) AS weighted
-			FROM leads
-			WHERE status NOT IN ('gagne', 'perdu')
-			GROUP BY status
-		`).All(&forecastByStage) //nolint:errcheck
+			SELECT ps.key AS stage, ps.name AS stage_name, ps.pipeline AS pipeline,
+			       COALESCE(SUM(`+leadValueSQL("l")+`), 0) AS total_amount,
+			       COALESCE(SUM(`+leadValueSQL("l")+` * ps.probability / 100.0), 0) AS weighted
+			FROM leads l
+			JOIN pipeline_stages ps ON ps.id = l.stage
+			WHERE ps.is_won = FALSE AND ps.is_lost = FALSE
+			GROUP BY ps.id
+			ORDER BY ps.pipeline, ps."order"
+		`).Bind(dbx.Params{"currency": rc}).All(&forecastByStage) //nolint:errcheck
 
 		// Revenue by month from paid invoices
 		type monthRevenueRow struct {
@@ -539,13 +599,13 @@ func buildFinancialStats(app core.App) func(*core.RequestEvent) error {
 		}
 		revenueByMonth := make([]monthRevenueRow, 0)
 		app.DB().NewQuery(`
-			SELECT strftime('%Y-%m', paid_at) AS month, COALESCE(SUM(total), 0) AS amount
+			SELECT strftime('%Y-%m', paid_at) AS month, COALESCE(SUM(`+invoiceTotalSQL("invoices")+`), 0) AS amount
 			FROM invoices
 			WHERE status = 'payee'
 			  AND paid_at != '' AND paid_at IS NOT NULL
 			GROUP BY month
 			ORDER BY month ASC
-		`).All(&revenueByMonth) //nolint:errcheck
+		`).Bind(dbx.Params{"currency": rc}).All(&revenueByMonth) //nolint:errcheck
 
 		return e.JSON(http.StatusOK, map[string]interface{}{
 			"by_status":         byStatus,
@@ -553,6 +613,7 @@ func buildFinancialStats(app core.App) func(*core.RequestEvent) error {
 			"forecast":          fmt.Sprintf("%.0f", forecast),
 			"forecast_by_stage": forecastByStage,
 			"revenue_by_month":  revenueByMonth,
+			"currency":          rc,
 		})
 	}
 }
@@ -562,6 +623,10 @@ func buildFinancialStats(app core.App) func(*core.RequestEvent) error {
 func buildMarketingStats(app core.App) func(*core.RequestEvent) error {
 	return func(e *core.RequestEvent) error {
 		start, _, _ := parsePeriodDates(e)
+		rc, err := statsCurrency(app, e)
+		if err != nil {
+			return e.BadRequestError(err.Error(), nil)
+		}
 
 		// Leads by month (all time, ascending)
 		type monthCountRow struct {
@@ -628,11 +693,11 @@ func buildMarketingStats(app core.App) func(*core.RequestEvent) error {
 		}
 		expenseRows := make([]expenseRow, 0)
 		app.DB().NewQuery(`
-			SELECT category, COALESCE(SUM(amount), 0) AS cost
+			SELECT category, COALESCE(SUM(`+expenseAmountSQL("marketing_expenses")+`), 0) AS cost
 			FROM marketing_expenses
 			WHERE date >= strftime('%Y-%m-%d', {:start})
 			GROUP BY category
-		`).Bind(dbx.Params{"start": start}).All(&expenseRows) //nolint:errcheck
+		`).Bind(dbx.Params{"start": start, "currency": rc}).All(&expenseRows) //nolint:errcheck
 
 		// Leads totaux par source (tous statuts, période courante)
 		type leadSourceRow struct {
@@ -656,12 +721,12 @@ func buildMarketingStats(app core.App) func(*core.RequestEvent) error {
 		revenueRows := make([]revenueRow, 0)
 		app.DB().NewQuery(`
 			SELECT COALESCE(source, 'autre') AS source,
-			       COALESCE(SUM(value), 0)   AS revenue,
+			       COALESCE(SUM(`+leadValueSQL("leads")+`), 0) AS revenue,
 			       COUNT(*)                  AS deals
 			FROM leads
-			WHERE status = 'gagne' AND closed_at >= {:start}
+			WHERE stage IN (SELECT id FROM pipeline_stages WHERE is_won = TRUE) AND closed_at >= {:start}
 			GROUP BY source
-		`).Bind(dbx.Params{"start": start}).All(&revenueRows) //nolint:errcheck
+		`).Bind(dbx.Params{"start": start, "currency": rc}).All(&revenueRows) //nolint:errcheck
 
 		// Indexer par source pour merge
 		expenseByCategory := make(map[string]float64, len(expenseRows))
@@ -767,21 +832,21 @@ func buildMarketingStats(app core.App) func(*core.RequestEvent) error {
 			SELECT c.id AS campaign_id, c.name AS campaign_name, c.type AS campaign_type,
 			       COALESCE(c.sent, 0) AS emails_sent,
 			       COUNT(DISTINCT CASE WHEN l.created >= {:start} THEN l.id END) AS leads_count,
-			       COALESCE(SUM(CASE WHEN l.status = 'gagne' AND l.closed_at >= {:start} THEN l.value ELSE 0 END), 0) AS revenue_won,
-			       COALESCE(SUM(CASE WHEN l.status = 'gagne' AND l.closed_at >= {:start} THEN 1 ELSE 0 END), 0) AS deals_won,
+			       COALESCE(SUM(CASE WHEN l.stage IN (SELECT id FROM pipeline_stages WHERE is_won = TRUE) AND l.closed_at >= {:start} THEN `+leadValueSQL("l")+` ELSE 0 END), 0) AS revenue_won,
+			       COALESCE(SUM(CASE WHEN l.stage IN (SELECT id FROM pipeline_stages WHERE is_won = TRUE) AND l.closed_at >= {:start} THEN 1 ELSE 0 END), 0) AS deals_won,
 			       COALESCE(exp.cost, 0) AS cost
 			FROM campaigns c
 			LEFT JOIN leads l ON l.campaign_id = c.id
 			LEFT JOIN (
-			    SELECT campaign_id, SUM(amount) AS cost
+			    SELECT campaign_id, SUM(`+expenseAmountSQL("marketing_expenses")+`) AS cost
 			    FROM marketing_expenses
 			    WHERE date >= strftime('%Y-%m-%d', {:start}) AND campaign_id != ''
 			    GROUP BY campaign_id
 			) exp ON exp.campaign_id = c.id
-			WHERE c.status IN ('en_cours', 'envoye', 'termine')
+			WHERE c.status IN ('en_cours', 'en_pause', 'envoye', 'termine', 'annulee')
 			GROUP BY c.id
 			ORDER BY revenue_won DESC
-		`).Bind(dbx.Params{"start": start}).All(&campaignPerfRows) //nolint:errcheck
+		`).Bind(dbx.Params{"start": start, "currency": rc}).All(&campaignPerfRows) //nolint:errcheck
 
 		type campaignPerfResult struct {
 			CampaignID   string  `json:"campaign_id"`
@@ -838,6 +903,7 @@ func buildMarketingStats(app core.App) func(*core.RequestEvent) error {
 			"roas_global":     roasGlobal,
 			"roi_by_channel":  roiByChannel,
 			"by_campaign":    byCampaign,
+			"currency":        rc,
 		})
 	}
 }
@@ -0,0 +1,723 @@
+package hooks
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"pocket-crm/services"
+)
+
+// ─── Subscriptions & recurring revenue ───────────────────────────────────────
+//
+// A subscription bills its lines every billing_interval from start_date. Its
+// amount (excl. tax per cycle) and mrr are computed by hook; every change of
+// the MRR that counts (mrr while actif, 0 otherwise) is written to
+// subscription_events, which the MRR stats replay. A scheduler generates the
+// draft invoice of each cycle, renews or expires contracts on renewal_date and
+// creates a reminder task for the owner before each renewal.
+
+const (
+	defaultSubscriptionTerm = 12
+	// defaultRenewalNoticeDays: reminder task this many days before renewal_date.
+	defaultRenewalNoticeDays = 30
+	// maxCyclesPerRun caps the invoices caught up for one subscription per run.
+	maxCyclesPerRun = 24
+)
+
+// billingIntervalMonths is the length of a billing cycle.
+var billingIntervalMonths = map[string]int{"mensuel": 1, "trimestriel": 3, "annuel": 12}
+
+// renewalNoticeDays reads SUBSCRIPTION_RENEWAL_NOTICE_DAYS (0 disables the reminders).
+func renewalNoticeDays() int {
+	if v, err := strconv.Atoi(os.Getenv("SUBSCRIPTION_RENEWAL_NOTICE_DAYS")); err == nil && v >= 0 {
+		return v
+	}
+	return defaultRenewalNoticeDays
+}
+
+// RegisterSubscriptionHooks prices subscriptions, records their MRR movements
+// and starts the billing / renewal scheduler (at startup, then hourly).
+func RegisterSubscriptionHooks(app core.App) {
+	author := func(e *core.RecordRequestEvent) error {
+		if e.Auth != nil && e.Auth.Collection().Name == "users" {
+			e.Record.Set(changedByKey, e.Auth.Id)
+		}
+		return e.Next()
+	}
+	app.OnRecordCreateRequest("subscriptions").BindFunc(author)
+	app.OnRecordUpdateRequest("subscriptions").BindFunc(author)
+
+	track := func(e *core.RecordEvent) error {
+		originalApp := e.App
+		defer func() { e.App = originalApp }()
+		return e.App.RunInTransaction(func(txApp core.App) error {
+			e.App = txApp
+
+			before := 0.0
+			if !e.Record.IsNew() {
+				before = effectiveMRR(e.Record.Original())
+			}
+			isNew := e.Record.IsNew()
+			if err := prepareSubscription(txApp, e.Record, time.Now().UTC()); err != nil {
+				return err
+			}
+			if err := e.Next(); err != nil {
+				return err
+			}
+			return recordMRRChange(txApp, e.Record, isNew, before)
+		})
+	}
+	app.OnRecordCreate("subscriptions").BindFunc(track)
+	app.OnRecordUpdate("subscriptions").BindFunc(track)
+
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.POST("/api/crm/subscriptions/run", func(e *core.RequestEvent) error {
+			if !e.HasSuperuserAuth() && e.Auth.GetString("role") != "admin" {
+				return e.ForbiddenError("Only admins can run the subscription billing", nil)
+			}
+			return e.JSON(http.StatusOK, processSubscriptions(app, time.Now().UTC()))
+		}).Bind(apis.RequireAuth())
+
+		go func() {
+			processSubscriptions(app, time.Now().UTC())
+			ticker := time.NewTicker(time.Hour)
+			defer ticker.Stop()
+			for range ticker.C {
+				processSubscriptions(app, time.Now().UTC())
+			}
+		}()
+		return se.Next()
+	})
+
+	log.Printf("[hooks] Subscription hooks registered (MRR events, billing scheduler 1h, renewal reminders %d days)", renewalNoticeDays())
+}
+
+// effectiveMRR is the MRR a subscription contributes: its mrr while active.
+func effectiveMRR(sub *core.Record) float64 {
+	if sub.GetString("status") != "actif" {
+		return 0
+	}
+	return sub.GetFloat("mrr")
+}
+
+// addMonthsClamped adds months to t, keeping the day within the target month
+// (Jan 31 + 1 month = Feb 28).
+func addMonthsClamped(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, months, 0)
+	last := first.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > last {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, time.UTC)
+}
+
+// cycleAfter returns the first date start + k×months (k ≥ 1) after t.
+func cycleAfter(start, t time.Time, months int) time.Time {
+	for k := 1; ; k++ {
+		if next := addMonthsClamped(start, k*months); next.After(t) {
+			return next
+		}
+	}
+}
+
+// dateOf reads a date field as a UTC day (zero when empty).
+func dateOf(rec *core.Record, field string) time.Time {
+	dt := rec.GetDateTime(field)
+	if dt.IsZero() {
+		return time.Time{}
+	}
+	t := dt.Time().UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// prepareSubscription fills product lines, validates the lines and dates,
+// computes amount / mrr and the billing and renewal dates, and stamps
+// cancellations.
+func prepareSubscription(app core.App, sub *core.Record, now time.Time) error {
+	var items []services.LineItem
+	if raw := sub.GetString("items"); raw != "" && raw != "null" {
+		if err := sub.UnmarshalJSONField("items", &items); err != nil {
+			return validation.Errors{"items": validation.NewError("validation_invalid_items", "format des lignes invalide")}
+		}
+	}
+	if len(items) == 0 {
+		return validation.Errors{"items": validation.NewError("validation_required", "au moins une ligne est requise")}
+	}
+	currency := sub.GetString("currency")
+	if currency == "" {
+		currency = services.ReportingCurrency()
+	}
+	// A product line without description is new: fill it from the catalogue
+	for i := range items {
+		it := &items[i]
+		if it.Product == "" || strings.TrimSpace(it.Description) != "" {
+			continue
+		}
+		product, err := app.FindRecordById("products", it.Product)
+		if err != nil {
+			return validation.Errors{"items": validation.NewError("validation_invalid_product",
+				fmt.Sprintf("ligne %d : produit introuvable", i+1))}
+		}
+		if product.GetBool("archived") {
+			return validation.Errors{"items": validation.NewError("validation_archived_product",
+				fmt.Sprintf("ligne %d : le produit %s n'est plus commercialisé", i+1, product.GetString("sku")))}
+		}
+		it.Description = product.GetString("name")
+		if it.Qty == 0 {
+			it.Qty = 1
+		}
+		if it.UnitPrice == 0 {
+			price, err := services.ConvertAmount(app, product.GetFloat("unit_price"), product.GetString("currency"), currency, now)
+			if err != nil {
+				return validation.Errors{"items": validation.NewError("validation_unknown_currency",
+					fmt.Sprintf("ligne %d : impossible de convertir le prix du produit en %s", i+1, currency))}
+			}
+			it.UnitPrice = roundCents(price)
+		}
+		if it.TaxRate == 0 {
+			it.TaxRate = product.GetFloat("tax_rate")
+		}
+	}
+	if err := services.ValidateLineItems(items); err != nil {
+		return validation.Errors{"items": validation.NewError("validation_invalid_items", err.Error())}
+	}
+	totals := services.ComputeLineTotals(items)
+	sub.Set("items", items)
+
+	months, ok := billingIntervalMonths[sub.GetString("billing_interval")]
+	if !ok {
+		months = 1
+	}
+	sub.Set("amount", totals.Subtotal)
+	sub.Set("mrr", roundCents(totals.Subtotal/float64(months)))
+
+	if sub.GetInt("term_months") == 0 {
+		sub.Set("term_months", defaultSubscriptionTerm)
+	}
+	if sub.GetString("status") == "" {
+		sub.Set("status", "actif")
+	}
+
+	start := dateOf(sub, "start_date")
+	if start.IsZero() {
+		return validation.Errors{"start_date": validation.NewError("validation_required", "la date de début est obligatoire")}
+	}
+	if end := dateOf(sub, "end_date"); !end.IsZero() && end.Before(start) {
+		return validation.Errors{"end_date": validation.NewError("validation_end_before_start",
+			"la date de fin doit être postérieure à la date de début")}
+	}
+	if sub.GetString("next_billing_date") == "" && sub.IsNew() {
+		sub.Set("next_billing_date", start.Format("2006-01-02 15:04:05.000Z"))
+	}
+	if !sub.IsNew() && (!start.Equal(dateOf(sub.Original(), "start_date")) ||
+		sub.GetString("billing_interval") != sub.Original().GetString("billing_interval")) {
+		// New schedule: resume at its first cycle not billed yet
+		sub.Set("next_billing_date", nextUnbilledCycle(app, sub.Id, start, months).Format("2006-01-02 15:04:05.000Z"))
+	}
+	if sub.GetString("renewal_date") == "" {
+		sub.Set("renewal_date", addMonthsClamped(start, sub.GetInt("term_months")).Format("2006-01-02 15:04:05.000Z"))
+	}
+
+	status, oldStatus := sub.GetString("status"), ""
+	if !sub.IsNew() {
+		oldStatus = sub.Original().GetString("status")
+	}
+	today := now.Format("2006-01-02 15:04:05.000Z")
+	switch {
+	case status == "resilie" && oldStatus != "resilie":
+		if sub.GetString("cancelled_at") == "" {
+			sub.Set("cancelled_at", today)
+		}
+		if sub.GetString("end_date") == "" {
+			sub.Set("end_date", today)
+		}
+	case status == "expire" && oldStatus != "expire":
+		if sub.GetString("end_date") == "" {
+			sub.Set("end_date", sub.GetString("renewal_date"))
+		}
+	case status == "actif" && oldStatus != "" && oldStatus != "actif":
+		// Reactivated: the contract runs again, with no end (the pending
+		// churn is withdrawn by recordMRRChange)
+		sub.Set("cancelled_at", "")
+		sub.Set("end_date", "")
+		if renewal := dateOf(sub, "renewal_date"); !renewal.After(now) {
+			sub.Set("renewal_date", cycleAfter(start, now, sub.GetInt("term_months")).Format("2006-01-02 15:04:05.000Z"))
+		}
+	}
+	return nil
+}
+
+// nextUnbilledCycle returns the first cycle (start + k×months, k ≥ 0) after
+// the periods already invoiced for the subscription.
+func nextUnbilledCycle(app core.App, subscriptionID string, start time.Time, months int) time.Time {
+	var billedUntil string
+	app.DB().NewQuery(`SELECT COALESCE(MAX(period_end), '') FROM invoices WHERE subscription = {:sub} AND status != 'annulee'`).
+		Bind(dbx.Params{"sub": subscriptionID}).Row(&billedUntil) //nolint:errcheck
+	last, err := time.Parse("2006-01-02", dayOf(billedUntil))
+	if err != nil || last.Before(start) {
+		return start
+	}
+	return cycleAfter(start, last, months)
+}
+
+// recordMRRChange writes the subscription_events entry of a saved
+// subscription whose effective MRR moved. New MRR counts from start_date,
+// churn from end_date; other movements from now. A subscription reactivated
+// before its end_date never churned: its pending resiliation is deleted and
+// only a change of MRR is recorded.
+func recordMRRChange(app core.App, sub *core.Record, isNew bool, before float64) error {
+	after := effectiveMRR(sub)
+	if after == before {
+		return nil
+	}
+	now := time.Now().UTC().Format("2006-01-02 15:04:05.000Z")
+	if !isNew && before == 0 {
+		pending, err := app.FindFirstRecordByFilter("subscription_events",
+			"subscription = {:sub} && type = 'resiliation' && occurred_at >= {:tomorrow}",
+			dbx.Params{"sub": sub.Id, "tomorrow": time.Now().UTC().AddDate(0, 0, 1).Format("2006-01-02")})
+		if err == nil {
+			if err := app.Delete(pending); err != nil {
+				return fmt.Errorf("withdraw pending churn: %w", err)
+			}
+			before = pending.GetFloat("mrr_before")
+			if after == before {
+				return nil
+			}
+		}
+	}
+	var eventType, at string
+	switch {
+	case isNew:
+		eventType, at = "nouveau", sub.GetString("start_date")
+	case before == 0:
+		eventType, at = "reactivation", now
+	case after == 0:
+		eventType, at = "resiliation", sub.GetString("end_date")
+	case after > before:
+		eventType, at = "expansion", now
+	default:
+		eventType, at = "contraction", now
+	}
+	if at == "" {
+		at = now
+	}
+
+	col, err := app.FindCollectionByNameOrId("subscription_events")
+	if err != nil {
+		return err
+	}
+	rec := core.NewRecord(col)
+	rec.Set("subscription", sub.Id)
+	rec.Set("type", eventType)
+	rec.Set("mrr_before", before)
+	rec.Set("mrr_after", after)
+	rec.Set("currency", sub.GetString("currency"))
+	rec.Set("occurred_at", at)
+	changedBy, _ := sub.GetRaw(changedByKey).(string)
+	if changedBy == "" {
+		changedBy = sub.GetString("owner")
+	}
+	rec.Set("changed_by", changedBy)
+	if err := app.Save(rec); err != nil {
+		return fmt.Errorf("record MRR change: %w", err)
+	}
+	return nil
+}
+
+// ─── Scheduler ───────────────────────────────────────────────────────────────
+
+// subscriptionRunResult summarises one scheduler pass.
+type subscriptionRunResult struct {
+	Invoices  int `json:"invoices"`
+	Renewed   int `json:"renewed"`
+	Expired   int `json:"expired"`
+	Reminders int `json:"reminders"`
+}
+
+// processSubscriptions renews / expires contracts, generates the due invoices
+// and creates the renewal reminders. Every step is idempotent.
+func processSubscriptions(app core.App, now time.Time) subscriptionRunResult {
+	var res subscriptionRunResult
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	// 1. Renewals: auto_renew contracts start a new term, the others expire
+	due, err := app.FindAllRecords("subscriptions", dbx.NewExp(
+		"status = 'actif' AND renewal_date != '' AND substr(renewal_date, 1, 10) <= {:today}",
+		dbx.Params{"today": today.Format("2006-01-02")}))
+	if err != nil {
+		log.Printf("[subscriptions] failed to list renewals: %v", err)
+	}
+	for _, sub := range due {
+		renew := sub.GetBool("auto_renew")
+		if renew {
+			sub.Set("renewal_date", cycleAfter(dateOf(sub, "start_date"), today, sub.GetInt("term_months")).Format("2006-01-02 15:04:05.000Z"))
+		} else {
+			sub.Set("status", "expire")
+		}
+		// A failed save is retried by the next pass
+		if err := app.Save(sub); err != nil {
+			log.Printf("[subscriptions] failed to renew %s: %v", sub.Id, err)
+			continue
+		}
+		if renew {
+			res.Renewed++
+		} else {
+			res.Expired++
+		}
+	}
+
+	// 2. Invoices of the cycles started up to today (and before end_date)
+	billable, err := app.FindAllRecords("subscriptions", dbx.NewExp(`
+		next_billing_date != '' AND substr(next_billing_date, 1, 10) <= {:today}
+		AND (status = 'actif' OR (end_date != '' AND substr(next_billing_date, 1, 10) < substr(end_date, 1, 10)))
+	`, dbx.Params{"today": today.Format("2006-01-02")}))
+	if err != nil {
+		log.Printf("[subscriptions] failed to list billable subscriptions: %v", err)
+	}
+	for _, sub := range billable {
+		n, err := billSubscription(app, sub, today)
+		if err != nil {
+			log.Printf("[subscriptions] billing of %s failed: %v", sub.Id, err)
+		}
+		res.Invoices += n
+	}
+
+	// 3. Renewal reminders
+	if notice := renewalNoticeDays(); notice > 0 {
+		upcoming, err := app.FindAllRecords("subscriptions", dbx.NewExp(`
+			status = 'actif' AND renewal_date != '' AND substr(renewal_date, 1, 10) <= {:limit}
+			AND substr(COALESCE(renewal_reminded_for, ''), 1, 10) != substr(renewal_date, 1, 10)
+		`, dbx.Params{"limit": today.AddDate(0, 0, notice).Format("2006-01-02")}))
+		if err != nil {
+			log.Printf("[subscriptions] failed to list upcoming renewals: %v", err)
+		}
+		for _, sub := range upcoming {
+			if err := createRenewalTask(app, sub, now); err != nil {
+				log.Printf("[subscriptions] failed to create renewal task for %s: %v", sub.Id, err)
+				continue
+			}
+			res.Reminders++
+		}
+	}
+
+	if res != (subscriptionRunResult{}) {
+		log.Printf("[subscriptions] %d invoice(s), %d renewed, %d expired, %d reminder(s)",
+			res.Invoices, res.Renewed, res.Expired, res.Reminders)
+	}
+	return res
+}
+
+// billSubscription creates the draft invoices of the subscription's due
+// cycles. Each invoice and the move of next_billing_date share a transaction;
+// the unique (subscription, period_start) index guards against double billing.
+func billSubscription(app core.App, sub *core.Record, today time.Time) (int, error) {
+	months, ok := billingIntervalMonths[sub.GetString("billing_interval")]
+	if !ok {
+		months = 1
+	}
+	start := dateOf(sub, "start_date")
+	end := dateOf(sub, "end_date")
+	var items []services.LineItem
+	if err := sub.UnmarshalJSONField("items", &items); err != nil {
+		return 0, err
+	}
+	created := 0
+	cycle := dateOf(sub, "next_billing_date")
+	for i := 0; i < maxCyclesPerRun && !cycle.After(today) && (end.IsZero() || cycle.Before(end)); i++ {
+		next := cycleAfter(start, cycle, months)
+		periodEnd := next.AddDate(0, 0, -1)
+		if !end.IsZero() && periodEnd.After(end) {
+			periodEnd = end
+		}
+		billed := false
+		err := app.RunInTransaction(func(txApp core.App) error {
+			var exists int
+			err := txApp.DB().NewQuery("SELECT COUNT(*) FROM invoices WHERE subscription = {:sub} AND substr(period_start, 1, 10) = {:start}").
+				Bind(dbx.Params{"sub": sub.Id, "start": cycle.Format("2006-01-02")}).Row(&exists)
+			if err != nil {
+				return fmt.Errorf("invoice of the cycle %s: %w", cycle.Format("2006-01-02"), err)
+			}
+			if exists == 0 {
+				col, err := txApp.FindCollectionByNameOrId("invoices")
+				if err != nil {
+					return err
+				}
+				invoice := core.NewRecord(col)
+				invoice.Set("contact", sub.GetString("contact"))
+				invoice.Set("company", sub.GetString("company"))
+				invoice.Set("lead", sub.GetString("lead"))
+				invoice.Set("owner", sub.GetString("owner"))
+				invoice.Set("subscription", sub.Id)
+				invoice.Set("period_start", cycle.Format("2006-01-02 15:04:05.000Z"))
+				invoice.Set("period_end", periodEnd.Format("2006-01-02 15:04:05.000Z"))
+				invoice.Set("currency", sub.GetString("currency"))
+				invoice.Set("tax_rate", commonTaxRate(items))
+				invoice.Set("status", "brouillon")
+				invoice.Set("items", items)
+				invoice.Set("notes", fmt.Sprintf("<p>Abonnement « %s » : période du %s au %s.</p>",
+					sub.GetString("name"), cycle.Format("02/01/2006"), periodEnd.Format("02/01/2006")))
+				if err := txApp.Save(invoice); err != nil {
+					return err
+				}
+				billed = true
+			}
+			// Raw update: the subscription hooks have nothing to do for this field
+			_, err = txApp.DB().NewQuery("UPDATE subscriptions SET next_billing_date = {:next} WHERE id = {:id}").
+				Bind(dbx.Params{"next": next.Format("2006-01-02 15:04:05.000Z"), "id": sub.Id}).Execute()
+			return err
+		})
+		if err != nil {
+			return created, err
+		}
+		if billed {
+			created++
+		}
+		cycle = next
+	}
+	return created, nil
+}
+
+// createRenewalTask reminds the owner of an upcoming renewal and remembers
+// which renewal_date it was created for.
+func createRenewalTask(app core.App, sub *core.Record, now time.Time) error {
+	col, err := app.FindCollectionByNameOrId("tasks")
+	if err != nil {
+		return err
+	}
+	locale := services.DefaultLocale
+	if owner, err := app.FindRecordById("users", sub.GetString("owner")); err == nil {
+		locale = services.ResolveUserLocale(owner)
+	}
+	renewal := dateOf(sub, "renewal_date")
+	key := "subscription_task.description_manual"
+	if sub.GetBool("auto_renew") {
+		key = "subscription_task.description_auto"
+	}
+
+	task := core.NewRecord(col)
+	task.Set("title", fmt.Sprintf(services.T(locale, "subscription_task.title"), sub.GetString("name")))
+	task.Set("description", fmt.Sprintf(services.T(locale, key), sub.GetString("name"), renewal.Format("02/01/2006")))
+	task.Set("type", "suivi")
+	task.Set("status", "a_faire")
+	task.Set("priority", "haute")
+	task.Set("due_date", renewal.Format("2006-01-02 15:04:05.000Z"))
+	task.Set("assignee", sub.GetString("owner"))
+	task.Set("created_by", sub.GetString("owner"))
+	task.Set("contact", sub.GetString("contact"))
+	task.Set("company", sub.GetString("company"))
+	task.Set("lead", sub.GetString("lead"))
+	if err := app.Save(task); err != nil {
+		return err
+	}
+	_, err = app.DB().NewQuery("UPDATE subscriptions SET renewal_reminded_for = renewal_date WHERE id = {:id}").
+		Bind(dbx.Params{"id": sub.Id}).Execute()
+	return err
+}
+
+// ─── MRR stats ───────────────────────────────────────────────────────────────
+
+// mrrMonth is the recurring revenue movement of one month.
+type mrrMonth struct {
+	Month              string  `json:"month"`
+	MRRStart           float64 `json:"mrr_start"`
+	New                float64 `json:"new"`
+	Expansion          float64 `json:"expansion"`
+	Contraction        float64 `json:"contraction"`
+	Churn              float64 `json:"churn"`
+	Reactivation       float64 `json:"reactivation"`
+	NetNew             float64 `json:"net_new"`
+	MRREnd             float64 `json:"mrr_end"`
+	ARREnd             float64 `json:"arr_end"`
+	SubscriptionsStart int     `json:"subscriptions_start"`
+	SubscriptionsEnd   int     `json:"subscriptions_end"`
+	Churned            int     `json:"churned"`
+	ChurnRatePct       float64 `json:"churn_rate_pct"`
+	LogoChurnRatePct   float64 `json:"logo_churn_rate_pct"`
+}
+
+type subscriptionEventRow struct {
+	Subscription string  `db:"subscription"`
+	Type         string  `db:"type"`
+	Before       float64 `db:"mrr_before"`
+	After        float64 `db:"mrr_after"`
+	Currency     string  `db:"currency"`
+	OccurredAt   string  `db:"occurred_at"`
+}
+
+// buildMRRStats serves GET /api/crm/stats/mrr?from=YYYY-MM&to=YYYY-MM&currency=
+// (default: the last 12 months). Each month replays subscription_events: MRR
+// at the start, new / expansion / contraction / churn / reactivation, MRR and
+// ARR at the end, revenue churn and logo churn (subscriptions lost) rates. Amounts of a month are
+// converted at the rate of its last day, so each month balances.
+func buildMRRStats(app core.App) func(*core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		rc, err := statsCurrency(app, e)
+		if err != nil {
+			return e.BadRequestError(err.Error(), nil)
+		}
+		now := time.Now().UTC()
+		q := e.Request.URL.Query()
+		to := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if v := q.Get("to"); v != "" {
+			if to, err = time.Parse("2006-01", v); err != nil {
+				return e.BadRequestError("to must be YYYY-MM", nil)
+			}
+		}
+		from := to.AddDate(0, -11, 0)
+		if v := q.Get("from"); v != "" {
+			if from, err = time.Parse("2006-01", v); err != nil {
+				return e.BadRequestError("from must be YYYY-MM", nil)
+			}
+		}
+		if from.After(to) || from.AddDate(5, 0, 0).Before(to) {
+			return e.BadRequestError("from must be before to, 5 years at most", nil)
+		}
+
+		events := make([]subscriptionEventRow, 0)
+		if err := app.DB().NewQuery(`
+			SELECT subscription, type, COALESCE(mrr_before, 0) AS mrr_before, COALESCE(mrr_after, 0) AS mrr_after,
+			       COALESCE(currency, '') AS currency, occurred_at
+			FROM subscription_events
+			WHERE substr(occurred_at, 1, 10) < {:end}
+			ORDER BY occurred_at, created
+		`).Bind(dbx.Params{"end": to.AddDate(0, 1, 0).Format("2006-01-02")}).All(&events); err != nil {
+			return e.InternalServerError("Failed to load subscription events", err)
+		}
+
+		// factor converts a currency at the rate of a day (cached)
+		factors := map[string]float64{}
+		factor := func(currency string, day time.Time) float64 {
+			if currency == "" {
+				currency = services.FxBaseCurrency
+			}
+			key := currency + day.Format("2006-01-02")
+			if f, ok := factors[key]; ok {
+				return f
+			}
+			f, err := services.ConvertAmount(app, 1, currency, rc, day)
+			if err != nil {
+				f = 0
+				log.Printf("[stats] no rate to convert %s into %s: %v", currency, rc, err)
+			}
+			factors[key] = f
+			return f
+		}
+
+		months := make([]mrrMonth, 0)
+		var sum mrrMonth
+		for m := from; !m.After(to); m = m.AddDate(0, 1, 0) {
+			rateDay := m.AddDate(0, 1, -1)
+			if rateDay.After(now) {
+				rateDay = now
+			}
+			row := replayMRRMonth(events, m, func(currency string) float64 { return factor(currency, rateDay) })
+			months = append(months, row)
+
+			sum.New += row.New
+			sum.Expansion += row.Expansion
+			sum.Contraction += row.Contraction
+			sum.Churn += row.Churn
+			sum.Reactivation += row.Reactivation
+			sum.Churned += row.Churned
+		}
+
+		current, active := mrrAt(events, now.AddDate(0, 0, 1), func(currency string) float64 { return factor(currency, now) })
+		arpa := 0.0
+		if active > 0 {
+			arpa = roundCents(current / float64(active))
+		}
+
+		return e.JSON(http.StatusOK, map[string]interface{}{
+			"from": from.Format("2006-01"),
+			"to":   to.Format("2006-01"),
+			"current": map[string]interface{}{
+				"mrr":                  roundCents(current),
+				"arr":                  roundCents(current * 12),
+				"active_subscriptions": active,
+				"arpa":                 arpa,
+			},
+			"months": months,
+			"totals": map[string]interface{}{
+				"new":          roundCents(sum.New),
+				"expansion":    roundCents(sum.Expansion),
+				"contraction":  roundCents(sum.Contraction),
+				"churn":        roundCents(sum.Churn),
+				"reactivation": roundCents(sum.Reactivation),
+				"net_new":      roundCents(sum.New + sum.Expansion + sum.Reactivation - sum.Contraction - sum.Churn),
+				"churned":      sum.Churned,
+			},
+			"currency": rc,
+		})
+	}
+}
+
+// mrrAt sums the MRR of each subscription (its last event before day) from
+// events sorted by occurred_at; factor converts a currency.
+func mrrAt(events []subscriptionEventRow, day time.Time, factor func(currency string) float64) (total float64, active int) {
+	last := map[string]subscriptionEventRow{}
+	for _, ev := range events {
+		if dayOf(ev.OccurredAt) >= day.Format("2006-01-02") {
+			break
+		}
+		last[ev.Subscription] = ev
+	}
+	for _, ev := range last {
+		if ev.After > 0 {
+			total += ev.After * factor(ev.Currency)
+			active++
+		}
+	}
+	return total, active
+}
+
+// replayMRRMonth replays events over the month starting at m: MRR and
+// subscriptions at both ends, movements by event type and churn rates.
+func replayMRRMonth(events []subscriptionEventRow, m time.Time, factor func(currency string) float64) mrrMonth {
+	next := m.AddDate(0, 1, 0)
+	row := mrrMonth{Month: m.Format("2006-01")}
+	start, activeStart := mrrAt(events, m, factor)
+	end, activeEnd := mrrAt(events, next, factor)
+	row.MRRStart, row.SubscriptionsStart = roundCents(start), activeStart
+	row.MRREnd, row.SubscriptionsEnd = roundCents(end), activeEnd
+	for _, ev := range events {
+		d := dayOf(ev.OccurredAt)
+		if d < m.Format("2006-01-02") || d >= next.Format("2006-01-02") {
+			continue
+		}
+		delta := (ev.After - ev.Before) * factor(ev.Currency)
+		switch ev.Type {
+		case "nouveau":
+			row.New += delta
+		case "expansion":
+			row.Expansion += delta
+		case "contraction":
+			row.Contraction -= delta
+		case "resiliation":
+			row.Churn -= delta
+			row.Churned++
+		case "reactivation":
+			row.Reactivation += delta
+		}
+	}
+	row.New, row.Expansion, row.Contraction = roundCents(row.New), roundCents(row.Expansion), roundCents(row.Contraction)
+	row.Churn, row.Reactivation = roundCents(row.Churn), roundCents(row.Reactivation)
+	row.NetNew = roundCents(row.New + row.Expansion + row.Reactivation - row.Contraction - row.Churn)
+	row.ARREnd = roundCents(row.MRREnd * 12)
+	if row.MRRStart > 0 {
+		row.ChurnRatePct = round1(row.Churn / row.MRRStart * 100)
+	}
+	if row.SubscriptionsStart > 0 {
+		row.LogoChurnRatePct = round1(float64(row.Churned) / float64(row.SubscriptionsStart) * 100)
+	}
+	return row
+}
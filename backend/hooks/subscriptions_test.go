@@ -0,0 +1,155 @@
+package hooks
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testDay(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestAddMonthsClamped(t *testing.T) {
+	tests := []struct {
+		name   string
+		t      time.Time
+		months int
+		want   string
+	}{
+		{"same day next month", testDay("2026-01-15"), 1, "2026-02-15"},
+		{"end of month clamped", testDay("2026-01-31"), 1, "2026-02-28"},
+		{"leap year", testDay("2028-01-31"), 1, "2028-02-29"},
+		{"back one month", testDay("2026-03-31"), -1, "2026-02-28"},
+		{"over the year", testDay("2026-11-30"), 3, "2027-02-28"},
+		{"one year", testDay("2026-05-31"), 12, "2027-05-31"},
+		{"time of day dropped", time.Date(2026, 1, 15, 14, 30, 0, 0, time.UTC), 0, "2026-01-15"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := addMonthsClamped(tt.t, tt.months)
+			if got.Format(time.RFC3339) != tt.want+"T00:00:00Z" {
+				t.Errorf("addMonthsClamped(%s, %d) = %s, want %s", tt.t.Format(time.RFC3339), tt.months, got.Format(time.RFC3339), tt.want)
+			}
+		})
+	}
+}
+
+func TestCycleAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		start  string
+		t      string
+		months int
+		want   string
+	}{
+		{"first cycle", "2026-01-31", "2026-01-31", 1, "2026-02-28"},
+		{"clamped from the start date, not the previous cycle", "2026-01-31", "2026-02-28", 1, "2026-03-31"},
+		{"at least one cycle after the start", "2026-03-15", "2026-01-01", 1, "2026-04-15"},
+		{"quarterly", "2026-01-10", "2026-06-01", 3, "2026-07-10"},
+		{"on a cycle date", "2026-01-10", "2026-04-10", 3, "2026-07-10"},
+		{"yearly from a leap day", "2028-02-29", "2028-03-01", 12, "2029-02-28"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cycleAfter(testDay(tt.start), testDay(tt.t), tt.months).Format("2006-01-02"); got != tt.want {
+				t.Errorf("cycleAfter(%s, %s, %d) = %s, want %s", tt.start, tt.t, tt.months, got, tt.want)
+			}
+		})
+	}
+}
+
+// testMRREvents: s1 and s3 start in January, s2 (in USD) in February; s1
+// grows, s3 is cancelled then reactivated, s2 shrinks.
+var testMRREvents = []subscriptionEventRow{
+	{Subscription: "s1", Type: "nouveau", Before: 0, After: 100, Currency: "EUR", OccurredAt: "2026-01-10 09:00:00.000Z"},
+	{Subscription: "s3", Type: "nouveau", Before: 0, After: 50, Currency: "EUR", OccurredAt: "2026-01-20 09:00:00.000Z"},
+	{Subscription: "s2", Type: "nouveau", Before: 0, After: 200, Currency: "USD", OccurredAt: "2026-02-05 09:00:00.000Z"},
+	{Subscription: "s1", Type: "expansion", Before: 100, After: 150, Currency: "EUR", OccurredAt: "2026-02-20 09:00:00.000Z"},
+	{Subscription: "s3", Type: "resiliation", Before: 50, After: 0, Currency: "EUR", OccurredAt: "2026-02-28 00:00:00.000Z"},
+	{Subscription: "s2", Type: "contraction", Before: 200, After: 100, Currency: "USD", OccurredAt: "2026-03-03 09:00:00.000Z"},
+	{Subscription: "s3", Type: "reactivation", Before: 0, After: 60, Currency: "EUR", OccurredAt: "2026-03-15 09:00:00.000Z"},
+}
+
+// testMRRFactor converts USD at 0.5 and every other currency at 1.
+func testMRRFactor(currency string) float64 {
+	if currency == "USD" {
+		return 0.5
+	}
+	return 1
+}
+
+func TestMRRAt(t *testing.T) {
+	tests := []struct {
+		name       string
+		events     []subscriptionEventRow
+		day        string
+		wantTotal  float64
+		wantActive int
+	}{
+		{"no events", nil, "2026-02-01", 0, 0},
+		{"before the first event", testMRREvents, "2026-01-10", 0, 0},
+		{"events of the day excluded", testMRREvents, "2026-02-20", 250, 3},
+		{"last event of each subscription", testMRREvents, "2026-02-21", 300, 3},
+		{"cancelled subscription not counted", testMRREvents, "2026-03-01", 250, 2},
+		{"reactivated", testMRREvents, "2026-04-01", 260, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total, active := mrrAt(tt.events, testDay(tt.day), testMRRFactor)
+			if total != tt.wantTotal || active != tt.wantActive {
+				t.Errorf("mrrAt(%s) = %v, %d, want %v, %d", tt.day, total, active, tt.wantTotal, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestReplayMRRMonth(t *testing.T) {
+	tests := []struct {
+		month string
+		want  mrrMonth
+	}{
+		{
+			month: "2026-01",
+			want: mrrMonth{
+				Month: "2026-01", New: 150, NetNew: 150,
+				MRREnd: 150, ARREnd: 1800, SubscriptionsEnd: 2,
+			},
+		},
+		{
+			// 50 of 150 lost: one subscription out of two
+			month: "2026-02",
+			want: mrrMonth{
+				Month: "2026-02", MRRStart: 150, New: 100, Expansion: 50, Churn: 50, NetNew: 100,
+				MRREnd: 250, ARREnd: 3000, SubscriptionsStart: 2, SubscriptionsEnd: 2,
+				Churned: 1, ChurnRatePct: 33.3, LogoChurnRatePct: 50,
+			},
+		},
+		{
+			month: "2026-03",
+			want: mrrMonth{
+				Month: "2026-03", MRRStart: 250, Contraction: 50, Reactivation: 60, NetNew: 10,
+				MRREnd: 260, ARREnd: 3120, SubscriptionsStart: 2, SubscriptionsEnd: 3,
+			},
+		},
+		{
+			month: "2026-04",
+			want: mrrMonth{
+				Month: "2026-04", MRRStart: 260, MRREnd: 260, ARREnd: 3120,
+				SubscriptionsStart: 3, SubscriptionsEnd: 3,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.month, func(t *testing.T) {
+			got := replayMRRMonth(testMRREvents, testDay(tt.month+"-01"), testMRRFactor)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("replayMRRMonth(%s) = %+v, want %+v", tt.month, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package hooks
+
+import (
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	_ "pocket-crm/pb_migrations"
+)
+
+// newTestApp returns an app on an empty database in a temporary directory,
+// migrated to the current schema, with the hooks of register bound.
+func newTestApp(t *testing.T, register ...func(core.App)) core.App {
+	t.Helper()
+	app := core.NewBaseApp(core.BaseAppConfig{DataDir: t.TempDir()})
+	if err := app.Bootstrap(); err != nil {
+		t.Fatalf("bootstrap: %v", err)
+	}
+	t.Cleanup(func() { app.ResetBootstrapState() }) //nolint:errcheck
+	if err := app.RunAllMigrations(); err != nil {
+		t.Fatalf("migrations: %v", err)
+	}
+	for _, r := range register {
+		r(app)
+	}
+	return app
+}
+
+// testRecord saves a record of collection with fields, failing the test on error.
+func testRecord(t *testing.T, app core.App, collection string, fields map[string]any) *core.Record {
+	t.Helper()
+	col, err := app.FindCollectionByNameOrId(collection)
+	if err != nil {
+		t.Fatalf("collection %s: %v", collection, err)
+	}
+	rec := core.NewRecord(col)
+	for k, v := range fields {
+		rec.Set(k, v)
+	}
+	if err := app.Save(rec); err != nil {
+		t.Fatalf("save %s: %v", collection, err)
+	}
+	return rec
+}
@@ -0,0 +1,524 @@
+package hooks
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+	"pocket-crm/services"
+)
+
+// ─── Web-to-lead ─────────────────────────────────────────────────────────────
+//
+// Each web_forms record has a secret token; the marketing site posts its forms
+// (JSON or urlencoded) to /api/crm/public/forms/{token} without logging in.
+// A submission finds (same email, company name or domain; existing records
+// are left untouched) or creates the contact and company, creates a site_web
+// lead with its UTM parameters (assignment rules pick the owner unless the
+// form has one) in one transaction, and optionally sends the autoresponder
+// template.
+
+const (
+	// maxWebFormSize caps a submission body.
+	maxWebFormSize = 64 << 10
+	// webFormMatchScore: an existing contact / company is reused from this
+	// score only (same email, same company name or domain).
+	webFormMatchScore = 90
+	// defaultWebFormRateLimit is the number of submissions per IP and hour.
+	defaultWebFormRateLimit = 10
+	defaultHoneypotField    = "url"
+)
+
+// webFormFields are the submission fields mapped onto records; the others are
+// appended to the lead notes.
+var webFormFields = map[string]bool{
+	"first_name": true, "last_name": true, "name": true, "email": true, "phone": true,
+	"position": true, "company": true, "website": true, "subject": true, "message": true,
+	"locale": true, "utm_source": true, "utm_medium": true, "utm_campaign": true,
+	"utm_term": true, "utm_content": true,
+}
+
+// ipRateLimiter allows limit hits per IP within a sliding window.
+type ipRateLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	hits   map[string][]time.Time
+}
+
+func newIPRateLimiter(limit int, window time.Duration) *ipRateLimiter {
+	return &ipRateLimiter{limit: limit, window: window, hits: map[string][]time.Time{}}
+}
+
+// allow records a hit for ip and reports whether it is within the limit.
+func (l *ipRateLimiter) allow(ip string, now time.Time) bool {
+	if l.limit <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cutoff := now.Add(-l.window)
+	recent := func(hits []time.Time) []time.Time {
+		kept := hits[:0]
+		for _, t := range hits {
+			if t.After(cutoff) {
+				kept = append(kept, t)
+			}
+		}
+		return kept
+	}
+	// Forget idle IPs now and then so the map does not grow forever
+	if len(l.hits) > 10000 {
+		for key, hits := range l.hits {
+			if len(recent(hits)) == 0 {
+				delete(l.hits, key)
+			}
+		}
+	}
+	hits := recent(l.hits[ip])
+	if len(hits) >= l.limit {
+		l.hits[ip] = hits
+		return false
+	}
+	l.hits[ip] = append(hits, now)
+	return true
+}
+
+// webFormRateLimit reads WEB_FORM_RATE_LIMIT (submissions per IP and hour,
+// 0 disables the limit).
+func webFormRateLimit() int {
+	if v, err := strconv.Atoi(os.Getenv("WEB_FORM_RATE_LIMIT")); err == nil && v >= 0 {
+		return v
+	}
+	return defaultWebFormRateLimit
+}
+
+// RegisterWebFormHooks generates the form tokens and serves the public
+// POST /api/crm/public/forms/{token} endpoint.
+func RegisterWebFormHooks(app core.App) {
+	prepare := func(e *core.RecordEvent) error {
+		if e.Record.GetString("token") == "" {
+			e.Record.Set("token", security.RandomString(32))
+		}
+		if e.Record.GetString("honeypot_field") == "" {
+			e.Record.Set("honeypot_field", defaultHoneypotField)
+		}
+		if webFormFields[e.Record.GetString("honeypot_field")] {
+			return validation.Errors{
+				"honeypot_field": validation.NewError("validation_honeypot_field", "ce nom de champ est utilisé par le formulaire"),
+			}
+		}
+		return e.Next()
+	}
+	app.OnRecordCreate("web_forms").BindFunc(prepare)
+	app.OnRecordUpdate("web_forms").BindFunc(prepare)
+
+	limiter := newIPRateLimiter(webFormRateLimit(), time.Hour)
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.POST("/api/crm/public/forms/{token}", buildWebFormSubmit(app, limiter))
+		return se.Next()
+	})
+
+	log.Printf("[hooks] Web form hooks registered (/api/crm/public/forms/{token}, %d submissions/IP/hour)", limiter.limit)
+}
+
+// webFormValues reads a JSON object or an urlencoded / multipart form into
+// trimmed strings (first value of repeated fields).
+func webFormValues(e *core.RequestEvent) (map[string]string, error) {
+	e.Request.Body = http.MaxBytesReader(e.Response, e.Request.Body, maxWebFormSize)
+	values := map[string]string{}
+
+	if strings.HasPrefix(e.Request.Header.Get("Content-Type"), "application/json") {
+		var raw map[string]any
+		if err := json.NewDecoder(e.Request.Body).Decode(&raw); err != nil {
+			return nil, err
+		}
+		for k, v := range raw {
+			switch v := v.(type) {
+			case nil:
+			case string:
+				values[k] = strings.TrimSpace(v)
+			case float64, bool:
+				values[k] = fmt.Sprint(v)
+			}
+		}
+		return values, nil
+	}
+
+	if err := e.Request.ParseMultipartForm(maxWebFormSize); err != nil && err != http.ErrNotMultipart {
+		return nil, err
+	}
+	for k, v := range e.Request.PostForm {
+		if len(v) > 0 {
+			values[k] = strings.TrimSpace(v[0])
+		}
+	}
+	return values, nil
+}
+
+// buildWebFormSubmit handles a form submission. Browser (non-JSON) posts are
+// redirected to the form's redirect_url, or get a thank-you page.
+func buildWebFormSubmit(app core.App, limiter *ipRateLimiter) func(*core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		form, err := app.FindFirstRecordByData("web_forms", "token", e.Request.PathValue("token"))
+		if err != nil || !form.GetBool("active") || form.GetString("token") == "" {
+			return e.NotFoundError("Form not found", nil)
+		}
+		if !limiter.allow(e.RealIP(), time.Now()) {
+			return e.TooManyRequestsError("Too many submissions, try again later", nil)
+		}
+		values, err := webFormValues(e)
+		if err != nil {
+			return e.BadRequestError("Invalid form data", nil)
+		}
+		fromBrowser := !strings.HasPrefix(e.Request.Header.Get("Content-Type"), "application/json")
+		locale := services.NormalizeLocale(values["locale"])
+
+		// Bots fill in the hidden field: answer as if it worked, keep nothing
+		if values[form.GetString("honeypot_field")] != "" {
+			if _, err := app.DB().NewQuery(`UPDATE web_forms SET spam_blocked = COALESCE(spam_blocked, 0) + 1 WHERE id = {:id}`).
+				Bind(map[string]any{"id": form.Id}).Execute(); err != nil {
+				log.Printf("[web-forms] failed to count spam for form %s: %v", form.Id, err)
+			}
+			return webFormDone(e, form, locale, fromBrowser)
+		}
+
+		if errs := validateWebFormValues(values); errs != nil {
+			return e.BadRequestError("Invalid form data", errs)
+		}
+
+		lead, err := createWebLead(app, form, values, locale, e.RealIP())
+		if err != nil {
+			log.Printf("[web-forms] submission to form %s failed: %v", form.Id, err)
+			return e.InternalServerError("Failed to record the submission", nil)
+		}
+		log.Printf("[web-forms] form %s: lead %s created", form.Id, lead.Id)
+		return webFormDone(e, form, locale, fromBrowser)
+	}
+}
+
+// webFormDone answers a successful (or silently dropped) submission.
+func webFormDone(e *core.RequestEvent, form *core.Record, locale string, fromBrowser bool) error {
+	if !fromBrowser {
+		return e.JSON(http.StatusOK, map[string]bool{"success": true})
+	}
+	if target := form.GetString("redirect_url"); target != "" {
+		return e.Redirect(http.StatusSeeOther, target)
+	}
+	return e.HTML(http.StatusOK, quotePage(locale, services.T(locale, "web_form.thanks_title"),
+		"<p>"+services.T(locale, "web_form.thanks")+"</p>"))
+}
+
+// validateWebFormValues requires an email and a first and last name (or a
+// "name" with both).
+func validateWebFormValues(values map[string]string) validation.Errors {
+	if values["first_name"] == "" && values["last_name"] == "" {
+		if first, last, ok := strings.Cut(values["name"], " "); ok {
+			values["first_name"], values["last_name"] = strings.TrimSpace(first), strings.TrimSpace(last)
+		}
+	}
+	errs := validation.Errors{}
+	if values["email"] == "" {
+		errs["email"] = validation.NewError("validation_required", "l'email est obligatoire")
+	} else if is.EmailFormat.Validate(values["email"]) != nil {
+		errs["email"] = validation.NewError("validation_is_email", "email invalide")
+	}
+	if values["first_name"] == "" {
+		errs["first_name"] = validation.NewError("validation_required", "le prénom est obligatoire")
+	}
+	if values["last_name"] == "" {
+		errs["last_name"] = validation.NewError("validation_required", "le nom est obligatoire")
+	}
+	for field, max := range map[string]int{"first_name": 200, "last_name": 200, "company": 200, "position": 200, "phone": 50, "subject": 200} {
+		if len([]rune(values[field])) > max {
+			errs[field] = validation.NewError("validation_length_too_long", fmt.Sprintf("%d caractères maximum", max))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// createWebLead finds or creates the company and contact, then the lead, in
+// one transaction.
+func createWebLead(app core.App, form *core.Record, values map[string]string, locale, ip string) (*core.Record, error) {
+	var lead, contact *core.Record
+	err := app.RunInTransaction(func(txApp core.App) error {
+		company, newCompany, err := webFormCompany(txApp, values)
+		if err != nil {
+			return fmt.Errorf("company: %w", err)
+		}
+		companyID := ""
+		if company != nil {
+			companyID = company.Id
+		}
+		var newContact bool
+		contact, newContact, err = webFormContact(txApp, values, companyID, locale)
+		if err != nil {
+			return fmt.Errorf("contact: %w", err)
+		}
+
+		leads, err := txApp.FindCollectionByNameOrId("leads")
+		if err != nil {
+			return err
+		}
+		lead = core.NewRecord(leads)
+		label := values["company"]
+		if label == "" {
+			label = values["first_name"] + " " + values["last_name"]
+		}
+		title := fmt.Sprintf("%s — %s", form.GetString("name"), label)
+		if values["subject"] != "" {
+			title = fmt.Sprintf("%s — %s", values["subject"], label)
+		}
+		lead.Set("title", title)
+		lead.Set("source", "site_web")
+		lead.Set("priority", "moyenne")
+		lead.Set("contact", contact.Id)
+		lead.Set("company", companyID)
+		lead.Set("pipeline", form.GetString("pipeline"))
+		lead.Set("owner", form.GetString("owner"))
+		lead.Set("campaign_id", form.GetString("campaign"))
+		lead.Set("web_form", form.Id)
+		for _, key := range []string{"utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content"} {
+			lead.Set(key, truncateRunes(values[key], 200))
+		}
+		lead.Set("notes", webLeadNotes(values, ip))
+		if err := txApp.Save(lead); err != nil {
+			return err
+		}
+
+		// Records created by the submission belong to the lead owner
+		if owner := lead.GetString("owner"); owner != "" {
+			created := make([]*core.Record, 0, 2)
+			if newContact {
+				created = append(created, contact)
+			}
+			if newCompany {
+				created = append(created, company)
+			}
+			for _, rec := range created {
+				if rec.GetString("owner") == "" {
+					rec.Set("owner", owner)
+					if err := txApp.Save(rec); err != nil {
+						return fmt.Errorf("owner of %s %s: %w", rec.Collection().Name, rec.Id, err)
+					}
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := app.DB().NewQuery(`
+		UPDATE web_forms SET submissions = COALESCE(submissions, 0) + 1, last_submission_at = {:now} WHERE id = {:id}
+	`).Bind(map[string]any{"id": form.Id, "now": time.Now().UTC().Format("2006-01-02 15:04:05.000Z")}).Execute(); err != nil {
+		log.Printf("[web-forms] failed to count submission for form %s: %v", form.Id, err)
+	}
+
+	if template := form.GetString("autoresponder"); template != "" {
+		pageLocale := ""
+		if values["locale"] != "" {
+			pageLocale = locale
+		}
+		sendWebFormAutoresponder(app, template, lead, contact, pageLocale)
+	}
+	return lead, nil
+}
+
+// webFormCompany returns the matching company (same name or domain), creates
+// it when a company name was given (created = true), or returns nil.
+func webFormCompany(app core.App, values map[string]string) (*core.Record, bool, error) {
+	name := truncateRunes(values["company"], 200)
+	candidate := companyCandidate("", name, values["website"], values["email"], "")
+	if candidate.Name == "" && candidate.Domain == "" {
+		return nil, false, nil
+	}
+	existing, err := webFormCompanyCandidates(app, name, candidate.Domain)
+	if err != nil {
+		return nil, false, err
+	}
+	if matches := services.MatchDuplicates("companies", candidate, existing, webFormMatchScore); len(matches) > 0 {
+		company, err := app.FindRecordById("companies", matches[0].B.ID)
+		return company, false, err
+	}
+	if name == "" {
+		return nil, false, nil
+	}
+	col, err := app.FindCollectionByNameOrId("companies")
+	if err != nil {
+		return nil, false, err
+	}
+	company := core.NewRecord(col)
+	company.Set("name", name)
+	if services.WebsiteDomain(values["website"]) != "" {
+		company.Set("website", truncateRunes(values["website"], 500))
+	}
+	if err := app.Save(company); err != nil {
+		return nil, false, err
+	}
+	return company, true, nil
+}
+
+// webFormCompanyCandidates reads the companies named name or whose website or
+// email is on domain, through the indexes of migration 0022.
+func webFormCompanyCandidates(app core.App, name, domain string) ([]services.DuplicateCandidate, error) {
+	conds := make([]string, 0, 3)
+	params := dbx.Params{}
+	if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+		conds = append(conds, "lower(name) = {:name}")
+		params["name"] = name
+	}
+	if domain != "" {
+		// Websites are stored as typed: "acme.fr", "https://www.acme.fr/"…
+		websites := make([]string, 0, 12)
+		for _, scheme := range []string{"", "http://", "https://"} {
+			for _, host := range []string{domain, "www." + domain} {
+				for _, slash := range []string{"", "/"} {
+					key := fmt.Sprintf("w%d", len(websites))
+					websites = append(websites, "{:"+key+"}")
+					params[key] = scheme + host + slash
+				}
+			}
+		}
+		conds = append(conds, "lower(website) IN ("+strings.Join(websites, ", ")+")",
+			"lower(substr(email, instr(email, '@') + 1)) = {:domain}")
+		params["domain"] = domain
+	}
+	if len(conds) == 0 {
+		return nil, nil
+	}
+
+	var rows []struct {
+		ID      string `db:"id"`
+		Name    string `db:"name"`
+		Website string `db:"website"`
+		Email   string `db:"email"`
+		Phone   string `db:"phone"`
+	}
+	err := app.DB().NewQuery(`
+		SELECT id, name, COALESCE(website, '') AS website, COALESCE(email, '') AS email, COALESCE(phone, '') AS phone
+		FROM companies WHERE ` + strings.Join(conds, " OR ")).Bind(params).All(&rows)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]services.DuplicateCandidate, 0, len(rows))
+	for _, r := range rows {
+		candidates = append(candidates, companyCandidate(r.ID, r.Name, r.Website, r.Email, r.Phone))
+	}
+	return candidates, nil
+}
+
+// webFormContact returns the contact with the same email whatever its case,
+// left untouched (an anonymous submission does not edit CRM data), or creates
+// it (created = true).
+func webFormContact(app core.App, values map[string]string, companyID, locale string) (*core.Record, bool, error) {
+	var existingID string
+	err := app.DB().NewQuery("SELECT id FROM contacts WHERE lower(email) = {:email} ORDER BY created LIMIT 1").
+		Bind(dbx.Params{"email": services.NormalizeEmail(values["email"])}).Row(&existingID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, err
+	}
+	if existingID != "" {
+		contact, err := app.FindRecordById("contacts", existingID)
+		return contact, false, err
+	}
+
+	col, err := app.FindCollectionByNameOrId("contacts")
+	if err != nil {
+		return nil, false, err
+	}
+	contact := core.NewRecord(col)
+	contact.Set("first_name", values["first_name"])
+	contact.Set("last_name", values["last_name"])
+	contact.Set("email", values["email"])
+	contact.Set("phone", values["phone"])
+	contact.Set("position", values["position"])
+	contact.Set("company", companyID)
+	contact.Set("tags", []string{"prospect"})
+	if values["locale"] != "" {
+		contact.Set("locale", locale)
+	}
+	if err := app.Save(contact); err != nil {
+		return nil, false, err
+	}
+	return contact, true, nil
+}
+
+// webLeadNotes keeps the message and the extra form fields on the lead.
+func webLeadNotes(values map[string]string, ip string) string {
+	var b strings.Builder
+	if msg := values["message"]; msg != "" {
+		b.WriteString("<p>" + strings.ReplaceAll(html.EscapeString(truncateRunes(msg, 10000)), "\n", "<br>") + "</p>")
+	}
+	extra := make([]string, 0)
+	for k, v := range values {
+		if !webFormFields[k] && v != "" {
+			extra = append(extra, k)
+		}
+	}
+	sort.Strings(extra)
+	if len(extra) > 0 {
+		b.WriteString("<ul>")
+		for _, k := range extra {
+			b.WriteString(fmt.Sprintf("<li><strong>%s</strong> : %s</li>", html.EscapeString(truncateRunes(k, 100)), html.EscapeString(truncateRunes(values[k], 1000))))
+		}
+		b.WriteString("</ul>")
+	}
+	b.WriteString(fmt.Sprintf("<p><em>Formulaire web, IP %s</em></p>", html.EscapeString(ip)))
+	return b.String()
+}
+
+// sendWebFormAutoresponder emails the template to the contact in the
+// background, as the lead owner (email_logs require a sender). An empty
+// locale falls back to the contact's language.
+func sendWebFormAutoresponder(app core.App, templateID string, lead, contact *core.Record, locale string) {
+	sender := lead.GetString("owner")
+	if sender == "" {
+		log.Printf("[web-forms] autoresponder skipped for lead %s: no owner to send as", lead.Id)
+		return
+	}
+	params := services.EmailSendParams{
+		TemplateID:         templateID,
+		RecipientEmail:     contact.GetString("email"),
+		RecipientName:      strings.TrimSpace(contact.GetString("first_name") + " " + contact.GetString("last_name")),
+		RecipientContactID: contact.Id,
+		LeadID:             lead.Id,
+		SentByID:           sender,
+		BaseURL:            app.Settings().Meta.AppURL,
+		Variables: map[string]string{
+			"first_name": contact.GetString("first_name"),
+			"last_name":  contact.GetString("last_name"),
+			"email":      contact.GetString("email"),
+			"lead_title": lead.GetString("title"),
+		},
+	}
+	// The page language wins over the contact's preferred one
+	if locale != "" {
+		params.Locale = locale
+	}
+	go func() {
+		if err := services.SendTemplatedEmail(app, params); err != nil {
+			log.Printf("[web-forms] autoresponder for lead %s failed: %v", lead.Id, err)
+		}
+	}()
+}
@@ -0,0 +1,140 @@
+package hooks
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+)
+
+func TestIPRateLimiter(t *testing.T) {
+	start := time.Date(2026, 5, 4, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		limit int
+		hits  []string      // ip of each hit, a minute apart
+		last  string        // ip of the checked hit
+		after time.Duration // delay of the checked hit after the last one
+		want  bool
+	}{
+		{"within the limit", 3, []string{"1.1.1.1", "1.1.1.1"}, "1.1.1.1", time.Minute, true},
+		{"over the limit", 2, []string{"1.1.1.1", "1.1.1.1"}, "1.1.1.1", time.Minute, false},
+		{"limit per ip", 2, []string{"1.1.1.1", "1.1.1.1"}, "2.2.2.2", time.Minute, true},
+		{"window elapsed", 2, []string{"1.1.1.1", "1.1.1.1"}, "1.1.1.1", time.Hour, true},
+		{"refused hits not counted", 1, []string{"1.1.1.1", "1.1.1.1", "1.1.1.1"}, "1.1.1.1", time.Hour - time.Minute, true},
+		{"no limit", 0, []string{"1.1.1.1", "1.1.1.1"}, "1.1.1.1", time.Minute, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := newIPRateLimiter(tt.limit, time.Hour)
+			now := start
+			for i, ip := range tt.hits {
+				now = start.Add(time.Duration(i) * time.Minute)
+				limiter.allow(ip, now)
+			}
+			if got := limiter.allow(tt.last, now.Add(tt.after)); got != tt.want {
+				t.Errorf("allow(%s) after %v = %v, want %v", tt.last, tt.after, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebFormContact(t *testing.T) {
+	app := newTestApp(t)
+	existing := testRecord(t, app, "contacts", map[string]any{"first_name": "Jean", "last_name": "Dupont", "email": "Jean.Dupont@Acme.fr"})
+
+	tests := []struct {
+		name         string
+		email        string
+		wantExisting bool
+	}{
+		{"same case", "Jean.Dupont@Acme.fr", true},
+		{"lower case", "jean.dupont@acme.fr", true},
+		{"upper case", "JEAN.DUPONT@ACME.FR", true},
+		{"other email", "marie.curie@acme.fr", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := map[string]string{"first_name": "Jean", "last_name": "Dupont", "email": tt.email}
+			contact, created, err := webFormContact(app, values, "", "fr")
+			if err != nil {
+				t.Fatalf("webFormContact() error = %v", err)
+			}
+			if created == tt.wantExisting || (contact.Id == existing.Id) != tt.wantExisting {
+				t.Errorf("webFormContact(%s) = %s, created %v, want existing %v", tt.email, contact.Id, created, tt.wantExisting)
+			}
+		})
+	}
+}
+
+func TestWebFormSubmit(t *testing.T) {
+	app := newTestApp(t, RegisterPipelineHooks, RegisterLeadHooks)
+	form := testRecord(t, app, "web_forms", map[string]any{"name": "Contact", "token": "abc123", "active": true, "honeypot_field": "url"})
+	limiter := newIPRateLimiter(3, time.Hour)
+	submit := buildWebFormSubmit(app, limiter)
+
+	const valid = `"first_name": "Marie", "last_name": "Curie", "email": "marie@radium.fr"`
+	tests := []struct {
+		name       string
+		ip         string
+		body       string
+		wantStatus int
+		wantLeads  int
+		wantSpam   int
+	}{
+		{"honeypot filled", "198.51.100.1", `{` + valid + `, "url": "http://spam.example"}`, http.StatusOK, 0, 1},
+		{"invalid submission", "198.51.100.1", `{"first_name": "Marie"}`, http.StatusBadRequest, 0, 1},
+		{"genuine submission", "198.51.100.1", `{` + valid + `, "url": ""}`, http.StatusOK, 1, 1},
+		{"rate limited", "198.51.100.1", `{` + valid + `}`, http.StatusTooManyRequests, 1, 1},
+		{"other ip", "198.51.100.2", `{` + valid + `}`, http.StatusOK, 2, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/crm/public/forms/abc123", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.RemoteAddr = tt.ip + ":40000"
+			req.SetPathValue("token", "abc123")
+			rec := httptest.NewRecorder()
+			e := &core.RequestEvent{App: app}
+			e.Request, e.Response = req, rec
+
+			status := rec.Code
+			if err := submit(e); err != nil {
+				var apiErr *router.ApiError
+				if !errors.As(err, &apiErr) {
+					t.Fatalf("submit() error = %v", err)
+				}
+				status = apiErr.Status
+			}
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+
+			leads, err := app.CountRecords("leads", dbx.HashExp{"web_form": form.Id})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if int(leads) != tt.wantLeads {
+				t.Errorf("leads = %d, want %d", leads, tt.wantLeads)
+			}
+			stored, err := app.FindRecordById("web_forms", form.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := stored.GetInt("spam_blocked"); got != tt.wantSpam {
+				t.Errorf("spam_blocked = %d, want %d", got, tt.wantSpam)
+			}
+		})
+	}
+
+	// Both genuine submissions share the contact
+	if n, _ := app.CountRecords("contacts", dbx.NewExp("lower(email) = 'marie@radium.fr'")); n != 1 {
+		t.Errorf("contacts of marie@radium.fr = %d, want 1", n)
+	}
+}
@@ -0,0 +1,130 @@
+package hooks
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// ─── Win / Loss Stats ─────────────────────────────────────────────────────────
+
+// winLossRow is one bucket of a win/loss breakdown.
+type winLossRow struct {
+	Key   string  `db:"key" json:"key"`
+	Label string  `db:"label" json:"label"`
+	Count int     `db:"count" json:"count"`
+	Value float64 `db:"value" json:"value"`
+}
+
+// winLossBreakdowns maps a breakdown name to its key expression, label
+// expression and extra joins over the "closed" CTE below.
+var winLossBreakdowns = map[string][3]string{
+	"by_reason": {
+		"COALESCE(c.close_reason, '')",
+		"COALESCE(dr.name, 'Non renseignée')",
+		"LEFT JOIN deal_reasons dr ON dr.id = c.close_reason",
+	},
+	"by_source": {"COALESCE(c.source, '')", "COALESCE(c.source, '')", ""},
+	"by_owner": {
+		"COALESCE(c.owner, '')",
+		"COALESCE(u.name, 'Non attribué')",
+		"LEFT JOIN users u ON u.id = c.owner",
+	},
+	"by_stage": {
+		"COALESCE(c.prev_stage, '')",
+		"COALESCE(ps.name, 'Inconnue')",
+		"LEFT JOIN pipeline_stages ps ON ps.id = c.prev_stage",
+	},
+	"by_competitor": {
+		"LOWER(COALESCE(NULLIF(TRIM(c.competitor), ''), ''))",
+		"COALESCE(NULLIF(TRIM(c.competitor), ''), 'Non renseigné')",
+		"",
+	},
+}
+
+// winLossBreakdown groups the leads closed (won or lost) since start.
+// prev_stage is the stage the lead was in right before closing.
+func winLossBreakdown(app core.App, outcomeFlag, name, start, pipeline, currency string) []winLossRow {
+	expr := winLossBreakdowns[name]
+	rows := make([]winLossRow, 0)
+	app.DB().NewQuery(fmt.Sprintf(`
+		WITH closed AS (
+			SELECT l.id, %s AS value, l.source, l.owner, l.competitor, l.close_reason,
+			       (SELECT h.from_stage FROM lead_stage_history h
+			        WHERE h.lead = l.id AND h.to_stage = l.stage
+			        ORDER BY h.changed_at DESC LIMIT 1) AS prev_stage
+			FROM leads l
+			JOIN pipeline_stages s ON s.id = l.stage
+			WHERE s.%s = TRUE
+			  AND l.closed_at >= {:start}
+			  AND ({:pipeline} = '' OR l.pipeline = {:pipeline})
+		)
+		SELECT %s AS key, %s AS label, COUNT(*) AS count, COALESCE(SUM(c.value), 0) AS value
+		FROM closed c
+		%s
+		GROUP BY key
+		ORDER BY count DESC, value DESC
+	`, leadValueSQL("l"), outcomeFlag, expr[0], expr[1], expr[2])).
+		Bind(dbx.Params{"start": start, "pipeline": pipeline, "currency": currency}).All(&rows) //nolint:errcheck
+	return rows
+}
+
+// buildWinLossStats serves GET /api/crm/stats/win-loss?period=&pipeline=
+// Losses are broken down by reason, source, owner, stage at loss and
+// competitor; wins by reason. Leads count in the period of their closed_at.
+func buildWinLossStats(app core.App) func(*core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		start, _, _ := parsePeriodDates(e)
+		pipeline := e.Request.URL.Query().Get("pipeline")
+		rc, err := statsCurrency(app, e)
+		if err != nil {
+			return e.BadRequestError(err.Error(), nil)
+		}
+
+		type totalsRow struct {
+			Won       int     `db:"won"`
+			WonValue  float64 `db:"won_value"`
+			Lost      int     `db:"lost"`
+			LostValue float64 `db:"lost_value"`
+		}
+		var totals totalsRow
+		value := leadValueSQL("l")
+		app.DB().NewQuery(`
+			SELECT
+				COALESCE(SUM(CASE WHEN s.is_won  = TRUE THEN 1 ELSE 0 END), 0) AS won,
+				COALESCE(SUM(CASE WHEN s.is_won  = TRUE THEN ` + value + ` ELSE 0 END), 0) AS won_value,
+				COALESCE(SUM(CASE WHEN s.is_lost = TRUE THEN 1 ELSE 0 END), 0) AS lost,
+				COALESCE(SUM(CASE WHEN s.is_lost = TRUE THEN ` + value + ` ELSE 0 END), 0) AS lost_value
+			FROM leads l
+			JOIN pipeline_stages s ON s.id = l.stage
+			WHERE l.closed_at >= {:start}
+			  AND ({:pipeline} = '' OR l.pipeline = {:pipeline})
+		`).Bind(dbx.Params{"start": start, "pipeline": pipeline, "currency": rc}).One(&totals) //nolint:errcheck
+
+		winRate := 0.0
+		if closed := totals.Won + totals.Lost; closed > 0 {
+			winRate = float64(totals.Won) / float64(closed) * 100
+		}
+
+		losses := map[string]interface{}{
+			"count": totals.Lost,
+			"value": totals.LostValue,
+		}
+		for _, name := range []string{"by_reason", "by_source", "by_owner", "by_stage", "by_competitor"} {
+			losses[name] = winLossBreakdown(app, "is_lost", name, start, pipeline, rc)
+		}
+
+		return e.JSON(http.StatusOK, map[string]interface{}{
+			"win_rate": fmt.Sprintf("%.1f", winRate),
+			"wins": map[string]interface{}{
+				"count":     totals.Won,
+				"value":     totals.WonValue,
+				"by_reason": winLossBreakdown(app, "is_won", "by_reason", start, pipeline, rc),
+			},
+			"losses":   losses,
+			"currency": rc,
+		})
+	}
+}
@@ -48,22 +48,66 @@ func main() {
 
 	// Phase 5 — Invoice hooks (auto-calculate TTC total + overdue check)
 	hooks.RegisterInvoiceHooks(app)
+	hooks.RegisterInvoiceSequenceHooks(app)
+
+	// Sales pipelines (default pipeline, stage validation)
+	hooks.RegisterPipelineHooks(app)
 
 	// Phase 6 — Lead lifecycle hooks (activity tracking + owner notifications)
 	hooks.RegisterLeadHooks(app)
 
+	// Product catalogue + lead line items (lead value computed from lines)
+	hooks.RegisterLeadItemHooks(app)
+
+	// Contacts involved in a lead, with their buying role
+	hooks.RegisterLeadContactHooks(app)
+
+	// Quotes (numbering, versions, PDF, sending, signed acceptance link)
+	hooks.RegisterQuoteHooks(app)
+
+	// Subscriptions (MRR events, cycle invoices, renewals and reminders)
+	hooks.RegisterSubscriptionHooks(app)
+
+	// Duplicate detection (check, report) and merge for contacts, companies, leads
+	hooks.RegisterDuplicateRoutes(app)
+
+	// Public web-to-lead forms (tokenized endpoint, honeypot, rate limit, autoresponder)
+	hooks.RegisterWebFormHooks(app)
+
+	// Rule-based lead scoring (related record changes + daily recompute)
+	hooks.RegisterLeadScoringHooks(app)
+	hooks.RegisterLeadScoringScheduler(app)
+
+	// Stale deal detection (follow-up tasks + digest, daily)
+	hooks.RegisterStaleLeadMonitor(app)
+
+	// Daily open pipeline snapshots (pipeline over time, waterfall, forecast accuracy)
+	hooks.RegisterPipelineSnapshotScheduler(app)
+
 	// Phase 6 — Email API routes + welcome hook
 	hooks.RegisterEmailRoutes(app)
 
+	// Email template translations validation
+	hooks.RegisterEmailTemplateHooks(app)
+
 	// Phase 6 — Scheduled campaign background sender (60s cron)
 	hooks.RegisterCampaignScheduler(app)
 
+	// Contact email engagement scores (daily recompute)
+	hooks.RegisterEngagementScheduler(app)
+
 	// Phase 7 — Analytics & statistics routes
 	hooks.RegisterStatsRoutes(app)
 
+	// Sales quotas per user / team (validation + attainment with pace projection)
+	hooks.RegisterQuotaHooks(app)
+
 	// Campaign ↔ expense category type validation
 	hooks.RegisterMarketingExpenseHooks(app)
 
+	// Multi-currency (currency validation on amounts + exchange rate import)
+	hooks.RegisterExchangeRateHooks(app)
+
 	if err := app.Start(); err != nil {
 		log.Fatal(err)
 	}
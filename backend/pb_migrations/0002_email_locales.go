@@ -0,0 +1,100 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Locales supported by the email templates and system notifications.
+var supportedLocales = []string{"fr", "en"}
+
+func init() {
+	m.Register(func(app core.App) error {
+		// ==========================================
+		// USERS — preferred language for notifications
+		// ==========================================
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+		users.Fields.Add(&core.SelectField{
+			Name:      "locale",
+			Values:    supportedLocales,
+			MaxSelect: 1,
+		})
+		if err := app.Save(users); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// CONTACTS — preferred language (falls back to company country)
+		// ==========================================
+		contacts, err := app.FindCollectionByNameOrId("contacts")
+		if err != nil {
+			return err
+		}
+		contacts.Fields.Add(&core.SelectField{
+			Name:      "locale",
+			Values:    supportedLocales,
+			MaxSelect: 1,
+		})
+		if err := app.Save(contacts); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// EMAIL_TEMPLATES — base locale + per-locale translations
+		// ==========================================
+		// translations: {"en": {"subject": "...", "body": "..."}, ...}
+		// subject/body remain the variant written in the template's base locale.
+		emailTemplates, err := app.FindCollectionByNameOrId("email_templates")
+		if err != nil {
+			return err
+		}
+		emailTemplates.Fields.Add(&core.SelectField{
+			Name:      "locale",
+			Values:    supportedLocales,
+			MaxSelect: 1,
+		})
+		emailTemplates.Fields.Add(&core.JSONField{Name: "translations", MaxSize: 500000})
+		if err := app.Save(emailTemplates); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// EMAIL_LOGS — locale actually used for the send
+		// ==========================================
+		emailLogs, err := app.FindCollectionByNameOrId("email_logs")
+		if err != nil {
+			return err
+		}
+		emailLogs.Fields.Add(&core.TextField{Name: "locale", Max: 10})
+		if err := app.Save(emailLogs); err != nil {
+			return err
+		}
+
+		// Existing templates were all written in French
+		_, err = app.DB().NewQuery("UPDATE email_templates SET locale = 'fr' WHERE locale = '' OR locale IS NULL").Execute()
+		return err
+	}, func(app core.App) error {
+		fields := map[string][]string{
+			"users":           {"locale"},
+			"contacts":        {"locale"},
+			"email_templates": {"locale", "translations"},
+			"email_logs":      {"locale"},
+		}
+		for name, names := range fields {
+			col, err := app.FindCollectionByNameOrId(name)
+			if err != nil {
+				continue
+			}
+			for _, f := range names {
+				col.Fields.RemoveByName(f)
+			}
+			if err := app.Save(col); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, "0002_email_locales")
+}
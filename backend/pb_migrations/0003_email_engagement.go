@@ -0,0 +1,90 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// ==========================================
+		// CONTACTS — engagement score (recomputed by hooks + daily job)
+		// ==========================================
+		contacts, err := app.FindCollectionByNameOrId("contacts")
+		if err != nil {
+			return err
+		}
+		contacts.Fields.Add(&core.NumberField{Name: "engagement_score", Min: floatPtr(0), Max: floatPtr(100)})
+		contacts.Fields.Add(&core.DateField{Name: "engagement_updated"})
+		if err := app.Save(contacts); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// EMAIL_LOGS — "ignore" status for recipients skipped before sending
+		// ==========================================
+		emailLogs, err := app.FindCollectionByNameOrId("email_logs")
+		if err != nil {
+			return err
+		}
+		if f, ok := emailLogs.Fields.GetByName("status").(*core.SelectField); ok {
+			f.Values = []string{"en_attente", "envoye", "echoue", "ouvert", "clique", "ignore"}
+		}
+		emailLogs.Fields.Add(&core.SelectField{
+			Name:      "skip_reason",
+			Values:    []string{"frequence", "engagement"},
+			MaxSelect: 1,
+		})
+		// Used by the engagement score and frequency cap lookups
+		emailLogs.AddIndex("idx_email_logs_recipient", false, "recipient_contact, sent_at", "")
+		if err := app.Save(emailLogs); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// CAMPAIGNS — engagement segment + skipped counter
+		// ==========================================
+		campaigns, err := app.FindCollectionByNameOrId("campaigns")
+		if err != nil {
+			return err
+		}
+		campaigns.Fields.Add(&core.NumberField{Name: "min_engagement", Min: floatPtr(0), Max: floatPtr(100)})
+		campaigns.Fields.Add(&core.NumberField{Name: "skipped", Min: floatPtr(0)})
+		if err := app.Save(campaigns); err != nil {
+			return err
+		}
+
+		campaignRuns, err := app.FindCollectionByNameOrId("campaign_runs")
+		if err != nil {
+			return err
+		}
+		campaignRuns.Fields.Add(&core.NumberField{Name: "skipped", Min: floatPtr(0)})
+		return app.Save(campaignRuns)
+	}, func(app core.App) error {
+		fields := map[string][]string{
+			"contacts":      {"engagement_score", "engagement_updated"},
+			"email_logs":    {"skip_reason"},
+			"campaigns":     {"min_engagement", "skipped"},
+			"campaign_runs": {"skipped"},
+		}
+		for name, names := range fields {
+			col, err := app.FindCollectionByNameOrId(name)
+			if err != nil {
+				continue
+			}
+			for _, f := range names {
+				col.Fields.RemoveByName(f)
+			}
+			if name == "email_logs" {
+				col.RemoveIndex("idx_email_logs_recipient")
+				if f, ok := col.Fields.GetByName("status").(*core.SelectField); ok {
+					f.Values = []string{"en_attente", "envoye", "echoue", "ouvert", "clique"}
+				}
+			}
+			if err := app.Save(col); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, "0003_email_engagement")
+}
@@ -0,0 +1,76 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// ==========================================
+		// CAMPAIGNS — paused / cancelled statuses
+		// ==========================================
+		campaigns, err := app.FindCollectionByNameOrId("campaigns")
+		if err != nil {
+			return err
+		}
+		if f, ok := campaigns.Fields.GetByName("status").(*core.SelectField); ok {
+			f.Values = []string{"brouillon", "programmee", "en_cours", "en_pause", "envoye", "termine", "annulee"}
+		}
+		if err := app.Save(campaigns); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// CAMPAIGN_RUNS — run state + progress for pause/resume/cancel
+		// ==========================================
+		campaignRuns, err := app.FindCollectionByNameOrId("campaign_runs")
+		if err != nil {
+			return err
+		}
+		campaignRuns.Fields.Add(&core.SelectField{
+			Name:      "status",
+			Values:    []string{"en_cours", "en_pause", "terminee", "annulee"},
+			MaxSelect: 1,
+		})
+		campaignRuns.Fields.Add(&core.NumberField{Name: "processed", Min: floatPtr(0)})
+		campaignRuns.Fields.Add(&core.NumberField{Name: "cancelled", Min: floatPtr(0)})
+		campaignRuns.Fields.Add(&core.DateField{Name: "finished_at"})
+		// contact_ids: recipients snapshot taken when the run starts
+		campaignRuns.Fields.Add(&core.JSONField{Name: "contact_ids", MaxSize: 500000})
+		if err := app.Save(campaignRuns); err != nil {
+			return err
+		}
+
+		// Runs created before this migration all went to completion and keep
+		// the contacts they actually mailed
+		if _, err := app.DB().NewQuery("UPDATE campaign_runs SET status = 'terminee', processed = total WHERE status = '' OR status IS NULL").Execute(); err != nil {
+			return err
+		}
+		_, err = app.DB().NewQuery(`
+			UPDATE campaign_runs SET contact_ids = (
+				SELECT json_group_array(DISTINCT el.recipient_contact) FROM email_logs el
+				WHERE el.run_id = campaign_runs.id AND COALESCE(el.recipient_contact, '') != ''
+			)
+		`).Execute()
+		return err
+	}, func(app core.App) error {
+		campaignRuns, err := app.FindCollectionByNameOrId("campaign_runs")
+		if err == nil {
+			for _, name := range []string{"status", "processed", "cancelled", "finished_at", "contact_ids"} {
+				campaignRuns.Fields.RemoveByName(name)
+			}
+			if err := app.Save(campaignRuns); err != nil {
+				return err
+			}
+		}
+		campaigns, err := app.FindCollectionByNameOrId("campaigns")
+		if err != nil {
+			return nil
+		}
+		if f, ok := campaigns.Fields.GetByName("status").(*core.SelectField); ok {
+			f.Values = []string{"brouillon", "programmee", "en_cours", "envoye", "termine"}
+		}
+		return app.Save(campaigns)
+	}, "0004_campaign_controls")
+}
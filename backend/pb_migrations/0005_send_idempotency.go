@@ -0,0 +1,63 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// ==========================================
+		// EMAIL_LOGS — "doublon" skip reason (same email twice in one send)
+		// ==========================================
+		emailLogs, err := app.FindCollectionByNameOrId("email_logs")
+		if err != nil {
+			return err
+		}
+		if f, ok := emailLogs.Fields.GetByName("skip_reason").(*core.SelectField); ok {
+			f.Values = []string{"frequence", "engagement", "doublon"}
+		}
+		if err := app.Save(emailLogs); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// IDEMPOTENCY_KEYS (write by hooks only)
+		// ==========================================
+		// One row per (Idempotency-Key, user, route); the stored response is
+		// replayed when the same request is retried.
+		idempotencyKeys := findOrCreateBase(app, "idempotency_keys")
+		idempotencyKeys.Fields.Add(&core.TextField{Name: "key", Required: true, Max: 255})
+		idempotencyKeys.Fields.Add(&core.TextField{Name: "user_id", Required: true, Max: 50})
+		idempotencyKeys.Fields.Add(&core.TextField{Name: "route", Required: true, Max: 300})
+		idempotencyKeys.Fields.Add(&core.TextField{Name: "request_hash", Max: 64})
+		idempotencyKeys.Fields.Add(&core.SelectField{
+			Name:      "status",
+			Required:  true,
+			Values:    []string{"en_cours", "termine"},
+			MaxSelect: 1,
+		})
+		idempotencyKeys.Fields.Add(&core.NumberField{Name: "response_status"})
+		idempotencyKeys.Fields.Add(&core.JSONField{Name: "response_body", MaxSize: 1000000})
+		idempotencyKeys.Fields.Add(&core.AutodateField{Name: "created", OnCreate: true})
+		idempotencyKeys.Fields.Add(&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true})
+		idempotencyKeys.AddIndex("idx_idempotency_keys_unique", true, "key, user_id, route", "")
+		// List/View/Create/Update/Delete = nil → hook-only (API disabled)
+
+		return app.Save(idempotencyKeys)
+	}, func(app core.App) error {
+		if col, err := app.FindCollectionByNameOrId("idempotency_keys"); err == nil {
+			if err := app.Delete(col); err != nil {
+				return err
+			}
+		}
+		emailLogs, err := app.FindCollectionByNameOrId("email_logs")
+		if err != nil {
+			return nil
+		}
+		if f, ok := emailLogs.Fields.GetByName("skip_reason").(*core.SelectField); ok {
+			f.Values = []string{"frequence", "engagement"}
+		}
+		return app.Save(emailLogs)
+	}, "0005_send_idempotency")
+}
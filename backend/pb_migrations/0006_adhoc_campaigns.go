@@ -0,0 +1,191 @@
+package pb_migrations
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// orphanCampaignRow aggregates the email_logs of a legacy ad-hoc send whose
+// random campaign_id never existed in the campaigns collection.
+type orphanCampaignRow struct {
+	CampaignID string `db:"campaign_id"`
+	TemplateID string `db:"template_id"`
+	SentBy     string `db:"sent_by"`
+	FirstSent  string `db:"first_sent"`
+	Total      int    `db:"total"`
+	Sent       int    `db:"sent"`
+	Failed     int    `db:"failed"`
+	Skipped    int    `db:"skipped"`
+}
+
+func init() {
+	m.Register(func(app core.App) error {
+		campaigns, err := app.FindCollectionByNameOrId("campaigns")
+		if err != nil {
+			return err
+		}
+		// ad_hoc: created by /api/crm/send-campaign rather than by a user in the UI
+		campaigns.Fields.Add(&core.BoolField{Name: "ad_hoc"})
+		if err := app.Save(campaigns); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// CAMPAIGN_RUNS — template mailed by the run
+		// ==========================================
+		templates, err := app.FindCollectionByNameOrId("email_templates")
+		if err != nil {
+			return err
+		}
+		runsCol, err := app.FindCollectionByNameOrId("campaign_runs")
+		if err != nil {
+			return err
+		}
+		runsCol.Fields.Add(&core.RelationField{Name: "template", CollectionId: templates.Id, MaxSelect: 1})
+		if err := app.Save(runsCol); err != nil {
+			return err
+		}
+
+		// Template of the run's own email logs, else the campaign's current one
+		if _, err := app.DB().NewQuery(`
+			UPDATE campaign_runs SET template = COALESCE(
+				(SELECT MAX(el.template) FROM email_logs el
+				 JOIN email_templates t ON t.id = el.template
+				 WHERE el.run_id = campaign_runs.id),
+				(SELECT c.template FROM campaigns c
+				 JOIN email_templates t ON t.id = c.template
+				 WHERE c.id = campaign_runs.campaign),
+				''
+			)
+		`).Execute(); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// Back-fill orphaned email_logs.campaign_id into synthetic campaigns
+		// ==========================================
+		// Senders and templates deleted since the send are dropped; a
+		// campaign without a remaining sender is credited to the oldest admin.
+		var fallbackCreator string
+		if err := app.DB().NewQuery(`
+			SELECT id FROM users ORDER BY role = 'admin' DESC, created LIMIT 1
+		`).Row(&fallbackCreator); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		var orphans []orphanCampaignRow
+		err = app.DB().NewQuery(`
+			SELECT
+				el.campaign_id AS campaign_id,
+				COALESCE(MAX(t.id), '') AS template_id,
+				COALESCE(MIN(u.id), '') AS sent_by,
+				COALESCE(MIN(NULLIF(el.sent_at, '')), MIN(el.created)) AS first_sent,
+				COUNT(*) AS total,
+				SUM(CASE WHEN el.status IN ('envoye','ouvert','clique') THEN 1 ELSE 0 END) AS sent,
+				SUM(CASE WHEN el.status = 'echoue' THEN 1 ELSE 0 END) AS failed,
+				SUM(CASE WHEN el.status = 'ignore' THEN 1 ELSE 0 END) AS skipped
+			FROM email_logs el
+			LEFT JOIN campaigns c ON c.id = el.campaign_id
+			LEFT JOIN users u ON u.id = el.sent_by
+			LEFT JOIN email_templates t ON t.id = el.template
+			WHERE el.campaign_id != '' AND el.campaign_id IS NOT NULL AND c.id IS NULL
+			GROUP BY el.campaign_id
+		`).All(&orphans)
+		if err != nil {
+			return err
+		}
+
+		for _, o := range orphans {
+			createdBy := o.SentBy
+			if createdBy == "" {
+				createdBy = fallbackCreator
+			}
+			if createdBy == "" {
+				continue // no user left to own the campaign
+			}
+
+			var contactIDs []string
+			if err := app.DB().NewQuery(`
+				SELECT DISTINCT recipient_contact FROM email_logs
+				WHERE campaign_id = {:id} AND recipient_contact != ''
+			`).Bind(dbx.Params{"id": o.CampaignID}).Column(&contactIDs); err != nil {
+				return err
+			}
+			if contactIDs == nil {
+				contactIDs = []string{}
+			}
+
+			date := o.FirstSent
+			if len(date) >= 10 {
+				date = date[:10]
+			}
+
+			campaign := core.NewRecord(campaigns)
+			campaign.Set("name", fmt.Sprintf("Envoi ponctuel — %s (%s)", date, o.CampaignID))
+			campaign.Set("type", "email")
+			if o.TemplateID != "" {
+				campaign.Set("template", o.TemplateID)
+			}
+			campaign.Set("contact_ids", contactIDs)
+			campaign.Set("status", "envoye")
+			campaign.Set("total", o.Total)
+			campaign.Set("sent", o.Sent)
+			campaign.Set("failed", o.Failed)
+			campaign.Set("skipped", o.Skipped)
+			campaign.Set("campaign_key", o.CampaignID)
+			campaign.Set("ad_hoc", true)
+			campaign.Set("created_by", createdBy)
+			if err := app.Save(campaign); err != nil {
+				return fmt.Errorf("back-fill campaign %s: %w", o.CampaignID, err)
+			}
+
+			run := core.NewRecord(runsCol)
+			run.Set("campaign", campaign.Id)
+			run.Set("run_number", 1)
+			if o.TemplateID != "" {
+				run.Set("template", o.TemplateID)
+			}
+			run.Set("contact_ids", contactIDs)
+			run.Set("total", o.Total)
+			run.Set("sent", o.Sent)
+			run.Set("failed", o.Failed)
+			run.Set("skipped", o.Skipped)
+			run.Set("processed", o.Total)
+			run.Set("status", "terminee")
+			run.Set("sent_by", o.SentBy)
+			run.Set("sent_at", o.FirstSent)
+			if err := app.Save(run); err != nil {
+				return fmt.Errorf("back-fill run for %s: %w", o.CampaignID, err)
+			}
+
+			if _, err := app.DB().NewQuery(`
+				UPDATE email_logs SET campaign_id = {:new}, run_id = {:run}
+				WHERE campaign_id = {:old}
+			`).Bind(dbx.Params{"new": campaign.Id, "run": run.Id, "old": o.CampaignID}).Execute(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func(app core.App) error {
+		// Synthetic campaigns are kept (email_logs now point to them); only the
+		// flag and the run template go.
+		if runsCol, err := app.FindCollectionByNameOrId("campaign_runs"); err == nil {
+			runsCol.Fields.RemoveByName("template")
+			if err := app.Save(runsCol); err != nil {
+				return err
+			}
+		}
+		campaigns, err := app.FindCollectionByNameOrId("campaigns")
+		if err != nil {
+			return nil
+		}
+		campaigns.Fields.RemoveByName("ad_hoc")
+		return app.Save(campaigns)
+	}, "0006_adhoc_campaigns")
+}
@@ -0,0 +1,233 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// stageDef describes a pipeline stage created by this migration.
+type stageDef struct {
+	key         string
+	name        string
+	probability float64
+	won         bool
+	lost        bool
+	rottingDays float64
+}
+
+// Former hard-coded lead statuses, with the probabilities used by the forecast.
+var newBusinessStages = []stageDef{
+	{"nouveau", "Nouveau", 10, false, false, 14},
+	{"contacte", "Contacté", 20, false, false, 14},
+	{"qualifie", "Qualifié", 40, false, false, 21},
+	{"proposition", "Proposition", 60, false, false, 21},
+	{"negociation", "Négociation", 80, false, false, 30},
+	{"gagne", "Gagné", 100, true, false, 0},
+	{"perdu", "Perdu", 0, false, true, 0},
+}
+
+var renewalStages = []stageDef{
+	{"a_renouveler", "À renouveler", 50, false, false, 30},
+	{"en_discussion", "En discussion", 70, false, false, 21},
+	{"renouvele", "Renouvelé", 100, true, false, 0},
+	{"non_renouvele", "Non renouvelé", 0, false, true, 0},
+}
+
+func init() {
+	m.Register(func(app core.App) error {
+		auth := strPtr("@request.auth.id != ''")
+		adminOnly := strPtr("@request.auth.role = 'admin'")
+
+		// ==========================================
+		// PIPELINES
+		// ==========================================
+		pipelines := findOrCreateBase(app, "pipelines")
+		pipelines.Fields.Add(&core.TextField{Name: "name", Required: true, Max: 200})
+		pipelines.Fields.Add(&core.TextField{Name: "description", Max: 1000})
+		pipelines.Fields.Add(&core.BoolField{Name: "is_default"})
+		pipelines.Fields.Add(&core.BoolField{Name: "active"})
+		pipelines.Fields.Add(&core.AutodateField{Name: "created", OnCreate: true})
+		pipelines.Fields.Add(&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true})
+
+		pipelines.ListRule = auth
+		pipelines.ViewRule = auth
+		pipelines.CreateRule = adminOnly
+		pipelines.UpdateRule = adminOnly
+		pipelines.DeleteRule = adminOnly
+
+		if err := app.Save(pipelines); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// PIPELINE_STAGES
+		// ==========================================
+		stages := findOrCreateBase(app, "pipeline_stages")
+		stages.Fields.Add(&core.RelationField{
+			Name:          "pipeline",
+			CollectionId:  pipelines.Id,
+			MaxSelect:     1,
+			Required:      true,
+			CascadeDelete: true,
+		})
+		// key: stable identifier copied into leads.status (e.g. "proposition")
+		stages.Fields.Add(&core.TextField{Name: "key", Required: true, Max: 50, Pattern: `^[a-z0-9_]+$`})
+		stages.Fields.Add(&core.TextField{Name: "name", Required: true, Max: 200})
+		stages.Fields.Add(&core.NumberField{Name: "order", Min: floatPtr(0)})
+		stages.Fields.Add(&core.NumberField{Name: "probability", Min: floatPtr(0), Max: floatPtr(100)})
+		stages.Fields.Add(&core.BoolField{Name: "is_won"})
+		stages.Fields.Add(&core.BoolField{Name: "is_lost"})
+		// rotting_days: days without activity after which an open lead is considered stale (0 = never)
+		stages.Fields.Add(&core.NumberField{Name: "rotting_days", Min: floatPtr(0)})
+		stages.Fields.Add(&core.AutodateField{Name: "created", OnCreate: true})
+		stages.Fields.Add(&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true})
+		stages.AddIndex("idx_pipeline_stages_key", true, "pipeline, key", "")
+
+		stages.ListRule = auth
+		stages.ViewRule = auth
+		stages.CreateRule = adminOnly
+		stages.UpdateRule = adminOnly
+		stages.DeleteRule = adminOnly
+
+		if err := app.Save(stages); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// Default pipelines
+		// ==========================================
+		createPipeline := func(name, description string, isDefault bool, defs []stageDef) (*core.Record, error) {
+			p := core.NewRecord(pipelines)
+			p.Set("name", name)
+			p.Set("description", description)
+			p.Set("is_default", isDefault)
+			p.Set("active", true)
+			if err := app.Save(p); err != nil {
+				return nil, err
+			}
+			for i, d := range defs {
+				s := core.NewRecord(stages)
+				s.Set("pipeline", p.Id)
+				s.Set("key", d.key)
+				s.Set("name", d.name)
+				s.Set("order", i+1)
+				s.Set("probability", d.probability)
+				s.Set("is_won", d.won)
+				s.Set("is_lost", d.lost)
+				s.Set("rotting_days", d.rottingDays)
+				if err := app.Save(s); err != nil {
+					return nil, err
+				}
+			}
+			return p, nil
+		}
+
+		newBusiness, err := createPipeline("Nouvelles affaires", "Prospection et signature de nouveaux clients", true, newBusinessStages)
+		if err != nil {
+			return err
+		}
+		if _, err := createPipeline("Renouvellements", "Renouvellement des contrats existants", false, renewalStages); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// LEADS — pipeline + stage, status becomes the free stage key
+		// ==========================================
+		leads, err := app.FindCollectionByNameOrId("leads")
+		if err != nil {
+			return err
+		}
+
+		// A field type cannot change in place: copy status aside, recreate it as text, copy back.
+		leads.Fields.Add(&core.TextField{Name: "status_tmp", Max: 50})
+		if err := app.Save(leads); err != nil {
+			return err
+		}
+		if _, err := app.DB().NewQuery("UPDATE leads SET status_tmp = status").Execute(); err != nil {
+			return err
+		}
+		leads.Fields.RemoveByName("status")
+		if err := app.Save(leads); err != nil {
+			return err
+		}
+		leads.Fields.Add(&core.TextField{Name: "status", Required: true, Max: 50})
+		leads.Fields.Add(&core.RelationField{Name: "pipeline", CollectionId: pipelines.Id, MaxSelect: 1})
+		leads.Fields.Add(&core.RelationField{Name: "stage", CollectionId: stages.Id, MaxSelect: 1})
+		if err := app.Save(leads); err != nil {
+			return err
+		}
+		if _, err := app.DB().NewQuery("UPDATE leads SET status = status_tmp").Execute(); err != nil {
+			return err
+		}
+		leads.Fields.RemoveByName("status_tmp")
+		if err := app.Save(leads); err != nil {
+			return err
+		}
+
+		// Map every existing lead onto the default pipeline's matching stage
+		_, err = app.DB().NewQuery(`
+			UPDATE leads SET
+				pipeline = {:pipeline},
+				stage = COALESCE(
+					(SELECT ps.id FROM pipeline_stages ps WHERE ps.pipeline = {:pipeline} AND ps.key = leads.status),
+					(SELECT ps.id FROM pipeline_stages ps WHERE ps.pipeline = {:pipeline} AND ps.key = 'nouveau')
+				)
+		`).Bind(dbx.Params{"pipeline": newBusiness.Id}).Execute()
+		return err
+	}, func(app core.App) error {
+		leads, err := app.FindCollectionByNameOrId("leads")
+		if err == nil {
+			// Keys of other pipelines fall back on their stage's outcome
+			if _, err := app.DB().NewQuery(`
+				UPDATE leads SET status = COALESCE(
+					(SELECT CASE WHEN ps.is_won THEN 'gagne' WHEN ps.is_lost THEN 'perdu' END
+					 FROM pipeline_stages ps WHERE ps.id = leads.stage),
+					'nouveau'
+				)
+				WHERE status NOT IN ('nouveau', 'contacte', 'qualifie', 'proposition', 'negociation', 'gagne', 'perdu')
+			`).Execute(); err != nil {
+				return err
+			}
+
+			// status becomes the original select again, through a copy as on the way up
+			leads.Fields.Add(&core.TextField{Name: "status_tmp", Max: 50})
+			if err := app.Save(leads); err != nil {
+				return err
+			}
+			if _, err := app.DB().NewQuery("UPDATE leads SET status_tmp = status").Execute(); err != nil {
+				return err
+			}
+			leads.Fields.RemoveByName("status")
+			leads.Fields.RemoveByName("pipeline")
+			leads.Fields.RemoveByName("stage")
+			if err := app.Save(leads); err != nil {
+				return err
+			}
+			leads.Fields.Add(&core.SelectField{
+				Name:      "status",
+				Required:  true,
+				Values:    []string{"nouveau", "contacte", "qualifie", "proposition", "negociation", "gagne", "perdu"},
+				MaxSelect: 1,
+			})
+			if err := app.Save(leads); err != nil {
+				return err
+			}
+			if _, err := app.DB().NewQuery("UPDATE leads SET status = status_tmp").Execute(); err != nil {
+				return err
+			}
+			leads.Fields.RemoveByName("status_tmp")
+			if err := app.Save(leads); err != nil {
+				return err
+			}
+		}
+		for _, name := range []string{"pipeline_stages", "pipelines"} {
+			if col, err := app.FindCollectionByNameOrId(name); err == nil {
+				if err := app.Delete(col); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}, "0007_pipelines")
+}
@@ -0,0 +1,130 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// leadRequirableFields lists the lead fields a stage may require before a lead enters it.
+var leadRequirableFields = []string{"value", "contact", "company", "owner", "expected_close", "source", "notes"}
+
+// stageRuleDef describes the default transition rules of a stage, by stage key.
+type stageRuleDef struct {
+	allowedFrom    []string
+	requiredFields []string
+	allowedRoles   []string
+}
+
+var newBusinessStageRules = map[string]stageRuleDef{
+	"qualifie":    {requiredFields: []string{"contact"}},
+	"proposition": {requiredFields: []string{"value", "contact"}},
+	"negociation": {requiredFields: []string{"value", "contact", "expected_close"}},
+	"gagne": {
+		allowedFrom:    []string{"proposition", "negociation"},
+		requiredFields: []string{"value", "contact", "expected_close"},
+		allowedRoles:   []string{"admin", "commercial"},
+	},
+}
+
+var renewalStageRules = map[string]stageRuleDef{
+	"renouvele": {
+		allowedFrom:    []string{"en_discussion"},
+		requiredFields: []string{"value", "contact"},
+		allowedRoles:   []string{"admin", "commercial"},
+	},
+}
+
+func init() {
+	m.Register(func(app core.App) error {
+		stages, err := app.FindCollectionByNameOrId("pipeline_stages")
+		if err != nil {
+			return err
+		}
+		// allowed_from: stages a lead may come from (empty = any stage)
+		stages.Fields.Add(&core.RelationField{Name: "allowed_from", CollectionId: stages.Id, MaxSelect: 50})
+		// required_fields: lead fields that must be filled to enter this stage
+		stages.Fields.Add(&core.SelectField{
+			Name:      "required_fields",
+			Values:    leadRequirableFields,
+			MaxSelect: len(leadRequirableFields),
+		})
+		// allowed_roles: roles allowed to move a lead into this stage (empty = any role)
+		stages.Fields.Add(&core.SelectField{
+			Name:      "allowed_roles",
+			Values:    []string{"admin", "commercial", "standard"},
+			MaxSelect: 3,
+		})
+		if err := app.Save(stages); err != nil {
+			return err
+		}
+
+		leads, err := app.FindCollectionByNameOrId("leads")
+		if err != nil {
+			return err
+		}
+		// loss_reason: free-text details of a loss (the reason itself is close_reason,
+		// added by 0014 and required in a lost stage by validateCloseReason)
+		leads.Fields.Add(&core.TextField{Name: "loss_reason", Max: 1000})
+		if err := app.Save(leads); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// Default rules for the seeded pipelines
+		// ==========================================
+		applyRules := func(pipelineName string, rules map[string]stageRuleDef) error {
+			pipeline, err := app.FindFirstRecordByFilter("pipelines", "name = {:name}", dbx.Params{"name": pipelineName})
+			if err != nil {
+				return nil // renamed or removed — nothing to configure
+			}
+			records, err := app.FindRecordsByFilter("pipeline_stages", "pipeline = {:pipeline}", "order", 0, 0,
+				dbx.Params{"pipeline": pipeline.Id})
+			if err != nil {
+				return err
+			}
+			idsByKey := make(map[string]string, len(records))
+			for _, r := range records {
+				idsByKey[r.GetString("key")] = r.Id
+			}
+			for _, r := range records {
+				rule, ok := rules[r.GetString("key")]
+				if !ok {
+					continue
+				}
+				from := make([]string, 0, len(rule.allowedFrom))
+				for _, key := range rule.allowedFrom {
+					if id, ok := idsByKey[key]; ok {
+						from = append(from, id)
+					}
+				}
+				r.Set("allowed_from", from)
+				r.Set("required_fields", rule.requiredFields)
+				r.Set("allowed_roles", rule.allowedRoles)
+				if err := app.Save(r); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		if err := applyRules("Nouvelles affaires", newBusinessStageRules); err != nil {
+			return err
+		}
+		return applyRules("Renouvellements", renewalStageRules)
+	}, func(app core.App) error {
+		if stages, err := app.FindCollectionByNameOrId("pipeline_stages"); err == nil {
+			stages.Fields.RemoveByName("allowed_from")
+			stages.Fields.RemoveByName("required_fields")
+			stages.Fields.RemoveByName("allowed_roles")
+			if err := app.Save(stages); err != nil {
+				return err
+			}
+		}
+		if leads, err := app.FindCollectionByNameOrId("leads"); err == nil {
+			leads.Fields.RemoveByName("loss_reason")
+			return app.Save(leads)
+		}
+		return nil
+	}, "0008_stage_rules")
+}
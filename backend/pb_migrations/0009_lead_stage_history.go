@@ -0,0 +1,169 @@
+package pb_migrations
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// statusChangePattern matches the description written by the leads update hook:
+// Opportunité "<title>" : statut changé de "<from>" → "<to>"
+var statusChangePattern = regexp.MustCompile(`statut changé de "([^"]*)" → "([^"]*)"$`)
+
+type historyLeadRow struct {
+	ID       string  `db:"id"`
+	Pipeline string  `db:"pipeline"`
+	Status   string  `db:"status"`
+	Owner    string  `db:"owner"`
+	Value    float64 `db:"value"`
+	Created  string  `db:"created"`
+}
+
+type historyActivityRow struct {
+	Description string `db:"description"`
+	User        string `db:"user"`
+	Created     string `db:"created"`
+}
+
+func init() {
+	m.Register(func(app core.App) error {
+		auth := strPtr("@request.auth.id != ''")
+
+		leads, err := app.FindCollectionByNameOrId("leads")
+		if err != nil {
+			return err
+		}
+		pipelines, err := app.FindCollectionByNameOrId("pipelines")
+		if err != nil {
+			return err
+		}
+		stages, err := app.FindCollectionByNameOrId("pipeline_stages")
+		if err != nil {
+			return err
+		}
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+
+		// ==========================================
+		// LEAD_STAGE_HISTORY
+		// ==========================================
+		history := findOrCreateBase(app, "lead_stage_history")
+		history.Fields.Add(&core.RelationField{
+			Name:          "lead",
+			CollectionId:  leads.Id,
+			MaxSelect:     1,
+			Required:      true,
+			CascadeDelete: true,
+		})
+		history.Fields.Add(&core.RelationField{Name: "pipeline", CollectionId: pipelines.Id, MaxSelect: 1})
+		history.Fields.Add(&core.RelationField{Name: "from_stage", CollectionId: stages.Id, MaxSelect: 1})
+		history.Fields.Add(&core.RelationField{Name: "to_stage", CollectionId: stages.Id, MaxSelect: 1})
+		// from_status / to_status: stage keys, kept when a stage is later deleted
+		history.Fields.Add(&core.TextField{Name: "from_status", Max: 50})
+		history.Fields.Add(&core.TextField{Name: "to_status", Max: 50})
+		history.Fields.Add(&core.RelationField{Name: "changed_by", CollectionId: users.Id, MaxSelect: 1})
+		history.Fields.Add(&core.DateField{Name: "changed_at", Required: true})
+		// value: lead value at the time of the change
+		history.Fields.Add(&core.NumberField{Name: "value"})
+		history.Fields.Add(&core.AutodateField{Name: "created", OnCreate: true})
+		history.AddIndex("idx_lead_stage_history_lead", false, "lead, changed_at", "")
+
+		history.ListRule = auth
+		history.ViewRule = auth
+		// Create/Update/Delete = nil → hook-only (API disabled)
+
+		if err := app.Save(history); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// Back-fill from leads + statut_change activities
+		// ==========================================
+		var leadRows []historyLeadRow
+		if err := app.DB().NewQuery(`
+			SELECT id, COALESCE(pipeline, '') AS pipeline, status, COALESCE(owner, '') AS owner,
+			       COALESCE(value, 0) AS value, created
+			FROM leads
+		`).All(&leadRows); err != nil {
+			return err
+		}
+
+		stageIDs := map[string]map[string]string{} // pipeline → key → stage id
+		stageID := func(pipelineID, key string) string {
+			if _, ok := stageIDs[pipelineID]; !ok {
+				byKey := map[string]string{}
+				records, _ := app.FindAllRecords("pipeline_stages", dbx.HashExp{"pipeline": pipelineID})
+				for _, r := range records {
+					byKey[r.GetString("key")] = r.Id
+				}
+				stageIDs[pipelineID] = byKey
+			}
+			return stageIDs[pipelineID][key]
+		}
+
+		addEntry := func(lead historyLeadRow, from, to, changedBy, changedAt string) error {
+			rec := core.NewRecord(history)
+			rec.Set("lead", lead.ID)
+			rec.Set("pipeline", lead.Pipeline)
+			rec.Set("from_stage", stageID(lead.Pipeline, from))
+			rec.Set("to_stage", stageID(lead.Pipeline, to))
+			rec.Set("from_status", from)
+			rec.Set("to_status", to)
+			rec.Set("changed_by", changedBy)
+			rec.Set("changed_at", changedAt)
+			rec.Set("value", lead.Value)
+			if err := app.Save(rec); err != nil {
+				return fmt.Errorf("back-fill history for lead %s: %w", lead.ID, err)
+			}
+			return nil
+		}
+
+		for _, lead := range leadRows {
+			var activities []historyActivityRow
+			if err := app.DB().NewQuery(`
+				SELECT description, COALESCE(user, '') AS user, created
+				FROM activities
+				WHERE lead = {:lead} AND type = 'statut_change'
+				ORDER BY created ASC
+			`).Bind(dbx.Params{"lead": lead.ID}).All(&activities); err != nil {
+				return err
+			}
+
+			type change struct{ from, to, by, at string }
+			changes := make([]change, 0, len(activities))
+			for _, a := range activities {
+				match := statusChangePattern.FindStringSubmatch(a.Description)
+				if match == nil {
+					continue
+				}
+				changes = append(changes, change{match[1], match[2], a.User, a.Created})
+			}
+
+			// Creation entry: the lead entered the pipeline in the status it left first
+			initial := lead.Status
+			if len(changes) > 0 {
+				initial = changes[0].from
+			}
+			if err := addEntry(lead, "", initial, lead.Owner, lead.Created); err != nil {
+				return err
+			}
+			for _, c := range changes {
+				if err := addEntry(lead, c.from, c.to, c.by, c.at); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}, func(app core.App) error {
+		if col, err := app.FindCollectionByNameOrId("lead_stage_history"); err == nil {
+			return app.Delete(col)
+		}
+		return nil
+	}, "0009_lead_stage_history")
+}
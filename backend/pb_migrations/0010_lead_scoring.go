@@ -0,0 +1,137 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+type scoringRuleDef struct {
+	name      string
+	criterion string
+	operator  string
+	value     string
+	points    float64
+}
+
+var defaultScoringRules = []scoringRuleDef{
+	{"Source : recommandation", "source", "equals", "recommandation", 20},
+	{"Source : salon", "source", "equals", "salon", 10},
+	{"Source : site web", "source", "equals", "site_web", 5},
+	{"Entreprise : ETI", "company_size", "equals", "eti", 10},
+	{"Entreprise : grande entreprise", "company_size", "equals", "grande_entreprise", 15},
+	{"Contact : directeur", "contact_title", "contains", "directeur", 15},
+	{"Contact : responsable", "contact_title", "contains", "responsable", 10},
+	{"Emails : 3 ouvertures ou plus (90 j)", "email_opens", "gte", "3", 10},
+	{"Emails : au moins un clic (90 j)", "email_clicks", "gte", "1", 15},
+	{"Activité : 3 interactions ou plus (30 j)", "recent_activities", "gte", "3", 10},
+	{"Montant ≥ 50 000", "deal_value", "gte", "50000", 10},
+	{"Montant ≥ 100 000", "deal_value", "gte", "100000", 5},
+}
+
+func init() {
+	m.Register(func(app core.App) error {
+		auth := strPtr("@request.auth.id != ''")
+		adminOnly := strPtr("@request.auth.role = 'admin'")
+
+		// ==========================================
+		// LEAD_SCORING_RULES
+		// ==========================================
+		rules := findOrCreateBase(app, "lead_scoring_rules")
+		rules.Fields.Add(&core.TextField{Name: "name", Required: true, Max: 200})
+		rules.Fields.Add(&core.BoolField{Name: "active"})
+		rules.Fields.Add(&core.SelectField{
+			Name:      "criterion",
+			Required:  true,
+			Values:    []string{"source", "company_size", "company_industry", "contact_title", "email_opens", "email_clicks", "recent_activities", "deal_value"},
+			MaxSelect: 1,
+		})
+		// operator: equals / contains for text criteria, gte / lte for numeric ones
+		rules.Fields.Add(&core.SelectField{
+			Name:      "operator",
+			Required:  true,
+			Values:    []string{"equals", "contains", "gte", "lte"},
+			MaxSelect: 1,
+		})
+		rules.Fields.Add(&core.TextField{Name: "value", Required: true, Max: 200})
+		// points: may be negative to penalise a criterion
+		rules.Fields.Add(&core.NumberField{Name: "points", Required: true})
+		rules.Fields.Add(&core.AutodateField{Name: "created", OnCreate: true})
+		rules.Fields.Add(&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true})
+
+		rules.ListRule = auth
+		rules.ViewRule = auth
+		rules.CreateRule = adminOnly
+		rules.UpdateRule = adminOnly
+		rules.DeleteRule = adminOnly
+
+		if err := app.Save(rules); err != nil {
+			return err
+		}
+
+		for _, d := range defaultScoringRules {
+			r := core.NewRecord(rules)
+			r.Set("name", d.name)
+			r.Set("active", true)
+			r.Set("criterion", d.criterion)
+			r.Set("operator", d.operator)
+			r.Set("value", d.value)
+			r.Set("points", d.points)
+			if err := app.Save(r); err != nil {
+				return err
+			}
+		}
+
+		// ==========================================
+		// LEADS — score (0–100)
+		// ==========================================
+		leads, err := app.FindCollectionByNameOrId("leads")
+		if err != nil {
+			return err
+		}
+		leads.Fields.Add(&core.NumberField{Name: "score", Min: floatPtr(0), Max: floatPtr(100)})
+		leads.Fields.Add(&core.DateField{Name: "score_updated"})
+		leads.AddIndex("idx_leads_score", false, "score", "")
+		if err := app.Save(leads); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// LEAD_SCORE_HISTORY
+		// ==========================================
+		history := findOrCreateBase(app, "lead_score_history")
+		history.Fields.Add(&core.RelationField{
+			Name:          "lead",
+			CollectionId:  leads.Id,
+			MaxSelect:     1,
+			Required:      true,
+			CascadeDelete: true,
+		})
+		history.Fields.Add(&core.NumberField{Name: "score"})
+		history.Fields.Add(&core.NumberField{Name: "previous_score"})
+		// matched_rules: [{rule, name, points}] that produced the score
+		history.Fields.Add(&core.JSONField{Name: "matched_rules"})
+		history.Fields.Add(&core.AutodateField{Name: "created", OnCreate: true})
+		history.AddIndex("idx_lead_score_history_lead", false, "lead, created", "")
+
+		history.ListRule = auth
+		history.ViewRule = auth
+		// Create/Update/Delete = nil → hook-only (API disabled)
+
+		return app.Save(history)
+	}, func(app core.App) error {
+		for _, name := range []string{"lead_score_history", "lead_scoring_rules"} {
+			if col, err := app.FindCollectionByNameOrId(name); err == nil {
+				if err := app.Delete(col); err != nil {
+					return err
+				}
+			}
+		}
+		if leads, err := app.FindCollectionByNameOrId("leads"); err == nil {
+			leads.RemoveIndex("idx_leads_score")
+			leads.Fields.RemoveByName("score")
+			leads.Fields.RemoveByName("score_updated")
+			return app.Save(leads)
+		}
+		return nil
+	}, "0010_lead_scoring")
+}
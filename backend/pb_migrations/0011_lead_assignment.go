@@ -0,0 +1,92 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		adminOnly := strPtr("@request.auth.role = 'admin'")
+		auth := strPtr("@request.auth.id != ''")
+
+		// ==========================================
+		// USERS — availability for automatic lead assignment
+		// ==========================================
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+		users.Fields.Add(&core.BoolField{Name: "out_of_office"})
+		// out_of_office_until: end of the absence (empty = until the flag is cleared)
+		users.Fields.Add(&core.DateField{Name: "out_of_office_until"})
+		// lead_capacity: max open leads owned (0 = unlimited)
+		users.Fields.Add(&core.NumberField{Name: "lead_capacity", Min: floatPtr(0), OnlyInt: true})
+		// assignment_weight: share of leads in weighted mode (0 = default weight 1)
+		users.Fields.Add(&core.NumberField{Name: "assignment_weight", Min: floatPtr(0)})
+		users.Fields.Add(&core.DateField{Name: "last_assigned_at"})
+		if err := app.Save(users); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// ACTIVITIES — "attribution" type for assignment decisions
+		// ==========================================
+		activities, err := app.FindCollectionByNameOrId("activities")
+		if err != nil {
+			return err
+		}
+		if f, ok := activities.Fields.GetByName("type").(*core.SelectField); ok {
+			f.Values = []string{"creation", "modification", "email", "appel", "note", "statut_change", "attribution"}
+		}
+		if err := app.Save(activities); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// LEAD_ASSIGNMENT_RULES
+		// ==========================================
+		rules := findOrCreateBase(app, "lead_assignment_rules")
+		rules.Fields.Add(&core.TextField{Name: "name", Required: true, Max: 200})
+		rules.Fields.Add(&core.BoolField{Name: "active"})
+		// order: rules are evaluated by ascending order, the first match wins
+		rules.Fields.Add(&core.NumberField{Name: "order", Min: floatPtr(0)})
+		// criterion: region matches the company's city or country
+		rules.Fields.Add(&core.SelectField{
+			Name:      "criterion",
+			Required:  true,
+			Values:    []string{"source", "region", "company_size"},
+			MaxSelect: 1,
+		})
+		rules.Fields.Add(&core.TextField{Name: "value", Required: true, Max: 200})
+		rules.Fields.Add(&core.RelationField{
+			Name:         "assignees",
+			CollectionId: users.Id,
+			Required:     true,
+			MaxSelect:    50,
+		})
+		rules.Fields.Add(&core.AutodateField{Name: "created", OnCreate: true})
+		rules.Fields.Add(&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true})
+
+		rules.ListRule = auth
+		rules.ViewRule = auth
+		rules.CreateRule = adminOnly
+		rules.UpdateRule = adminOnly
+		rules.DeleteRule = adminOnly
+
+		return app.Save(rules)
+	}, func(app core.App) error {
+		if col, err := app.FindCollectionByNameOrId("lead_assignment_rules"); err == nil {
+			if err := app.Delete(col); err != nil {
+				return err
+			}
+		}
+		if users, err := app.FindCollectionByNameOrId("users"); err == nil {
+			for _, name := range []string{"out_of_office", "out_of_office_until", "lead_capacity", "assignment_weight", "last_assigned_at"} {
+				users.Fields.RemoveByName(name)
+			}
+			return app.Save(users)
+		}
+		return nil
+	}, "0011_lead_assignment")
+}
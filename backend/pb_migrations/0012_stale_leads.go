@@ -0,0 +1,26 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		leads, err := app.FindCollectionByNameOrId("leads")
+		if err != nil {
+			return err
+		}
+		// stale_since: set by the stale deal job when the lead exceeds its stage's
+		// rotting_days without activity, cleared once it moves again
+		leads.Fields.Add(&core.DateField{Name: "stale_since"})
+		return app.Save(leads)
+	}, func(app core.App) error {
+		leads, err := app.FindCollectionByNameOrId("leads")
+		if err != nil {
+			return nil
+		}
+		leads.Fields.RemoveByName("stale_since")
+		return app.Save(leads)
+	}, "0012_stale_leads")
+}
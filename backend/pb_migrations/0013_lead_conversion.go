@@ -0,0 +1,136 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// templateTask is one entry of task_templates.tasks.
+type templateTask struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Type        string `json:"type"`
+	Priority    string `json:"priority"`
+	DueInDays   int    `json:"due_in_days"`
+}
+
+var onboardingTasks = []templateTask{
+	{Title: "Appel de lancement", Type: "appel", Priority: "haute", DueInDays: 2},
+	{Title: "Réunion de kick-off", Type: "reunion", Priority: "haute", DueInDays: 7},
+	{Title: "Envoyer la facture d'acompte", Type: "email", Priority: "moyenne", DueInDays: 7},
+	{Title: "Point de satisfaction à 30 jours", Type: "suivi", Priority: "moyenne", DueInDays: 30},
+}
+
+func init() {
+	m.Register(func(app core.App) error {
+		auth := strPtr("@request.auth.id != ''")
+		adminOnly := strPtr("@request.auth.role = 'admin'")
+
+		// ==========================================
+		// TASK_TEMPLATES
+		// ==========================================
+		templates := findOrCreateBase(app, "task_templates")
+		templates.Fields.Add(&core.TextField{Name: "name", Required: true, Max: 200})
+		templates.Fields.Add(&core.TextField{Name: "description", Max: 1000})
+		// tasks: [{title, description, type, priority, due_in_days}]
+		templates.Fields.Add(&core.JSONField{Name: "tasks", MaxSize: 100000})
+		templates.Fields.Add(&core.AutodateField{Name: "created", OnCreate: true})
+		templates.Fields.Add(&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true})
+
+		templates.ListRule = auth
+		templates.ViewRule = auth
+		templates.CreateRule = adminOnly
+		templates.UpdateRule = adminOnly
+		templates.DeleteRule = adminOnly
+
+		if err := app.Save(templates); err != nil {
+			return err
+		}
+
+		onboarding := core.NewRecord(templates)
+		onboarding.Set("name", "Onboarding client")
+		onboarding.Set("description", "Tâches de démarrage après la signature d'une nouvelle affaire")
+		onboarding.Set("tasks", onboardingTasks)
+		if err := app.Save(onboarding); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// PIPELINES — conversion settings for won leads
+		// ==========================================
+		pipelines, err := app.FindCollectionByNameOrId("pipelines")
+		if err != nil {
+			return err
+		}
+		pipelines.Fields.Add(&core.BoolField{Name: "convert_on_won"})
+		pipelines.Fields.Add(&core.BoolField{Name: "create_invoice_on_won"})
+		pipelines.Fields.Add(&core.NumberField{Name: "invoice_tax_rate", Min: floatPtr(0), Max: floatPtr(100)})
+		pipelines.Fields.Add(&core.RelationField{Name: "onboarding_template", CollectionId: templates.Id, MaxSelect: 1})
+		if err := app.Save(pipelines); err != nil {
+			return err
+		}
+
+		if _, err := app.DB().NewQuery(`
+			UPDATE pipelines SET convert_on_won = TRUE, create_invoice_on_won = TRUE, invoice_tax_rate = 20
+		`).Execute(); err != nil {
+			return err
+		}
+		if _, err := app.DB().NewQuery(`
+			UPDATE pipelines SET onboarding_template = {:template} WHERE name = 'Nouvelles affaires'
+		`).Bind(dbx.Params{"template": onboarding.Id}).Execute(); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// ACTIVITIES — "conversion" type
+		// ==========================================
+		activities, err := app.FindCollectionByNameOrId("activities")
+		if err != nil {
+			return err
+		}
+		if f, ok := activities.Fields.GetByName("type").(*core.SelectField); ok {
+			f.Values = []string{"creation", "modification", "email", "appel", "note", "statut_change", "attribution", "conversion"}
+		}
+		if err := app.Save(activities); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// LEADS — converted_at (a lead is converted only once)
+		// ==========================================
+		leads, err := app.FindCollectionByNameOrId("leads")
+		if err != nil {
+			return err
+		}
+		leads.Fields.Add(&core.DateField{Name: "converted_at"})
+		if err := app.Save(leads); err != nil {
+			return err
+		}
+		// Leads already won were handled by hand: they are not converted again
+		_, err = app.DB().NewQuery(`
+			UPDATE leads SET converted_at = COALESCE(NULLIF(closed_at, ''), updated)
+			WHERE stage IN (SELECT id FROM pipeline_stages WHERE is_won = TRUE)
+		`).Execute()
+		return err
+	}, func(app core.App) error {
+		if leads, err := app.FindCollectionByNameOrId("leads"); err == nil {
+			leads.Fields.RemoveByName("converted_at")
+			if err := app.Save(leads); err != nil {
+				return err
+			}
+		}
+		if pipelines, err := app.FindCollectionByNameOrId("pipelines"); err == nil {
+			for _, name := range []string{"convert_on_won", "create_invoice_on_won", "invoice_tax_rate", "onboarding_template"} {
+				pipelines.Fields.RemoveByName(name)
+			}
+			if err := app.Save(pipelines); err != nil {
+				return err
+			}
+		}
+		if col, err := app.FindCollectionByNameOrId("task_templates"); err == nil {
+			return app.Delete(col)
+		}
+		return nil
+	}, "0013_lead_conversion")
+}
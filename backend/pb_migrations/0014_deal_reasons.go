@@ -0,0 +1,119 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+var defaultLossReasons = []string{
+	"Prix trop élevé",
+	"Concurrent retenu",
+	"Pas de budget",
+	"Projet abandonné",
+	"Mauvais timing",
+	"Sans réponse du prospect",
+	"Autre",
+}
+
+var defaultWinReasons = []string{
+	"Qualité de l'offre",
+	"Prix compétitif",
+	"Relation client",
+	"Expertise technique",
+	"Recommandation",
+	"Autre",
+}
+
+func init() {
+	m.Register(func(app core.App) error {
+		auth := strPtr("@request.auth.id != ''")
+		adminOnly := strPtr("@request.auth.role = 'admin'")
+
+		// ==========================================
+		// DEAL_REASONS
+		// ==========================================
+		reasons := findOrCreateBase(app, "deal_reasons")
+		reasons.Fields.Add(&core.TextField{Name: "name", Required: true, Max: 200})
+		// type: perte = reason for a lost lead, gain = reason for a won lead
+		reasons.Fields.Add(&core.SelectField{
+			Name:      "type",
+			Required:  true,
+			Values:    []string{"perte", "gain"},
+			MaxSelect: 1,
+		})
+		reasons.Fields.Add(&core.BoolField{Name: "active"})
+		reasons.Fields.Add(&core.NumberField{Name: "order", Min: floatPtr(0)})
+		reasons.Fields.Add(&core.AutodateField{Name: "created", OnCreate: true})
+		reasons.Fields.Add(&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true})
+		reasons.AddIndex("idx_deal_reasons_name", true, "type, name", "")
+
+		reasons.ListRule = auth
+		reasons.ViewRule = auth
+		reasons.CreateRule = adminOnly
+		reasons.UpdateRule = adminOnly
+		reasons.DeleteRule = adminOnly
+
+		if err := app.Save(reasons); err != nil {
+			return err
+		}
+
+		var otherLoss string
+		addReasons := func(reasonType string, names []string) error {
+			for i, name := range names {
+				r := core.NewRecord(reasons)
+				r.Set("name", name)
+				r.Set("type", reasonType)
+				r.Set("active", true)
+				r.Set("order", i+1)
+				if err := app.Save(r); err != nil {
+					return err
+				}
+				if reasonType == "perte" && name == "Autre" {
+					otherLoss = r.Id
+				}
+			}
+			return nil
+		}
+		if err := addReasons("perte", defaultLossReasons); err != nil {
+			return err
+		}
+		if err := addReasons("gain", defaultWinReasons); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// LEADS — structured win/loss reason + competitor
+		// ==========================================
+		leads, err := app.FindCollectionByNameOrId("leads")
+		if err != nil {
+			return err
+		}
+		leads.Fields.Add(&core.RelationField{Name: "close_reason", CollectionId: reasons.Id, MaxSelect: 1})
+		// competitor: who the deal was lost to (or won against)
+		leads.Fields.Add(&core.TextField{Name: "competitor", Max: 200})
+		if err := app.Save(leads); err != nil {
+			return err
+		}
+
+		// Free-text loss reasons become "Autre" + their text kept in loss_reason as details
+		_, err = app.DB().NewQuery(`
+			UPDATE leads SET close_reason = {:other}
+			WHERE loss_reason != '' AND loss_reason IS NOT NULL
+			  AND stage IN (SELECT id FROM pipeline_stages WHERE is_lost = TRUE)
+		`).Bind(dbx.Params{"other": otherLoss}).Execute()
+		return err
+	}, func(app core.App) error {
+		if leads, err := app.FindCollectionByNameOrId("leads"); err == nil {
+			leads.Fields.RemoveByName("close_reason")
+			leads.Fields.RemoveByName("competitor")
+			if err := app.Save(leads); err != nil {
+				return err
+			}
+		}
+		if col, err := app.FindCollectionByNameOrId("deal_reasons"); err == nil {
+			return app.Delete(col)
+		}
+		return nil
+	}, "0014_deal_reasons")
+}
@@ -0,0 +1,74 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// currencyCollections are the collections whose amounts carry a currency code.
+var currencyCollections = []string{"leads", "invoices", "marketing_expenses"}
+
+func init() {
+	m.Register(func(app core.App) error {
+		auth := strPtr("@request.auth.id != ''")
+		adminOnly := strPtr("@request.auth.role = 'admin'")
+
+		// ==========================================
+		// EXCHANGE_RATES — units of currency for 1 EUR on a given date
+		// ==========================================
+		rates := findOrCreateBase(app, "exchange_rates")
+		rates.Fields.Add(&core.TextField{Name: "currency", Required: true, Min: 3, Max: 3, Pattern: `^[A-Z]{3}$`})
+		rates.Fields.Add(&core.DateField{Name: "date", Required: true})
+		rates.Fields.Add(&core.NumberField{Name: "rate", Required: true, Min: floatPtr(0)})
+		rates.Fields.Add(&core.SelectField{
+			Name:      "source",
+			Values:    []string{"manuel", "csv", "ecb"},
+			MaxSelect: 1,
+		})
+		rates.Fields.Add(&core.AutodateField{Name: "created", OnCreate: true})
+		rates.Fields.Add(&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true})
+		rates.AddIndex("idx_exchange_rates_currency_date", true, "currency, date", "")
+
+		rates.ListRule = auth
+		rates.ViewRule = auth
+		rates.CreateRule = adminOnly
+		rates.UpdateRule = adminOnly
+		rates.DeleteRule = adminOnly
+
+		if err := app.Save(rates); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// LEADS / INVOICES / MARKETING_EXPENSES — currency code
+		// ==========================================
+		for _, name := range currencyCollections {
+			col, err := app.FindCollectionByNameOrId(name)
+			if err != nil {
+				return err
+			}
+			col.Fields.Add(&core.TextField{Name: "currency", Max: 3, Pattern: `^[A-Z]{3}$`})
+			if err := app.Save(col); err != nil {
+				return err
+			}
+			// Existing amounts were all entered in euros
+			if _, err := app.DB().NewQuery("UPDATE " + name + " SET currency = 'EUR' WHERE currency = '' OR currency IS NULL").Execute(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(app core.App) error {
+		for _, name := range currencyCollections {
+			if col, err := app.FindCollectionByNameOrId(name); err == nil {
+				col.Fields.RemoveByName("currency")
+				if err := app.Save(col); err != nil {
+					return err
+				}
+			}
+		}
+		if col, err := app.FindCollectionByNameOrId("exchange_rates"); err == nil {
+			return app.Delete(col)
+		}
+		return nil
+	}, "0015_currencies")
+}
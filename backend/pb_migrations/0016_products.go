@@ -0,0 +1,84 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		auth := strPtr("@request.auth.id != ''")
+		adminOnly := strPtr("@request.auth.role = 'admin'")
+		leadOwner := strPtr("@request.auth.role = 'admin' || lead.owner = @request.auth.id")
+
+		// ==========================================
+		// PRODUCTS — catalogue
+		// ==========================================
+		products := findOrCreateBase(app, "products")
+		products.Fields.Add(&core.TextField{Name: "sku", Required: true, Max: 50})
+		products.Fields.Add(&core.TextField{Name: "name", Required: true, Max: 300})
+		products.Fields.Add(&core.TextField{Name: "description", Max: 2000})
+		products.Fields.Add(&core.NumberField{Name: "unit_price", Min: floatPtr(0)})
+		products.Fields.Add(&core.TextField{Name: "currency", Max: 3, Pattern: `^[A-Z]{3}$`})
+		products.Fields.Add(&core.NumberField{Name: "tax_rate", Min: floatPtr(0), Max: floatPtr(100)})
+		// recurring: billed every period (subscription) rather than once
+		products.Fields.Add(&core.BoolField{Name: "recurring"})
+		// archived: kept for existing lines, no longer offered on new ones
+		products.Fields.Add(&core.BoolField{Name: "archived"})
+		products.Fields.Add(&core.AutodateField{Name: "created", OnCreate: true})
+		products.Fields.Add(&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true})
+		products.AddIndex("idx_products_sku", true, "sku", "")
+
+		products.ListRule = auth
+		products.ViewRule = auth
+		products.CreateRule = adminOnly
+		products.UpdateRule = adminOnly
+		products.DeleteRule = adminOnly
+
+		if err := app.Save(products); err != nil {
+			return err
+		}
+
+		leads, err := app.FindCollectionByNameOrId("leads")
+		if err != nil {
+			return err
+		}
+
+		// ==========================================
+		// LEAD_ITEMS — line items, in the lead's currency
+		// ==========================================
+		items := findOrCreateBase(app, "lead_items")
+		items.Fields.Add(&core.RelationField{Name: "lead", CollectionId: leads.Id, Required: true, MaxSelect: 1, CascadeDelete: true})
+		items.Fields.Add(&core.RelationField{Name: "product", CollectionId: products.Id, MaxSelect: 1})
+		items.Fields.Add(&core.TextField{Name: "description", Max: 500})
+		items.Fields.Add(&core.NumberField{Name: "quantity", Required: true, Min: floatPtr(0)})
+		items.Fields.Add(&core.NumberField{Name: "unit_price", Min: floatPtr(0)})
+		// discount: percentage off the line (0–100)
+		items.Fields.Add(&core.NumberField{Name: "discount", Min: floatPtr(0), Max: floatPtr(100)})
+		items.Fields.Add(&core.NumberField{Name: "tax_rate", Min: floatPtr(0), Max: floatPtr(100)})
+		items.Fields.Add(&core.BoolField{Name: "recurring"})
+		// total: quantity × unit_price × (1 − discount/100), computed by hook
+		items.Fields.Add(&core.NumberField{Name: "total", Min: floatPtr(0)})
+		items.Fields.Add(&core.NumberField{Name: "order", Min: floatPtr(0)})
+		items.Fields.Add(&core.AutodateField{Name: "created", OnCreate: true})
+		items.Fields.Add(&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true})
+		items.AddIndex("idx_lead_items_lead", false, "lead", "")
+
+		items.ListRule = auth
+		items.ViewRule = auth
+		items.CreateRule = leadOwner
+		items.UpdateRule = leadOwner
+		items.DeleteRule = leadOwner
+
+		return app.Save(items)
+	}, func(app core.App) error {
+		for _, name := range []string{"lead_items", "products"} {
+			if col, err := app.FindCollectionByNameOrId(name); err == nil {
+				if err := app.Delete(col); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}, "0016_products")
+}
@@ -0,0 +1,117 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		auth := strPtr("@request.auth.id != ''")
+		adminOnly := strPtr("@request.auth.role = 'admin'")
+		adminOrCommercial := strPtr("@request.auth.role = 'admin' || @request.auth.role = 'commercial'")
+
+		leads, err := app.FindCollectionByNameOrId("leads")
+		if err != nil {
+			return err
+		}
+		contacts, err := app.FindCollectionByNameOrId("contacts")
+		if err != nil {
+			return err
+		}
+		companies, err := app.FindCollectionByNameOrId("companies")
+		if err != nil {
+			return err
+		}
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+		invoices, err := app.FindCollectionByNameOrId("invoices")
+		if err != nil {
+			return err
+		}
+
+		// ==========================================
+		// QUOTES — devis, one record per version
+		// ==========================================
+		quotes := findOrCreateBase(app, "quotes")
+		// number: DEV-YYYY-NNNN, shared by all versions of a quote
+		quotes.Fields.Add(&core.TextField{Name: "number", Max: 50})
+		quotes.Fields.Add(&core.NumberField{Name: "version", Min: floatPtr(1), OnlyInt: true})
+		quotes.Fields.Add(&core.TextField{Name: "title", Max: 300})
+		quotes.Fields.Add(&core.RelationField{Name: "lead", CollectionId: leads.Id, Required: true, MaxSelect: 1, CascadeDelete: true})
+		quotes.Fields.Add(&core.RelationField{Name: "contact", CollectionId: contacts.Id, MaxSelect: 1})
+		quotes.Fields.Add(&core.RelationField{Name: "company", CollectionId: companies.Id, MaxSelect: 1})
+		quotes.Fields.Add(&core.RelationField{Name: "owner", CollectionId: users.Id, MaxSelect: 1})
+		quotes.Fields.Add(&core.SelectField{
+			Name:      "status",
+			Required:  true,
+			MaxSelect: 1,
+			Values:    []string{"brouillon", "envoye", "accepte", "refuse", "expire"},
+		})
+		quotes.Fields.Add(&core.TextField{Name: "currency", Max: 3, Pattern: `^[A-Z]{3}$`})
+		// items: [{description, qty, unit_price, discount, tax_rate, total}]
+		quotes.Fields.Add(&core.JSONField{Name: "items", MaxSize: 100000})
+		// subtotal, tax_total, total: computed by hook from items
+		quotes.Fields.Add(&core.NumberField{Name: "subtotal", Min: floatPtr(0)})
+		quotes.Fields.Add(&core.NumberField{Name: "tax_total", Min: floatPtr(0)})
+		quotes.Fields.Add(&core.NumberField{Name: "total", Min: floatPtr(0)})
+		quotes.Fields.Add(&core.DateField{Name: "valid_until"})
+		quotes.Fields.Add(&core.EditorField{Name: "notes", MaxSize: 50000})
+		quotes.Fields.Add(&core.DateField{Name: "sent_at"})
+		quotes.Fields.Add(&core.DateField{Name: "accepted_at"})
+		quotes.Fields.Add(&core.DateField{Name: "refused_at"})
+		// signer_*: who answered through the public link
+		quotes.Fields.Add(&core.TextField{Name: "signer_name", Max: 200})
+		quotes.Fields.Add(&core.TextField{Name: "signer_ip", Max: 100})
+		quotes.Fields.Add(&core.TextField{Name: "refusal_reason", Max: 2000})
+		// invoice: draft invoice created on acceptance
+		quotes.Fields.Add(&core.RelationField{Name: "invoice", CollectionId: invoices.Id, MaxSelect: 1})
+		quotes.Fields.Add(&core.AutodateField{Name: "created", OnCreate: true})
+		quotes.Fields.Add(&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true})
+		quotes.AddIndex("idx_quotes_number_version", true, "number, version", "")
+		quotes.AddIndex("idx_quotes_lead", false, "lead", "")
+
+		quotes.ListRule = auth
+		quotes.ViewRule = auth
+		quotes.CreateRule = adminOrCommercial
+		quotes.UpdateRule = strPtr("@request.auth.role = 'admin' || owner = @request.auth.id")
+		quotes.DeleteRule = adminOnly
+
+		if err := app.Save(quotes); err != nil {
+			return err
+		}
+
+		// previous_version: the version this one revises
+		quotes.Fields.Add(&core.RelationField{Name: "previous_version", CollectionId: quotes.Id, MaxSelect: 1})
+		if err := app.Save(quotes); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// ACTIVITIES — "devis" type
+		// ==========================================
+		activities, err := app.FindCollectionByNameOrId("activities")
+		if err != nil {
+			return err
+		}
+		if f, ok := activities.Fields.GetByName("type").(*core.SelectField); ok {
+			f.Values = []string{"creation", "modification", "email", "appel", "note", "statut_change", "attribution", "conversion", "devis"}
+		}
+		return app.Save(activities)
+	}, func(app core.App) error {
+		if activities, err := app.FindCollectionByNameOrId("activities"); err == nil {
+			if f, ok := activities.Fields.GetByName("type").(*core.SelectField); ok {
+				f.Values = []string{"creation", "modification", "email", "appel", "note", "statut_change", "attribution", "conversion"}
+			}
+			if err := app.Save(activities); err != nil {
+				return err
+			}
+		}
+		if col, err := app.FindCollectionByNameOrId("quotes"); err == nil {
+			return app.Delete(col)
+		}
+		return nil
+	}, "0017_quotes")
+}
@@ -0,0 +1,46 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// ==========================================
+		// ACTIVITIES — "fusion" type (duplicate merges)
+		// ==========================================
+		activities, err := app.FindCollectionByNameOrId("activities")
+		if err != nil {
+			return err
+		}
+		if f, ok := activities.Fields.GetByName("type").(*core.SelectField); ok {
+			f.Values = []string{"creation", "modification", "email", "appel", "note", "statut_change", "attribution", "conversion", "devis", "fusion"}
+		}
+		if err := app.Save(activities); err != nil {
+			return err
+		}
+
+		// Lookups of the duplicate check
+		contacts, err := app.FindCollectionByNameOrId("contacts")
+		if err != nil {
+			return err
+		}
+		contacts.AddIndex("idx_contacts_email", false, "email", "")
+		return app.Save(contacts)
+	}, func(app core.App) error {
+		if contacts, err := app.FindCollectionByNameOrId("contacts"); err == nil {
+			contacts.RemoveIndex("idx_contacts_email")
+			if err := app.Save(contacts); err != nil {
+				return err
+			}
+		}
+		if activities, err := app.FindCollectionByNameOrId("activities"); err == nil {
+			if f, ok := activities.Fields.GetByName("type").(*core.SelectField); ok {
+				f.Values = []string{"creation", "modification", "email", "appel", "note", "statut_change", "attribution", "conversion", "devis"}
+			}
+			return app.Save(activities)
+		}
+		return nil
+	}, "0018_duplicates")
+}
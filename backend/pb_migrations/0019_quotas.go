@@ -0,0 +1,92 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		auth := strPtr("@request.auth.id != ''")
+		adminOnly := strPtr("@request.auth.role = 'admin'")
+
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+
+		// ==========================================
+		// TEAMS — sales teams (quotas, attainment)
+		// ==========================================
+		teams := findOrCreateBase(app, "teams")
+		teams.Fields.Add(&core.TextField{Name: "name", Required: true, Max: 200})
+		teams.Fields.Add(&core.RelationField{Name: "manager", CollectionId: users.Id, MaxSelect: 1})
+		teams.Fields.Add(&core.AutodateField{Name: "created", OnCreate: true})
+		teams.Fields.Add(&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true})
+		teams.AddIndex("idx_teams_name", true, "name", "")
+
+		teams.ListRule = auth
+		teams.ViewRule = auth
+		teams.CreateRule = adminOnly
+		teams.UpdateRule = adminOnly
+		teams.DeleteRule = adminOnly
+
+		if err := app.Save(teams); err != nil {
+			return err
+		}
+
+		// USERS — team membership (one team per user)
+		users.Fields.Add(&core.RelationField{Name: "team", CollectionId: teams.Id, MaxSelect: 1})
+		if err := app.Save(users); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// SALES_QUOTAS — monthly / quarterly targets
+		// ==========================================
+		quotas := findOrCreateBase(app, "sales_quotas")
+		// user or team: exactly one is set (checked by hook)
+		quotas.Fields.Add(&core.RelationField{Name: "user", CollectionId: users.Id, MaxSelect: 1, CascadeDelete: true})
+		quotas.Fields.Add(&core.RelationField{Name: "team", CollectionId: teams.Id, MaxSelect: 1, CascadeDelete: true})
+		quotas.Fields.Add(&core.SelectField{
+			Name:      "period_type",
+			Required:  true,
+			MaxSelect: 1,
+			Values:    []string{"mois", "trimestre"},
+		})
+		// period_start: first day of the month / quarter (normalised by hook)
+		quotas.Fields.Add(&core.DateField{Name: "period_start", Required: true})
+		// revenue_target: won lead value, in currency
+		quotas.Fields.Add(&core.NumberField{Name: "revenue_target", Min: floatPtr(0)})
+		// deals_target: number of won leads
+		quotas.Fields.Add(&core.NumberField{Name: "deals_target", Min: floatPtr(0), OnlyInt: true})
+		quotas.Fields.Add(&core.TextField{Name: "currency", Max: 3, Pattern: `^[A-Z]{3}$`})
+		quotas.Fields.Add(&core.AutodateField{Name: "created", OnCreate: true})
+		quotas.Fields.Add(&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true})
+		quotas.AddIndex("idx_sales_quotas_period", true, "user, team, period_type, period_start", "")
+
+		quotas.ListRule = auth
+		quotas.ViewRule = auth
+		quotas.CreateRule = adminOnly
+		quotas.UpdateRule = adminOnly
+		quotas.DeleteRule = adminOnly
+
+		return app.Save(quotas)
+	}, func(app core.App) error {
+		if col, err := app.FindCollectionByNameOrId("sales_quotas"); err == nil {
+			if err := app.Delete(col); err != nil {
+				return err
+			}
+		}
+		if users, err := app.FindCollectionByNameOrId("users"); err == nil {
+			users.Fields.RemoveByName("team")
+			if err := app.Save(users); err != nil {
+				return err
+			}
+		}
+		if col, err := app.FindCollectionByNameOrId("teams"); err == nil {
+			return app.Delete(col)
+		}
+		return nil
+	}, "0019_quotas")
+}
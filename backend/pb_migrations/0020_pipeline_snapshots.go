@@ -0,0 +1,60 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		auth := strPtr("@request.auth.id != ''")
+
+		pipelines, err := app.FindCollectionByNameOrId("pipelines")
+		if err != nil {
+			return err
+		}
+		stages, err := app.FindCollectionByNameOrId("pipeline_stages")
+		if err != nil {
+			return err
+		}
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+
+		// ==========================================
+		// PIPELINE_SNAPSHOTS — open leads, one row per lead and day
+		// ==========================================
+		snapshots := findOrCreateBase(app, "pipeline_snapshots")
+		snapshots.Fields.Add(&core.DateField{Name: "snapshot_date", Required: true})
+		// lead, title: kept as text so the history survives deleted leads
+		snapshots.Fields.Add(&core.TextField{Name: "lead", Required: true, Max: 50})
+		snapshots.Fields.Add(&core.TextField{Name: "title", Max: 300})
+		snapshots.Fields.Add(&core.RelationField{Name: "pipeline", CollectionId: pipelines.Id, MaxSelect: 1})
+		snapshots.Fields.Add(&core.RelationField{Name: "stage", CollectionId: stages.Id, MaxSelect: 1})
+		// stage_name, stage_order, probability: stage as it was on that day
+		snapshots.Fields.Add(&core.TextField{Name: "stage_name", Max: 100})
+		snapshots.Fields.Add(&core.NumberField{Name: "stage_order"})
+		snapshots.Fields.Add(&core.NumberField{Name: "probability", Min: floatPtr(0), Max: floatPtr(100)})
+		snapshots.Fields.Add(&core.RelationField{Name: "owner", CollectionId: users.Id, MaxSelect: 1})
+		snapshots.Fields.Add(&core.NumberField{Name: "value"})
+		// weighted_value: value × probability / 100, in currency
+		snapshots.Fields.Add(&core.NumberField{Name: "weighted_value"})
+		snapshots.Fields.Add(&core.TextField{Name: "currency", Max: 3})
+		snapshots.Fields.Add(&core.DateField{Name: "expected_close"})
+		snapshots.Fields.Add(&core.AutodateField{Name: "created", OnCreate: true})
+		snapshots.AddIndex("idx_pipeline_snapshots_date_lead", true, "snapshot_date, lead", "")
+		snapshots.AddIndex("idx_pipeline_snapshots_lead", false, "lead", "")
+
+		snapshots.ListRule = auth
+		snapshots.ViewRule = auth
+		// Create/Update/Delete = nil → hook-only (API disabled)
+
+		return app.Save(snapshots)
+	}, func(app core.App) error {
+		if col, err := app.FindCollectionByNameOrId("pipeline_snapshots"); err == nil {
+			return app.Delete(col)
+		}
+		return nil
+	}, "0020_pipeline_snapshots")
+}
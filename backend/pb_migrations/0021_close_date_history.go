@@ -0,0 +1,69 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		auth := strPtr("@request.auth.id != ''")
+
+		leads, err := app.FindCollectionByNameOrId("leads")
+		if err != nil {
+			return err
+		}
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+
+		// ==========================================
+		// LEADS — number of times expected_close was pushed back
+		// ==========================================
+		leads.Fields.Add(&core.NumberField{Name: "close_date_slips", Min: floatPtr(0), OnlyInt: true})
+		if err := app.Save(leads); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// LEAD_CLOSE_DATE_HISTORY
+		// ==========================================
+		history := findOrCreateBase(app, "lead_close_date_history")
+		history.Fields.Add(&core.RelationField{
+			Name:          "lead",
+			CollectionId:  leads.Id,
+			MaxSelect:     1,
+			Required:      true,
+			CascadeDelete: true,
+		})
+		history.Fields.Add(&core.DateField{Name: "old_date"})
+		history.Fields.Add(&core.DateField{Name: "new_date"})
+		// days_moved: new_date − old_date in days (> 0 = slipped)
+		history.Fields.Add(&core.NumberField{Name: "days_moved", OnlyInt: true})
+		history.Fields.Add(&core.RelationField{Name: "changed_by", CollectionId: users.Id, MaxSelect: 1})
+		history.Fields.Add(&core.DateField{Name: "changed_at", Required: true})
+		// value: lead value at the time of the change
+		history.Fields.Add(&core.NumberField{Name: "value"})
+		history.Fields.Add(&core.AutodateField{Name: "created", OnCreate: true})
+		history.AddIndex("idx_lead_close_date_history_lead", false, "lead, changed_at", "")
+		history.AddIndex("idx_lead_close_date_history_old_date", false, "old_date", "")
+
+		history.ListRule = auth
+		history.ViewRule = auth
+		// Create/Update/Delete = nil → hook-only (API disabled)
+
+		return app.Save(history)
+	}, func(app core.App) error {
+		if col, err := app.FindCollectionByNameOrId("lead_close_date_history"); err == nil {
+			if err := app.Delete(col); err != nil {
+				return err
+			}
+		}
+		if leads, err := app.FindCollectionByNameOrId("leads"); err == nil {
+			leads.Fields.RemoveByName("close_date_slips")
+			return app.Save(leads)
+		}
+		return nil
+	}, "0021_close_date_history")
+}
@@ -0,0 +1,132 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// utmFields are the campaign tracking parameters kept on web leads.
+var utmFields = []string{"utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content"}
+
+func init() {
+	m.Register(func(app core.App) error {
+		adminOnly := strPtr("@request.auth.role = 'admin'")
+
+		pipelines, err := app.FindCollectionByNameOrId("pipelines")
+		if err != nil {
+			return err
+		}
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+		templates, err := app.FindCollectionByNameOrId("email_templates")
+		if err != nil {
+			return err
+		}
+		campaigns, err := app.FindCollectionByNameOrId("campaigns")
+		if err != nil {
+			return err
+		}
+
+		// ==========================================
+		// WEB_FORMS — public lead capture forms
+		// ==========================================
+		forms := findOrCreateBase(app, "web_forms")
+		forms.Fields.Add(&core.TextField{Name: "name", Required: true, Max: 200})
+		// token: secret part of the public URL, generated by hook when empty
+		forms.Fields.Add(&core.TextField{Name: "token", Max: 64, Pattern: `^[a-zA-Z0-9]*$`})
+		forms.Fields.Add(&core.BoolField{Name: "active"})
+		// pipeline: empty = default pipeline
+		forms.Fields.Add(&core.RelationField{Name: "pipeline", CollectionId: pipelines.Id, MaxSelect: 1})
+		// owner: fixed owner of the leads (empty = assignment rules)
+		forms.Fields.Add(&core.RelationField{Name: "owner", CollectionId: users.Id, MaxSelect: 1})
+		forms.Fields.Add(&core.RelationField{Name: "campaign", CollectionId: campaigns.Id, MaxSelect: 1})
+		// autoresponder: template sent to the submitter (optional)
+		forms.Fields.Add(&core.RelationField{Name: "autoresponder", CollectionId: templates.Id, MaxSelect: 1})
+		// honeypot_field: hidden input bots fill in (default "url")
+		forms.Fields.Add(&core.TextField{Name: "honeypot_field", Max: 50, Pattern: `^[a-zA-Z0-9_-]*$`})
+		// redirect_url: where browser (urlencoded) submissions are sent afterwards
+		forms.Fields.Add(&core.URLField{Name: "redirect_url"})
+		forms.Fields.Add(&core.NumberField{Name: "submissions", Min: floatPtr(0), OnlyInt: true})
+		forms.Fields.Add(&core.NumberField{Name: "spam_blocked", Min: floatPtr(0), OnlyInt: true})
+		forms.Fields.Add(&core.DateField{Name: "last_submission_at"})
+		forms.Fields.Add(&core.AutodateField{Name: "created", OnCreate: true})
+		forms.Fields.Add(&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true})
+		forms.AddIndex("idx_web_forms_token", true, "token", "")
+
+		forms.ListRule = adminOnly
+		forms.ViewRule = adminOnly
+		forms.CreateRule = adminOnly
+		forms.UpdateRule = adminOnly
+		forms.DeleteRule = adminOnly
+
+		if err := app.Save(forms); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// LEADS — web form and UTM parameters
+		// ==========================================
+		leads, err := app.FindCollectionByNameOrId("leads")
+		if err != nil {
+			return err
+		}
+		leads.Fields.Add(&core.RelationField{Name: "web_form", CollectionId: forms.Id, MaxSelect: 1})
+		for _, name := range utmFields {
+			leads.Fields.Add(&core.TextField{Name: name, Max: 200})
+		}
+		if err := app.Save(leads); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// COMPANIES / CONTACTS — lookups of submissions (name, website, email
+		// domain; case-insensitive email)
+		// ==========================================
+		companies, err := app.FindCollectionByNameOrId("companies")
+		if err != nil {
+			return err
+		}
+		companies.AddIndex("idx_companies_name", false, "lower(name)", "")
+		companies.AddIndex("idx_companies_website", false, "lower(website)", "")
+		companies.AddIndex("idx_companies_email_domain", false, "lower(substr(email, instr(email, '@') + 1))", "")
+		if err := app.Save(companies); err != nil {
+			return err
+		}
+		contacts, err := app.FindCollectionByNameOrId("contacts")
+		if err != nil {
+			return err
+		}
+		contacts.AddIndex("idx_contacts_email_lower", false, "lower(email)", "")
+		return app.Save(contacts)
+	}, func(app core.App) error {
+		if contacts, err := app.FindCollectionByNameOrId("contacts"); err == nil {
+			contacts.RemoveIndex("idx_contacts_email_lower")
+			if err := app.Save(contacts); err != nil {
+				return err
+			}
+		}
+		if companies, err := app.FindCollectionByNameOrId("companies"); err == nil {
+			for _, name := range []string{"idx_companies_name", "idx_companies_website", "idx_companies_email_domain"} {
+				companies.RemoveIndex(name)
+			}
+			if err := app.Save(companies); err != nil {
+				return err
+			}
+		}
+		if leads, err := app.FindCollectionByNameOrId("leads"); err == nil {
+			leads.Fields.RemoveByName("web_form")
+			for _, name := range utmFields {
+				leads.Fields.RemoveByName(name)
+			}
+			if err := app.Save(leads); err != nil {
+				return err
+			}
+		}
+		if col, err := app.FindCollectionByNameOrId("web_forms"); err == nil {
+			return app.Delete(col)
+		}
+		return nil
+	}, "0022_web_forms")
+}
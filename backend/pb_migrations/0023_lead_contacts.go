@@ -0,0 +1,122 @@
+package pb_migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		auth := strPtr("@request.auth.id != ''")
+		leadOwner := strPtr("@request.auth.role = 'admin' || lead.owner = @request.auth.id")
+
+		leads, err := app.FindCollectionByNameOrId("leads")
+		if err != nil {
+			return err
+		}
+		contacts, err := app.FindCollectionByNameOrId("contacts")
+		if err != nil {
+			return err
+		}
+
+		// ==========================================
+		// LEAD_CONTACTS — contacts involved in a deal, with their buying role
+		// ==========================================
+		leadContacts := findOrCreateBase(app, "lead_contacts")
+		leadContacts.Fields.Add(&core.RelationField{Name: "lead", CollectionId: leads.Id, Required: true, MaxSelect: 1, CascadeDelete: true})
+		leadContacts.Fields.Add(&core.RelationField{Name: "contact", CollectionId: contacts.Id, Required: true, MaxSelect: 1, CascadeDelete: true})
+		// role: empty = not qualified yet
+		leadContacts.Fields.Add(&core.SelectField{
+			Name:      "role",
+			Values:    []string{"decideur", "influenceur", "utilisateur", "achats"},
+			MaxSelect: 1,
+		})
+		leadContacts.Fields.Add(&core.TextField{Name: "notes", Max: 500})
+		leadContacts.Fields.Add(&core.AutodateField{Name: "created", OnCreate: true})
+		leadContacts.Fields.Add(&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true})
+		leadContacts.AddIndex("idx_lead_contacts_lead_contact", true, "lead, contact", "")
+		leadContacts.AddIndex("idx_lead_contacts_contact", false, "contact", "")
+
+		leadContacts.ListRule = auth
+		leadContacts.ViewRule = auth
+		leadContacts.CreateRule = leadOwner
+		leadContacts.UpdateRule = leadOwner
+		leadContacts.DeleteRule = leadOwner
+
+		if err := app.Save(leadContacts); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// ACTIVITIES — every contact involved (contact stays the main one)
+		// ==========================================
+		activities, err := app.FindCollectionByNameOrId("activities")
+		if err != nil {
+			return err
+		}
+		activities.Fields.Add(&core.RelationField{Name: "contacts", CollectionId: contacts.Id, MaxSelect: 50})
+		if err := app.Save(activities); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// EMAIL_LOGS — lead the email was sent for
+		// ==========================================
+		emailLogs, err := app.FindCollectionByNameOrId("email_logs")
+		if err != nil {
+			return err
+		}
+		emailLogs.Fields.Add(&core.RelationField{Name: "lead", CollectionId: leads.Id, MaxSelect: 1})
+		emailLogs.AddIndex("idx_email_logs_lead", false, "lead", "")
+		if err := app.Save(emailLogs); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// Back-fill: main contact of each lead, contacts of past activities
+		// ==========================================
+		var rows []struct {
+			Lead    string `db:"id"`
+			Contact string `db:"contact"`
+		}
+		if err := app.DB().NewQuery(`
+			SELECT l.id, l.contact FROM leads l
+			JOIN contacts c ON c.id = l.contact
+		`).All(&rows); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			rec := core.NewRecord(leadContacts)
+			rec.Set("lead", row.Lead)
+			rec.Set("contact", row.Contact)
+			if err := app.Save(rec); err != nil {
+				return fmt.Errorf("back-fill lead contact for lead %s: %w", row.Lead, err)
+			}
+		}
+		_, err = app.DB().NewQuery(`
+			UPDATE activities SET contacts = json_array(contact)
+			WHERE COALESCE(contact, '') != ''
+		`).Execute()
+		return err
+	}, func(app core.App) error {
+		if col, err := app.FindCollectionByNameOrId("lead_contacts"); err == nil {
+			if err := app.Delete(col); err != nil {
+				return err
+			}
+		}
+		if col, err := app.FindCollectionByNameOrId("email_logs"); err == nil {
+			col.Fields.RemoveByName("lead")
+			col.RemoveIndex("idx_email_logs_lead")
+			if err := app.Save(col); err != nil {
+				return err
+			}
+		}
+		if col, err := app.FindCollectionByNameOrId("activities"); err == nil {
+			col.Fields.RemoveByName("contacts")
+			return app.Save(col)
+		}
+		return nil
+	}, "0023_lead_contacts")
+}
@@ -0,0 +1,155 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		auth := strPtr("@request.auth.id != ''")
+		adminOrCommercial := strPtr("@request.auth.role = 'admin' || @request.auth.role = 'commercial'")
+		adminOrOwner := strPtr("@request.auth.role = 'admin' || owner = @request.auth.id")
+		adminOnly := strPtr("@request.auth.role = 'admin'")
+
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+		companies, err := app.FindCollectionByNameOrId("companies")
+		if err != nil {
+			return err
+		}
+		contacts, err := app.FindCollectionByNameOrId("contacts")
+		if err != nil {
+			return err
+		}
+		leads, err := app.FindCollectionByNameOrId("leads")
+		if err != nil {
+			return err
+		}
+
+		// ==========================================
+		// SUBSCRIPTIONS — recurring contracts
+		// ==========================================
+		subs := findOrCreateBase(app, "subscriptions")
+		subs.Fields.Add(&core.TextField{Name: "name", Required: true, Max: 300})
+		subs.Fields.Add(&core.RelationField{Name: "company", CollectionId: companies.Id, MaxSelect: 1})
+		subs.Fields.Add(&core.RelationField{Name: "contact", CollectionId: contacts.Id, MaxSelect: 1})
+		// lead: deal the contract was signed from
+		subs.Fields.Add(&core.RelationField{Name: "lead", CollectionId: leads.Id, MaxSelect: 1})
+		subs.Fields.Add(&core.RelationField{Name: "owner", CollectionId: users.Id, MaxSelect: 1, Required: true})
+		subs.Fields.Add(&core.SelectField{
+			Name:      "status",
+			Required:  true,
+			Values:    []string{"actif", "resilie", "expire"},
+			MaxSelect: 1,
+		})
+		// items: lines billed every cycle (product, description, qty, unit_price, discount, tax_rate)
+		subs.Fields.Add(&core.JSONField{Name: "items", MaxSize: 100000})
+		subs.Fields.Add(&core.SelectField{
+			Name:      "billing_interval",
+			Required:  true,
+			Values:    []string{"mensuel", "trimestriel", "annuel"},
+			MaxSelect: 1,
+		})
+		// amount: excl. tax per billing cycle, mrr: amount per month (both computed by hook)
+		subs.Fields.Add(&core.NumberField{Name: "amount", Min: floatPtr(0)})
+		subs.Fields.Add(&core.NumberField{Name: "mrr", Min: floatPtr(0)})
+		subs.Fields.Add(&core.TextField{Name: "currency", Max: 3, Pattern: `^[A-Z]{3}$`})
+		subs.Fields.Add(&core.DateField{Name: "start_date", Required: true})
+		// end_date: last day of service (set on cancellation / expiry)
+		subs.Fields.Add(&core.DateField{Name: "end_date"})
+		// term_months: commitment renewed on renewal_date (default 12)
+		subs.Fields.Add(&core.NumberField{Name: "term_months", Min: floatPtr(1), Max: floatPtr(120), OnlyInt: true})
+		subs.Fields.Add(&core.DateField{Name: "renewal_date"})
+		subs.Fields.Add(&core.BoolField{Name: "auto_renew"})
+		// next_billing_date: start of the next cycle to invoice (advanced by the scheduler)
+		subs.Fields.Add(&core.DateField{Name: "next_billing_date"})
+		// renewal_reminded_for: renewal_date the reminder task was created for
+		subs.Fields.Add(&core.DateField{Name: "renewal_reminded_for"})
+		subs.Fields.Add(&core.DateField{Name: "cancelled_at"})
+		subs.Fields.Add(&core.TextField{Name: "cancel_reason", Max: 500})
+		subs.Fields.Add(&core.EditorField{Name: "notes", MaxSize: 50000})
+		subs.Fields.Add(&core.AutodateField{Name: "created", OnCreate: true})
+		subs.Fields.Add(&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true})
+		subs.AddIndex("idx_subscriptions_status", false, "status, next_billing_date", "")
+		subs.AddIndex("idx_subscriptions_company", false, "company", "")
+
+		subs.ListRule = auth
+		subs.ViewRule = auth
+		subs.CreateRule = adminOrCommercial
+		subs.UpdateRule = adminOrOwner
+		subs.DeleteRule = adminOnly
+
+		if err := app.Save(subs); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// SUBSCRIPTION_EVENTS — MRR movements (new, expansion, contraction, churn, reactivation)
+		// ==========================================
+		events := findOrCreateBase(app, "subscription_events")
+		events.Fields.Add(&core.RelationField{
+			Name:          "subscription",
+			CollectionId:  subs.Id,
+			MaxSelect:     1,
+			Required:      true,
+			CascadeDelete: true,
+		})
+		events.Fields.Add(&core.SelectField{
+			Name:      "type",
+			Required:  true,
+			Values:    []string{"nouveau", "expansion", "contraction", "resiliation", "reactivation"},
+			MaxSelect: 1,
+		})
+		events.Fields.Add(&core.NumberField{Name: "mrr_before", Min: floatPtr(0)})
+		events.Fields.Add(&core.NumberField{Name: "mrr_after", Min: floatPtr(0)})
+		events.Fields.Add(&core.TextField{Name: "currency", Max: 3, Pattern: `^[A-Z]{3}$`})
+		events.Fields.Add(&core.DateField{Name: "occurred_at", Required: true})
+		events.Fields.Add(&core.RelationField{Name: "changed_by", CollectionId: users.Id, MaxSelect: 1})
+		events.Fields.Add(&core.AutodateField{Name: "created", OnCreate: true})
+		events.AddIndex("idx_subscription_events_subscription", false, "subscription, occurred_at", "")
+		events.AddIndex("idx_subscription_events_occurred_at", false, "occurred_at", "")
+
+		events.ListRule = auth
+		events.ViewRule = auth
+		// Create/Update/Delete = nil → hook-only (API disabled)
+
+		if err := app.Save(events); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// INVOICES — billing cycle of a subscription
+		// ==========================================
+		invoices, err := app.FindCollectionByNameOrId("invoices")
+		if err != nil {
+			return err
+		}
+		invoices.Fields.Add(&core.RelationField{Name: "subscription", CollectionId: subs.Id, MaxSelect: 1})
+		invoices.Fields.Add(&core.DateField{Name: "period_start"})
+		invoices.Fields.Add(&core.DateField{Name: "period_end"})
+		// One invoice per subscription and cycle, even if the scheduler runs twice
+		invoices.AddIndex("idx_invoices_subscription_period", true, "subscription, period_start", "subscription != ''")
+		return app.Save(invoices)
+	}, func(app core.App) error {
+		if invoices, err := app.FindCollectionByNameOrId("invoices"); err == nil {
+			invoices.RemoveIndex("idx_invoices_subscription_period")
+			for _, name := range []string{"subscription", "period_start", "period_end"} {
+				invoices.Fields.RemoveByName(name)
+			}
+			if err := app.Save(invoices); err != nil {
+				return err
+			}
+		}
+		for _, name := range []string{"subscription_events", "subscriptions"} {
+			if col, err := app.FindCollectionByNameOrId(name); err == nil {
+				if err := app.Delete(col); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}, "0024_subscriptions")
+}
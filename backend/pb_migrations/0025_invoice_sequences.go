@@ -0,0 +1,140 @@
+package pb_migrations
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// yearCounterPattern reads the year and counter of a number such as FAC-2026-006.
+var yearCounterPattern = regexp.MustCompile(`(?:^|\D)(\d{4})\D+(\d+)$`)
+
+func init() {
+	m.Register(func(app core.App) error {
+		auth := strPtr("@request.auth.id != ''")
+		adminOnly := strPtr("@request.auth.role = 'admin'")
+
+		// ==========================================
+		// INVOICE_SEQUENCES — legal numbering of issued invoices
+		// ==========================================
+		sequences := findOrCreateBase(app, "invoice_sequences")
+		sequences.Fields.Add(&core.TextField{Name: "name", Required: true, Max: 100})
+		// prefix: start of every number, e.g. "FA-" → FA-2026-00042
+		sequences.Fields.Add(&core.TextField{Name: "prefix", Max: 20, Pattern: `^[A-Za-z0-9_/-]*$`})
+		// reset_yearly: the year is part of the number and the counter restarts at 1 each year
+		sequences.Fields.Add(&core.BoolField{Name: "reset_yearly"})
+		// padding: minimum number of digits of the counter
+		sequences.Fields.Add(&core.NumberField{Name: "padding", Min: floatPtr(1), Max: floatPtr(10), OnlyInt: true})
+		// is_default: sequence used for invoices issued without one
+		sequences.Fields.Add(&core.BoolField{Name: "is_default"})
+		// last_number / last_year: counter, advanced by hook (last_number may be set on create or raised to continue a series)
+		sequences.Fields.Add(&core.NumberField{Name: "last_number", Min: floatPtr(0), OnlyInt: true})
+		sequences.Fields.Add(&core.NumberField{Name: "last_year", Min: floatPtr(0), OnlyInt: true})
+		sequences.Fields.Add(&core.AutodateField{Name: "created", OnCreate: true})
+		sequences.Fields.Add(&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true})
+		sequences.AddIndex("idx_invoice_sequences_name", true, "name", "")
+
+		sequences.ListRule = auth
+		sequences.ViewRule = auth
+		sequences.CreateRule = adminOnly
+		sequences.UpdateRule = adminOnly
+		sequences.DeleteRule = adminOnly
+
+		if err := app.Save(sequences); err != nil {
+			return err
+		}
+
+		var count int
+		if err := app.DB().NewQuery("SELECT COUNT(*) FROM invoice_sequences").Row(&count); err != nil {
+			return err
+		}
+		if count == 0 {
+			// The default series continues the numbers issued this year
+			// (e.g. FAC-2026-006 → next FA-2026-00007)
+			year := time.Now().UTC().Year()
+			var numbers []string
+			if err := app.DB().NewQuery(`
+				SELECT number FROM invoices WHERE status != 'brouillon' AND number != '' AND number NOT LIKE 'BROUILLON-%'
+			`).Column(&numbers); err != nil {
+				return err
+			}
+			last := 0
+			for _, number := range numbers {
+				match := yearCounterPattern.FindStringSubmatch(number)
+				if match == nil || match[1] != strconv.Itoa(year) {
+					continue
+				}
+				if n, err := strconv.Atoi(match[2]); err == nil && n > last {
+					last = n
+				}
+			}
+
+			def := core.NewRecord(sequences)
+			def.Set("name", "Factures")
+			def.Set("prefix", "FA-")
+			def.Set("reset_yearly", true)
+			def.Set("padding", 5)
+			def.Set("is_default", true)
+			def.Set("last_number", last)
+			def.Set("last_year", year)
+			if err := app.Save(def); err != nil {
+				return err
+			}
+		}
+
+		// ==========================================
+		// INVOICES — number assigned on issuance, unique
+		// ==========================================
+		invoices, err := app.FindCollectionByNameOrId("invoices")
+		if err != nil {
+			return err
+		}
+		if f, ok := invoices.Fields.GetByName("number").(*core.TextField); ok {
+			f.Required = false // drafts have no number
+		}
+		invoices.Fields.Add(&core.RelationField{Name: "sequence", CollectionId: sequences.Id, MaxSelect: 1})
+
+		// Drafts lose their placeholder number; duplicated numbers of issued
+		// invoices are made unique so the index can be created: the earliest
+		// invoice keeps the number, the later ones get their id appended.
+		if _, err := app.DB().NewQuery("UPDATE invoices SET number = '' WHERE status = 'brouillon'").Execute(); err != nil {
+			return err
+		}
+		if _, err := app.DB().NewQuery(`
+			UPDATE invoices SET number = number || '-' || id
+			WHERE id IN (
+				SELECT id FROM (
+					SELECT id, ROW_NUMBER() OVER (PARTITION BY number ORDER BY created, id) AS position
+					FROM invoices WHERE number != ''
+				) WHERE position > 1
+			)
+		`).Execute(); err != nil {
+			return err
+		}
+		invoices.AddIndex("idx_invoices_number", true, "number", "number != ''")
+		return app.Save(invoices)
+	}, func(app core.App) error {
+		if invoices, err := app.FindCollectionByNameOrId("invoices"); err == nil {
+			invoices.RemoveIndex("idx_invoices_number")
+			invoices.Fields.RemoveByName("sequence")
+			if f, ok := invoices.Fields.GetByName("number").(*core.TextField); ok {
+				f.Required = true
+			}
+			if _, err := app.DB().NewQuery(`
+				UPDATE invoices SET number = 'BROUILLON-' || id WHERE number = ''
+			`).Execute(); err != nil {
+				return err
+			}
+			if err := app.Save(invoices); err != nil {
+				return err
+			}
+		}
+		if col, err := app.FindCollectionByNameOrId("invoice_sequences"); err == nil {
+			return app.Delete(col)
+		}
+		return nil
+	}, "0025_invoice_sequences")
+}
@@ -0,0 +1,126 @@
+package pb_migrations
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"pocket-crm/services"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// ==========================================
+		// INVOICES — per-line tax, totals computed by hook from items
+		// ==========================================
+		invoices, err := app.FindCollectionByNameOrId("invoices")
+		if err != nil {
+			return err
+		}
+		// amount: subtotal excl. tax; tax_rate: default rate of lines without one
+		invoices.Fields.Add(&core.NumberField{Name: "tax_total", Min: floatPtr(0)})
+		// taxes: [{rate, base, amount}] per tax rate
+		invoices.Fields.Add(&core.JSONField{Name: "taxes", MaxSize: 10000})
+		if err := app.Save(invoices); err != nil {
+			return err
+		}
+
+		// ==========================================
+		// Back-fill: line totals, tax breakdown and, for drafts, the invoice
+		// totals (raw writes, no hooks). Only the line rates overriding the
+		// invoice rate are kept; invoices without lines get a single line
+		// worth their amount. An issued invoice whose lines do not add up to
+		// its amount and total keeps them, its tax being the difference at the
+		// invoice rate.
+		// ==========================================
+		var rows []struct {
+			ID      string  `db:"id"`
+			Status  string  `db:"status"`
+			Items   string  `db:"items"`
+			Amount  float64 `db:"amount"`
+			TaxRate float64 `db:"tax_rate"`
+			Total   float64 `db:"total"`
+		}
+		if err := app.DB().NewQuery(`
+			SELECT id, status, COALESCE(items, '') AS items, amount, tax_rate, total FROM invoices
+		`).All(&rows); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			var lines []map[string]any
+			if row.Items != "" && row.Items != "null" {
+				if err := json.Unmarshal([]byte(row.Items), &lines); err != nil {
+					return fmt.Errorf("invoice %s items: %w", row.ID, err)
+				}
+			}
+			if len(lines) == 0 && row.Amount > 0 {
+				lines = append(lines, map[string]any{"description": "Prestation", "qty": 1.0, "unit_price": row.Amount})
+			}
+			items := make([]services.LineItem, 0, len(lines))
+			for _, line := range lines {
+				item := services.LineItem{TaxRate: row.TaxRate}
+				item.Description, _ = line["description"].(string)
+				item.Qty, _ = line["qty"].(float64)
+				item.UnitPrice, _ = line["unit_price"].(float64)
+				item.Discount, _ = line["discount"].(float64)
+				if rate, ok := line["tax_rate"].(float64); ok {
+					item.TaxRate = rate
+				}
+				items = append(items, item)
+			}
+
+			totals := services.ComputeLineTotals(items)
+			for i, line := range lines {
+				line["total"] = items[i].Total
+				if rate, ok := line["tax_rate"].(float64); ok && rate == row.TaxRate {
+					delete(line, "tax_rate")
+				}
+			}
+			amount, total := totals.Subtotal, totals.Total
+			if row.Status != "brouillon" && (!sameAmount(amount, row.Amount) || !sameAmount(total, row.Total)) {
+				amount, total = row.Amount, row.Total
+				totals.TaxTotal = math.Round((row.Total-row.Amount)*100) / 100
+				totals.Taxes = []services.TaxLine{{Rate: row.TaxRate, Base: row.Amount, Amount: totals.TaxTotal}}
+			}
+
+			itemsJSON, err := json.Marshal(lines)
+			if err != nil {
+				return err
+			}
+			taxesJSON, err := json.Marshal(totals.Taxes)
+			if err != nil {
+				return err
+			}
+			if _, err := app.DB().NewQuery(`
+				UPDATE invoices SET items = {:items}, amount = {:amount}, tax_total = {:tax_total}, taxes = {:taxes}, total = {:total}
+				WHERE id = {:id}
+			`).Bind(dbx.Params{
+				"id":        row.ID,
+				"items":     string(itemsJSON),
+				"amount":    amount,
+				"tax_total": totals.TaxTotal,
+				"taxes":     string(taxesJSON),
+				"total":     total,
+			}).Execute(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(app core.App) error {
+		invoices, err := app.FindCollectionByNameOrId("invoices")
+		if err != nil {
+			return nil
+		}
+		invoices.Fields.RemoveByName("tax_total")
+		invoices.Fields.RemoveByName("taxes")
+		return app.Save(invoices)
+	}, "0026_invoice_lines")
+}
+
+// sameAmount compares two amounts to the cent.
+func sameAmount(a, b float64) bool {
+	return math.Round(a*100) == math.Round(b*100)
+}
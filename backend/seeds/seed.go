@@ -173,19 +173,42 @@ func Run(app core.App, force bool) error {
 		}
 	}
 
+	// dealReason returns the id of a default deal_reasons entry ("" if missing).
+	dealReason := func(reasonType, name string) string {
+		r, err := app.FindFirstRecordByFilter("deal_reasons", "type = {:type} && name = {:name}",
+			dbx.Params{"type": reasonType, "name": name})
+		if err != nil {
+			log.Printf("[seed] deal reason %s/%s: %v", reasonType, name, err)
+			return ""
+		}
+		return r.Id
+	}
+
 	// Won leads (status=gagne) — spread Nov 2024 → Feb 2026, growing revenue.
 	// created = ~2 months before closed_at to show realistic sales cycles.
+	// The won stage is only reachable from proposition/negociation: each lead
+	// is created in negociation then won. converted_at is preset so that these
+	// historical deals get no draft invoice nor onboarding tasks.
+	winReason := dealReason("gain", "Qualité de l'offre")
 	mkWon := func(title string, value float64, owner *core.Record, contact *core.Record, company *core.Record, source, created, closedAt string) *core.Record {
 		l := core.NewRecord(leadsCol)
 		l.Set("title", title)
 		l.Set("value", value)
-		l.Set("status", "gagne")
+		l.Set("status", "negociation")
 		l.Set("priority", "haute")
 		l.Set("source", source)
 		l.Set("contact", contact.Id)
 		l.Set("company", company.Id)
 		l.Set("owner", owner.Id)
+		l.Set("expected_close", closedAt+" 00:00:00.000Z")
+		if err := app.Save(l); err != nil {
+			log.Printf("[seed] won lead %s: %v", title, err)
+			return l
+		}
+		l.Set("status", "gagne")
+		l.Set("close_reason", winReason)
 		l.Set("closed_at", closedAt+" 12:00:00.000Z")
+		l.Set("converted_at", closedAt+" 12:00:00.000Z")
 		if err := app.Save(l); err != nil {
 			log.Printf("[seed] won lead %s: %v", title, err)
 		}
@@ -258,11 +281,12 @@ func Run(app core.App, force bool) error {
 	_ = mkActive("DevOps Acme Enterprise",            165000, "negociation", "site_web",      "urgente",  alice, c2,  acme,     "2025-10-15", "2026-02-28")
 
 	// Lost leads (5) — for realistic conversion rate (~60%)
-	mkLost := func(title string, value float64, owner, contact *core.Record, company *core.Record, source, created string) {
+	mkLost := func(title string, value float64, owner, contact *core.Record, company *core.Record, source, created, reason string) {
 		l := core.NewRecord(leadsCol)
 		l.Set("title", title)
 		l.Set("value", value)
 		l.Set("status", "perdu")
+		l.Set("close_reason", dealReason("perte", reason))
 		l.Set("priority", "moyenne")
 		l.Set("source", source)
 		l.Set("contact", contact.Id)
@@ -274,11 +298,11 @@ func Run(app core.App, force bool) error {
 		setCreated("leads", l.Id, created)
 	}
 
-	mkLost("ERP BioVert — perdu concurrent",      42000, bob,   c4,  biovert,  "salon",          "2024-10-05")
-	mkLost("Refonte DSI Acme — budget refusé",     55000, alice, c2,  acme,     "recommandation", "2025-02-20")
-	mkLost("DataFlow migration — délais client",   38000, bob,   c5,  dataflow, "site_web",       "2025-05-10")
-	mkLost("EcoLogis contrat cadre — concurrent",  70000, alice, c7,  ecologis, "telephone",      "2025-08-15")
-	mkLost("FinTech audit Q3 — concurrent",        90000, bob,   c10, fintech,  "email",          "2025-11-20")
+	mkLost("ERP BioVert — perdu concurrent",      42000, bob,   c4,  biovert,  "salon",          "2024-10-05", "Concurrent retenu")
+	mkLost("Refonte DSI Acme — budget refusé",     55000, alice, c2,  acme,     "recommandation", "2025-02-20", "Pas de budget")
+	mkLost("DataFlow migration — délais client",   38000, bob,   c5,  dataflow, "site_web",       "2025-05-10", "Mauvais timing")
+	mkLost("EcoLogis contrat cadre — concurrent",  70000, alice, c7,  ecologis, "telephone",      "2025-08-15", "Concurrent retenu")
+	mkLost("FinTech audit Q3 — concurrent",        90000, bob,   c10, fintech,  "email",          "2025-11-20", "Concurrent retenu")
 
 	// ==========================================
 	// TASKS (10: 3 meetings today + 4 overdue + 3 completed)
@@ -436,7 +460,6 @@ func Run(app core.App, force bool) error {
 	app.Save(inv18) //nolint:errcheck
 
 	inv19 := core.NewRecord(invoicesCol)
-	inv19.Set("number", "FAC-2026-005")
 	inv19.Set("contact", c10.Id)
 	inv19.Set("company", fintech.Id)
 	inv19.Set("owner", bob.Id)
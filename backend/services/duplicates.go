@@ -0,0 +1,306 @@
+package services
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ─── Duplicate detection ─────────────────────────────────────────────────────
+//
+// Records are reduced to normalised keys (email, phone, website domain, name)
+// and compared pairwise within blocks of records sharing at least one key or
+// name token, so the report stays fast on large tables.
+
+// DuplicateMinScore is the score from which two records are reported.
+const DuplicateMinScore = 60
+
+// DuplicateCandidate is the comparable form of a contact, company or lead.
+type DuplicateCandidate struct {
+	ID      string `json:"id"`
+	Label   string `json:"label"`
+	Name    string `json:"-"` // NormalizeName(full name / company name / lead title)
+	Email   string `json:"-"` // NormalizeEmail
+	Phone   string `json:"-"` // NormalizePhone
+	Domain  string `json:"-"` // website (or business email) domain
+	Company string `json:"-"` // company id
+	Contact string `json:"-"` // contact id
+}
+
+// DuplicateMatch is a pair of likely duplicates.
+type DuplicateMatch struct {
+	A       DuplicateCandidate `json:"a"`
+	B       DuplicateCandidate `json:"b"`
+	Score   int                `json:"score"`
+	Reasons []string           `json:"reasons"`
+}
+
+// freeEmailDomains are shared by unrelated people, so they say nothing about a company.
+var freeEmailDomains = map[string]bool{
+	"gmail.com": true, "googlemail.com": true, "yahoo.com": true, "yahoo.fr": true, "hotmail.com": true,
+	"hotmail.fr": true, "outlook.com": true, "outlook.fr": true, "live.com": true, "live.fr": true,
+	"msn.com": true, "icloud.com": true, "me.com": true, "orange.fr": true, "wanadoo.fr": true,
+	"free.fr": true, "sfr.fr": true, "laposte.net": true, "gmx.com": true, "gmx.fr": true, "proton.me": true,
+	"protonmail.com": true, "aol.com": true,
+}
+
+// legalSuffixes are dropped from company names before comparing them.
+var legalSuffixes = map[string]bool{
+	"sa": true, "sas": true, "sasu": true, "sarl": true, "eurl": true, "sci": true, "snc": true,
+	"inc": true, "ltd": true, "llc": true, "gmbh": true, "ag": true, "bv": true, "spa": true, "srl": true,
+	"plc": true, "corp": true, "co": true, "limited": true,
+}
+
+var accentFolding = strings.NewReplacer(
+	"à", "a", "â", "a", "ä", "a", "á", "a", "ã", "a", "å", "a",
+	"ç", "c", "é", "e", "è", "e", "ê", "e", "ë", "e",
+	"î", "i", "ï", "i", "í", "i", "ì", "i", "ñ", "n",
+	"ô", "o", "ö", "o", "ó", "o", "ò", "o", "õ", "o", "ø", "o",
+	"ù", "u", "û", "u", "ü", "u", "ú", "u", "ÿ", "y", "ý", "y",
+	"œ", "oe", "æ", "ae", "ß", "ss", "&", " et ",
+)
+
+// NormalizeName lowercases, folds accents, drops punctuation and legal forms
+// ("Dupont & Fils SARL" → "dupont et fils").
+func NormalizeName(s string) string {
+	s = accentFolding.Replace(strings.ToLower(s))
+	words := strings.FieldsFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	kept := words[:0]
+	for _, w := range words {
+		if !legalSuffixes[w] {
+			kept = append(kept, w)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+// NormalizePhone keeps the last 9 digits, the national number in France and
+// most of Europe, so "+33 6 12 34 56 78" and "06.12.34.56.78" compare equal.
+// Numbers shorter than 8 digits are ignored.
+func NormalizePhone(s string) string {
+	var digits strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	d := digits.String()
+	if len(d) < 8 {
+		return ""
+	}
+	if len(d) > 9 {
+		d = d[len(d)-9:]
+	}
+	return d
+}
+
+// WebsiteDomain returns the host of a website without "www." ("" if invalid).
+func WebsiteDomain(website string) string {
+	website = strings.TrimSpace(strings.ToLower(website))
+	if website == "" {
+		return ""
+	}
+	if !strings.Contains(website, "://") {
+		website = "http://" + website
+	}
+	u, err := url.Parse(website)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(u.Hostname(), "www.")
+}
+
+// BusinessEmailDomain returns the domain of an email unless it is a free mailbox provider.
+func BusinessEmailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	domain := NormalizeEmail(email[at+1:])
+	if freeEmailDomains[domain] {
+		return ""
+	}
+	return domain
+}
+
+// NameSimilarity is the Jaro-Winkler similarity of two normalised names (0–1).
+func NameSimilarity(a, b string) float64 {
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	window := max(len(ra), len(rb))/2 - 1
+	if window < 0 {
+		window = 0
+	}
+	matchedA := make([]bool, len(ra))
+	matchedB := make([]bool, len(rb))
+	matches := 0
+	for i := range ra {
+		for j := max(0, i-window); j < min(len(rb), i+window+1); j++ {
+			if !matchedB[j] && ra[i] == rb[j] {
+				matchedA[i], matchedB[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+	transpositions, j := 0, 0
+	for i := range ra {
+		if !matchedA[i] {
+			continue
+		}
+		for !matchedB[j] {
+			j++
+		}
+		if ra[i] != rb[j] {
+			transpositions++
+		}
+		j++
+	}
+	m := float64(matches)
+	jaro := (m/float64(len(ra)) + m/float64(len(rb)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < min(4, len(ra), len(rb)) && ra[prefix] == rb[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+// CompareDuplicates scores how likely a and b (same kind: "contacts",
+// "companies" or "leads") are the same record, with the matching signals.
+func CompareDuplicates(kind string, a, b DuplicateCandidate) (int, []string) {
+	var scores []int
+	var reasons []string
+	add := func(score int, reason string) {
+		scores = append(scores, score)
+		reasons = append(reasons, reason)
+	}
+	similarity := NameSimilarity(a.Name, b.Name)
+
+	switch kind {
+	case "contacts":
+		if a.Email != "" && a.Email == b.Email {
+			add(100, "email")
+		}
+		if a.Phone != "" && a.Phone == b.Phone {
+			add(80, "phone")
+		}
+		if similarity >= 0.92 {
+			score := int(similarity * 70)
+			if a.Company != "" && a.Company == b.Company {
+				score += 20
+			}
+			add(score, "name")
+		}
+	case "companies":
+		if a.Name != "" && a.Name == b.Name {
+			add(95, "name")
+		} else if similarity >= 0.88 {
+			add(int(similarity*80), "name")
+		}
+		if a.Domain != "" && a.Domain == b.Domain {
+			add(90, "domain")
+		}
+		if a.Phone != "" && a.Phone == b.Phone {
+			add(70, "phone")
+		}
+	case "leads":
+		sameParty := (a.Company != "" && a.Company == b.Company) || (a.Contact != "" && a.Contact == b.Contact)
+		if sameParty && similarity >= 0.85 {
+			add(int(similarity*90), "title")
+			if a.Contact != "" && a.Contact == b.Contact {
+				add(0, "contact")
+			}
+			if a.Company != "" && a.Company == b.Company {
+				add(0, "company")
+			}
+		}
+	}
+
+	// Strongest signal, plus 5 per corroborating one
+	best := 0
+	for _, s := range scores {
+		best = max(best, s)
+	}
+	if best == 0 {
+		return 0, nil
+	}
+	return min(100, best+5*(len(scores)-1)), reasons
+}
+
+// blockingKeys are the keys two records must share to be compared.
+func blockingKeys(c DuplicateCandidate) []string {
+	keys := make([]string, 0, 6)
+	for prefix, v := range map[string]string{"e:": c.Email, "p:": c.Phone, "d:": c.Domain, "co:": c.Company, "ct:": c.Contact} {
+		if v != "" {
+			keys = append(keys, prefix+v)
+		}
+	}
+	// Name tokens catch typos in the rest of the name
+	for _, w := range strings.Fields(c.Name) {
+		if len([]rune(w)) >= 3 {
+			keys = append(keys, "n:"+string([]rune(w)[:3]))
+		}
+	}
+	return keys
+}
+
+// FindDuplicates returns the pairs of candidates scoring at least minScore,
+// best first.
+func FindDuplicates(kind string, candidates []DuplicateCandidate, minScore int) []DuplicateMatch {
+	blocks := map[string][]int{}
+	for i, c := range candidates {
+		for _, key := range blockingKeys(c) {
+			blocks[key] = append(blocks[key], i)
+		}
+	}
+
+	seen := map[[2]int]bool{}
+	matches := make([]DuplicateMatch, 0)
+	for _, block := range blocks {
+		for x := 0; x < len(block); x++ {
+			for y := x + 1; y < len(block); y++ {
+				pair := [2]int{block[x], block[y]}
+				if seen[pair] {
+					continue
+				}
+				seen[pair] = true
+				a, b := candidates[pair[0]], candidates[pair[1]]
+				if score, reasons := CompareDuplicates(kind, a, b); score >= minScore {
+					matches = append(matches, DuplicateMatch{A: a, B: b, Score: score, Reasons: reasons})
+				}
+			}
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].A.Label < matches[j].A.Label
+	})
+	return matches
+}
+
+// MatchDuplicates compares one (unsaved) candidate against existing records.
+func MatchDuplicates(kind string, c DuplicateCandidate, existing []DuplicateCandidate, minScore int) []DuplicateMatch {
+	matches := make([]DuplicateMatch, 0)
+	for _, other := range existing {
+		if other.ID == c.ID && c.ID != "" {
+			continue
+		}
+		if score, reasons := CompareDuplicates(kind, c, other); score >= minScore {
+			matches = append(matches, DuplicateMatch{A: c, B: other, Score: score, Reasons: reasons})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	return matches
+}
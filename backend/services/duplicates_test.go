@@ -0,0 +1,186 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestNameSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string // rounded to 4 decimals
+	}{
+		// Reference Jaro-Winkler values
+		{"martha", "marhta", "0.9611"},
+		{"dwayne", "duane", "0.8400"},
+		{"dixon", "dicksonx", "0.8133"},
+		{"jean dupont", "jean dupond", "0.9636"},
+		{"acme", "acme corp", "0.8889"},
+		{"dupont et fils", "dupont et fils", "1.0000"},
+		{"abc", "xyz", "0.0000"},
+		{"a", "b", "0.0000"},
+		{"", "acme", "0.0000"},
+		{"", "", "0.0000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			if got := fmt.Sprintf("%.4f", NameSimilarity(tt.a, tt.b)); got != tt.want {
+				t.Errorf("NameSimilarity(%q, %q) = %s, want %s", tt.a, tt.b, got, tt.want)
+			}
+			if got := fmt.Sprintf("%.4f", NameSimilarity(tt.b, tt.a)); got != tt.want {
+				t.Errorf("NameSimilarity(%q, %q) = %s, want %s (symmetry)", tt.b, tt.a, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizers(t *testing.T) {
+	tests := []struct {
+		fn    func(string) string
+		name  string
+		input string
+		want  string
+	}{
+		{NormalizeName, "NormalizeName", "Dupont & Fils SARL", "dupont et fils"},
+		{NormalizeName, "NormalizeName", "  Société Générale S.A. ", "societe generale s a"},
+		{NormalizeName, "NormalizeName", "ACME, Inc.", "acme"},
+		{NormalizePhone, "NormalizePhone", "+33 6 12 34 56 78", "612345678"},
+		{NormalizePhone, "NormalizePhone", "06.12.34.56.78", "612345678"},
+		{NormalizePhone, "NormalizePhone", "112", ""},
+		{WebsiteDomain, "WebsiteDomain", "https://www.Acme.fr/contact", "acme.fr"},
+		{WebsiteDomain, "WebsiteDomain", "acme.fr", "acme.fr"},
+		{WebsiteDomain, "WebsiteDomain", "", ""},
+		{BusinessEmailDomain, "BusinessEmailDomain", "Jean@Acme.FR", "acme.fr"},
+		{BusinessEmailDomain, "BusinessEmailDomain", "jean.dupont@gmail.com", ""},
+		{BusinessEmailDomain, "BusinessEmailDomain", "not an email", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+"/"+tt.input, func(t *testing.T) {
+			if got := tt.fn(tt.input); got != tt.want {
+				t.Errorf("%s(%q) = %q, want %q", tt.name, tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchDuplicates(t *testing.T) {
+	contacts := []DuplicateCandidate{
+		{ID: "c1", Name: "jean dupont", Email: "jean@acme.fr", Phone: "612345678", Company: "acme"},
+		{ID: "c2", Name: "jean dupond", Phone: "612345678", Company: "acme"},
+		{ID: "c3", Name: "jean dupond"},
+		{ID: "c4", Name: "marie curie", Email: "marie@lab.fr"},
+	}
+	companies := []DuplicateCandidate{
+		{ID: "o1", Name: "acme", Domain: "acme.fr"},
+		{ID: "o2", Name: "acme corp"},
+		{ID: "o3", Name: "globex", Domain: "acme.fr"},
+		{ID: "o4", Name: "initech", Phone: "140000000"},
+	}
+	leads := []DuplicateCandidate{
+		{ID: "l1", Name: "refonte du site web", Company: "acme"},
+		{ID: "l2", Name: "refonte du site web", Company: "globex"},
+		{ID: "l3", Name: "audit securite", Company: "acme"},
+	}
+
+	type match struct {
+		ID      string
+		Score   int
+		Reasons []string
+	}
+	tests := []struct {
+		name     string
+		kind     string
+		c        DuplicateCandidate
+		existing []DuplicateCandidate
+		minScore int
+		want     []match
+	}{
+		{
+			name:     "same email",
+			kind:     "contacts",
+			c:        DuplicateCandidate{Name: "j dupont", Email: "jean@acme.fr"},
+			existing: contacts,
+			minScore: DuplicateMinScore,
+			want:     []match{{"c1", 100, []string{"email"}}},
+		},
+		{
+			// best signal + 5 for the other one: name 90 (70 + 20 same company)
+			// and phone 80 for c1, phone 80 and name 87 (67 + 20) for c2
+			name:     "phone and similar name, best first",
+			kind:     "contacts",
+			c:        DuplicateCandidate{Name: "jean dupont", Phone: "612345678", Company: "acme"},
+			existing: contacts,
+			minScore: DuplicateMinScore,
+			want: []match{
+				{"c1", 95, []string{"phone", "name"}},
+				{"c2", 92, []string{"phone", "name"}},
+				{"c3", 67, []string{"name"}},
+			},
+		},
+		{
+			name:     "strict score of web forms",
+			kind:     "contacts",
+			c:        DuplicateCandidate{Name: "jean dupont"},
+			existing: contacts,
+			minScore: 90,
+			want:     []match{},
+		},
+		{
+			name:     "the record itself is skipped",
+			kind:     "contacts",
+			c:        contacts[3],
+			existing: contacts,
+			minScore: DuplicateMinScore,
+			want:     []match{},
+		},
+		{
+			name:     "company name and domain",
+			kind:     "companies",
+			c:        DuplicateCandidate{Name: "acme", Domain: "acme.fr"},
+			existing: companies,
+			minScore: DuplicateMinScore,
+			want: []match{
+				{"o1", 100, []string{"name", "domain"}},
+				{"o3", 90, []string{"domain"}},
+				{"o2", 71, []string{"name"}},
+			},
+		},
+		{
+			name:     "company phone",
+			kind:     "companies",
+			c:        DuplicateCandidate{Name: "bureau paris", Phone: "140000000"},
+			existing: companies,
+			minScore: DuplicateMinScore,
+			want:     []match{{"o4", 70, []string{"phone"}}},
+		},
+		{
+			// 84 for the title + 5 for the shared company
+			name:     "lead title of the same company",
+			kind:     "leads",
+			c:        DuplicateCandidate{Name: "refonte site web", Company: "acme"},
+			existing: leads,
+			minScore: DuplicateMinScore,
+			want:     []match{{"l1", 89, []string{"title", "company"}}},
+		},
+		{
+			name:     "no candidates",
+			kind:     "leads",
+			c:        DuplicateCandidate{Name: "refonte site web", Company: "acme"},
+			existing: nil,
+			minScore: DuplicateMinScore,
+			want:     []match{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]match, 0)
+			for _, m := range MatchDuplicates(tt.kind, tt.c, tt.existing, tt.minScore) {
+				got = append(got, match{m.B.ID, m.Score, m.Reasons})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MatchDuplicates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
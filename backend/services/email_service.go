@@ -1,7 +1,9 @@
 package services
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"log"
 	"net/mail"
 	neturl "net/url"
@@ -24,21 +26,36 @@ type EmailSendParams struct {
 	RecipientEmail     string
 	RecipientName      string
 	RecipientContactID string            // optional — links email_log to a contact
+	LeadID             string            // optional — links email_log to a lead
 	SentByID           string            // user who triggered the send
 	Variables          map[string]string // {{key}} → value substitution
 	CampaignID         string            // optional — groups bulk sends together
 	RunID              string            // optional — links email_log to a specific campaign_run
 	BaseURL            string            // app base URL used to inject tracking pixel
+	Locale             string            // optional — forces the template variant (else resolved from the contact)
+	Subject            string            // used with Body when no TemplateID is given
+	Body               string            // HTML, {{key}} variables are substituted too
+	Attachments        map[string][]byte // optional — file name → content
 }
 
 // SendTemplatedEmail renders a template with variable substitution, creates an
 // email_log record, injects a tracking pixel, sends via PocketBase mailer, and
 // updates the log status (envoye / echoue).
+//
+// The template variant is chosen by locale: params.Locale, else the contact's
+// locale, else its company's country, else the template's base locale.
+// Without a TemplateID, params.Subject and params.Body are sent instead.
 func SendTemplatedEmail(app core.App, params EmailSendParams) error {
 	// 1. Load email template
-	template, err := app.FindRecordById("email_templates", params.TemplateID)
-	if err != nil {
-		return fmt.Errorf("template %q not found: %w", params.TemplateID, err)
+	var template *core.Record
+	if params.TemplateID != "" {
+		var err error
+		template, err = app.FindRecordById("email_templates", params.TemplateID)
+		if err != nil {
+			return fmt.Errorf("template %q not found: %w", params.TemplateID, err)
+		}
+	} else if params.Subject == "" || params.Body == "" {
+		return fmt.Errorf("a template or a subject and body are required")
 	}
 
 	// 2. Inject date variables (cannot be overridden by caller)
@@ -58,9 +75,15 @@ func SendTemplatedEmail(app core.App, params EmailSendParams) error {
 		}
 	}
 
-	// 3. Render subject and body
-	subject := renderVars(template.GetString("subject"), params.Variables)
-	body := renderVars(template.GetString("body"), params.Variables)
+	// 3. Pick the localized variant and render subject and body
+	rawSubject, rawBody, locale := params.Subject, params.Body, DefaultLocale
+	if template != nil {
+		rawSubject, rawBody, locale = pickTemplateVariant(template, resolveParamsLocale(app, params))
+	} else if l := NormalizeLocale(resolveParamsLocale(app, params)); l != "" {
+		locale = l
+	}
+	subject := renderVars(rawSubject, params.Variables)
+	body := renderVars(rawBody, params.Variables)
 
 	// 4. Create email_log with status "en_attente"
 	logCol, err := app.FindCollectionByNameOrId("email_logs")
@@ -68,12 +91,18 @@ func SendTemplatedEmail(app core.App, params EmailSendParams) error {
 		return fmt.Errorf("email_logs collection not found: %w", err)
 	}
 	logRec := core.NewRecord(logCol)
-	logRec.Set("template", params.TemplateID)
+	if params.TemplateID != "" {
+		logRec.Set("template", params.TemplateID)
+	}
 	logRec.Set("recipient_email", params.RecipientEmail)
 	if params.RecipientContactID != "" {
 		logRec.Set("recipient_contact", params.RecipientContactID)
 	}
+	if params.LeadID != "" {
+		logRec.Set("lead", params.LeadID)
+	}
 	logRec.Set("subject", subject)
+	logRec.Set("locale", locale)
 	logRec.Set("status", "en_attente")
 	logRec.Set("sent_by", params.SentByID)
 	if params.CampaignID != "" {
@@ -120,6 +149,12 @@ func SendTemplatedEmail(app core.App, params EmailSendParams) error {
 		Subject: subject,
 		HTML:    body,
 	}
+	if len(params.Attachments) > 0 {
+		msg.Attachments = map[string]io.Reader{}
+		for name, data := range params.Attachments {
+			msg.Attachments[name] = bytes.NewReader(data)
+		}
+	}
 
 	sendErr := app.NewMailClient().Send(msg)
 
@@ -142,6 +177,19 @@ func SendTemplatedEmail(app core.App, params EmailSendParams) error {
 	return nil
 }
 
+// resolveParamsLocale returns the locale requested by the caller, or the
+// recipient contact's preferred locale when none was forced.
+func resolveParamsLocale(app core.App, params EmailSendParams) string {
+	if params.Locale != "" || params.RecipientContactID == "" {
+		return params.Locale
+	}
+	contact, err := app.FindRecordById("contacts", params.RecipientContactID)
+	if err != nil {
+		return ""
+	}
+	return ResolveContactLocale(app, contact)
+}
+
 // rewriteLinksForTracking replaces all http/https href values in the HTML body
 // with tracking redirect URLs so that link clicks can be recorded.
 // Non-http links (mailto:, tel:, #anchor) and already-wrapped URLs are skipped.
@@ -168,6 +216,11 @@ func rewriteLinksForTracking(htmlBody, baseURL, logID string) string {
 	)
 }
 
+// NormalizeEmail lowercases and trims an address so that duplicates compare equal.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
 // renderVars replaces {{key}} placeholders in tmpl with values from vars.
 func renderVars(tmpl string, vars map[string]string) string {
 	result := tmpl
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	// engagementHalfLifeDays is the age at which an email counts for half as much.
+	engagementHalfLifeDays = 30.0
+	// engagementPriorWeight is the number of "virtual" emails at 50% engagement
+	// blended into every score, so contacts with little history stay near neutral.
+	engagementPriorWeight = 1.0
+
+	defaultFrequencyCap     = 3
+	defaultFrequencyCapDays = 7
+)
+
+// SkipReason values stored in email_logs.skip_reason for recipients not mailed.
+const (
+	SkipReasonFrequency  = "frequence"
+	SkipReasonEngagement = "engagement"
+	SkipReasonDuplicate  = "doublon"
+)
+
+type engagementLogRow struct {
+	SentAt     string `db:"sent_at"`
+	OpenCount  int    `db:"open_count"`
+	ClickCount int    `db:"click_count"`
+}
+
+// ComputeEngagementScore returns a 0–100 engagement score for a contact from
+// its email_logs: each delivered email weighs 0.5^(age/30 days), an open earns
+// one point and a click two, out of three possible per email. A neutral prior
+// keeps contacts without history at 50.
+func ComputeEngagementScore(app core.App, contactID string, now time.Time) (float64, error) {
+	var rows []engagementLogRow
+	err := app.DB().NewQuery(`
+		SELECT COALESCE(sent_at, '') AS sent_at, open_count, click_count
+		FROM email_logs
+		WHERE recipient_contact = {:contact}
+		  AND status IN ('envoye','ouvert','clique')
+	`).Bind(dbx.Params{"contact": contactID}).All(&rows)
+	if err != nil {
+		return 0, err
+	}
+	return engagementScore(rows, now), nil
+}
+
+// engagementScore computes the score of ComputeEngagementScore from the
+// contact's delivered emails.
+func engagementScore(rows []engagementLogRow, now time.Time) float64 {
+	var weightedSent, weightedPoints float64
+	for _, r := range rows {
+		sentAt, err := parseDate(r.SentAt)
+		if err != nil {
+			continue
+		}
+		ageDays := now.Sub(sentAt).Hours() / 24
+		if ageDays < 0 {
+			ageDays = 0
+		}
+		w := math.Pow(0.5, ageDays/engagementHalfLifeDays)
+		weightedSent += w
+		if r.OpenCount > 0 || r.ClickCount > 0 {
+			weightedPoints += w
+		}
+		if r.ClickCount > 0 {
+			weightedPoints += 2 * w
+		}
+	}
+
+	score := 100 * (weightedPoints + 1.5*engagementPriorWeight) / (3 * (weightedSent + engagementPriorWeight))
+	return math.Round(score*10) / 10
+}
+
+// RefreshContactEngagement recomputes and stores the engagement score of a
+// contact. The score is written with a raw UPDATE: nothing reacts to it, and
+// the daily pass over every contact must not run the contact save hooks.
+func RefreshContactEngagement(app core.App, contactID string) error {
+	if contactID == "" {
+		return nil
+	}
+	now := time.Now().UTC()
+	score, err := ComputeEngagementScore(app, contactID, now)
+	if err != nil {
+		return err
+	}
+	res, err := app.DB().NewQuery(`
+		UPDATE contacts SET engagement_score = {:score}, engagement_updated = {:now} WHERE id = {:id}
+	`).Bind(dbx.Params{"score": score, "now": now.Format("2006-01-02 15:04:05.000Z"), "id": contactID}).Execute()
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("contact %s not found", contactID)
+	}
+	return nil
+}
+
+// FrequencyCap returns the maximum number of marketing emails a contact may
+// receive per rolling window, read from MARKETING_EMAIL_CAP and
+// MARKETING_EMAIL_CAP_DAYS. A max of 0 disables capping.
+func FrequencyCap() (max int, days int) {
+	max, days = defaultFrequencyCap, defaultFrequencyCapDays
+	if v, err := strconv.Atoi(os.Getenv("MARKETING_EMAIL_CAP")); err == nil && v >= 0 {
+		max = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("MARKETING_EMAIL_CAP_DAYS")); err == nil && v > 0 {
+		days = v
+	}
+	return max, days
+}
+
+// IsFrequencyCapped reports whether the recipient already received the maximum
+// number of marketing emails within the rolling window.
+func IsFrequencyCapped(app core.App, contactID, email string, now time.Time) (bool, error) {
+	max, days := FrequencyCap()
+	if max == 0 {
+		return false, nil
+	}
+	since := now.AddDate(0, 0, -days).Format("2006-01-02 15:04:05.000Z")
+
+	var count int
+	err := app.DB().NewQuery(`
+		SELECT COUNT(*)
+		FROM email_logs el
+		JOIN email_templates t ON t.id = el.template
+		WHERE t.type = 'marketing'
+		  AND el.status IN ('envoye','ouvert','clique')
+		  AND el.sent_at >= {:since}
+		  AND (el.recipient_contact = {:contact} OR LOWER(el.recipient_email) = {:email})
+	`).Bind(dbx.Params{
+		"since":   since,
+		"contact": contactID,
+		"email":   NormalizeEmail(email),
+	}).Row(&count)
+	if err != nil {
+		return false, err
+	}
+	return count >= max, nil
+}
+
+// LogSkippedEmail records a recipient that was deliberately not mailed as an
+// email_log with status "ignore" and the given skip reason, so it shows up in
+// the send history without counting as sent or failed.
+func LogSkippedEmail(app core.App, params EmailSendParams, reason string) error {
+	template, err := app.FindRecordById("email_templates", params.TemplateID)
+	if err != nil {
+		return fmt.Errorf("template %q not found: %w", params.TemplateID, err)
+	}
+	logCol, err := app.FindCollectionByNameOrId("email_logs")
+	if err != nil {
+		return fmt.Errorf("email_logs collection not found: %w", err)
+	}
+
+	subject, _, locale := pickTemplateVariant(template, resolveParamsLocale(app, params))
+
+	logRec := core.NewRecord(logCol)
+	logRec.Set("template", params.TemplateID)
+	logRec.Set("recipient_email", params.RecipientEmail)
+	if params.RecipientContactID != "" {
+		logRec.Set("recipient_contact", params.RecipientContactID)
+	}
+	logRec.Set("subject", renderVars(subject, params.Variables))
+	logRec.Set("locale", locale)
+	logRec.Set("status", "ignore")
+	logRec.Set("skip_reason", reason)
+	logRec.Set("sent_by", params.SentByID)
+	if params.CampaignID != "" {
+		logRec.Set("campaign_id", params.CampaignID)
+	}
+	if params.RunID != "" {
+		logRec.Set("run_id", params.RunID)
+	}
+	logRec.Set("open_count", 0)
+	logRec.Set("click_count", 0)
+	return app.Save(logRec)
+}
+
+// parseDate parses the PocketBase datetime format, falling back to a plain date.
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02 15:04:05.000Z", value); err == nil {
+		return t, nil
+	}
+	if len(value) < 10 {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	return time.Parse("2006-01-02", value[:10])
+}
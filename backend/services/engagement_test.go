@@ -0,0 +1,46 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEngagementScore(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(daysAgo int) string {
+		return now.AddDate(0, 0, -daysAgo).Format("2006-01-02 15:04:05.000Z")
+	}
+
+	tests := []struct {
+		name string
+		rows []engagementLogRow
+		want float64
+	}{
+		{"no history stays neutral", nil, 50},
+		{"one fresh ignored email", []engagementLogRow{{SentAt: at(0)}}, 25},
+		{"one fresh open", []engagementLogRow{{SentAt: at(0), OpenCount: 1}}, 41.7},
+		{"one fresh click", []engagementLogRow{{SentAt: at(0), ClickCount: 1}}, 75},
+		{"click without open counts both", []engagementLogRow{{SentAt: at(0), OpenCount: 3, ClickCount: 2}}, 75},
+		// a 30-day-old email weighs half: (0 + 1.5) / (3 * 1.5)
+		{"half-life decay", []engagementLogRow{{SentAt: at(30)}}, 33.3},
+		{"future dates count as fresh", []engagementLogRow{{SentAt: at(-5)}}, 25},
+		{"unparsable dates are skipped", []engagementLogRow{{SentAt: ""}, {SentAt: "n/a"}}, 50},
+		// midnight of the same day: 12 hours of decay
+		{"date-only values are accepted", []engagementLogRow{{SentAt: now.Format("2006-01-02"), ClickCount: 1}}, 74.9},
+		{
+			"many clicks approach 100",
+			[]engagementLogRow{
+				{SentAt: at(1), ClickCount: 1}, {SentAt: at(2), ClickCount: 1}, {SentAt: at(3), ClickCount: 1},
+				{SentAt: at(4), ClickCount: 1}, {SentAt: at(5), ClickCount: 1}, {SentAt: at(6), ClickCount: 1},
+			},
+			92.4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := engagementScore(tt.rows, now); got != tt.want {
+				t.Errorf("engagementScore() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
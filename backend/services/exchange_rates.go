@@ -0,0 +1,231 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// FxBaseCurrency is the currency exchange_rates are quoted against: a rate is
+// the number of units of the currency for 1 EUR, as published by the ECB.
+const FxBaseCurrency = "EUR"
+
+var currencyCodeRe = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ExchangeRate is one parsed rate, ready to be stored in exchange_rates.
+type ExchangeRate struct {
+	Currency string
+	Date     string // YYYY-MM-DD
+	Rate     float64
+}
+
+// IsCurrencyCode reports whether code looks like an ISO 4217 code (e.g. "CHF").
+func IsCurrencyCode(code string) bool {
+	return currencyCodeRe.MatchString(code)
+}
+
+// ReportingCurrency returns the currency stats are converted into
+// (REPORTING_CURRENCY, default EUR). It is also the default currency of new
+// leads, invoices and expenses.
+func ReportingCurrency() string {
+	code := strings.ToUpper(strings.TrimSpace(os.Getenv("REPORTING_CURRENCY")))
+	if !IsCurrencyCode(code) {
+		return FxBaseCurrency
+	}
+	return code
+}
+
+// HasExchangeRate reports whether amounts in code can be converted, i.e. it is
+// the base currency or exchange_rates holds at least one rate for it.
+func HasExchangeRate(app core.App, code string) bool {
+	if code == FxBaseCurrency {
+		return true
+	}
+	var count int
+	app.DB().NewQuery(`SELECT COUNT(*) FROM exchange_rates WHERE currency = {:currency}`).
+		Bind(dbx.Params{"currency": code}).Row(&count) //nolint:errcheck
+	return count > 0
+}
+
+// ExchangeRateAt returns the units of code for 1 EUR at date: the latest rate
+// on or before date, else the earliest known rate (same rule as the stats).
+func ExchangeRateAt(app core.App, code string, date time.Time) (float64, error) {
+	if code == FxBaseCurrency {
+		return 1, nil
+	}
+	var rate float64
+	err := app.DB().NewQuery(`
+		SELECT COALESCE(
+			(SELECT rate FROM exchange_rates WHERE currency = {:currency} AND date < {:before} ORDER BY date DESC LIMIT 1),
+			(SELECT rate FROM exchange_rates WHERE currency = {:currency} ORDER BY date ASC LIMIT 1)
+		)
+	`).Bind(dbx.Params{"currency": code, "before": date.AddDate(0, 0, 1).Format("2006-01-02")}).Row(&rate)
+	if err != nil || rate <= 0 {
+		return 0, fmt.Errorf("no exchange rate for %s", code)
+	}
+	return rate, nil
+}
+
+// ConvertAmount converts amount from one currency to another at date.
+func ConvertAmount(app core.App, amount float64, from, to string, date time.Time) (float64, error) {
+	if from == to || amount == 0 {
+		return amount, nil
+	}
+	fromRate, err := ExchangeRateAt(app, from, date)
+	if err != nil {
+		return 0, err
+	}
+	toRate, err := ExchangeRateAt(app, to, date)
+	if err != nil {
+		return 0, err
+	}
+	return amount * toRate / fromRate, nil
+}
+
+// ─── Import parsers ──────────────────────────────────────────────────────────
+
+// ParseExchangeRates reads an ECB XML feed (eurofxref-daily.xml, -hist.xml)
+// or a CSV file. Two CSV layouts are accepted:
+//
+//	date,currency,rate         one rate per line
+//	Date,USD,JPY,CHF,...       ECB layout (eurofxref.csv, eurofxref-hist.csv)
+//
+// Rates of the base currency itself and empty/"N/A" cells are skipped. The
+// detected format ("ecb" or "csv") is returned with the rates.
+func ParseExchangeRates(data []byte) ([]ExchangeRate, string, error) {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	if len(trimmed) == 0 {
+		return nil, "", fmt.Errorf("empty file")
+	}
+	if trimmed[0] == '<' {
+		rates, err := parseECBXML(trimmed)
+		return rates, "ecb", err
+	}
+	rates, err := parseRatesCSV(trimmed)
+	return rates, "csv", err
+}
+
+type ecbEnvelope struct {
+	Cube struct {
+		Days []struct {
+			Time  string `xml:"time,attr"`
+			Rates []struct {
+				Currency string `xml:"currency,attr"`
+				Rate     string `xml:"rate,attr"`
+			} `xml:"Cube"`
+		} `xml:"Cube"`
+	} `xml:"Cube"`
+}
+
+func parseECBXML(data []byte) ([]ExchangeRate, error) {
+	var env ecbEnvelope
+	if err := xml.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("invalid ECB XML: %w", err)
+	}
+	rates := make([]ExchangeRate, 0)
+	for _, day := range env.Cube.Days {
+		date, err := parseRateDate(day.Time)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range day.Rates {
+			if rate, ok := newExchangeRate(r.Currency, date, r.Rate); ok {
+				rates = append(rates, rate)
+			}
+		}
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("no rates found in ECB XML")
+	}
+	return rates, nil
+}
+
+func parseRatesCSV(data []byte) ([]ExchangeRate, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if firstLine, _, _ := bytes.Cut(data, []byte("\n")); bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		reader.Comma = ';'
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+	if len(header) == 0 || header[0] != "date" {
+		return nil, fmt.Errorf("the first CSV column must be \"date\"")
+	}
+	long := len(header) >= 3 && header[1] == "currency" && header[2] == "rate"
+
+	rates := make([]ExchangeRate, 0)
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV line %d: %w", line, err)
+		}
+		if len(row) == 0 || strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+		date, err := parseRateDate(row[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if long {
+			if len(row) < 3 {
+				return nil, fmt.Errorf("line %d: expected date,currency,rate", line)
+			}
+			rate, ok := newExchangeRate(row[1], date, row[2])
+			if !ok {
+				return nil, fmt.Errorf("line %d: invalid currency or rate", line)
+			}
+			rates = append(rates, rate)
+			continue
+		}
+		for i := 1; i < len(row) && i < len(header); i++ {
+			if rate, ok := newExchangeRate(header[i], date, row[i]); ok {
+				rates = append(rates, rate)
+			}
+		}
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("no rates found in CSV")
+	}
+	return rates, nil
+}
+
+// newExchangeRate validates one (currency, rate) pair.
+func newExchangeRate(currency, date, value string) (ExchangeRate, bool) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if !IsCurrencyCode(currency) || currency == FxBaseCurrency || err != nil || rate <= 0 {
+		return ExchangeRate{}, false
+	}
+	return ExchangeRate{Currency: currency, Date: date, Rate: rate}, true
+}
+
+// parseRateDate accepts ISO dates and the "02 January 2006" form of eurofxref.csv.
+func parseRateDate(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{"2006-01-02", "02 January 2006", "2 January 2006", "02/01/2006"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format("2006-01-02"), nil
+		}
+	}
+	return "", fmt.Errorf("invalid date %q", value)
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseExchangeRates(t *testing.T) {
+	const ecbDaily = `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<Cube>
+		<Cube time="2026-03-02">
+			<Cube currency="USD" rate="1.0812"/>
+			<Cube currency="GBP" rate="0.8561"/>
+		</Cube>
+		<Cube time="2026-02-27">
+			<Cube currency="USD" rate="1.0790"/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`
+
+	tests := []struct {
+		name       string
+		data       string
+		want       []ExchangeRate
+		wantFormat string
+		wantErr    bool
+	}{
+		{
+			name: "ECB XML",
+			data: ecbDaily,
+			want: []ExchangeRate{
+				{Currency: "USD", Date: "2026-03-02", Rate: 1.0812},
+				{Currency: "GBP", Date: "2026-03-02", Rate: 0.8561},
+				{Currency: "USD", Date: "2026-02-27", Rate: 1.079},
+			},
+			wantFormat: "ecb",
+		},
+		{
+			name: "one rate per line",
+			data: "date,currency,rate\n2026-03-02,usd,1.0812\n2026-03-02, CHF ,0.9420\n",
+			want: []ExchangeRate{
+				{Currency: "USD", Date: "2026-03-02", Rate: 1.0812},
+				{Currency: "CHF", Date: "2026-03-02", Rate: 0.942},
+			},
+			wantFormat: "csv",
+		},
+		{
+			name: "ECB CSV layout skips N/A, empty and EUR cells",
+			data: "\xef\xbb\xbfDate, USD, JPY, EUR, CYP,\n02 March 2026, 1.0812, 162.31, 1, N/A,\n27 February 2026, 1.0790, , 1, N/A,\n",
+			want: []ExchangeRate{
+				{Currency: "USD", Date: "2026-03-02", Rate: 1.0812},
+				{Currency: "JPY", Date: "2026-03-02", Rate: 162.31},
+				{Currency: "USD", Date: "2026-02-27", Rate: 1.079},
+			},
+			wantFormat: "csv",
+		},
+		{
+			name: "semicolons and French dates",
+			data: "date;currency;rate\n02/03/2026;USD;1.0812\n",
+			want: []ExchangeRate{
+				{Currency: "USD", Date: "2026-03-02", Rate: 1.0812},
+			},
+			wantFormat: "csv",
+		},
+		{
+			name:       "blank lines are skipped",
+			data:       "date,currency,rate\n\n2026-03-02,USD,1.0812\n\n",
+			want:       []ExchangeRate{{Currency: "USD", Date: "2026-03-02", Rate: 1.0812}},
+			wantFormat: "csv",
+		},
+		{name: "empty file", data: "  \n", wantErr: true},
+		{name: "missing date column", data: "currency,rate\nUSD,1.08\n", wantFormat: "csv", wantErr: true},
+		{name: "invalid date", data: "date,currency,rate\n2026-13-45,USD,1.08\n", wantFormat: "csv", wantErr: true},
+		{name: "invalid rate in long layout", data: "date,currency,rate\n2026-03-02,USD,-1\n", wantFormat: "csv", wantErr: true},
+		{name: "invalid currency in long layout", data: "date,currency,rate\n2026-03-02,DOLLAR,1.08\n", wantFormat: "csv", wantErr: true},
+		{name: "header only", data: "date,currency,rate\n", wantFormat: "csv", wantErr: true},
+		{name: "malformed XML", data: "<Envelope><Cube>", wantFormat: "ecb", wantErr: true},
+		{name: "XML without rates", data: "<Envelope><Cube></Cube></Envelope>", wantFormat: "ecb", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, format, err := ParseExchangeRates([]byte(tt.data))
+			if format != tt.wantFormat {
+				t.Errorf("format = %q, want %q", format, tt.wantFormat)
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseExchangeRates() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseExchangeRates() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseExchangeRates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	// scoringEmailWindowDays is how far back email opens/clicks count.
+	scoringEmailWindowDays = 90
+	// scoringActivityWindowDays is how far back lead activities count.
+	scoringActivityWindowDays = 30
+)
+
+// MatchedScoringRule is a lead_scoring_rules record that contributed to a score.
+type MatchedScoringRule struct {
+	Rule   string  `json:"rule"`
+	Name   string  `json:"name"`
+	Points float64 `json:"points"`
+}
+
+// LeadScoreChange describes a score update applied by ApplyLeadScore.
+type LeadScoreChange struct {
+	Previous float64
+	Score    float64
+	Matched  []MatchedScoringRule
+}
+
+// leadScoringFacts gathers the values scoring rules are evaluated against.
+func leadScoringFacts(app core.App, lead *core.Record, now time.Time) (map[string]string, map[string]float64) {
+	text := map[string]string{"source": lead.GetString("source")}
+	numbers := map[string]float64{"deal_value": lead.GetFloat("value")}
+
+	if companyID := lead.GetString("company"); companyID != "" {
+		if company, err := app.FindRecordById("companies", companyID); err == nil {
+			text["company_size"] = company.GetString("size")
+			text["company_industry"] = company.GetString("industry")
+		}
+	}
+
+	var opens, clicks int
+	if contactID := lead.GetString("contact"); contactID != "" {
+		if contact, err := app.FindRecordById("contacts", contactID); err == nil {
+			text["contact_title"] = contact.GetString("position")
+		}
+		since := now.AddDate(0, 0, -scoringEmailWindowDays).Format("2006-01-02 15:04:05.000Z")
+		app.DB().NewQuery(`
+			SELECT COALESCE(SUM(CASE WHEN open_count  > 0 THEN 1 ELSE 0 END), 0),
+			       COALESCE(SUM(CASE WHEN click_count > 0 THEN 1 ELSE 0 END), 0)
+			FROM email_logs
+			WHERE recipient_contact = {:contact} AND sent_at >= {:since}
+		`).Bind(dbx.Params{"contact": contactID, "since": since}).Row(&opens, &clicks) //nolint:errcheck
+	}
+	numbers["email_opens"] = float64(opens)
+	numbers["email_clicks"] = float64(clicks)
+
+	var activities int
+	if lead.Id != "" {
+		since := now.AddDate(0, 0, -scoringActivityWindowDays).Format("2006-01-02 15:04:05.000Z")
+		app.DB().NewQuery(`
+			SELECT COUNT(*) FROM activities
+			WHERE lead = {:lead} AND type IN ('email', 'appel', 'note') AND created >= {:since}
+		`).Bind(dbx.Params{"lead": lead.Id, "since": since}).Row(&activities) //nolint:errcheck
+	}
+	numbers["recent_activities"] = float64(activities)
+
+	return text, numbers
+}
+
+// scoringRuleMatches evaluates one rule against the lead facts.
+func scoringRuleMatches(rule *core.Record, text map[string]string, numbers map[string]float64) bool {
+	criterion := rule.GetString("criterion")
+	expected := strings.TrimSpace(rule.GetString("value"))
+
+	if n, ok := numbers[criterion]; ok {
+		threshold, err := strconv.ParseFloat(expected, 64)
+		if err != nil {
+			return false
+		}
+		switch rule.GetString("operator") {
+		case "gte":
+			return n >= threshold
+		case "lte":
+			return n <= threshold
+		case "equals":
+			return n == threshold
+		}
+		return false
+	}
+
+	actual := strings.ToLower(strings.TrimSpace(text[criterion]))
+	if actual == "" {
+		return false
+	}
+	switch rule.GetString("operator") {
+	case "equals":
+		return actual == strings.ToLower(expected)
+	case "contains":
+		return strings.Contains(actual, strings.ToLower(expected))
+	}
+	return false
+}
+
+// ComputeLeadScore sums the points of every active lead_scoring_rules record
+// the lead matches, clamped to 0–100.
+func ComputeLeadScore(app core.App, lead *core.Record, now time.Time) (float64, []MatchedScoringRule, error) {
+	rules, err := app.FindRecordsByFilter("lead_scoring_rules", "active = true", "created", 0, 0)
+	if err != nil {
+		return 0, nil, err
+	}
+	text, numbers := leadScoringFacts(app, lead, now)
+
+	var total float64
+	matched := make([]MatchedScoringRule, 0)
+	for _, rule := range rules {
+		if !scoringRuleMatches(rule, text, numbers) {
+			continue
+		}
+		points := rule.GetFloat("points")
+		total += points
+		matched = append(matched, MatchedScoringRule{Rule: rule.Id, Name: rule.GetString("name"), Points: points})
+	}
+	return math.Max(0, math.Min(100, math.Round(total))), matched, nil
+}
+
+// ApplyLeadScore recomputes the score on the (unsaved) lead record, compared
+// with its persisted value. It returns nil when the score did not change.
+func ApplyLeadScore(app core.App, lead *core.Record) (*LeadScoreChange, error) {
+	now := time.Now().UTC()
+	score, matched, err := ComputeLeadScore(app, lead, now)
+	if err != nil {
+		return nil, err
+	}
+	// score is system-managed: values sent by API clients are overwritten
+	original := lead.Original()
+	previous := original.GetFloat("score")
+	lead.Set("score", score)
+	if score == previous && original.GetString("score_updated") != "" {
+		lead.Set("score_updated", original.GetString("score_updated"))
+		return nil, nil
+	}
+	lead.Set("score_updated", now.Format("2006-01-02 15:04:05.000Z"))
+	return &LeadScoreChange{Previous: previous, Score: score, Matched: matched}, nil
+}
+
+// LogLeadScoreChange appends an entry to lead_score_history.
+func LogLeadScoreChange(app core.App, leadID string, change *LeadScoreChange) error {
+	col, err := app.FindCollectionByNameOrId("lead_score_history")
+	if err != nil {
+		return err
+	}
+	rec := core.NewRecord(col)
+	rec.Set("lead", leadID)
+	rec.Set("score", change.Score)
+	rec.Set("previous_score", change.Previous)
+	rec.Set("matched_rules", change.Matched)
+	return app.Save(rec)
+}
+
+// RefreshLeadScore recomputes a lead's score and saves the lead when it
+// changed; the leads update hook then records the history entry.
+func RefreshLeadScore(app core.App, leadID string) error {
+	lead, err := app.FindRecordById("leads", leadID)
+	if err != nil {
+		return err
+	}
+	score, _, err := ComputeLeadScore(app, lead, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if score == lead.GetFloat("score") && lead.GetString("score_updated") != "" {
+		return nil
+	}
+	return app.Save(lead)
+}
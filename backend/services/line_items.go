@@ -0,0 +1,136 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// LineItem is one entry of a quote's (or invoice's) items JSON.
+type LineItem struct {
+	Product     string  `json:"product,omitempty"` // optional products id
+	Description string  `json:"description"`
+	Qty         float64 `json:"qty"`
+	UnitPrice   float64 `json:"unit_price"`
+	Discount    float64 `json:"discount,omitempty"` // percentage off the line
+	TaxRate     float64 `json:"tax_rate"`
+	Total       float64 `json:"total"` // excl. tax, after discount
+}
+
+// TaxLine is the tax due for one rate.
+type TaxLine struct {
+	Rate   float64 `json:"rate"`
+	Base   float64 `json:"base"`
+	Amount float64 `json:"amount"`
+}
+
+// LineTotals are the document totals computed from its lines.
+type LineTotals struct {
+	Subtotal float64   `json:"subtotal"`
+	Taxes    []TaxLine `json:"taxes"`
+	TaxTotal float64   `json:"tax_total"`
+	Total    float64   `json:"total"`
+}
+
+// ValidateLineItems checks the lines a user entered; the message names the
+// first invalid line (1-based).
+func ValidateLineItems(items []LineItem) error {
+	for i, it := range items {
+		switch {
+		case strings.TrimSpace(it.Description) == "":
+			return fmt.Errorf("ligne %d : la description est requise", i+1)
+		case it.Qty <= 0:
+			return fmt.Errorf("ligne %d : la quantité doit être supérieure à 0", i+1)
+		case it.UnitPrice < 0:
+			return fmt.Errorf("ligne %d : le prix unitaire ne peut pas être négatif", i+1)
+		case it.Discount < 0 || it.Discount > 100:
+			return fmt.Errorf("ligne %d : la remise doit être comprise entre 0 et 100 %%", i+1)
+		case it.TaxRate < 0 || it.TaxRate > 100:
+			return fmt.Errorf("ligne %d : le taux de TVA doit être compris entre 0 et 100 %%", i+1)
+		}
+	}
+	return nil
+}
+
+// ComputeLineTotals fills each line's total and returns the document totals.
+// Amounts are computed in integer cents: each line is rounded once, tax is
+// computed per rate on the summed bases, so totals always add up.
+func ComputeLineTotals(items []LineItem) LineTotals {
+	var subtotal int64
+	baseByRate := map[float64]int64{}
+	for i := range items {
+		cents := toCents(items[i].Qty * items[i].UnitPrice * (1 - items[i].Discount/100))
+		items[i].Total = fromCents(cents)
+		subtotal += cents
+		baseByRate[items[i].TaxRate] += cents
+	}
+
+	rates := make([]float64, 0, len(baseByRate))
+	for rate := range baseByRate {
+		rates = append(rates, rate)
+	}
+	sort.Float64s(rates)
+
+	totals := LineTotals{Subtotal: fromCents(subtotal), Taxes: make([]TaxLine, 0, len(rates))}
+	var taxTotal int64
+	for _, rate := range rates {
+		base := baseByRate[rate]
+		tax := int64(math.Round(float64(base) * rate / 100))
+		taxTotal += tax
+		totals.Taxes = append(totals.Taxes, TaxLine{Rate: rate, Base: fromCents(base), Amount: fromCents(tax)})
+	}
+	totals.TaxTotal = fromCents(taxTotal)
+	totals.Total = fromCents(subtotal + taxTotal)
+	return totals
+}
+
+func toCents(v float64) int64 {
+	// Round half away from zero after removing float noise (e.g. 1.005 → 100.49999…)
+	return int64(math.Round(math.Round(v*1e6) / 1e4))
+}
+
+func fromCents(c int64) float64 {
+	return float64(c) / 100
+}
+
+// FormatMoney formats an amount for documents: "1 234,50 €" in French,
+// "€1,234.50" in English; other currencies use their ISO code.
+func FormatMoney(amount float64, currency, locale string) string {
+	cents := toCents(math.Abs(amount))
+	units := fmt.Sprintf("%d", cents/100)
+	sep, dec := "\u00a0", ","
+	if locale == "en" {
+		sep, dec = ",", "."
+	}
+	var grouped strings.Builder
+	for i, c := range units {
+		if i > 0 && (len(units)-i)%3 == 0 {
+			grouped.WriteString(sep)
+		}
+		grouped.WriteRune(c)
+	}
+	number := fmt.Sprintf("%s%s%02d", grouped.String(), dec, cents%100)
+	if amount < 0 {
+		number = "-" + number
+	}
+
+	symbol := map[string]string{"EUR": "€", "GBP": "£", "USD": "$"}[currency]
+	switch {
+	case symbol == "":
+		return number + "\u00a0" + currency
+	case locale == "en":
+		return symbol + number
+	default:
+		return number + "\u00a0" + symbol
+	}
+}
+
+// FormatNumber formats a quantity or rate without trailing zeros ("1,5", "20").
+func FormatNumber(v float64, locale string) string {
+	s := strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.2f", v), "0"), ".")
+	if locale != "en" {
+		s = strings.Replace(s, ".", ",", 1)
+	}
+	return s
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestComputeLineTotals(t *testing.T) {
+	tests := []struct {
+		name       string
+		items      []LineItem
+		wantTotals LineTotals
+		wantLines  []float64
+	}{
+		{
+			name:       "no lines",
+			items:      nil,
+			wantTotals: LineTotals{Taxes: []TaxLine{}},
+			wantLines:  []float64{},
+		},
+		{
+			name:  "single line",
+			items: []LineItem{{Qty: 2, UnitPrice: 150, TaxRate: 20}},
+			wantTotals: LineTotals{
+				Subtotal: 300, TaxTotal: 60, Total: 360,
+				Taxes: []TaxLine{{Rate: 20, Base: 300, Amount: 60}},
+			},
+			wantLines: []float64{300},
+		},
+		{
+			name:  "discount",
+			items: []LineItem{{Qty: 3, UnitPrice: 99.99, Discount: 15, TaxRate: 20}},
+			wantTotals: LineTotals{
+				Subtotal: 254.97, TaxTotal: 50.99, Total: 305.96,
+				Taxes: []TaxLine{{Rate: 20, Base: 254.97, Amount: 50.99}},
+			},
+			wantLines: []float64{254.97},
+		},
+		{
+			name: "rates grouped and sorted",
+			items: []LineItem{
+				{Qty: 1, UnitPrice: 100, TaxRate: 20},
+				{Qty: 1, UnitPrice: 50, TaxRate: 5.5},
+				{Qty: 2, UnitPrice: 25, TaxRate: 20},
+				{Qty: 1, UnitPrice: 10, TaxRate: 0},
+			},
+			wantTotals: LineTotals{
+				Subtotal: 210, TaxTotal: 32.75, Total: 242.75,
+				Taxes: []TaxLine{
+					{Rate: 0, Base: 10, Amount: 0},
+					{Rate: 5.5, Base: 50, Amount: 2.75},
+					{Rate: 20, Base: 150, Amount: 30},
+				},
+			},
+			wantLines: []float64{100, 50, 50, 10},
+		},
+		{
+			// 1.005 is 1.00499… in binary: rounded as the half cent it was meant to be
+			name:  "float noise",
+			items: []LineItem{{Qty: 1, UnitPrice: 1.005, TaxRate: 20}},
+			wantTotals: LineTotals{
+				Subtotal: 1.01, TaxTotal: 0.2, Total: 1.21,
+				Taxes: []TaxLine{{Rate: 20, Base: 1.01, Amount: 0.2}},
+			},
+			wantLines: []float64{1.01},
+		},
+		{
+			// per-line tax would give 3 × 0.07 = 0.21; on the summed base it is 0.20
+			name: "tax on the summed base",
+			items: []LineItem{
+				{Qty: 1, UnitPrice: 0.33, TaxRate: 20},
+				{Qty: 1, UnitPrice: 0.33, TaxRate: 20},
+				{Qty: 1, UnitPrice: 0.33, TaxRate: 20},
+			},
+			wantTotals: LineTotals{
+				Subtotal: 0.99, TaxTotal: 0.2, Total: 1.19,
+				Taxes: []TaxLine{{Rate: 20, Base: 0.99, Amount: 0.2}},
+			},
+			wantLines: []float64{0.33, 0.33, 0.33},
+		},
+		{
+			name:  "full discount",
+			items: []LineItem{{Qty: 4, UnitPrice: 80, Discount: 100, TaxRate: 20}},
+			wantTotals: LineTotals{
+				Taxes: []TaxLine{{Rate: 20, Base: 0, Amount: 0}},
+			},
+			wantLines: []float64{0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ComputeLineTotals(tt.items)
+			if !reflect.DeepEqual(got, tt.wantTotals) {
+				t.Errorf("ComputeLineTotals() = %+v, want %+v", got, tt.wantTotals)
+			}
+			lines := make([]float64, 0, len(tt.items))
+			for _, it := range tt.items {
+				lines = append(lines, it.Total)
+			}
+			if !reflect.DeepEqual(lines, tt.wantLines) {
+				t.Errorf("line totals = %v, want %v", lines, tt.wantLines)
+			}
+		})
+	}
+}
+
+func TestValidateLineItems(t *testing.T) {
+	valid := LineItem{Description: "Licence", Qty: 1, UnitPrice: 100, TaxRate: 20}
+	with := func(change func(*LineItem)) LineItem {
+		it := valid
+		change(&it)
+		return it
+	}
+
+	tests := []struct {
+		name    string
+		items   []LineItem
+		wantErr string
+	}{
+		{"no lines", nil, ""},
+		{"valid lines", []LineItem{valid, with(func(it *LineItem) { it.Discount, it.TaxRate = 100, 0 })}, ""},
+		{"free line", []LineItem{with(func(it *LineItem) { it.UnitPrice = 0 })}, ""},
+		{"blank description", []LineItem{with(func(it *LineItem) { it.Description = "  " })}, "ligne 1 : la description est requise"},
+		{"zero quantity", []LineItem{valid, with(func(it *LineItem) { it.Qty = 0 })}, "ligne 2 : la quantité"},
+		{"negative price", []LineItem{with(func(it *LineItem) { it.UnitPrice = -1 })}, "ligne 1 : le prix unitaire"},
+		{"discount above 100", []LineItem{with(func(it *LineItem) { it.Discount = 120 })}, "ligne 1 : la remise"},
+		{"negative discount", []LineItem{with(func(it *LineItem) { it.Discount = -5 })}, "ligne 1 : la remise"},
+		{"tax rate above 100", []LineItem{with(func(it *LineItem) { it.TaxRate = 200 })}, "ligne 1 : le taux de TVA"},
+		{"first invalid line is named", []LineItem{valid, valid, with(func(it *LineItem) { it.Qty = -1 }), with(func(it *LineItem) { it.Description = "" })}, "ligne 3 :"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateLineItems(tt.items)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("ValidateLineItems() = %v, want nil", err)
+			case tt.wantErr != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.wantErr)):
+				t.Errorf("ValidateLineItems() = %v, want %q…", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// DefaultLocale is used when neither the recipient nor the template specify a language.
+const DefaultLocale = "fr"
+
+// SupportedLocales lists the languages templates can be translated into.
+var SupportedLocales = []string{"fr", "en"}
+
+// countryLocales maps normalized company country values (names in FR/EN and
+// ISO codes) to the language we write to. Countries not listed fall through
+// to the template's base locale.
+var countryLocales = map[string]string{
+	"france": "fr", "fr": "fr",
+	"belgique": "fr", "belgium": "fr", "be": "fr",
+	"suisse": "fr", "switzerland": "fr", "ch": "fr",
+	"luxembourg": "fr", "lu": "fr",
+	"monaco": "fr", "mc": "fr",
+	"royaume-uni": "en", "royaume uni": "en", "united kingdom": "en", "uk": "en", "gb": "en", "angleterre": "en", "england": "en",
+	"irlande": "en", "ireland": "en", "ie": "en",
+	"etats-unis": "en", "états-unis": "en", "united states": "en", "usa": "en", "us": "en",
+	"canada": "en", "ca": "en",
+	"australie": "en", "australia": "en", "au": "en",
+}
+
+// TemplateTranslation is one localized variant stored in email_templates.translations.
+type TemplateTranslation struct {
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// NormalizeLocale reduces a locale tag ("en-GB", "EN_us", " fr ") to a supported
+// base language, or returns "" if it is not supported.
+func NormalizeLocale(locale string) string {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	if i := strings.IndexAny(locale, "-_"); i > 0 {
+		locale = locale[:i]
+	}
+	for _, l := range SupportedLocales {
+		if l == locale {
+			return l
+		}
+	}
+	return ""
+}
+
+// LocaleForCountry returns the language associated with a company country, or "".
+func LocaleForCountry(country string) string {
+	return countryLocales[strings.ToLower(strings.TrimSpace(country))]
+}
+
+// ResolveContactLocale returns the preferred language of a contact:
+// its own locale field first, then the country of its company. Returns ""
+// when nothing is known so the caller can apply its own fallback.
+func ResolveContactLocale(app core.App, contact *core.Record) string {
+	if l := NormalizeLocale(contact.GetString("locale")); l != "" {
+		return l
+	}
+	companyID := contact.GetString("company")
+	if companyID == "" {
+		return ""
+	}
+	company, err := app.FindRecordById("companies", companyID)
+	if err != nil {
+		return ""
+	}
+	return LocaleForCountry(company.GetString("country"))
+}
+
+// ResolveUserLocale returns the preferred language of a CRM user, defaulting to DefaultLocale.
+func ResolveUserLocale(user *core.Record) string {
+	if l := NormalizeLocale(user.GetString("locale")); l != "" {
+		return l
+	}
+	return DefaultLocale
+}
+
+// pickTemplateVariant selects the subject/body to send for the wanted locale.
+//
+// Fallback chain:
+//  1. translations[wanted] when both subject and body are filled
+//  2. the base subject/body, written in the template's own locale
+//
+// It returns the locale of the variant actually chosen.
+func pickTemplateVariant(template *core.Record, wanted string) (subject, body, locale string) {
+	baseLocale := NormalizeLocale(template.GetString("locale"))
+	if baseLocale == "" {
+		baseLocale = DefaultLocale
+	}
+	subject = template.GetString("subject")
+	body = template.GetString("body")
+
+	wanted = NormalizeLocale(wanted)
+	if wanted == "" || wanted == baseLocale {
+		return subject, body, baseLocale
+	}
+
+	translations := map[string]TemplateTranslation{}
+	if err := template.UnmarshalJSONField("translations", &translations); err != nil {
+		return subject, body, baseLocale
+	}
+	for key, tr := range translations {
+		if NormalizeLocale(key) == wanted && tr.Subject != "" && tr.Body != "" {
+			return tr.Subject, tr.Body, wanted
+		}
+	}
+	return subject, body, baseLocale
+}
+
+// notificationStrings holds the system notification texts per locale.
+var notificationStrings = map[string]map[string]string{
+	"fr": {
+		"lead_assigned.subject": "[CRM] Opportunité assignée : %s",
+		"lead_assigned.body": `
+<p>Bonjour %s,</p>
+<p>L'opportunité <strong>%s</strong> vous a été assignée.</p>
+<p>Connectez-vous à Pocket CRM pour en voir les détails.</p>
+<p>— L'équipe Pocket CRM</p>
+`,
+		"stale_task.title":       "Relancer : %s",
+		"stale_task.description": "<p>Aucune activité depuis %d jours à l'étape « %s ».</p>",
+		"stale_digest.subject":   "[CRM] %d opportunité(s) sans activité",
+		"stale_digest.body": `
+<p>Bonjour %s,</p>
+<p>Ces opportunités n'ont plus bougé depuis trop longtemps ; une tâche de relance a été créée pour chacune :</p>
+<ul>%s</ul>
+<p>— L'équipe Pocket CRM</p>
+`,
+		"stale_digest.item": "<li><strong>%s</strong> — %s, %d jours sans activité</li>",
+
+		"quote_pdf.title":           "DEVIS",
+		"quote_pdf.number":          "N° %s — version %d",
+		"quote_pdf.date":            "Date : %s",
+		"quote_pdf.valid_until":     "Valable jusqu'au : %s",
+		"quote_pdf.client":          "Client",
+		"quote_pdf.col_description": "Description",
+		"quote_pdf.col_qty":         "Qté",
+		"quote_pdf.col_unit_price":  "Prix unit. HT",
+		"quote_pdf.col_discount":    "Remise",
+		"quote_pdf.col_tax":         "TVA",
+		"quote_pdf.col_total":       "Total HT",
+		"quote_pdf.subtotal":        "Sous-total HT",
+		"quote_pdf.tax":             "TVA %s %%",
+		"quote_pdf.total":           "Total TTC",
+		"quote_pdf.accept":          "Pour accepter ce devis en ligne :",
+
+		"quote_email.subject": "Devis %s — %s",
+		"quote_email.body": `
+<p>Bonjour %s,</p>
+<p>Veuillez trouver ci-joint notre devis <strong>%s</strong> d'un montant de <strong>%s</strong>, valable jusqu'au %s.</p>
+<p>Vous pouvez le consulter et l'accepter en ligne : <a href="%s">voir le devis</a>.</p>
+<p>Nous restons à votre disposition pour toute question.</p>
+`,
+
+		"quote_page.title":      "Devis %s",
+		"quote_page.summary":    "Montant total : <strong>%s</strong> — valable jusqu'au %s.",
+		"quote_page.download":   "Télécharger le devis (PDF)",
+		"quote_page.name_label": "Votre nom",
+		"quote_page.accept":     "Accepter le devis",
+		"quote_page.refuse":     "Refuser",
+		"quote_page.accepted":   "Le devis %s a été accepté le %s. Merci pour votre confiance !",
+		"quote_page.refused":    "Le devis %s a été refusé. Merci pour votre retour.",
+		"quote_page.expired":    "Ce devis n'est plus valable. Contactez-nous pour en obtenir une nouvelle version.",
+		"quote_page.superseded": "Ce devis a été remplacé par une version plus récente.",
+		"quote_page.invalid":    "Ce lien n'est pas valide.",
+
+		"web_form.thanks_title": "Merci !",
+		"web_form.thanks":       "Votre demande a bien été envoyée. Nous revenons vers vous très vite.",
+
+		"subscription_task.title":              "Renouvellement : %s",
+		"subscription_task.description_auto":   "<p>L'abonnement « %s » est reconduit automatiquement le %s. Vérifiez la satisfaction du client et les opportunités d'évolution.</p>",
+		"subscription_task.description_manual": "<p>L'abonnement « %s » arrive à échéance le %s sans reconduction automatique : contactez le client pour le renouveler.</p>",
+	},
+	"en": {
+		"lead_assigned.subject": "[CRM] Opportunity assigned: %s",
+		"lead_assigned.body": `
+<p>Hello %s,</p>
+<p>The opportunity <strong>%s</strong> has been assigned to you.</p>
+<p>Log in to Pocket CRM to see the details.</p>
+<p>— The Pocket CRM team</p>
+`,
+		"stale_task.title":       "Follow up: %s",
+		"stale_task.description": "<p>No activity for %d days in stage \"%s\".</p>",
+		"stale_digest.subject":   "[CRM] %d opportunity(ies) without activity",
+		"stale_digest.body": `
+<p>Hello %s,</p>
+<p>These opportunities have not moved for too long; a follow-up task was created for each one:</p>
+<ul>%s</ul>
+<p>— The Pocket CRM team</p>
+`,
+		"stale_digest.item": "<li><strong>%s</strong> — %s, %d days without activity</li>",
+
+		"quote_pdf.title":           "QUOTE",
+		"quote_pdf.number":          "No. %s — version %d",
+		"quote_pdf.date":            "Date: %s",
+		"quote_pdf.valid_until":     "Valid until: %s",
+		"quote_pdf.client":          "Customer",
+		"quote_pdf.col_description": "Description",
+		"quote_pdf.col_qty":         "Qty",
+		"quote_pdf.col_unit_price":  "Unit price",
+		"quote_pdf.col_discount":    "Discount",
+		"quote_pdf.col_tax":         "VAT",
+		"quote_pdf.col_total":       "Net total",
+		"quote_pdf.subtotal":        "Net subtotal",
+		"quote_pdf.tax":             "VAT %s %%",
+		"quote_pdf.total":           "Total incl. VAT",
+		"quote_pdf.accept":          "Accept this quote online:",
+
+		"quote_email.subject": "Quote %s — %s",
+		"quote_email.body": `
+<p>Hello %s,</p>
+<p>Please find attached our quote <strong>%s</strong> for <strong>%s</strong>, valid until %s.</p>
+<p>You can review and accept it online: <a href="%s">view the quote</a>.</p>
+<p>Do not hesitate to contact us with any question.</p>
+`,
+
+		"quote_page.title":      "Quote %s",
+		"quote_page.summary":    "Total amount: <strong>%s</strong> — valid until %s.",
+		"quote_page.download":   "Download the quote (PDF)",
+		"quote_page.name_label": "Your name",
+		"quote_page.accept":     "Accept the quote",
+		"quote_page.refuse":     "Decline",
+		"quote_page.accepted":   "Quote %s was accepted on %s. Thank you for your trust!",
+		"quote_page.refused":    "Quote %s was declined. Thank you for your feedback.",
+		"quote_page.expired":    "This quote is no longer valid. Contact us to get a new version.",
+		"quote_page.superseded": "This quote was replaced by a newer version.",
+		"quote_page.invalid":    "This link is not valid.",
+
+		"web_form.thanks_title": "Thank you!",
+		"web_form.thanks":       "Your request has been sent. We will get back to you shortly.",
+
+		"subscription_task.title":              "Renewal: %s",
+		"subscription_task.description_auto":   "<p>The subscription \"%s\" renews automatically on %s. Check the customer's satisfaction and upsell opportunities.</p>",
+		"subscription_task.description_manual": "<p>The subscription \"%s\" ends on %s and does not renew automatically: contact the customer to renew it.</p>",
+	},
+}
+
+// T returns the notification string for key in locale, falling back to
+// DefaultLocale and finally to the key itself.
+func T(locale, key string) string {
+	if s, ok := notificationStrings[NormalizeLocale(locale)][key]; ok {
+		return s
+	}
+	if s, ok := notificationStrings[DefaultLocale][key]; ok {
+		return s
+	}
+	return key
+}
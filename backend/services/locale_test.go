@@ -0,0 +1,52 @@
+package services
+
+import "testing"
+
+func TestNormalizeLocale(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"fr", "fr"},
+		{"en", "en"},
+		{" fr ", "fr"},
+		{"EN", "en"},
+		{"en-GB", "en"},
+		{"EN_us", "en"},
+		{"fr-BE", "fr"},
+		{"de", ""},
+		{"de-CH", ""},
+		{"", ""},
+		{"-en", ""},
+		{"english", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			if got := NormalizeLocale(tt.locale); got != tt.want {
+				t.Errorf("NormalizeLocale(%q) = %q, want %q", tt.locale, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocaleForCountry(t *testing.T) {
+	tests := []struct {
+		country string
+		want    string
+	}{
+		{"France", "fr"},
+		{" belgique ", "fr"},
+		{"CH", "fr"},
+		{"United Kingdom", "en"},
+		{"États-Unis", "en"},
+		{"Allemagne", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.country, func(t *testing.T) {
+			if got := LocaleForCountry(tt.country); got != tt.want {
+				t.Errorf("LocaleForCountry(%q) = %q, want %q", tt.country, got, tt.want)
+			}
+		})
+	}
+}
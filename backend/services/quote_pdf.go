@@ -0,0 +1,155 @@
+package services
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	htmlBreaks = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|li|h[1-6])>`)
+	htmlTags   = regexp.MustCompile(`<[^>]*>`)
+)
+
+// QuoteDocument is everything printed on a quote.
+type QuoteDocument struct {
+	Locale        string
+	SellerName    string
+	Number        string
+	Version       int
+	IssuedAt      string // DD/MM/YYYY
+	ValidUntil    string // DD/MM/YYYY
+	Title         string
+	ClientCompany string
+	ClientName    string
+	ClientEmail   string
+	Currency      string
+	Items         []LineItem
+	Totals        LineTotals
+	Notes         string // plain text
+	AcceptURL     string
+}
+
+// RenderQuotePDF lays a quote out on A4 pages.
+func RenderQuotePDF(q QuoteDocument) []byte {
+	const (
+		left   = 50.0
+		right  = PDFPageWidth - 50
+		bottom = PDFPageHeight - 70
+	)
+	t := func(key string) string { return T(q.Locale, key) }
+	money := func(v float64) string { return FormatMoney(v, q.Currency, q.Locale) }
+
+	doc := NewPDF()
+
+	// Header
+	doc.Text(left, 70, 16, true, q.SellerName)
+	doc.TextRight(right, 70, 22, true, t("quote_pdf.title"))
+	doc.TextRight(right, 90, 10, false, fmt.Sprintf(t("quote_pdf.number"), q.Number, q.Version))
+	doc.TextRight(right, 104, 10, false, fmt.Sprintf(t("quote_pdf.date"), q.IssuedAt))
+	if q.ValidUntil != "" {
+		doc.TextRight(right, 118, 10, false, fmt.Sprintf(t("quote_pdf.valid_until"), q.ValidUntil))
+	}
+
+	// Customer block
+	y := 150.0
+	doc.Text(left, y, 9, true, strings.ToUpper(t("quote_pdf.client")))
+	for _, line := range []string{q.ClientCompany, q.ClientName, q.ClientEmail} {
+		if line != "" {
+			y += 14
+			doc.Text(left, y, 10, false, line)
+		}
+	}
+	if q.Title != "" {
+		y += 30
+		doc.Text(left, y, 12, true, q.Title)
+	}
+
+	// Items table: description | qty | unit price | discount | VAT | total
+	// (right edges of the number columns)
+	const descWidth, qtyX, priceX, discountX, taxX, totalX = 220.0, 310.0, 380.0, 425.0, 468.0, right - 4
+	header := func(y float64) float64 {
+		doc.FillRect(left, y, right-left, 20, 0.92)
+		doc.Text(left+4, y+14, 9, true, t("quote_pdf.col_description"))
+		doc.TextRight(qtyX, y+14, 9, true, t("quote_pdf.col_qty"))
+		doc.TextRight(priceX, y+14, 9, true, t("quote_pdf.col_unit_price"))
+		doc.TextRight(discountX, y+14, 9, true, t("quote_pdf.col_discount"))
+		doc.TextRight(taxX, y+14, 9, true, t("quote_pdf.col_tax"))
+		doc.TextRight(totalX, y+14, 9, true, t("quote_pdf.col_total"))
+		return y + 20
+	}
+	y = header(y + 25)
+	for _, it := range q.Items {
+		lines := WrapText(it.Description, 9, descWidth, false)
+		height := float64(len(lines))*12 + 8
+		if y+height > bottom {
+			doc.AddPage()
+			y = header(50)
+		}
+		for i, line := range lines {
+			doc.Text(left+4, y+14+float64(i)*12, 9, false, line)
+		}
+		doc.TextRight(qtyX, y+14, 9, false, FormatNumber(it.Qty, q.Locale))
+		doc.TextRight(priceX, y+14, 9, false, money(it.UnitPrice))
+		if it.Discount > 0 {
+			doc.TextRight(discountX, y+14, 9, false, FormatNumber(it.Discount, q.Locale)+" %")
+		}
+		doc.TextRight(taxX, y+14, 9, false, FormatNumber(it.TaxRate, q.Locale)+" %")
+		doc.TextRight(totalX, y+14, 9, false, money(it.Total))
+		y += height
+		doc.Line(left, y, right, y, 0.5, 0.8)
+	}
+
+	// Totals, labels aligned under the unit price column
+	const totalsX = 340.0
+	if y+40+float64(len(q.Totals.Taxes))*16 > bottom {
+		doc.AddPage()
+		y = 50
+	}
+	y += 20
+	doc.Text(totalsX, y, 10, false, t("quote_pdf.subtotal"))
+	doc.TextRight(right-4, y, 10, false, money(q.Totals.Subtotal))
+	for _, tax := range q.Totals.Taxes {
+		y += 16
+		doc.Text(totalsX, y, 10, false, fmt.Sprintf(t("quote_pdf.tax"), FormatNumber(tax.Rate, q.Locale)))
+		doc.TextRight(right-4, y, 10, false, money(tax.Amount))
+	}
+	y += 8
+	doc.Line(totalsX, y, right, y, 1, 0)
+	y += 16
+	doc.Text(totalsX, y, 11, true, t("quote_pdf.total"))
+	doc.TextRight(right-4, y, 11, true, money(q.Totals.Total))
+
+	// Notes and acceptance link
+	y += 40
+	for _, line := range WrapText(q.Notes, 9, right-left, false) {
+		if y > bottom {
+			doc.AddPage()
+			y = 50
+		}
+		doc.Text(left, y, 9, false, line)
+		y += 12
+	}
+	if q.AcceptURL != "" {
+		if y+30 > bottom {
+			doc.AddPage()
+			y = 50
+		}
+		doc.Text(left, y+12, 9, true, t("quote_pdf.accept"))
+		doc.Text(left, y+26, 8, false, q.AcceptURL)
+	}
+
+	return doc.Bytes()
+}
+
+// HTMLToText turns editor HTML (notes, terms) into plain text for documents.
+func HTMLToText(s string) string {
+	s = htmlBreaks.ReplaceAllString(s, "\n")
+	s = html.UnescapeString(htmlTags.ReplaceAllString(s, ""))
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
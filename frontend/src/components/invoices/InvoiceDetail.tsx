@@ -21,7 +21,7 @@ export default function InvoiceDetail({ invoice, onStatusChange, canEdit }: Prop
       {/* Header */}
       <div>
         <p className="text-xs text-surface-400 font-medium uppercase tracking-wider mb-1">{t('invoices.invoice')}</p>
-        <h2 className="text-xl font-bold text-surface-900">{invoice.number}</h2>
+        <h2 className="text-xl font-bold text-surface-900">{invoice.number || t('invoiceStatus.brouillon')}</h2>
         <div className="flex flex-wrap items-center gap-2 mt-1">
           <span className="text-2xl font-bold text-primary-600">{fmtCurrency(invoice.total)}</span>
           <Badge variant={invoice.status as InvoiceStatus}>{t(`invoiceStatus.${invoice.status}`)}</Badge>
@@ -89,7 +89,7 @@ export default function InvoiceDetail({ invoice, onStatusChange, canEdit }: Prop
                     <td className="px-3 py-2 text-surface-900">{item.description || '—'}</td>
                     <td className="px-3 py-2 text-right text-surface-600">{item.qty}</td>
                     <td className="px-3 py-2 text-right text-surface-600">{fmtCurrency(item.unit_price)}</td>
-                    <td className="px-3 py-2 text-right font-medium text-surface-900">{fmtCurrency(item.qty * item.unit_price)}</td>
+                    <td className="px-3 py-2 text-right font-medium text-surface-900">{fmtCurrency(item.total ?? item.qty * item.unit_price)}</td>
                   </tr>
                 ))}
               </tbody>
@@ -98,10 +98,12 @@ export default function InvoiceDetail({ invoice, onStatusChange, canEdit }: Prop
                   <td colSpan={3} className="px-3 py-2 text-right text-xs text-surface-500">{t('invoices.amountHT')}</td>
                   <td className="px-3 py-2 text-right font-medium text-surface-900">{fmtCurrency(invoice.amount)}</td>
                 </tr>
-                <tr>
-                  <td colSpan={3} className="px-3 py-2 text-right text-xs text-surface-500">{t('invoices.taxRate')} ({invoice.tax_rate}%)</td>
-                  <td className="px-3 py-2 text-right font-medium text-surface-900">{fmtCurrency(invoice.amount * invoice.tax_rate / 100)}</td>
-                </tr>
+                {(invoice.taxes ?? []).map((tax) => (
+                  <tr key={tax.rate}>
+                    <td colSpan={3} className="px-3 py-2 text-right text-xs text-surface-500">{t('invoices.taxRate')} ({tax.rate}% / {fmtCurrency(tax.base)})</td>
+                    <td className="px-3 py-2 text-right font-medium text-surface-900">{fmtCurrency(tax.amount)}</td>
+                  </tr>
+                ))}
                 <tr>
                   <td colSpan={3} className="px-3 py-2 text-right text-sm font-bold text-surface-900">{t('invoices.totalTTC')}</td>
                   <td className="px-3 py-2 text-right font-bold text-primary-600">{fmtCurrency(invoice.total)}</td>
@@ -28,7 +28,6 @@ export default function InvoiceForm({ invoice, contacts, companies, leads, loadi
   const { user } = useAuthStore()
 
   const [form, setForm] = useState({
-    number: invoice?.number || '',
     contact: invoice?.contact || '',
     company: invoice?.company || '',
     lead: invoice?.lead || '',
@@ -45,9 +44,12 @@ export default function InvoiceForm({ invoice, contacts, companies, leads, loadi
 
   const [errors, setErrors] = useState<Record<string, string>>({})
 
-  // Computed amounts
-  const amount = items.reduce((sum, it) => sum + it.qty * it.unit_price, 0)
-  const total = amount * (1 + form.tax_rate / 100)
+  // Computed amounts (preview — the server recomputes them from the lines,
+  // a line without tax_rate takes the invoice rate)
+  const lineTotal = (it: InvoiceItem) => it.qty * it.unit_price * (1 - (it.discount ?? 0) / 100)
+  const amount = items.reduce((sum, it) => sum + lineTotal(it), 0)
+  const taxTotal = items.reduce((sum, it) => sum + lineTotal(it) * (it.tax_rate ?? Number(form.tax_rate)) / 100, 0)
+  const total = amount + taxTotal
 
   function set(key: string, value: string | number) {
     setForm((f) => ({ ...f, [key]: value }))
@@ -68,7 +70,6 @@ export default function InvoiceForm({ invoice, contacts, companies, leads, loadi
   function handleSubmit(e: React.FormEvent) {
     e.preventDefault()
     const newErrors: Record<string, string> = {}
-    if (!form.number.trim()) newErrors.number = t('validation.required')
     if (items.length === 0) newErrors.items = t('invoices.noItems', { defaultValue: 'Ajoutez au moins une ligne' })
     if (Object.keys(newErrors).length) { setErrors(newErrors); return }
 
@@ -90,7 +91,7 @@ export default function InvoiceForm({ invoice, contacts, companies, leads, loadi
     <form onSubmit={handleSubmit} className="space-y-5">
       {/* Header fields */}
       <div className="grid grid-cols-1 sm:grid-cols-2 gap-4">
-        <Input label={t('invoices.number')} required value={form.number} onChange={(e) => set('number', e.target.value)} error={errors.number} placeholder="FAC-2026-001" />
+        <Input label={t('invoices.number')} value={invoice?.number || ''} disabled placeholder={t('invoices.numberOnIssue', { defaultValue: "Attribué à l'émission" })} />
         <Select label={t('fields.status')} options={statuses.map((v) => ({ value: v, label: t(`invoiceStatus.${v}`) }))} value={form.status} onChange={(v) => set('status', v)} />
         <Select label={t('entities.contact')} options={contacts} value={form.contact} onChange={(v) => set('contact', v)} placeholder={t('entities.contact')} searchable />
         <Select label={t('fields.company')} options={companies} value={form.company} onChange={(v) => set('company', v)} placeholder={t('fields.company')} searchable />
@@ -164,7 +165,7 @@ export default function InvoiceForm({ invoice, contacts, companies, leads, loadi
           </div>
           <div className="flex gap-8 text-surface-500">
             <span>{t('invoices.taxRate')} ({form.tax_rate}%)</span>
-            <span className="w-32 text-right font-medium text-surface-900">{fmtCurrency(amount * form.tax_rate / 100)}</span>
+            <span className="w-32 text-right font-medium text-surface-900">{fmtCurrency(taxTotal)}</span>
           </div>
           <div className="flex gap-8 border-t border-surface-200 pt-1 mt-1">
             <span className="font-semibold text-surface-900">{t('invoices.totalTTC')}</span>
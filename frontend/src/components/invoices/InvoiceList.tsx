@@ -23,7 +23,12 @@ export default function InvoiceList({ invoices, loading, sortBy, sortDir, onSort
     d ? new Intl.DateTimeFormat(i18n.language, { dateStyle: 'medium' }).format(new Date(d)) : '—'
 
   const columns: TableColumn<Invoice>[] = [
-    { key: 'number', labelKey: 'invoices.number', sortable: true },
+    {
+      key: 'number',
+      labelKey: 'invoices.number',
+      sortable: true,
+      render: (val) => (val as string) || '—',
+    },
     {
       key: 'company',
       labelKey: 'fields.company',
@@ -8,6 +8,9 @@ export interface BaseModel {
   collectionName: string
 }
 
+/** Supported languages for emails and notifications */
+export type Locale = 'fr' | 'en'
+
 /** User roles */
 export type UserRole = 'admin' | 'commercial' | 'standard'
 
@@ -19,6 +22,13 @@ export interface User extends BaseModel {
   role: UserRole
   avatar: string
   phone: string
+  locale?: Locale
+  out_of_office?: boolean
+  out_of_office_until?: string
+  lead_capacity?: number
+  assignment_weight?: number
+  last_assigned_at?: string
+  team?: string
 }
 
 /** Company size categories */
@@ -53,9 +63,12 @@ export interface Contact extends BaseModel {
   owner: string
   notes: string
   tags: ContactTag[]
+  locale?: Locale
+  engagement_score?: number
+  engagement_updated?: string
 }
 
-/** Lead pipeline statuses */
+/** Lead pipeline statuses (stage keys of the default pipeline) */
 export type LeadStatus =
   | 'nouveau'
   | 'contacte'
@@ -65,6 +78,63 @@ export type LeadStatus =
   | 'gagne'
   | 'perdu'
 
+export interface Pipeline extends BaseModel {
+  name: string
+  description: string
+  is_default: boolean
+  active: boolean
+  convert_on_won: boolean
+  create_invoice_on_won: boolean
+  invoice_tax_rate: number
+  onboarding_template: string
+}
+
+export interface TaskTemplateItem {
+  title: string
+  description?: string
+  type: TaskType
+  priority: Priority
+  due_in_days: number
+}
+
+export interface TaskTemplate extends BaseModel {
+  name: string
+  description: string
+  tasks: TaskTemplateItem[]
+}
+
+export interface PipelineStage extends BaseModel {
+  pipeline: string
+  key: string
+  name: string
+  order: number
+  probability: number
+  is_won: boolean
+  is_lost: boolean
+  rotting_days: number
+  allowed_from: string[]
+  required_fields: LeadRequirableField[]
+  allowed_roles: UserRole[]
+}
+
+/** Managed reason for closing a lead (won or lost) */
+export interface DealReason extends BaseModel {
+  name: string
+  type: 'perte' | 'gain'
+  active: boolean
+  order: number
+}
+
+/** Lead fields a stage can require before a lead enters it */
+export type LeadRequirableField =
+  | 'value'
+  | 'contact'
+  | 'company'
+  | 'owner'
+  | 'expected_close'
+  | 'source'
+  | 'notes'
+
 /** Priority levels */
 export type Priority = 'basse' | 'moyenne' | 'haute' | 'urgente'
 
@@ -80,16 +150,35 @@ export type LeadSource =
 export interface Lead extends BaseModel {
   title: string
   value: number
-  status: LeadStatus
+  currency?: CurrencyCode
+  status: LeadStatus | (string & {})
+  pipeline?: string
+  stage?: string
+  loss_reason?: string
+  close_reason?: string
+  competitor?: string
+  score?: number
+  score_updated?: string
+  stale_since?: string
+  converted_at?: string
   priority: Priority
   source: LeadSource
   contact: string
   company: string
   owner: string
   expected_close: string
+  /** Times expected_close was pushed back (server-side) */
+  close_date_slips?: number
   closed_at: string
   notes: string
   campaign_id?: string
+  /** Public form the lead came from */
+  web_form?: string
+  utm_source?: string
+  utm_medium?: string
+  utm_campaign?: string
+  utm_term?: string
+  utm_content?: string
 }
 
 /** Task types */
@@ -119,19 +208,140 @@ export interface InvoiceItem {
   description: string
   qty: number
   unit_price: number
+  /** percentage off the line */
+  discount?: number
+  /** defaults to the invoice tax_rate */
+  tax_rate?: number
+  /** excl. tax, after discount (computed server-side) */
+  total?: number
+  product?: string
+}
+
+/** Tax due for one rate (invoices.taxes) */
+export interface InvoiceTax {
+  rate: number
+  base: number
+  amount: number
+}
+
+export interface Product extends BaseModel {
+  sku: string
+  name: string
+  description: string
+  unit_price: number
+  currency: CurrencyCode
+  tax_rate: number
+  recurring: boolean
+  archived: boolean
+}
+
+/** Lead line item; total = quantity × unit_price × (1 − discount / 100) */
+export interface LeadItem extends BaseModel {
+  lead: string
+  product?: string
+  description: string
+  quantity: number
+  unit_price: number
+  discount: number
+  tax_rate: number
+  recurring: boolean
+  total: number
+  order: number
+}
+
+/** Quote statuses */
+export type QuoteStatus = 'brouillon' | 'envoye' | 'accepte' | 'refuse' | 'expire'
+
+/** Quote line; total (excl. tax) is computed by the server */
+export interface QuoteItem {
+  description: string
+  qty: number
+  unit_price: number
+  discount?: number
+  tax_rate: number
+  total?: number
+  /** products id the line was taken from (subscriptions) */
+  product?: string
+}
+
+export interface Quote extends BaseModel {
+  number: string
+  version: number
+  title: string
+  lead: string
+  contact: string
+  company: string
+  owner: string
+  status: QuoteStatus
+  currency: CurrencyCode
+  items: QuoteItem[]
+  subtotal: number
+  tax_total: number
+  total: number
+  valid_until: string
+  notes: string
+  sent_at: string
+  accepted_at: string
+  refused_at: string
+  signer_name: string
+  signer_ip: string
+  refusal_reason: string
+  invoice?: string
+  previous_version?: string
+}
+
+/** Collections checked for duplicates */
+export type DuplicateCollection = 'contacts' | 'companies' | 'leads'
+
+/** A likely duplicate pair from GET /api/crm/duplicates */
+export interface DuplicatePair {
+  a: { id: string; label: string }
+  b: { id: string; label: string }
+  score: number
+  reasons: Array<'email' | 'phone' | 'name' | 'domain' | 'title' | 'contact' | 'company'>
+}
+
+/** ISO 4217 currency code (EUR, CHF, GBP…) */
+export type CurrencyCode = string
+
+/** Exchange rate: units of `currency` for 1 EUR on `date` */
+export interface ExchangeRate extends BaseModel {
+  currency: CurrencyCode
+  date: string
+  rate: number
+  source: 'manuel' | 'csv' | 'ecb'
 }
 
 /** Invoice statuses */
 export type InvoiceStatus = 'brouillon' | 'emise' | 'payee' | 'en_retard' | 'annulee'
 
+/** Numbering sequence of issued invoices (e.g. FA-2026-00042) */
+export interface InvoiceSequence extends BaseModel {
+  name: string
+  prefix: string
+  reset_yearly: boolean
+  padding: number
+  is_default: boolean
+  /** counter, advanced by hook only */
+  last_number: number
+  last_year: number
+}
+
 export interface Invoice extends BaseModel {
+  /** assigned when the invoice leaves brouillon, empty for drafts */
   number: string
+  sequence?: string
   contact: string
   company: string
   lead: string
   owner: string
+  /** subtotal excl. tax (computed server-side from items) */
   amount: number
+  currency?: CurrencyCode
+  /** default rate of lines without one */
   tax_rate: number
+  tax_total?: number
+  taxes?: InvoiceTax[]
   total: number
   status: InvoiceStatus
   issued_at: string
@@ -139,6 +349,59 @@ export interface Invoice extends BaseModel {
   paid_at: string
   items: InvoiceItem[]
   notes: string
+  /** subscription billed by this invoice and its billing period */
+  subscription?: string
+  period_start?: string
+  period_end?: string
+}
+
+/** Subscription (recurring contract) */
+export type SubscriptionStatus = 'actif' | 'resilie' | 'expire'
+
+export type BillingInterval = 'mensuel' | 'trimestriel' | 'annuel'
+
+export interface Subscription extends BaseModel {
+  name: string
+  company: string
+  contact: string
+  lead: string
+  owner: string
+  status: SubscriptionStatus
+  items: QuoteItem[]
+  billing_interval: BillingInterval
+  /** excl. tax per billing cycle (computed server-side) */
+  amount: number
+  /** monthly recurring revenue (computed server-side) */
+  mrr: number
+  currency?: CurrencyCode
+  start_date: string
+  end_date: string
+  term_months: number
+  renewal_date: string
+  auto_renew: boolean
+  next_billing_date: string
+  renewal_reminded_for: string
+  cancelled_at: string
+  cancel_reason: string
+  notes: string
+}
+
+export type SubscriptionEventType =
+  | 'nouveau'
+  | 'expansion'
+  | 'contraction'
+  | 'resiliation'
+  | 'reactivation'
+
+/** MRR movement of a subscription (hook-only) */
+export interface SubscriptionEvent extends BaseModel {
+  subscription: string
+  type: SubscriptionEventType
+  mrr_before: number
+  mrr_after: number
+  currency?: CurrencyCode
+  occurred_at: string
+  changed_by: string
 }
 
 /** Email template types */
@@ -148,6 +411,12 @@ export type EmailTemplateType =
   | 'relance'
   | 'bienvenue'
 
+/** Localized subject/body of an email template */
+export interface EmailTemplateTranslation {
+  subject: string
+  body: string
+}
+
 export interface EmailTemplate extends BaseModel {
   name: string
   subject: string
@@ -155,10 +424,15 @@ export interface EmailTemplate extends BaseModel {
   type: EmailTemplateType
   active: boolean
   created_by: string
+  locale?: Locale
+  translations?: Partial<Record<Locale, EmailTemplateTranslation>>
 }
 
 /** Email log statuses */
-export type EmailLogStatus = 'envoye' | 'echoue' | 'en_attente' | 'ouvert' | 'clique'
+export type EmailLogStatus = 'envoye' | 'echoue' | 'en_attente' | 'ouvert' | 'clique' | 'ignore'
+
+/** Why a recipient was skipped instead of mailed */
+export type EmailSkipReason = 'frequence' | 'engagement' | 'doublon'
 
 export interface EmailLog extends BaseModel {
   template: string
@@ -175,6 +449,10 @@ export interface EmailLog extends BaseModel {
   sent_by: string
   campaign_id: string
   run_id: string
+  /** Lead the email was sent for */
+  lead?: string
+  locale?: Locale
+  skip_reason?: EmailSkipReason
 }
 
 export interface CampaignRun {
@@ -183,6 +461,9 @@ export interface CampaignRun {
   total: number
   sent: number
   failed: number
+  skipped?: number
+  cancelled?: number
+  status?: CampaignRunStatus
   sent_at: string
 }
 
@@ -190,7 +471,17 @@ export interface CampaignRun {
 export type CampaignType = 'email' | 'ads' | 'social' | 'event' | 'seo' | 'autre'
 
 /** Campaign statuses */
-export type CampaignStatus = 'brouillon' | 'programmee' | 'en_cours' | 'envoye' | 'termine'
+export type CampaignStatus =
+  | 'brouillon'
+  | 'programmee'
+  | 'en_cours'
+  | 'en_pause'
+  | 'envoye'
+  | 'termine'
+  | 'annulee'
+
+/** Campaign run statuses */
+export type CampaignRunStatus = 'en_cours' | 'en_pause' | 'terminee' | 'annulee'
 
 export interface Campaign extends BaseModel {
   name: string
@@ -201,6 +492,9 @@ export interface Campaign extends BaseModel {
   total: number
   sent: number
   failed: number
+  skipped?: number
+  min_engagement?: number
+  ad_hoc?: boolean
   campaign_key: string
   created_by: string
   scheduled_at?: string
@@ -214,17 +508,176 @@ export type ActivityType =
   | 'appel'
   | 'note'
   | 'statut_change'
+  | 'attribution'
+  | 'conversion'
+  | 'devis'
+  | 'fusion'
 
 export interface Activity extends BaseModel {
   type: ActivityType
   description: string
   user: string
   contact: string
+  /** Every contact involved in the lead (main contact first) */
+  contacts?: string[]
   lead: string
   company: string
   metadata: Record<string, unknown>
 }
 
+export interface LeadStageHistory extends BaseModel {
+  lead: string
+  pipeline: string
+  from_stage: string
+  to_stage: string
+  from_status: string
+  to_status: string
+  changed_by: string
+  changed_at: string
+  value: number
+}
+
+/** Buying roles of the contacts involved in a deal */
+export type BuyingRole = 'decideur' | 'influenceur' | 'utilisateur' | 'achats'
+
+/** A contact involved in a lead (one row per lead and contact) */
+export interface LeadContact extends BaseModel {
+  lead: string
+  contact: string
+  role: BuyingRole | ''
+  notes: string
+}
+
+/** A change of a lead's expected_close (days_moved > 0 = slipped) */
+export interface LeadCloseDateHistory extends BaseModel {
+  lead: string
+  old_date: string
+  new_date: string
+  days_moved: number
+  changed_by: string
+  changed_at: string
+  value: number
+}
+
+/** Daily copy of an open lead (written by the snapshot job) */
+export interface PipelineSnapshot extends BaseModel {
+  snapshot_date: string
+  lead: string
+  title: string
+  pipeline: string
+  stage: string
+  stage_name: string
+  stage_order: number
+  probability: number
+  owner: string
+  value: number
+  weighted_value: number
+  currency?: CurrencyCode
+  expected_close: string
+}
+
+/** Public web-to-lead form (POST /api/crm/public/forms/{token}) */
+export interface WebForm extends BaseModel {
+  name: string
+  token: string
+  active: boolean
+  pipeline: string
+  owner: string
+  campaign: string
+  autoresponder: string
+  honeypot_field: string
+  redirect_url: string
+  submissions: number
+  spam_blocked: number
+  last_submission_at: string
+}
+
+/** Lead scoring rule criteria */
+export type ScoringCriterion =
+  | 'source'
+  | 'company_size'
+  | 'company_industry'
+  | 'contact_title'
+  | 'email_opens'
+  | 'email_clicks'
+  | 'recent_activities'
+  | 'deal_value'
+
+export type ScoringOperator = 'equals' | 'contains' | 'gte' | 'lte'
+
+export interface LeadScoringRule extends BaseModel {
+  name: string
+  active: boolean
+  criterion: ScoringCriterion
+  operator: ScoringOperator
+  value: string
+  points: number
+}
+
+export interface LeadScoreHistory extends BaseModel {
+  lead: string
+  score: number
+  previous_score: number
+  matched_rules: { rule: string; name: string; points: number }[]
+}
+
+export type AssignmentCriterion = 'source' | 'region' | 'company_size'
+
+export interface LeadAssignmentRule extends BaseModel {
+  name: string
+  active: boolean
+  order: number
+  criterion: AssignmentCriterion
+  value: string
+  assignees: string[]
+}
+
+export interface Team extends BaseModel {
+  name: string
+  manager?: string
+}
+
+/** Quota periods */
+export type QuotaPeriodType = 'mois' | 'trimestre'
+
+/** Sales quota of a user or a team (exactly one is set) */
+export interface SalesQuota extends BaseModel {
+  user?: string
+  team?: string
+  period_type: QuotaPeriodType
+  period_start: string
+  revenue_target: number
+  deals_target: number
+  currency?: CurrencyCode
+}
+
+export type QuotaStatus = 'atteint' | 'en_avance' | 'en_retard' | 'a_venir'
+
+/** Progress against a quota (GET /api/crm/quotas/attainment) */
+export interface QuotaAttainment {
+  scope: 'user' | 'team'
+  subject_id: string
+  name: string
+  quota_id: string
+  period_type: QuotaPeriodType
+  period_start: string
+  period_end: string
+  revenue_target: number
+  revenue: number
+  attainment_pct: number
+  expected_to_date: number
+  projected_revenue: number
+  projected_pct: number
+  revenue_gap: number
+  deals_target: number
+  deals: number
+  deals_attainment_pct: number
+  projected_deals: number
+  elapsed_pct: number
+  days_left: number
+  status: QuotaStatus
+}
+
 /** Marketing expense categories — aligned with LeadSource */
 export type MarketingExpenseCategory =
   | 'email'
@@ -237,6 +690,7 @@ export type MarketingExpenseCategory =
 export interface MarketingExpense extends BaseModel {
   date: string
   amount: number
+  currency?: CurrencyCode
   category: MarketingExpenseCategory
   description: string
   campaign_id?: string